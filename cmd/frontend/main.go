@@ -21,10 +21,12 @@ import (
 	"github.com/google/safehtml/template"
 	"golang.org/x/pkgsite/cmd/internal/cmdconfig"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/complete"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/dcensus"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/fetch"
 	"golang.org/x/pkgsite/internal/frontend"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/middleware"
@@ -32,21 +34,22 @@ import (
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/proxydatasource"
 	"golang.org/x/pkgsite/internal/queue"
+	"golang.org/x/pkgsite/internal/resilient"
 	"golang.org/x/pkgsite/internal/source"
 )
 
 var (
-	queueName      = config.GetEnv("GO_DISCOVERY_FRONTEND_TASK_QUEUE", "")
 	workers        = flag.Int("workers", 10, "number of concurrent requests to the fetch service, when running locally")
 	_              = flag.String("static", "content/static", "path to folder containing static files served")
 	thirdPartyPath = flag.String("third_party", "third_party", "path to folder containing third-party libraries")
 	devMode        = flag.Bool("dev", false, "enable developer mode (reload templates on each page load, serve non-minified JS/CSS, etc.)")
 	disableCSP     = flag.Bool("nocsp", false, "enable Content Security Policy")
-	proxyURL       = flag.String("proxy_url", "https://proxy.golang.org", "Uses the module proxy referred to by this URL "+
+	proxyURL       = flag.String("proxy_url", "https://proxy.golang.org", "Uses the module proxies referred to by this GOPROXY-style list of URLs "+
 		"for direct proxy mode and frontend fetches")
 	directProxy = flag.Bool("direct_proxy", false, "if set to true, uses the module proxy referred to by this URL "+
 		"as a direct backend, bypassing the database")
 	bypassLicenseCheck = flag.Bool("bypass_license_check", false, "display all information, even for non-redistributable paths")
+	validateConfig     = flag.Bool("validate-config", false, "check the configuration, print it with secrets redacted, and exit")
 )
 
 func main() {
@@ -56,6 +59,13 @@ func main() {
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
+	if *validateConfig {
+		// config.Init has checked the configuration; show what it resolved to.
+		if err := cfg.Dump(os.Stdout); err != nil {
+			log.Fatal(ctx, err)
+		}
+		return
+	}
 	cfg.Dump(os.Stderr)
 	if cfg.UseProfiler {
 		if err := profiler.Start(profiler.Config{}); err != nil {
@@ -69,11 +79,10 @@ func main() {
 		dsg        func(context.Context) internal.DataSource
 		expg       middleware.ExperimentGetter
 		fetchQueue queue.Queue
+		db         *postgres.DB // nil in direct proxy mode
 	)
-	proxyClient, err := proxy.New(*proxyURL)
-	if err != nil {
-		log.Fatal(ctx, err)
-	}
+	proxyClient := cmdconfig.ProxyClient(ctx, cfg, *proxyURL)
+	fetch.SetZipLimits(ctx, cfg.MaxModuleZipSize, cfg.MaxInFlightZipSize)
 	if *bypassLicenseCheck {
 		log.Info(ctx, "BYPASSING LICENSE CHECKING: DISPLAYING NON-REDISTRIBUTABLE INFORMATION")
 	}
@@ -85,7 +94,7 @@ func main() {
 			pds = proxydatasource.New(proxyClient)
 		}
 		dsg = func(context.Context) internal.DataSource { return pds }
-		exps := readLocalExperiments(ctx)
+		exps := readLocalExperiments(ctx, cfg.LocalExperimentsFile)
 		expg = func(context.Context) ([]*internal.Experiment, error) { return exps, nil }
 	} else {
 		// Wrap the postgres driver with OpenCensus instrumentation.
@@ -97,12 +106,12 @@ func main() {
 		if err != nil {
 			log.Fatal(ctx, err)
 		}
-		var db *postgres.DB
 		if *bypassLicenseCheck {
 			db = postgres.NewBypassingLicenseCheck(ddb)
 		} else {
 			db = postgres.New(ddb)
 		}
+		db.SetRetryPolicies(cfg.RetryPolicies)
 		defer db.Close()
 		dsg = func(context.Context) internal.DataSource { return db }
 		expg = db.GetExperiments
@@ -110,7 +119,7 @@ func main() {
 		// The closure passed to queue.New is only used for testing and local
 		// execution, not in production. So it's okay that it doesn't use a
 		// per-request connection.
-		fetchQueue, err = queue.New(ctx, cfg, queueName, *workers, expg,
+		fetchQueue, err = queue.New(ctx, cfg, cfg.FrontendTaskQueue, cfg.FrontendLaneQueues, *workers, expg,
 			func(ctx context.Context, modulePath, version string) (int, error) {
 				return frontend.FetchAndUpdateState(ctx, modulePath, version, proxyClient, sourceClient, db)
 			})
@@ -118,16 +127,15 @@ func main() {
 			log.Fatalf(ctx, "queue.New: %v", err)
 		}
 	}
-	var haClient *redis.Client
-	if cfg.RedisHAHost != "" {
-		haClient = redis.NewClient(&redis.Options{
-			Addr: cfg.RedisHAHost + ":" + cfg.RedisHAPort,
-		})
+	store := cacheStore(ctx, cfg)
+	c := completer(ctx, cfg, db)
+	if c != nil && store != nil {
+		c = complete.NewCachedCompleter(c, store, completionCacheTTL)
 	}
 	server, err := frontend.NewServer(frontend.ServerConfig{
 		DataSourceGetter:     dsg,
 		Queue:                fetchQueue,
-		CompletionClient:     haClient,
+		Completer:            c,
 		TaskIDChangeInterval: config.TaskIDChangeIntervalFrontend,
 		StaticPath:           template.TrustedSourceFromFlag(flag.Lookup("static").Value),
 		ThirdPartyPath:       *thirdPartyPath,
@@ -140,13 +148,7 @@ func main() {
 		log.Fatalf(ctx, "frontend.NewServer: %v", err)
 	}
 	router := dcensus.NewRouter(frontend.TagRoute)
-	var cacheClient *redis.Client
-	if cfg.RedisCacheHost != "" {
-		cacheClient = redis.NewClient(&redis.Options{
-			Addr: cfg.RedisCacheHost + ":" + cfg.RedisCachePort,
-		})
-	}
-	server.Install(router.Handle, cacheClient, cfg.AuthValues)
+	server.Install(router.Handle, store, cfg.AuthValues)
 	views := append(dcensus.ServerViews,
 		postgres.SearchLatencyDistribution,
 		postgres.SearchResponseCount,
@@ -158,7 +160,14 @@ func main() {
 		middleware.CacheErrorCount,
 		middleware.CacheLatency,
 		middleware.QuotaResultCount,
+		queue.EnqueueCount,
+		queue.WaitLatencyDistribution,
+		queue.InFlightCount,
+		proxy.UpstreamLatencyDistribution,
+		proxy.UpstreamResponseCount,
+		proxy.DiskCacheResultCount,
 	)
+	views = append(views, resilient.Views...)
 	if err := dcensus.Init(cfg, views...); err != nil {
 		log.Fatal(ctx, err)
 	}
@@ -198,6 +207,103 @@ func main() {
 	log.Fatal(ctx, http.ListenAndServe(addr, mw(router)))
 }
 
+// localCacheTTL is how long pages are kept in memory by the "memory" and
+// "tiered" caches. The worker invalidates pages only in redis, so this bounds
+// how long a frontend instance serves a page that was invalidated.
+const localCacheTTL = 1 * time.Minute
+
+// cacheStore returns the store for the page cache selected by
+// cfg.CacheBackend, or nil if pages should not be cached.
+func cacheStore(ctx context.Context, cfg *config.Config) middleware.CacheStore {
+	backend := cfg.CacheBackend
+	if backend == "" {
+		backend = "none"
+		if cfg.RedisCacheHost != "" {
+			backend = "redis"
+		}
+	}
+	newRedisStore := func() middleware.CacheStore {
+		if cfg.RedisCacheHost == "" {
+			log.Fatalf(ctx, "cache backend %q requires GO_DISCOVERY_REDIS_HOST", backend)
+		}
+		return middleware.NewRedisStore(redis.NewClient(&redis.Options{
+			Addr: cfg.RedisCacheHost + ":" + cfg.RedisCachePort,
+		}))
+	}
+	log.Infof(ctx, "caching pages with backend %q", backend)
+	switch backend {
+	case "none":
+		return nil
+	case "redis":
+		return newRedisStore()
+	case "memory":
+		return middleware.NewLRUStore(cfg.CacheMemoryMaxBytes, localCacheTTL)
+	case "tiered":
+		return middleware.NewTieredStore(middleware.NewLRUStore(cfg.CacheMemoryMaxBytes, 0), newRedisStore(), localCacheTTL)
+	default:
+		log.Fatalf(ctx, "unknown cache backend %q", backend)
+		return nil
+	}
+}
+
+// completionReloadInterval is how often the in-memory completer reloads
+// package paths from the database.
+const completionReloadInterval = 1 * time.Hour
+
+// completionCacheTTL is how long the results of an autocomplete query are
+// kept in the page cache store. The completion indexes change slowly, so
+// slightly stale results are fine.
+const completionCacheTTL = 10 * time.Minute
+
+// completer returns the completer selected by cfg.CompletionBackend, or nil
+// if package paths should not be autocompleted. db is nil in direct proxy
+// mode.
+func completer(ctx context.Context, cfg *config.Config, db *postgres.DB) complete.Completer {
+	backend := cfg.CompletionBackend
+	if backend == "" {
+		backend = "none"
+		if cfg.RedisHAHost != "" {
+			backend = "redis"
+		}
+	}
+	if (backend == "postgres" || backend == "memory") && db == nil {
+		log.Fatalf(ctx, "completion backend %q requires a database", backend)
+	}
+	log.Infof(ctx, "autocompleting with backend %q", backend)
+	switch backend {
+	case "none":
+		return nil
+	case "redis":
+		if cfg.RedisHAHost == "" {
+			log.Fatalf(ctx, "completion backend %q requires GO_DISCOVERY_REDIS_HA_HOST", backend)
+		}
+		return complete.NewRedisCompleter(redis.NewClient(&redis.Options{
+			Addr: cfg.RedisHAHost + ":" + cfg.RedisHAPort,
+		}))
+	case "postgres":
+		return db
+	case "memory":
+		mc := complete.NewMemoryCompleter()
+		go func() {
+			for {
+				start := time.Now()
+				if err := mc.Load(func(add func(complete.Completion)) error {
+					return db.ForEachPartialCompletion(ctx, add)
+				}); err != nil {
+					log.Errorf(ctx, "loading completions: %v", err)
+				} else {
+					log.Infof(ctx, "loaded completions in %s", time.Since(start))
+				}
+				time.Sleep(completionReloadInterval)
+			}
+		}()
+		return mc
+	default:
+		log.Fatalf(ctx, "unknown completion backend %q", backend)
+		return nil
+	}
+}
+
 // TODO(https://github.com/golang/go/issues/40097): factor out to reduce
 // duplication with cmd/worker/main.go.
 
@@ -227,8 +333,7 @@ func openDB(ctx context.Context, cfg *config.Config, driver string) (_ *database
 // Format of the file: each line is
 //     name,rollout
 // For each experiment.
-func readLocalExperiments(ctx context.Context) []*internal.Experiment {
-	filename := config.GetEnv("GO_DISCOVERY_LOCAL_EXPERIMENTS", "")
+func readLocalExperiments(ctx context.Context, filename string) []*internal.Experiment {
 	if filename == "" {
 		return nil
 	}
@@ -12,24 +12,72 @@ import (
 
 	"cloud.google.com/go/errorreporting"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/checksum"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/config/dynconfig"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/proxy"
 )
 
-// Logger configures a middleware.Logger.
+// Logger configures logging and returns a middleware.Logger for request
+// logs. On GCP, logs are sent to Stackdriver. Elsewhere, they are written
+// as plain text or, if cfg.LogFormat is "json", as lines of JSON.
 func Logger(ctx context.Context, cfg *config.Config, logName string) middleware.Logger {
-	if cfg.OnGCP() {
+	rules, err := log.ParseSamplingRules(cfg.LogSampling)
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	log.SetSampling(rules)
+	switch {
+	case cfg.OnGCP():
 		logger, err := log.UseStackdriver(ctx, cfg, logName)
 		if err != nil {
 			log.Fatal(ctx, err)
 		}
 		return logger
+	case cfg.LogFormat == "json":
+		logger, err := log.UseJSON(os.Stdout)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		return logger
+	case cfg.LogFormat != "" && cfg.LogFormat != "text":
+		log.Fatalf(ctx, "unknown log format %q", cfg.LogFormat)
 	}
 	return middleware.LocalLogger{}
 }
 
+// ProxyClient configures a proxy.Client for the proxies in proxyURL, with the
+// routes, disk cache and checksum database from cfg.
+func ProxyClient(ctx context.Context, cfg *config.Config, proxyURL string) *proxy.Client {
+	routes, err := proxy.ParseRoutes(cfg.ProxyRoutes)
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	client, err := proxy.New(proxyURL, routes...)
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	if cfg.SumDB != "" && cfg.SumDB != "off" {
+		db, err := checksum.New(cfg.SumDB, cfg.NoSumDB, nil)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		log.Infof(ctx, "verifying module zips against checksum database %s", db.Name())
+		client = client.WithChecksumDB(db)
+	}
+	if cfg.ProxyCacheDir == "" {
+		return client
+	}
+	dc, err := proxy.NewDiskCache(cfg.ProxyCacheDir, cfg.ProxyCacheMaxBytes)
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	log.Infof(ctx, "caching proxy responses in %s (%d of %d bytes used)", cfg.ProxyCacheDir, dc.TotalSize(), cfg.ProxyCacheMaxBytes)
+	return client.WithDiskCache(dc)
+}
+
 // ReportingClient configures an Error Reporting client.
 func ReportingClient(ctx context.Context, cfg *config.Config) *errorreporting.Client {
 	if !cfg.OnGCP() {
@@ -49,7 +97,7 @@ func ReportingClient(ctx context.Context, cfg *config.Config) *errorreporting.Cl
 
 // Experimenter configures a middleware.Experimenter.
 func Experimenter(ctx context.Context, cfg *config.Config, getter middleware.ExperimentGetter, reportingClient *errorreporting.Client) *middleware.Experimenter {
-	if os.Getenv("GO_DISCOVERY_EXPERIMENTS_FROM_CONFIG") == "true" {
+	if cfg.ExperimentsFromConfig {
 		// Ignore getter, use dynamic config.
 		if cfg.DynamicConfigLocation == "" {
 			log.Warningf(ctx, "experiments are not configured")
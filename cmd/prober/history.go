@@ -0,0 +1,149 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// maxHistory is the number of probe runs kept for the history page.
+const maxHistory = 50
+
+// history holds the results of recent probe runs.
+var history runHistory
+
+// A probeRun holds the results of running all the probes once.
+type probeRun struct {
+	Start    time.Time
+	Statuses map[string]*ProbeStatus // by probe name
+}
+
+// runHistory is a bounded list of probe runs, oldest first.
+type runHistory struct {
+	mu   sync.Mutex
+	runs []*probeRun
+}
+
+func (h *runHistory) add(start time.Time, statuses []*ProbeStatus) {
+	run := &probeRun{Start: start, Statuses: map[string]*ProbeStatus{}}
+	for _, s := range statuses {
+		run.Statuses[s.Probe.Name] = s
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.runs = append(h.runs, run)
+	if len(h.runs) > maxHistory {
+		h.runs = h.runs[len(h.runs)-maxHistory:]
+	}
+}
+
+// historyRow is the history of one probe, for the history page.
+type historyRow struct {
+	Name     string
+	Passed   int
+	Runs     int
+	Statuses []*ProbeStatus // newest first; nil if the probe did not run
+}
+
+// PassRate returns the percentage of runs of the probe that passed.
+func (r *historyRow) PassRate() string {
+	if r.Runs == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", 100*float64(r.Passed)/float64(r.Runs))
+}
+
+func (h *runHistory) rows() ([]*historyRow, []time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var starts []time.Time
+	for i := len(h.runs) - 1; i >= 0; i-- {
+		starts = append(starts, h.runs[i].Start)
+	}
+	var rows []*historyRow
+	for _, p := range probes {
+		row := &historyRow{Name: p.Name}
+		for i := len(h.runs) - 1; i >= 0; i-- {
+			s := h.runs[i].Statuses[p.Name]
+			row.Statuses = append(row.Statuses, s)
+			if s == nil {
+				continue
+			}
+			row.Runs++
+			if s.Passed {
+				row.Passed++
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, starts
+}
+
+// handleHistory serves a page with the pass/fail history of each probe over
+// the last maxHistory runs.
+func handleHistory(w http.ResponseWriter, r *http.Request) {
+	rows, starts := history.rows()
+	var data = struct {
+		BaseURL string
+		Starts  []time.Time
+		Rows    []*historyRow
+	}{
+		BaseURL: baseURL,
+		Starts:  starts,
+		Rows:    rows,
+	}
+	var buf bytes.Buffer
+	if err := historyTemplate.Execute(&buf, data); err != nil {
+		http.Error(w, fmt.Sprintf("template execution failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	buf.WriteTo(w) // ignore error; nothing we can do about it
+}
+
+var historyTemplate = template.Must(template.New("").Parse(`
+<html>
+  <head>
+    <title>Go Discovery Prober History</title>
+    <style>
+      .pass { color: green; }
+      .fail { color: red; }
+    </style>
+  </head>
+  <body>
+    <h1>Probe history</h1>
+    Base URL: {{.BaseURL}}<br/>
+    {{with .Starts}}
+      Last {{len .}} runs, newest first, from {{(index . 0).Format "2006-1-2 15:04"}}.
+    {{else}}
+      No probes have run yet.
+    {{end}}
+    <table cellspacing="10rem">
+      <tr><th>Name</th><th>Pass rate</th><th>Runs</th></tr>
+      {{range .Rows}}
+        <tr>
+          <td>{{.Name}}</td>
+          <td>{{.PassRate}}</td>
+          <td>
+            {{- range .Statuses -}}
+              {{- if not . -}}
+                <span>-</span>
+              {{- else if .Passed -}}
+                <span class="pass" title="{{.Latency}}ms">&#x2713;</span>
+              {{- else -}}
+                <span class="fail" title="{{.Text}}">&#x2717;</span>
+              {{- end -}}
+            {{- end -}}
+          </td>
+        </tr>
+      {{end}}
+    </table>
+  </body>
+</html>
+`))
@@ -2,7 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// The prober hits the frontend with a fixed set of URLs.
+// The prober hits the frontend with a set of URLs read from a YAML file,
+// and checks the responses.
 // It is designed to be run periodically and to export
 // metrics for altering and performance tracking.
 package main
@@ -15,6 +16,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
 	"text/template"
 	"time"
 
@@ -28,147 +30,18 @@ import (
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/dcensus"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/testing/pagespec"
 )
 
-var credsFile = flag.String("creds", "", "filename for credentials, when running locally")
-
-// A Probe represents a single HTTP GET request.
-type Probe struct {
-	// A short, stable name for the probe.
-	// Since it is used in metrics, it shouldn't be too long and
-	// should stay the same even if actual URL changes.
-	Name string
-
-	// The part of the URL after the host:port.
-	RelativeURL string
+var (
+	credsFile  = flag.String("creds", "", "filename for credentials, when running locally")
+	probesFile = flag.String("probes", config.GetEnv("PROBER_PROBES_FILE", "cmd/prober/probes.yaml"), "YAML file of probe definitions")
+	base       = flag.String("base", "", "base URL of the frontend to probe; defaults to $PROBER_BASE_URL")
+	once       = flag.Bool("once", false, "run the probes once, print the results and exit, with a non-zero status if any failed; for CI")
+)
 
-	// Whether or not to set a header that causes the frontend to skip the redis
-	// cache.
-	BypassCache bool
-}
-
-var probes = []*Probe{
-	{
-		Name:        "home",
-		RelativeURL: "",
-	},
-	{
-		Name:        "search-help",
-		RelativeURL: "search-help",
-	},
-	{
-		Name:        "license-policy",
-		RelativeURL: "license-policy",
-	},
-	{
-		Name:        "pkg-firestore",
-		RelativeURL: "cloud.google.com/go/firestore",
-	},
-	{
-		Name:        "pkg-firestore-nocache",
-		RelativeURL: "cloud.google.com/go/firestore",
-		BypassCache: true,
-	},
-	{
-		Name:        "pkg-firestore-readme",
-		RelativeURL: "cloud.google.com/go/firestore?tab=readme",
-	},
-	{
-		Name:        "pkg-firestore-readme-nocache",
-		RelativeURL: "cloud.google.com/go/firestore?tab=readme",
-		BypassCache: true,
-	},
-	{
-		Name:        "pkg-firestore-versions",
-		RelativeURL: "cloud.google.com/go/firestore?tab=versions",
-	},
-	{
-		Name:        "pkg-firestore-versions-nocache",
-		RelativeURL: "cloud.google.com/go/firestore?tab=versions",
-		BypassCache: true,
-	},
-	{
-		Name:        "pkg-firestore-imports",
-		RelativeURL: "cloud.google.com/go/firestore?tab=imports",
-	},
-	{
-		Name:        "pkg-firestore-imports-nocache",
-		RelativeURL: "cloud.google.com/go/firestore?tab=imports",
-		BypassCache: true,
-	},
-	{
-		Name:        "pkg-firestore-importedby",
-		RelativeURL: "cloud.google.com/go/firestore?tab=importedby",
-	},
-	{
-		Name:        "pkg-firestore-importedby-nocache",
-		RelativeURL: "cloud.google.com/go/firestore?tab=importedby",
-		BypassCache: true,
-	},
-	{
-		Name:        "pkg-firestore-licenses",
-		RelativeURL: "cloud.google.com/go/firestore?tab=licenses",
-	},
-	{
-		Name:        "pkg-firestore-licenses-nocache",
-		RelativeURL: "cloud.google.com/go/firestore?tab=licenses",
-		BypassCache: true,
-	},
-	{
-		Name:        "pkg-errors-importedby",
-		RelativeURL: "github.com/pkg/errors?tab=importedby",
-	},
-	{
-		Name:        "pkg-errors-importedby-nocache",
-		RelativeURL: "github.com/pkg/errors?tab=importedby",
-		BypassCache: true,
-	},
-	{
-		Name:        "pkg-hortonworks-versions",
-		RelativeURL: "github.com/hortonworks/cb-cli?tab=versions",
-		BypassCache: true,
-	},
-	{
-		Name:        "pkg-xtoolsgo-directory",
-		RelativeURL: "golang.org/x/tools/go",
-		BypassCache: true,
-	},
-	{
-		Name:        "mod-xtools-nocache",
-		RelativeURL: "mod/golang.org/x/tools",
-		BypassCache: true,
-	},
-	{
-		Name:        "mod-xtools-packages-nocache",
-		RelativeURL: "mod/golang.org/x/tools?tab=packages",
-		BypassCache: true,
-	},
-	{
-		Name:        "mod-xtools-versions-nocache",
-		RelativeURL: "mod/golang.org/x/tools?tab=versions",
-		BypassCache: true,
-	},
-	{
-		Name:        "search-github",
-		RelativeURL: "search?q=github",
-	},
-	{
-		Name:        "search-github-nocache",
-		RelativeURL: "search?q=github",
-		BypassCache: true,
-	},
-}
-
-func init() {
-	// Validate that probe names are unique.
-	names := map[string]bool{}
-	for _, p := range probes {
-		if names[p.Name] {
-			log.Fatalf(context.Background(), "duplicate probe name %q", p.Name)
-		}
-		names[p.Name] = true
-	}
-}
+// probes are the pages of the spec file *probesFile.
+var probes []*pagespec.Page
 
 var (
 	baseURL        string
@@ -209,12 +82,28 @@ func main() {
 	flag.Parse()
 	ctx := context.Background()
 
-	baseURL = config.GetEnv("PROBER_BASE_URL", "")
+	baseURL = *base
+	if baseURL == "" {
+		baseURL = config.GetEnv("PROBER_BASE_URL", "")
+	}
 	if baseURL == "" {
-		log.Fatal(ctx, "must set PROBER_BASE_URL")
+		log.Fatal(ctx, "must set -base or PROBER_BASE_URL")
 	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
 	log.Infof(ctx, "base URL %s", baseURL)
 
+	spec, err := pagespec.Load(*probesFile)
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	if len(spec.Modules) > 0 {
+		log.Warningf(ctx, "ignoring the modules in %s; the prober does not load data", *probesFile)
+	}
+	probes = spec.Pages
+	if *once {
+		os.Exit(runOnce(ctx))
+	}
+
 	cfg, err := config.Init(ctx)
 	if err != nil {
 		log.Fatal(ctx, err)
@@ -255,6 +144,7 @@ func main() {
 	http.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "content/static/img/favicon.ico")
 	})
+	http.HandleFunc("/history", handleHistory)
 	http.HandleFunc("/", handleProbe)
 
 	addr := cfg.HostAddr("localhost:8080")
@@ -262,9 +152,27 @@ func main() {
 	log.Fatal(ctx, http.ListenAndServe(addr, nil))
 }
 
+// runOnce runs the probes against baseURL without credentials, as in CI
+// against a local frontend, and prints the results. It returns the exit
+// status: 0 if all probes passed, and 1 otherwise.
+func runOnce(ctx context.Context) int {
+	client = http.DefaultClient
+	code := 0
+	for _, s := range runProbes(ctx) {
+		result := "ok  "
+		if !s.Passed {
+			result = "FAIL"
+			code = 1
+		}
+		fmt.Printf("%s %-30s %5dms  %s\n", result, s.Probe.Name, s.Latency, s.Text)
+	}
+	return code
+}
+
 // ProbeStatus records the result if a single probe attempt
 type ProbeStatus struct {
-	Probe   *Probe
+	Probe   *pagespec.Page
+	Passed  bool
 	Text    string // describes what happened: "OK", or "FAILED" with a reason
 	Latency int    // in milliseconds
 }
@@ -290,71 +198,43 @@ func handleProbe(w http.ResponseWriter, r *http.Request) {
 }
 
 func runProbes(ctx context.Context) []*ProbeStatus {
+	start := time.Now()
 	var statuses []*ProbeStatus
 	for _, p := range probes {
 		s := runProbe(ctx, p)
 		statuses = append(statuses, s)
 	}
-	metricReader.ReadAndExport(metricExporter)
-	metricExporter.Flush()
-	log.Info(ctx, "metrics exported to StackDriver")
+	history.add(start, statuses)
+	if metricExporter != nil {
+		metricReader.ReadAndExport(metricExporter)
+		metricExporter.Flush()
+		log.Info(ctx, "metrics exported to StackDriver")
+	}
 	return statuses
 }
 
-func runProbe(ctx context.Context, p *Probe) *ProbeStatus {
+func runProbe(ctx context.Context, p *pagespec.Page) *ProbeStatus {
 	status := &ProbeStatus{Probe: p}
-	url := baseURL + "/" + p.RelativeURL
-	log.Infof(ctx, "running %s = %s", p.Name, url)
+	log.Infof(ctx, "running %s = %s/%s", p.Name, baseURL, p.RelativeURL)
 	defer func() {
 		log.Infof(ctx, "%s in %dms", status.Text, status.Latency)
 	}()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if p.BypassCache {
-		req.Header.Set("x-go-discovery-bypass-cache", "yes")
-	}
-	if err != nil {
-		status.Text = fmt.Sprintf("FAILED making request: %v", err)
-		return status
-	}
-	start := time.Now()
-	res, err := client.Do(req.WithContext(ctx))
-
-	latency := float64(time.Since(start)) / float64(time.Millisecond)
+	res := p.Run(ctx, client, baseURL)
+	latency := float64(res.Latency) / float64(time.Millisecond)
 	status.Latency = int(latency)
-	record := func(statusTag string) {
-		stats.RecordWithTags(ctx, []tag.Mutator{
-			tag.Upsert(keyName, p.Name),
-			tag.Upsert(keyStatus, statusTag),
-		}, firstByteLatency.M(latency))
-	}
-
-	if err != nil {
-		status.Text = fmt.Sprintf("FAILED call: %v", err)
-		record("FAILED call")
-		return status
-	}
-	defer res.Body.Close()
-	if res.StatusCode != http.StatusOK {
-		status.Text = fmt.Sprintf("FAILED with status %s", res.Status)
-		record(res.Status)
-		return status
-	}
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		status.Text = fmt.Sprintf("FAILED reading body: %v", err)
-		record("FAILED read body")
-		return status
-	}
-	if !bytes.Contains(body, []byte("go.dev")) {
-		status.Text = "FAILED: body does not contain 'go.dev'"
-		record("FAILED wrong body")
+	stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(keyName, p.Name),
+		tag.Upsert(keyStatus, res.Outcome),
+	}, firstByteLatency.M(latency))
+	if res.Err != nil {
+		status.Text = fmt.Sprintf("FAILED: %v", res.Err)
 		return status
 	}
 	status.Text = "OK"
-	record("200 OK")
+	status.Passed = true
 	return status
 }
 
@@ -0,0 +1,144 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/pkgsite/internal/testing/pagespec"
+)
+
+func TestLoadProbeFiles(t *testing.T) {
+	for _, filename := range []string{"probes.yaml", "local.yaml"} {
+		spec, err := pagespec.Load(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(spec.Pages) == 0 {
+			t.Errorf("%s: no probes", filename)
+		}
+		if len(spec.Modules) > 0 {
+			t.Errorf("%s: has modules, which the prober does not load", filename)
+		}
+	}
+}
+
+const testProbes = `
+Pages:
+  - Name: pass
+    RelativeURL: "pkg"
+    Contains: [Answer]
+    Matches: ["v1\\.[0-9]+\\.0"]
+    Selectors:
+      - Selector: h1
+        Text: ^pkg$
+      - Selector: .Error
+        Absent: true
+  - Name: missing-text
+    RelativeURL: "pkg"
+    Contains: [Question]
+  - Name: wrong-heading
+    RelativeURL: "pkg"
+    Selectors:
+      - Selector: h1
+        Text: other
+  - Name: redirect
+    RelativeURL: "old"
+    RedirectTo: "/pkg"
+  - Name: wrong-redirect
+    RelativeURL: "old"
+    RedirectTo: "/elsewhere"
+  - Name: not-found
+    RelativeURL: "missing"
+    Status: 404
+  - Name: unexpected-status
+    RelativeURL: "missing"
+  - Name: slow
+    RelativeURL: "slow"
+    MaxLatency: 1ms
+`
+
+func TestRunProbe(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pkg", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><h1>pkg</h1><p>const Answer = 42</p><p>v1.2.0</p></body></html>`))
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/pkg", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	spec, err := pagespec.Parse([]byte(testProbes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(b string, c *http.Client, p []*pagespec.Page) { baseURL, client, probes = b, c, p }(baseURL, client, probes)
+	baseURL = ts.URL
+	client = http.DefaultClient
+	probes = spec.Pages
+
+	want := map[string]bool{
+		"pass":              true,
+		"missing-text":      false,
+		"wrong-heading":     false,
+		"redirect":          true,
+		"wrong-redirect":    false,
+		"not-found":         true,
+		"unexpected-status": false,
+		"slow":              false,
+	}
+	statuses := runProbes(context.Background())
+	if len(statuses) != len(want) {
+		t.Fatalf("got %d statuses, want %d", len(statuses), len(want))
+	}
+	for _, s := range statuses {
+		if s.Passed != want[s.Probe.Name] {
+			t.Errorf("%s: got passed %t (%s), want %t", s.Probe.Name, s.Passed, s.Text, want[s.Probe.Name])
+		}
+		if !s.Passed && !strings.HasPrefix(s.Text, "FAILED: ") {
+			t.Errorf("%s: got text %q, want a failure reason", s.Probe.Name, s.Text)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	defer func(p []*pagespec.Page) { probes = p }(probes)
+	a, b := &pagespec.Page{Name: "a"}, &pagespec.Page{Name: "b"}
+	probes = []*pagespec.Page{a, b}
+
+	var h runHistory
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < maxHistory+2; i++ {
+		statuses := []*ProbeStatus{{Probe: a, Passed: i%2 == 0}}
+		if i == maxHistory+1 {
+			statuses = append(statuses, &ProbeStatus{Probe: b, Passed: true})
+		}
+		h.add(start.Add(time.Duration(i)*time.Minute), statuses)
+	}
+
+	rows, starts := h.rows()
+	if len(starts) != maxHistory {
+		t.Fatalf("got %d runs, want %d", len(starts), maxHistory)
+	}
+	if want := start.Add((maxHistory + 1) * time.Minute); !starts[0].Equal(want) {
+		t.Errorf("newest run started at %v, want %v", starts[0], want)
+	}
+	if got, want := rows[0].PassRate(), "50%"; got != want {
+		t.Errorf("a: got pass rate %s, want %s", got, want)
+	}
+	if rows[1].Runs != 1 || rows[1].Statuses[0] == nil || rows[1].Statuses[1] != nil {
+		t.Errorf("b: got %d runs, statuses %v; want only the newest run", rows[1].Runs, rows[1].Statuses)
+	}
+}
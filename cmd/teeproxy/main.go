@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"os"
 
+	"golang.org/x/pkgsite/cmd/internal/cmdconfig"
 	"golang.org/x/pkgsite/internal/auth"
 	"golang.org/x/pkgsite/internal/breaker"
 	"golang.org/x/pkgsite/internal/config"
@@ -38,12 +39,7 @@ func main() {
 	cfg.Dump(os.Stderr)
 
 	log.SetLevel(cfg.LogLevel)
-	if cfg.OnGCP() {
-		_, err := log.UseStackdriver(ctx, cfg, "teeproxy-log")
-		if err != nil {
-			log.Fatal(ctx, err)
-		}
-	}
+	cmdconfig.Logger(ctx, cfg, "teeproxy-log")
 	client := &http.Client{}
 	var jsonCreds []byte
 	if *credsFile != "" {
@@ -75,6 +71,8 @@ func main() {
 		teeproxy.TeeproxyGddoRequestLatencyDistribution,
 		teeproxy.TeeproxyPkgGoDevRequestLatencyDistribution,
 		teeproxy.TeeproxyPkgGoDevBrokenPathCount,
+		teeproxy.TeeproxyContentParityDistribution,
+		teeproxy.TeeproxyContentMismatchCount,
 	)
 	dcensus.Init(cfg, views...)
 
@@ -94,12 +92,14 @@ func main() {
 			MaxTimeout:       cfg.Teeproxy.MaxTimeout,
 			SuccsToGreen:     cfg.Teeproxy.SuccsToGreen,
 		},
-		Hosts:  cfg.Teeproxy.Hosts,
-		Client: client,
+		Hosts:      cfg.Teeproxy.Hosts,
+		Client:     client,
+		SampleRate: cfg.Teeproxy.SampleRate,
 	})
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
+	http.HandleFunc("/report", server.HandleReport)
 	http.Handle("/", server)
 
 	addr := cfg.HostAddr("localhost:8020")
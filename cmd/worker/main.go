@@ -25,7 +25,9 @@ import (
 	"golang.org/x/pkgsite/internal/fetch"
 	"golang.org/x/pkgsite/internal/index"
 	"golang.org/x/pkgsite/internal/queue"
+	"golang.org/x/pkgsite/internal/resilient"
 	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/worker"
 
 	"golang.org/x/pkgsite/internal/log"
@@ -37,12 +39,11 @@ import (
 )
 
 var (
-	timeout   = config.GetEnvInt("GO_DISCOVERY_WORKER_TIMEOUT_MINUTES", 10)
-	queueName = config.GetEnv("GO_DISCOVERY_WORKER_TASK_QUEUE", "")
-	workers   = flag.Int("workers", 10, "number of concurrent requests to the fetch service, when running locally")
+	workers = flag.Int("workers", 10, "number of concurrent requests to the fetch service, when running locally")
 	// flag used in call to safehtml/template.TrustedSourceFromFlag
 	_                  = flag.String("static", "content/static", "path to folder containing static files served")
 	bypassLicenseCheck = flag.Bool("bypass_license_check", false, "insert all data into the DB, even for non-redistributable paths")
+	validateConfig     = flag.Bool("validate-config", false, "check the configuration, print it with secrets redacted, and exit")
 )
 
 func main() {
@@ -54,6 +55,13 @@ func main() {
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
+	if *validateConfig {
+		// config.Init has checked the configuration; show what it resolved to.
+		if err := cfg.Dump(os.Stdout); err != nil {
+			log.Fatal(ctx, err)
+		}
+		return
+	}
 	cfg.Dump(os.Stdout)
 
 	log.SetLevel(cfg.LogLevel)
@@ -64,7 +72,8 @@ func main() {
 		}
 	}
 
-	readProxyRemoved(ctx)
+	readProxyRemoved(ctx, cfg.ProxyRemovedFile)
+	fetch.SetZipLimits(ctx, cfg.MaxModuleZipSize, cfg.MaxInFlightZipSize)
 
 	// Wrap the postgres driver with OpenCensus instrumentation.
 	driverName, err := ocsql.Register("postgres", ocsql.WithAllTraceOptions())
@@ -81,22 +90,36 @@ func main() {
 	} else {
 		db = postgres.New(ddb)
 	}
+	db.SetRetryPolicies(cfg.RetryPolicies)
+	db.SetLargeModulesLimit(cfg.LargeModulesLimit)
 	defer db.Close()
 
-	populateExcluded(ctx, db)
+	populateExcluded(ctx, db, cfg.ExcludedFile)
 
-	indexClient, err := index.New(cfg.IndexURL)
-	if err != nil {
+	if err := stdlib.UseLocalSource(cfg.StdlibSourceDir); err != nil {
+		log.Fatal(ctx, err)
+	}
+	if err := stdlib.SetZipCacheDir(cfg.StdlibZipCacheDir); err != nil {
 		log.Fatal(ctx, err)
 	}
-	proxyClient, err := proxy.New(cfg.ProxyURL)
+
+	indexClient, err := index.New(cfg.IndexURL)
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
+	proxyClient := cmdconfig.ProxyClient(ctx, cfg, cfg.ProxyURL)
+	var discoverers []index.Discoverer
+	if cfg.ModuleListURL != "" {
+		discoverers = append(discoverers, index.NewListDiscoverer(cfg.ModuleListURL))
+	}
+	if len(cfg.WatchedPrefixes) > 0 {
+		discoverers = append(discoverers, index.NewProxyDiscoverer(proxyClient, cfg.WatchedPrefixes, db.GetModulePathsWithPrefix))
+	}
 	sourceClient := source.NewClient(config.SourceTimeout)
-	fetchQueue, err := queue.New(ctx, cfg, queueName, *workers, db.GetExperiments,
+	redisCacheClient := getCacheRedis(ctx, cfg)
+	fetchQueue, err := queue.New(ctx, cfg, cfg.WorkerTaskQueue, cfg.WorkerLaneQueues, *workers, db.GetExperiments,
 		func(ctx context.Context, modulePath, version string) (int, error) {
-			return worker.FetchAndUpdateState(ctx, modulePath, version, proxyClient, sourceClient, db, cfg.AppVersionLabel())
+			return worker.FetchAndUpdateState(ctx, modulePath, version, proxyClient, sourceClient, db, redisCacheClient, cfg.AppVersionLabel())
 		})
 	if err != nil {
 		log.Fatalf(ctx, "queue.New: %v", err)
@@ -104,11 +127,11 @@ func main() {
 
 	reportingClient := cmdconfig.ReportingClient(ctx, cfg)
 	redisHAClient := getHARedis(ctx, cfg)
-	redisCacheClient := getCacheRedis(ctx, cfg)
 	experimenter := cmdconfig.Experimenter(ctx, cfg, db.GetExperiments, reportingClient)
 	server, err := worker.NewServer(cfg, worker.ServerConfig{
 		DB:                   db,
 		IndexClient:          indexClient,
+		Discoverers:          discoverers,
 		ProxyClient:          proxyClient,
 		SourceClient:         sourceClient,
 		RedisHAClient:        redisHAClient,
@@ -127,10 +150,18 @@ func main() {
 
 	views := append(dcensus.ServerViews,
 		worker.EnqueueResponseCount,
+		worker.FetchStageLatencyDistribution,
+		queue.EnqueueCount,
+		queue.WaitLatencyDistribution,
+		queue.InFlightCount,
+		proxy.UpstreamLatencyDistribution,
+		proxy.UpstreamResponseCount,
+		proxy.DiskCacheResultCount,
 		fetch.FetchLatencyDistribution,
 		fetch.FetchResponseCount,
 		fetch.SheddedFetchCount,
 		fetch.FetchPackageCount)
+	views = append(views, resilient.Views...)
 	if err := dcensus.Init(cfg, views...); err != nil {
 		log.Fatal(ctx, err)
 	}
@@ -145,13 +176,13 @@ func main() {
 	}
 
 	iap := middleware.Identity()
-	if aud := os.Getenv("GO_DISCOVERY_IAP_AUDIENCE"); aud != "" {
-		iap = middleware.ValidateIAPHeader(aud)
+	if cfg.IAPAudience != "" {
+		iap = middleware.ValidateIAPHeader(cfg.IAPAudience)
 	}
 
 	mw := middleware.Chain(
 		middleware.RequestLog(cmdconfig.Logger(ctx, cfg, "worker-log")),
-		middleware.Timeout(time.Duration(timeout)*time.Minute),
+		middleware.Timeout(cfg.WorkerTimeout),
 		iap,
 		middleware.Experiment(experimenter),
 	)
@@ -193,8 +224,7 @@ func getRedis(ctx context.Context, host, port string, writeTimeout, readTimeout
 // the are in the index but not stored in the proxy.
 // Format of the file: each line is
 //     module@version
-func readProxyRemoved(ctx context.Context) {
-	filename := config.GetEnv("GO_DISCOVERY_PROXY_REMOVED", "")
+func readProxyRemoved(ctx context.Context, filename string) {
 	if filename == "" {
 		return
 	}
@@ -208,10 +238,9 @@ func readProxyRemoved(ctx context.Context) {
 	log.Infof(ctx, "read %d excluded module versions from %s", len(worker.ProxyRemoved), filename)
 }
 
-// populateExcluded adds each element of excludedPrefixes to the excluded_prefixes
+// populateExcluded adds each prefix in the file filename to the excluded_prefixes
 // table if it isn't already present.
-func populateExcluded(ctx context.Context, db *postgres.DB) {
-	filename := config.GetEnv("GO_DISCOVERY_EXCLUDED_FILENAME", "")
+func populateExcluded(ctx context.Context, db *postgres.DB, filename string) {
 	if filename == "" {
 		return
 	}
@@ -6,7 +6,10 @@
  */
 
 function submitForm(formName, reload) {
-  let form = document[formName];
+  submitFormElement(document[formName], reload);
+}
+
+function submitFormElement(form, reload) {
   form.result.value = 'request pending...';
   let xhr = new XMLHttpRequest();
   xhr.onreadystatechange = function () {
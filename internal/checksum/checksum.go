@@ -0,0 +1,245 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package checksum verifies module zips against a checksum database, such as
+// sum.golang.org.
+package checksum
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/dirhash"
+	"golang.org/x/mod/sumdb/note"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// DefaultName is the name of the public Go checksum database.
+const DefaultName = "sum.golang.org"
+
+// defaultKey is the verifier key of the public Go checksum database.
+const defaultKey = "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8"
+
+// maxCacheEntries bounds the number of lookup records and tiles held in
+// memory. Tiles are at most 8K, so the cache holds at most about 32M.
+const maxCacheEntries = 4096
+
+// A DB verifies module zips against a checksum database.
+type DB struct {
+	name       string
+	key        string
+	url        string
+	nosumdb    string
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	latest []byte            // latest signed tree head seen
+	cache  map[string][]byte // verified lookup records and tiles
+}
+
+// New returns a DB for the checksum database described by spec, which has
+// the format of the go command's GOSUMDB environment variable:
+//
+//   sum.golang.org          the public checksum database
+//   <key>                   the database with the given verifier key,
+//                           served at https://<name of key>
+//   <name or key> <url>     the database served at url
+//
+// Module paths matching the comma-separated glob patterns in nosumdb, which
+// has the format of GONOSUMDB, are not looked up. If httpClient is nil,
+// http.DefaultClient is used.
+func New(spec, nosumdb string, httpClient *http.Client) (_ *DB, err error) {
+	defer derrors.Wrap(&err, "checksum.New(%q)", spec)
+
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("want key or name, optionally followed by URL: %w", derrors.InvalidArgument)
+	}
+	key := fields[0]
+	if key == DefaultName {
+		key = defaultKey
+	}
+	verifier, err := note.NewVerifier(key)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, derrors.InvalidArgument)
+	}
+	url := "https://" + verifier.Name()
+	if len(fields) == 2 {
+		url = strings.TrimSuffix(fields[1], "/")
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &DB{
+		name:       verifier.Name(),
+		key:        key,
+		url:        url,
+		nosumdb:    nosumdb,
+		httpClient: httpClient,
+		cache:      map[string][]byte{},
+	}, nil
+}
+
+// Name returns the name of the checksum database.
+func (db *DB) Name() string {
+	return db.name
+}
+
+// VerifyZip checks the h1: hash of the zip for the module version against
+// the checksum database. It returns nil if the database has a matching hash.
+// It returns an error wrapping derrors.ModuleUnverified if the database has
+// no hash for the module version, or the module path is excluded by the
+// nosumdb patterns, and one wrapping derrors.ChecksumMismatch if the hashes
+// differ. Other errors, such as a failure to reach the database, should be
+// treated as transient.
+func (db *DB) VerifyZip(ctx context.Context, modulePath, version string, zipReader *zip.Reader) (err error) {
+	defer derrors.Wrap(&err, "checksum.DB.VerifyZip(%q, %q)", modulePath, version)
+
+	h, err := HashZip(zipReader)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, derrors.BadModule)
+	}
+	// sumdb.Client remembers failed lookups for its lifetime, so use a new
+	// one for each lookup. The tree head and tiles it has verified are kept
+	// in db and shared across clients.
+	ops := &clientOps{db: db, ctx: ctx}
+	client := sumdb.NewClient(ops)
+	if db.nosumdb != "" {
+		client.SetGONOSUMDB(db.nosumdb)
+	}
+	lines, err := client.Lookup(modulePath, version)
+	if errors.Is(err, sumdb.ErrGONOSUMDB) {
+		return fmt.Errorf("module path excluded from checksum database: %w", derrors.ModuleUnverified)
+	}
+	if err != nil {
+		if ops.lookupNotFound() {
+			return fmt.Errorf("not in checksum database %s: %w", db.name, derrors.ModuleUnverified)
+		}
+		return err
+	}
+	want := fmt.Sprintf("%s %s %s", modulePath, version, h)
+	for _, line := range lines {
+		if line == want {
+			return nil
+		}
+	}
+	return fmt.Errorf("zip has hash %s, checksum database %s has %q: %w", h, db.name, lines, derrors.ChecksumMismatch)
+}
+
+// HashZip returns the h1: hash of the files in a module zip, as recorded in
+// go.sum files and the checksum database.
+func HashZip(zipReader *zip.Reader) (string, error) {
+	var files []string
+	zfiles := map[string]*zip.File{}
+	for _, f := range zipReader.File {
+		files = append(files, f.Name)
+		zfiles[f.Name] = f
+	}
+	return dirhash.Hash1(files, func(name string) (io.ReadCloser, error) {
+		f := zfiles[name]
+		if f == nil {
+			return nil, fmt.Errorf("file %q not found in zip", name) // should never happen
+		}
+		return f.Open()
+	})
+}
+
+// clientOps implements sumdb.ClientOps for a single lookup. Configuration and
+// cache files are stored in the DB.
+type clientOps struct {
+	db       *DB
+	ctx      context.Context
+	notFound int32 // set atomically when the lookup request gets a 404 or 410
+}
+
+// lookupNotFound reports whether the database reported that it has no record
+// of the module version.
+func (o *clientOps) lookupNotFound() bool {
+	return atomic.LoadInt32(&o.notFound) != 0
+}
+
+func (o *clientOps) ReadRemote(path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(o.ctx, http.MethodGet, o.db.url+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.db.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return ioutil.ReadAll(resp.Body)
+	case strings.HasPrefix(path, "/lookup/") && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone):
+		atomic.StoreInt32(&o.notFound, 1)
+		return nil, fmt.Errorf("GET %s: %s: %w", path, resp.Status, derrors.NotFound)
+	default:
+		return nil, fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+}
+
+func (o *clientOps) ReadConfig(file string) ([]byte, error) {
+	if file == "key" {
+		return []byte(o.db.key), nil
+	}
+	if file == o.db.name+"/latest" {
+		o.db.mu.Lock()
+		defer o.db.mu.Unlock()
+		return o.db.latest, nil
+	}
+	return nil, fmt.Errorf("unknown config file %q", file)
+}
+
+func (o *clientOps) WriteConfig(file string, old, new []byte) error {
+	if file != o.db.name+"/latest" {
+		return fmt.Errorf("cannot write config file %q", file)
+	}
+	o.db.mu.Lock()
+	defer o.db.mu.Unlock()
+	if string(old) != string(o.db.latest) {
+		return sumdb.ErrWriteConflict
+	}
+	o.db.latest = new
+	return nil
+}
+
+func (o *clientOps) ReadCache(file string) ([]byte, error) {
+	o.db.mu.Lock()
+	defer o.db.mu.Unlock()
+	data, ok := o.db.cache[file]
+	if !ok {
+		return nil, derrors.NotFound
+	}
+	return data, nil
+}
+
+func (o *clientOps) WriteCache(file string, data []byte) {
+	o.db.mu.Lock()
+	defer o.db.mu.Unlock()
+	if len(o.db.cache) >= maxCacheEntries {
+		// Entries are cheap to fetch again, so start over rather than
+		// tracking their use.
+		o.db.cache = map[string][]byte{}
+	}
+	o.db.cache[file] = data
+}
+
+func (o *clientOps) Log(msg string) {
+	log.Debug(o.ctx, msg)
+}
+
+func (o *clientOps) SecurityError(msg string) {
+	log.Errorf(o.ctx, "checksum database %s: %s", o.db.name, msg)
+}
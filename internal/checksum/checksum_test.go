@@ -0,0 +1,155 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checksum
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/note"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+func testZip(t *testing.T, modulePath, version, contents string) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(modulePath + "@" + version + "/go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(contents)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func TestVerifyZip(t *testing.T) {
+	ctx := context.Background()
+	const version = "v1.0.0"
+	good := testZip(t, "example.com/good", version, "module example.com/good\n")
+	goodHash, err := HashZip(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The checksum database knows example.com/good and example.com/bad, and
+	// records the same hash for both.
+	gosum := func(path, vers string) ([]byte, error) {
+		switch path {
+		case "example.com/good", "example.com/bad":
+			return []byte(fmt.Sprintf("%s %s %s\n", path, vers, goodHash)), nil
+		default:
+			return nil, os.ErrNotExist
+		}
+	}
+	skey, vkey, err := note.GenerateKey(nil, "sumdb.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(sumdb.NewServer(sumdb.NewTestServer(skey, gosum)))
+	defer server.Close()
+
+	db, err := New(vkey+" "+server.URL, "example.com/private", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		modulePath, contents string
+		want                 error
+	}{
+		{"example.com/good", "module example.com/good\n", nil},
+		{"example.com/bad", "module example.com/bad\n", derrors.ChecksumMismatch},
+		{"example.com/unknown", "module example.com/unknown\n", derrors.ModuleUnverified},
+		{"example.com/private", "module example.com/private\n", derrors.ModuleUnverified},
+	} {
+		t.Run(test.modulePath, func(t *testing.T) {
+			zr := testZip(t, test.modulePath, version, test.contents)
+			err := db.VerifyZip(ctx, test.modulePath, version, zr)
+			if test.want == nil {
+				if err != nil {
+					t.Fatalf("got %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, test.want) {
+				t.Fatalf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+
+	// A database signed with a different key is rejected.
+	_, otherKey, err := note.GenerateKey(nil, "sumdb.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err = New(otherKey+" "+server.URL, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.VerifyZip(ctx, "example.com/good", version, good)
+	if err == nil || errors.Is(err, derrors.ModuleUnverified) || errors.Is(err, derrors.ChecksumMismatch) {
+		t.Errorf("wrong key: got %v, want a verification failure", err)
+	}
+}
+
+func TestVerifyZipServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	_, vkey, err := note.GenerateKey(nil, "sumdb.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := New(vkey+" "+server.URL, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr := testZip(t, "example.com/m", "v1.0.0", "module example.com/m\n")
+	err = db.VerifyZip(context.Background(), "example.com/m", "v1.0.0", zr)
+	if err == nil || errors.Is(err, derrors.ModuleUnverified) {
+		t.Errorf("got %v, want a transient error", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, test := range []struct {
+		spec, wantName, wantURL string
+		wantErr                 bool
+	}{
+		{"sum.golang.org", "sum.golang.org", "https://sum.golang.org", false},
+		{"sum.golang.org https://proxy.golang.org/sumdb/sum.golang.org/", "sum.golang.org", "https://proxy.golang.org/sumdb/sum.golang.org", false},
+		{"", "", "", true},
+		{"sum.example.com", "", "", true},
+		{"a b c", "", "", true},
+	} {
+		db, err := New(test.spec, "", nil)
+		if (err != nil) != test.wantErr {
+			t.Errorf("New(%q): got error %v, want error: %t", test.spec, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if db.name != test.wantName || db.url != test.wantURL {
+			t.Errorf("New(%q): got name %q, URL %q; want %q, %q", test.spec, db.name, db.url, test.wantName, test.wantURL)
+		}
+	}
+}
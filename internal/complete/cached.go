@@ -0,0 +1,85 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package complete
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/middleware"
+)
+
+// CachedCompleter is a Completer that keeps the results of another Completer
+// in a middleware.CacheStore, the store of the page cache, so that repeated
+// queries, which are common as users type the same prefixes, don't reach the
+// underlying index.
+type CachedCompleter struct {
+	completer Completer
+	store     middleware.CacheStore
+	ttl       time.Duration
+}
+
+// NewCachedCompleter returns a CachedCompleter that keeps the results of c in
+// store for ttl.
+func NewCachedCompleter(c Completer, store middleware.CacheStore, ttl time.Duration) *CachedCompleter {
+	return &CachedCompleter{completer: c, store: store, ttl: ttl}
+}
+
+// Complete implements Completer.Complete. Failures of the store are logged,
+// and the query goes to the underlying Completer.
+func (c *CachedCompleter) Complete(ctx context.Context, q string, maxResults int) ([]*Completion, error) {
+	key := cacheKey(q, maxResults)
+	if val, ok, err := c.store.Get(ctx, key); err != nil {
+		log.Errorf(ctx, "CachedCompleter.Complete(%q): reading cache: %v", q, err)
+	} else if ok {
+		cs, err := decodeCompletions(string(val))
+		if err == nil {
+			return cs, nil
+		}
+		log.Errorf(ctx, "CachedCompleter.Complete(%q): %v", q, err)
+	}
+	cs, err := c.completer.Complete(ctx, q, maxResults)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.store.Put(ctx, key, []byte(encodeCompletions(cs)), c.ttl, nil); err != nil {
+		log.Errorf(ctx, "CachedCompleter.Complete(%q): writing cache: %v", q, err)
+	}
+	return cs, nil
+}
+
+// cacheKey returns the key of the cached results of a query. Page cache keys
+// are URLs, which never have this prefix.
+func cacheKey(q string, maxResults int) string {
+	return fmt.Sprintf("autocomplete:%d:%s", maxResults, q)
+}
+
+// encodeCompletions encodes cs, in order, one per line.
+func encodeCompletions(cs []*Completion) string {
+	var lines []string
+	for _, c := range cs {
+		lines = append(lines, c.Encode())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// decodeCompletions decodes the output of encodeCompletions.
+func decodeCompletions(s string) ([]*Completion, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var cs []*Completion
+	for _, line := range strings.Split(s, "\n") {
+		c, err := Decode(line)
+		if err != nil {
+			return nil, err
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
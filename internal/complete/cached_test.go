@@ -0,0 +1,52 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package complete
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/pkgsite/internal/middleware"
+)
+
+// countingCompleter counts the queries that reach a Completer.
+type countingCompleter struct {
+	Completer
+	n int
+}
+
+func (c *countingCompleter) Complete(ctx context.Context, q string, maxResults int) ([]*Completion, error) {
+	c.n++
+	return c.Completer.Complete(ctx, q, maxResults)
+}
+
+func TestCachedCompleter(t *testing.T) {
+	cc := &countingCompleter{Completer: rankCompleter{}}
+	c := NewCachedCompleter(cc, middleware.NewLRUStore(1<<20, 0), time.Minute)
+	testCompleter(t, c)
+	n := cc.n
+	// The second time, every result comes from the cache.
+	testCompleter(t, c)
+	if cc.n != n {
+		t.Errorf("got %d queries to the underlying completer, want %d", cc.n, n)
+	}
+}
+
+// failingStore is a CacheStore whose operations all fail.
+type failingStore struct{ middleware.CacheStore }
+
+func (failingStore) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("bad store")
+}
+
+func (failingStore) Put(context.Context, string, []byte, time.Duration, []string) error {
+	return errors.New("bad store")
+}
+
+func TestCachedCompleterStoreFailure(t *testing.T) {
+	testCompleter(t, NewCachedCompleter(rankCompleter{}, failingStore{}, time.Minute))
+}
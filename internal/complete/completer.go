@@ -0,0 +1,70 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package complete
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// A Completer completes partial package paths.
+type Completer interface {
+	// Complete returns at most maxResults completions whose Suffix starts
+	// with the lower-case prefix q, best first.
+	Complete(ctx context.Context, q string, maxResults int) ([]*Completion, error)
+}
+
+// Score returns the relevancy score of a completion, used to sort completions
+// in descending order.
+//
+// It weights importers by distance of the matching text from the end of the
+// import path. This is done in an attempt to make results more relevant the
+// closer the match is to the end of the import path. For example, if the user
+// types 'net', we should have some preference for 'net' over 'net/http'. In
+// this case, it actually works out like so:
+//  - net has ~68000 importers
+//  - net/http has ~130000 importers
+//
+// So the score of 'net' is ~68000 (offset=1), and the score of 'net/http' is
+// ~65000 (130K/2, as offset=2), therefore net should be sorted above
+// 'net/http' in the results.
+//
+// This heuristic is a total guess, but since this is just autocomplete it
+// probably doesn't matter much. In testing, it felt like autocomplete was
+// completing the packages I wanted.
+//
+// The `- offset` term is added to break ties in the case where all completion
+// results have 0 importers.
+func Score(c *Completion) int {
+	offset := len(strings.Split(c.Encode(), "/"))
+	return c.Importers/offset - offset
+}
+
+// best sorts cs by descending score, and returns at most the first
+// maxResults.
+func best(cs []*Completion, maxResults int) []*Completion {
+	sort.SliceStable(cs, func(i, j int) bool {
+		return Score(cs[i]) > Score(cs[j])
+	})
+	if len(cs) > maxResults {
+		cs = cs[:maxResults]
+	}
+	return cs
+}
+
+// Rank returns at most maxResults of the path completions of partials whose
+// Suffix starts with q, best first.
+func Rank(partials []Completion, q string, maxResults int) []*Completion {
+	var cs []*Completion
+	for _, p := range partials {
+		for _, c := range PathCompletions(p) {
+			if strings.HasPrefix(c.Suffix, q) {
+				cs = append(cs, c)
+			}
+		}
+	}
+	return best(cs, maxResults)
+}
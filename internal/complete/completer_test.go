@@ -0,0 +1,113 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package complete
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testPartials are the packages that each Completer is tested with.
+var testPartials = []Completion{
+	{PackagePath: "foo.com/bar/baz", ModulePath: "foo.com/bar", Version: "v1.2.3", Importers: 123},
+	{PackagePath: "foo.com/quux/bark", ModulePath: "foo.com/quux", Version: "v1.0.0", Importers: 10},
+	{PackagePath: "github.com/something/foo", ModulePath: "github.com/something", Version: "v2.0.0", Importers: 80},
+	{PackagePath: "example.com/zero", ModulePath: "example.com/zero", Version: "v0.1.0", Importers: 0},
+}
+
+// testCompleter checks the completions of c, which holds testPartials.
+func testCompleter(t *testing.T, c Completer) {
+	t.Helper()
+	for _, test := range []struct {
+		q    string
+		want []string
+	}{
+		{"baz", []string{"foo.com/bar/baz"}},
+		{"bar", []string{"foo.com/bar/baz", "foo.com/quux/bark"}},
+		{"foo", []string{"github.com/something/foo", "foo.com/bar/baz"}},
+		{"zer", []string{"example.com/zero"}},
+		{"nothing", nil},
+	} {
+		results, err := c.Complete(context.Background(), test.q, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, res := range results {
+			got = append(got, res.PackagePath)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("Complete(%q) mismatch (-want +got)\n%s", test.q, diff)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	testCompleter(t, rankCompleter{})
+}
+
+// rankCompleter completes by ranking all of testPartials.
+type rankCompleter struct{}
+
+func (rankCompleter) Complete(ctx context.Context, q string, maxResults int) ([]*Completion, error) {
+	return Rank(testPartials, q, maxResults), nil
+}
+
+func TestMemoryCompleter(t *testing.T) {
+	m := NewMemoryCompleter()
+	load := func(add func(Completion)) error {
+		for _, p := range testPartials {
+			add(p)
+		}
+		return nil
+	}
+	if err := m.Load(load); err != nil {
+		t.Fatal(err)
+	}
+	testCompleter(t, m)
+
+	// A failed load keeps the old completions.
+	if err := m.Load(func(func(Completion)) error { return errors.New("bad") }); err == nil {
+		t.Fatal("Load succeeded, want error")
+	}
+	testCompleter(t, m)
+}
+
+func TestMemoryCompleterPastMaxDepth(t *testing.T) {
+	partials := []Completion{
+		{PackagePath: "github.com/averyveryverylongname/x", ModulePath: "github.com/averyveryverylongname", Version: "v1.0.0", Importers: 5},
+		{PackagePath: "github.com/averyveryverylongname/y", ModulePath: "github.com/averyveryverylongname", Version: "v1.0.0", Importers: 50},
+		{PackagePath: "github.com/averyveryverylongnamf", ModulePath: "github.com/averyveryverylongnamf", Version: "v1.0.0", Importers: 500},
+		{PackagePath: "github.com/a", ModulePath: "github.com/a", Version: "v1.0.0", Importers: 1},
+	}
+	m := NewMemoryCompleter()
+	if err := m.Load(func(add func(Completion)) error {
+		for _, p := range partials {
+			add(p)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Every prefix of every suffix, including those longer than
+	// maxTrieDepth, gets the same completions as ranking all of them.
+	for _, p := range partials {
+		for _, c := range PathCompletions(p) {
+			for i := 1; i <= len(c.Suffix); i++ {
+				q := c.Suffix[:i]
+				got, err := m.Complete(context.Background(), q, 3)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(Rank(partials, q, 3), got); diff != "" {
+					t.Errorf("Complete(%q) mismatch (-want +got)\n%s", q, diff)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package complete
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxTrieResults is the number of completions kept for each prefix by a
+// MemoryCompleter, and so the most it returns for a query.
+const maxTrieResults = 10
+
+// maxTrieDepth bounds the depth of the trie of a MemoryCompleter, and so the
+// number of nodes allocated for each completion. Each node at this depth
+// holds every completion below it instead of having children, and longer
+// queries are answered by filtering that list.
+const maxTrieDepth = 16
+
+// MemoryCompleter is a Completer that keeps a trie of path completions in
+// memory. Each node of the trie holds the best completions for its prefix,
+// so a query no longer than maxTrieDepth takes time proportional to its
+// length.
+type MemoryCompleter struct {
+	mu   sync.RWMutex
+	root *trieNode
+}
+
+type trieNode struct {
+	children map[byte]*trieNode
+	// best holds the completions with the highest score among those whose
+	// Suffix starts with the prefix of the node, best first.
+	best []scoredCompletion
+	// rest holds, at maxTrieDepth, the completions whose Suffix is longer
+	// than the prefix of the node, best first.
+	rest []scoredCompletion
+}
+
+type scoredCompletion struct {
+	c     *Completion
+	score int
+}
+
+// NewMemoryCompleter returns an empty MemoryCompleter.
+func NewMemoryCompleter() *MemoryCompleter {
+	return &MemoryCompleter{root: &trieNode{}}
+}
+
+// Load replaces the completions of m. It calls each with a function that
+// adds the path completions of a partial completion, as returned by
+// PathCompletions. m keeps serving the old completions until each returns
+// successfully.
+func (m *MemoryCompleter) Load(each func(add func(partial Completion)) error) error {
+	root := &trieNode{}
+	err := each(func(partial Completion) {
+		for _, c := range PathCompletions(partial) {
+			root.insert(c, Score(c))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	root.sortRest()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.root = root
+	return nil
+}
+
+// insert adds c, whose score is score, to n and its descendants along the
+// Suffix of c, down to maxTrieDepth.
+func (n *trieNode) insert(c *Completion, score int) {
+	for i := 0; ; i++ {
+		n.addBest(c, score)
+		if i == len(c.Suffix) {
+			return
+		}
+		if i == maxTrieDepth {
+			n.rest = append(n.rest, scoredCompletion{c, score})
+			return
+		}
+		if n.children == nil {
+			n.children = map[byte]*trieNode{}
+		}
+		child := n.children[c.Suffix[i]]
+		if child == nil {
+			child = &trieNode{}
+			n.children[c.Suffix[i]] = child
+		}
+		n = child
+	}
+}
+
+// sortRest sorts the rest of n and its descendants, best first.
+func (n *trieNode) sortRest() {
+	sort.SliceStable(n.rest, func(i, j int) bool {
+		return n.rest[i].score > n.rest[j].score
+	})
+	for _, child := range n.children {
+		child.sortRest()
+	}
+}
+
+// addBest adds c to n.best if it is among the maxTrieResults best.
+func (n *trieNode) addBest(c *Completion, score int) {
+	i := len(n.best)
+	for i > 0 && n.best[i-1].score < score {
+		i--
+	}
+	if i >= maxTrieResults {
+		return
+	}
+	if len(n.best) < maxTrieResults {
+		n.best = append(n.best, scoredCompletion{})
+	}
+	copy(n.best[i+1:], n.best[i:])
+	n.best[i] = scoredCompletion{c, score}
+}
+
+// Complete implements Completer.Complete. It returns at most maxTrieResults
+// completions.
+func (m *MemoryCompleter) Complete(ctx context.Context, q string, maxResults int) ([]*Completion, error) {
+	if q == "" {
+		return nil, nil
+	}
+	m.mu.RLock()
+	n := m.root
+	m.mu.RUnlock()
+	for i := 0; i < len(q) && i < maxTrieDepth && n != nil; i++ {
+		n = n.children[q[i]]
+	}
+	if n == nil {
+		return nil, nil
+	}
+	candidates := n.best
+	if len(q) > maxTrieDepth {
+		candidates = n.rest
+	}
+	if maxResults > maxTrieResults {
+		maxResults = maxTrieResults
+	}
+	var completions []*Completion
+	for _, sc := range candidates {
+		if len(completions) == maxResults {
+			break
+		}
+		if strings.HasPrefix(sc.c.Suffix, q) {
+			completions = append(completions, sc.c)
+		}
+	}
+	return completions, nil
+}
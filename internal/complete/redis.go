@@ -0,0 +1,115 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package complete
+
+import (
+	"context"
+	"sort"
+
+	"github.com/go-redis/redis/v7"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// RedisCompleter is a Completer that queries the redis sorted sets written
+// by the worker, which index package path suffixes.
+type RedisCompleter struct {
+	client *redis.Client
+}
+
+// NewRedisCompleter returns a RedisCompleter that uses client.
+func NewRedisCompleter(client *redis.Client) *RedisCompleter {
+	return &RedisCompleter{client: client}
+}
+
+// Complete executes the completion query against redis. This is inspired
+// by http://oldblog.antirez.com/post/autocomplete-with-redis.html, but
+// improved as follows:
+//  + Use ZRANGEBYLEX to avoid storing each possible prefix, since that was
+//    added to Redis since the original blog post.
+//  + Use an additional sorted set that holds popular packages, to improve
+//    completion relevancy.
+//
+// We autocomplete the query 'q' as follows
+//  1. Query for popular completions starting with q using ZRANGEBYLEX (more
+//     details on this below). We fetch an arbitrary number of results (1000)
+//     to bound the amount of work done by redis.
+//  2. Sort the returned completions by Score, and filter to the top
+//     maxResults.
+//  3. If we have maxResults results, we're done. Otherwise do (1) on the index
+//     of remaining (unpopular) package paths, add to our result set, and sort
+//     again (because unpopular packages might actually score higher than
+//     popular packages).
+func (r *RedisCompleter) Complete(ctx context.Context, q string, maxResults int) (_ []*Completion, err error) {
+	defer derrors.Wrap(&err, "RedisCompleter.Complete(%q, %d)", q, maxResults)
+	completions, err := r.completeWithIndex(ctx, q, PopularKey, maxResults)
+	if err != nil {
+		return nil, err
+	}
+	if len(completions) < maxResults {
+		unpopular, err := r.completeWithIndex(ctx, q, RemainingKey, maxResults-len(completions))
+		if err != nil {
+			return nil, err
+		}
+		completions = append(completions, unpopular...)
+		// Re-sort, as it is possible that an unpopular completion actually has a
+		// higher score than a popular completion due to the weighting for suffix
+		// length.
+		sort.Slice(completions, func(i, j int) bool {
+			return Score(completions[i]) > Score(completions[j])
+		})
+	}
+	return completions, nil
+}
+
+func (r *RedisCompleter) completeWithIndex(ctx context.Context, q, indexKey string, maxResults int) (_ []*Completion, err error) {
+	defer derrors.Wrap(&err, "completeWithIndex(%q, %q, %d)", q, indexKey, maxResults)
+
+	// Query for possible completions using ZRANGEBYLEX. See documentation at
+	// https://redis.io/commands/zrangebylex
+	// Notably, the "(" character in the Min and Max fields means 'exclude this
+	// endpoint'.
+	// We bound our search in two ways: (1) by setting Max to the smallest string
+	// that lexically greater than q but does not start with q, and (2) by
+	// setting an arbitrary limit of 1000 results.
+	entries, err := r.client.WithContext(ctx).ZRangeByLex(indexKey, &redis.ZRangeBy{
+		Min:   "(" + q,
+		Max:   "(" + nextPrefix(q),
+		Count: 1000,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	var completions []*Completion
+	for _, entry := range entries {
+		c, err := Decode(entry)
+		if err != nil {
+			return nil, err
+		}
+		completions = append(completions, c)
+	}
+	return best(completions, maxResults), nil
+}
+
+// nextPrefix returns the first string (according to lexical sorting) that is
+// greater than prefix but does not start with prefix.
+func nextPrefix(prefix string) string {
+	// redis strings are ASCII. Note that among printing ASCII characters '!' has
+	// the smallest byte value and '~' has the largest byte value. It also so
+	// happens that these are both valid characters in a URL.
+	if prefix == "" {
+		return ""
+	}
+	lastChar := prefix[len(prefix)-1]
+	if lastChar >= '~' {
+		// If the last character is '~', there is no greater ascii character so we
+		// must move to the previous character to find a lexically greater string
+		// that doesn't start with prefix. Note that in the degenerate case where
+		// prefix is nothing but twiddles (e.g. "~~~"), we will recurse until we return "",
+		// which is acceptable: there is no prefix that satisfies our requirements:
+		// all strings greater than "~~~" must also start with "~~~"
+		return nextPrefix(prefix[:len(prefix)-1])
+	}
+	return prefix[:len(prefix)-1] + string(lastChar+1)
+}
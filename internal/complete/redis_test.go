@@ -0,0 +1,51 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package complete
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+)
+
+func TestRedisCompleter(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	r := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	for _, partial := range testPartials {
+		completions := PathCompletions(partial)
+		var zs []*redis.Z
+		for _, cmpl := range completions {
+			zs = append(zs, &redis.Z{Member: cmpl.Encode()})
+		}
+		if partial.Importers > 0 {
+			r.ZAdd(PopularKey, zs...)
+		} else {
+			r.ZAdd(RemainingKey, zs...)
+		}
+	}
+	testCompleter(t, NewRedisCompleter(r))
+}
+
+func TestNextPrefix(t *testing.T) {
+	tests := []struct {
+		prefix, want string
+	}{
+		{"", ""},
+		{"~~~", ""},
+		{"aa", "ab"},
+		{"aB", "aC"},
+		{"a~", "b"},
+	}
+	for _, test := range tests {
+		if got := nextPrefix(test.prefix); got != test.want {
+			t.Errorf("nextPrefix(%q) = %q, want %q", test.prefix, got, test.want)
+		}
+	}
+}
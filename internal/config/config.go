@@ -18,6 +18,7 @@ import (
 	"log"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"strconv"
@@ -113,6 +114,41 @@ type Config struct {
 	// Discovery environment variables
 	ProxyURL, IndexURL string
 
+	// ProxyRoutes sends requests for some module paths to proxies other than
+	// ProxyURL. See proxy.ParseRoutes for the format.
+	ProxyRoutes string
+
+	// ProxyCacheDir, if set, is the directory in which responses from the
+	// proxy are cached. ProxyCacheMaxBytes bounds the size of the cache.
+	ProxyCacheDir      string
+	ProxyCacheMaxBytes int64
+
+	// StdlibSourceDir, if set, is a clone of the Go repository or a
+	// directory of Go source tarballs to read the standard library from,
+	// instead of cloning the Go repository. StdlibZipCacheDir, if set, is the
+	// directory in which the module zips built for the standard library are
+	// cached. See stdlib.UseLocalSource and stdlib.SetZipCacheDir.
+	StdlibSourceDir, StdlibZipCacheDir string
+
+	// SumDB describes the checksum database that module zips are verified
+	// against, in the format of GOSUMDB. Verification is off if it is empty
+	// or "off". NoSumDB lists module path patterns, in the format of
+	// GONOSUMDB, whose zips are not verified; like the go command, it
+	// defaults to GONOSUMDB, then GOPRIVATE.
+	SumDB, NoSumDB string
+
+	// ModuleListURL, if set, is a file or URL listing module versions for the
+	// worker to process, one MODULE_PATH@VERSION per line. WatchedPrefixes
+	// are module path prefixes; the worker lists the versions of the modules
+	// under them on the proxy. Both find modules that do not appear in the
+	// module index.
+	ModuleListURL   string
+	WatchedPrefixes []string
+
+	// WebhookSecret authenticates forge tag-push webhooks sent to the worker.
+	// The webhook endpoint is disabled if it is empty.
+	WebhookSecret string `json:"-"`
+
 	// Ports used for hosting. 'DebugPort' is used for serving HTTP debug pages.
 	Port, DebugPort string
 
@@ -136,6 +172,54 @@ type Config struct {
 	// IAP that is gating access to the worker.
 	QueueAudience string
 
+	// IAPAudience, if set, is the audience that the worker requires in the
+	// JWT assertion header set by the Identity-Aware Proxy.
+	IAPAudience string
+
+	// WorkerTaskQueue and FrontendTaskQueue are the IDs of the Cloud Tasks
+	// queues on which the worker and the frontend enqueue fetches, for lanes
+	// without their own queue.
+	WorkerTaskQueue, FrontendTaskQueue string
+
+	// WorkerLaneQueues and FrontendLaneQueues map fetch queue lanes to the
+	// IDs of the Cloud Tasks queues on which the worker and the frontend
+	// enqueue fetches in those lanes. Lanes without a queue use the server's
+	// default queue.
+	WorkerLaneQueues, FrontendLaneQueues map[string]string
+
+	// QueueLaneWeights maps each fetch queue lane to its share of the
+	// workers of the in-memory queue.
+	QueueLaneWeights map[string]int
+
+	// WorkerTimeout bounds the time the worker spends on a request.
+	WorkerTimeout time.Duration
+
+	// ProxyRemovedFile, if set, is a file listing module versions that are in
+	// the index but not on the proxy, one MODULE_PATH@VERSION per line. The
+	// worker does not process them.
+	ProxyRemovedFile string
+
+	// ExcludedFile, if set, is a file of prefixes to exclude, one "PREFIX
+	// REASON" per line, that the worker adds to the database at startup.
+	ExcludedFile string
+
+	// LargeModulesLimit is the number of modules with many packages that the
+	// worker requeues at a time. See postgres.DB.SetLargeModulesLimit.
+	LargeModulesLimit int
+
+	// MaxModuleZipSize, if positive, is the size in bytes of the largest
+	// module zip that is processed. MaxInFlightZipSize, if positive, bounds
+	// the total size of the zips processed at once. See fetch.SetZipLimits.
+	MaxModuleZipSize, MaxInFlightZipSize int64
+
+	// LocalExperimentsFile, if set, is a file of experiments, one
+	// "NAME,ROLLOUT" per line, for the frontend in direct proxy mode.
+	LocalExperimentsFile string
+
+	// ExperimentsFromConfig means that experiments are read from the dynamic
+	// config instead of from the database.
+	ExperimentsFromConfig bool
+
 	// GoogleTagManagerID is the ID used for GoogleTagManager. It has the
 	// structure GTM-XXXX.
 	GoogleTagManagerID string
@@ -160,10 +244,25 @@ type Config struct {
 	// Configuration for redis page cache.
 	RedisCacheHost, RedisCachePort string
 
+	// CacheBackend selects where the frontend caches pages and autocomplete
+	// results: "redis", "memory", "tiered" (memory in front of redis) or
+	// "none". If it is empty, redis is used if RedisCacheHost is set.
+	CacheBackend string
+
+	// CacheMemoryMaxBytes bounds the size of the in-memory page cache.
+	CacheMemoryMaxBytes int64
+
 	// Configuration for redis autocompletion. This is different from the page
 	// cache instance as it has different availability requirements.
 	RedisHAHost, RedisHAPort string
 
+	// CompletionBackend selects how the frontend autocompletes package paths:
+	// with the redis indexes written by the worker ("redis"), by querying
+	// the database ("postgres"), from an in-memory index loaded from the
+	// database ("memory"), or not at all ("none"). If it is empty, redis is
+	// used if RedisHAHost is set.
+	CompletionBackend string
+
 	// UseProfiler specifies whether to enable Stackdriver Profiler.
 	UseProfiler bool
 
@@ -173,10 +272,25 @@ type Config struct {
 	Teeproxy TeeproxySettings
 
 	// Minimum log level below which no logs will be printed.
-	// Possible values are [debug, info, error, fatal].
-	// In case of invalid/empty value, all logs will be printed.
+	// Possible values are [debug, info, warning, error, fatal].
+	// If it is empty, all logs will be printed.
 	LogLevel string
 
+	// LogFormat is the format of the logs written when not on GCP: "text"
+	// (the default) for plain text, or "json" for a JSON object per line.
+	LogFormat string
+
+	// LogSampling is a comma-separated list of rules for sampling log
+	// entries, like "info:0.1". See log.ParseSamplingRules.
+	LogSampling string
+
+	// OTLP configures exporting traces with the OpenTelemetry protocol.
+	OTLP OTLPSettings
+
+	// RetryPolicies maps each retry class to the policy for retrying module
+	// versions whose fetch failed with a status in that class.
+	RetryPolicies map[string]RetryPolicy
+
 	// DynamicConfigLocation is the location (either a file or gs://bucket/object) for
 	// dynamic configuration.
 	DynamicConfigLocation string
@@ -334,6 +448,27 @@ type QuotaSettings struct {
 	AuthValues []string
 }
 
+// OTLPSettings configures exporting traces to an OpenTelemetry collector.
+// They are read from the environment variables described at
+// https://opentelemetry.io/docs/reference/specification/protocol/exporter/.
+type OTLPSettings struct {
+	// TracesEndpoint is the URL to which traces are sent. If it is empty,
+	// traces are not exported with OTLP.
+	TracesEndpoint string
+
+	// Protocol is the protocol used to send traces: "http/protobuf", the
+	// default, or "http/json". Only OTLP over HTTP is supported, not "grpc".
+	Protocol string
+
+	// Headers are added to each request to the endpoint. They often hold
+	// credentials, so their values are redacted when dumped.
+	Headers map[string]string
+
+	// ServiceName identifies the process in traces. If it is empty, the
+	// application name is used.
+	ServiceName string
+}
+
 // TeeproxySettings contains the configuration values for the teeproxy. See
 // internal/teeproxy.Config to see what these values mean.
 type TeeproxySettings struct {
@@ -348,70 +483,123 @@ type TeeproxySettings struct {
 	MinTimeout       time.Duration
 	MaxTimeout       time.Duration
 	SuccsToGreen     int
+	SampleRate       float64
 }
 
 // Init resolves all configuration values provided by the config package. It
 // must be called before any configuration values are used.
 func Init(ctx context.Context) (_ *Config, err error) {
 	defer derrors.Add(&err, "config.Init(ctx)")
+	// If GO_DISCOVERY_CONFIG_FILE is set, it names a YAML file whose settings
+	// are used for the environment variables that are not set.
+	e := &environment{}
+	if filename := os.Getenv("GO_DISCOVERY_CONFIG_FILE"); filename != "" {
+		e.file, err = readConfigFile(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+	recordOnly := e.getBool("GO_DISCOVERY_QUOTA_RECORD_ONLY", true)
 	// Build a Config from the execution environment, loading some values
 	// from envvars and others from remote services.
 	cfg := &Config{
-		AuthValues: parseCommaList(os.Getenv("GO_DISCOVERY_AUTH_VALUES")),
-		IndexURL:   GetEnv("GO_MODULE_INDEX_URL", "https://index.golang.org/index"),
-		ProxyURL:   GetEnv("GO_MODULE_PROXY_URL", "https://proxy.golang.org"),
-		Port:       os.Getenv("PORT"),
-		DebugPort:  os.Getenv("DEBUG_PORT"),
+		AuthValues:         parseCommaList(e.get("GO_DISCOVERY_AUTH_VALUES", "")),
+		IndexURL:           e.get("GO_MODULE_INDEX_URL", "https://index.golang.org/index"),
+		ProxyURL:           e.get("GO_MODULE_PROXY_URL", "https://proxy.golang.org"),
+		ProxyRoutes:        e.get("GO_MODULE_PROXY_ROUTES", ""),
+		ProxyCacheDir:      e.get("GO_MODULE_PROXY_CACHE_DIR", ""),
+		ProxyCacheMaxBytes: int64(e.getInt("GO_MODULE_PROXY_CACHE_MAX_MB", 10*1024)) * 1024 * 1024,
+		StdlibSourceDir:    e.get("GO_DISCOVERY_STDLIB_SOURCE_DIR", ""),
+		StdlibZipCacheDir:  e.get("GO_DISCOVERY_STDLIB_ZIP_CACHE_DIR", ""),
+		SumDB:              e.get("GO_MODULE_SUMDB", ""),
+		NoSumDB:            e.get("GO_MODULE_NOSUMDB", e.get("GONOSUMDB", e.get("GOPRIVATE", ""))),
+		ModuleListURL:      e.get("GO_DISCOVERY_MODULE_LIST_URL", ""),
+		WatchedPrefixes:    parseCommaList(e.get("GO_DISCOVERY_WATCHED_PREFIXES", "")),
+		WebhookSecret:      e.get("GO_DISCOVERY_WEBHOOK_SECRET", ""),
+		Port:               e.get("PORT", ""),
+		DebugPort:          e.get("DEBUG_PORT", ""),
 		// Resolve AppEngine identifiers
-		ProjectID:          os.Getenv("GOOGLE_CLOUD_PROJECT"),
-		ServiceID:          GetEnv("GAE_SERVICE", os.Getenv("GO_DISCOVERY_SERVICE")),
-		VersionID:          GetEnv("GAE_VERSION", os.Getenv("DOCKER_IMAGE")),
-		InstanceID:         GetEnv("GAE_INSTANCE", os.Getenv("GO_DISCOVERY_INSTANCE")),
-		GoogleTagManagerID: os.Getenv("GO_DISCOVERY_GOOGLE_TAG_MANAGER_ID"),
-		QueueService:       os.Getenv("GO_DISCOVERY_QUEUE_SERVICE"),
-		QueueURL:           os.Getenv("GO_DISCOVERY_QUEUE_URL"),
-		QueueAudience:      os.Getenv("GO_DISCOVERY_QUEUE_AUDIENCE"),
+		ProjectID:          e.get("GOOGLE_CLOUD_PROJECT", ""),
+		ServiceID:          e.get("GAE_SERVICE", e.get("GO_DISCOVERY_SERVICE", "")),
+		VersionID:          e.get("GAE_VERSION", e.get("DOCKER_IMAGE", "")),
+		InstanceID:         e.get("GAE_INSTANCE", e.get("GO_DISCOVERY_INSTANCE", "")),
+		GoogleTagManagerID: e.get("GO_DISCOVERY_GOOGLE_TAG_MANAGER_ID", ""),
+		QueueService:       e.get("GO_DISCOVERY_QUEUE_SERVICE", ""),
+		QueueURL:           e.get("GO_DISCOVERY_QUEUE_URL", ""),
+		QueueAudience:      e.get("GO_DISCOVERY_QUEUE_AUDIENCE", ""),
+		IAPAudience:        e.get("GO_DISCOVERY_IAP_AUDIENCE", ""),
+		WorkerTaskQueue:    e.get("GO_DISCOVERY_WORKER_TASK_QUEUE", ""),
+		FrontendTaskQueue:  e.get("GO_DISCOVERY_FRONTEND_TASK_QUEUE", ""),
+		WorkerLaneQueues:   e.laneQueues("GO_DISCOVERY_WORKER_TASK_QUEUE"),
+		FrontendLaneQueues: e.laneQueues("GO_DISCOVERY_FRONTEND_TASK_QUEUE"),
+		QueueLaneWeights:   e.queueLaneWeights(),
+
+		WorkerTimeout:         time.Duration(e.getInt("GO_DISCOVERY_WORKER_TIMEOUT_MINUTES", 10)) * time.Minute,
+		ProxyRemovedFile:      e.get("GO_DISCOVERY_PROXY_REMOVED", ""),
+		ExcludedFile:          e.get("GO_DISCOVERY_EXCLUDED_FILENAME", ""),
+		LargeModulesLimit:     e.getInt("GO_DISCOVERY_LARGE_MODULES_LIMIT", 100),
+		MaxModuleZipSize:      int64(e.getInt("GO_DISCOVERY_MAX_MODULE_ZIP_MI", 0)) * 1024 * 1024,
+		MaxInFlightZipSize:    int64(e.getInt("GO_DISCOVERY_MAX_IN_FLIGHT_ZIP_MI", 0)) * 1024 * 1024,
+		LocalExperimentsFile:  e.get("GO_DISCOVERY_LOCAL_EXPERIMENTS", ""),
+		ExperimentsFromConfig: e.getBool("GO_DISCOVERY_EXPERIMENTS_FROM_CONFIG", false),
 
 		// LocationID is essentially hard-coded until we figure out a good way to
 		// determine it programmatically, but we check an environment variable in
 		// case it needs to be overridden.
-		LocationID: GetEnv("GO_DISCOVERY_GAE_LOCATION_ID", "us-central1"),
+		LocationID: e.get("GO_DISCOVERY_GAE_LOCATION_ID", "us-central1"),
 		// This fallback should only be used when developing locally.
 		FallbackVersionLabel: time.Now().Format(AppVersionFormat),
-		DBHost:               chooseOne(GetEnv("GO_DISCOVERY_DATABASE_HOST", "localhost")),
-		DBUser:               GetEnv("GO_DISCOVERY_DATABASE_USER", "postgres"),
-		DBPassword:           os.Getenv("GO_DISCOVERY_DATABASE_PASSWORD"),
-		DBSecondaryHost:      chooseOne(os.Getenv("GO_DISCOVERY_DATABASE_SECONDARY_HOST")),
-		DBPort:               GetEnv("GO_DISCOVERY_DATABASE_PORT", "5432"),
-		DBName:               GetEnv("GO_DISCOVERY_DATABASE_NAME", "discovery-db"),
-		DBSecret:             os.Getenv("GO_DISCOVERY_DATABASE_SECRET"),
-		RedisCacheHost:       os.Getenv("GO_DISCOVERY_REDIS_HOST"),
-		RedisCachePort:       GetEnv("GO_DISCOVERY_REDIS_PORT", "6379"),
-		RedisHAHost:          os.Getenv("GO_DISCOVERY_REDIS_HA_HOST"),
-		RedisHAPort:          GetEnv("GO_DISCOVERY_REDIS_HA_PORT", "6379"),
+		DBHost:               chooseOne(e.get("GO_DISCOVERY_DATABASE_HOST", "localhost")),
+		DBUser:               e.get("GO_DISCOVERY_DATABASE_USER", "postgres"),
+		DBPassword:           e.get("GO_DISCOVERY_DATABASE_PASSWORD", ""),
+		DBSecondaryHost:      chooseOne(e.get("GO_DISCOVERY_DATABASE_SECONDARY_HOST", "")),
+		DBPort:               e.get("GO_DISCOVERY_DATABASE_PORT", "5432"),
+		DBName:               e.get("GO_DISCOVERY_DATABASE_NAME", "discovery-db"),
+		DBSecret:             e.get("GO_DISCOVERY_DATABASE_SECRET", ""),
+		RedisCacheHost:       e.get("GO_DISCOVERY_REDIS_HOST", ""),
+		RedisCachePort:       e.get("GO_DISCOVERY_REDIS_PORT", "6379"),
+		CacheBackend:         e.get("GO_DISCOVERY_CACHE_BACKEND", ""),
+		CacheMemoryMaxBytes:  int64(e.getInt("GO_DISCOVERY_CACHE_MEMORY_MB", 256)) * 1024 * 1024,
+		RedisHAHost:          e.get("GO_DISCOVERY_REDIS_HA_HOST", ""),
+		RedisHAPort:          e.get("GO_DISCOVERY_REDIS_HA_PORT", "6379"),
+		CompletionBackend:    e.get("GO_DISCOVERY_COMPLETION_BACKEND", ""),
 		Quota: QuotaSettings{
-			QPS:        10,
-			Burst:      20,
-			MaxEntries: 1000,
-			RecordOnly: func() *bool { t := true; return &t }(),
-			AuthValues: parseCommaList(os.Getenv("GO_DISCOVERY_AUTH_VALUES")),
+			QPS:        e.getInt("GO_DISCOVERY_QUOTA_QPS", 10),
+			Burst:      e.getInt("GO_DISCOVERY_QUOTA_BURST", 20),
+			MaxEntries: e.getInt("GO_DISCOVERY_QUOTA_MAX_ENTRIES", 1000),
+			RecordOnly: &recordOnly,
+			AuthValues: parseCommaList(e.get("GO_DISCOVERY_AUTH_VALUES", "")),
 		},
-		UseProfiler: os.Getenv("GO_DISCOVERY_USE_PROFILER") == "TRUE",
+		UseProfiler: e.getBool("GO_DISCOVERY_USE_PROFILER", false),
 		Teeproxy: TeeproxySettings{
 			AuthKey:          BypassQuotaAuthHeader,
-			AuthValue:        os.Getenv("GO_DISCOVERY_TEEPROXY_AUTH_VALUE"),
-			Hosts:            parseCommaList(os.Getenv("GO_DISCOVERY_TEEPROXY_FORWARDED_HOSTS")),
-			Rate:             GetEnvFloat64("GO_DISCOVERY_TEEPROXY_RATE", 50),
-			Burst:            GetEnvInt("GO_DISCOVERY_TEEPROXY_BURST", 50),
-			FailsToRed:       GetEnvInt("GO_DISCOVERY_TEEPROXY_FAILS_TO_RED", 10),
-			FailureThreshold: GetEnvFloat64("GO_DISCOVERY_TEEPROXY_FAILURE_THRESHOLD", 0.5),
-			GreenInterval:    time.Duration(GetEnvInt("GO_DISCOVERY_TEEPROXY_GREEN_INTERVAL_SECONDS", 10)) * time.Second,
-			MinTimeout:       time.Duration(GetEnvInt("GO_DISCOVERY_TEEPROXY_MIN_TIMEOUT_SECONDS", 30)) * time.Second,
-			MaxTimeout:       time.Duration(GetEnvInt("GO_DISCOVERY_TEEPROXY_MAX_TIMEOUT_SECONDS", 240)) * time.Second,
-			SuccsToGreen:     GetEnvInt("GO_DISCOVERY_TEEPROXY_SUCCS_TO_GREEN", 20),
+			AuthValue:        e.get("GO_DISCOVERY_TEEPROXY_AUTH_VALUE", ""),
+			Hosts:            parseCommaList(e.get("GO_DISCOVERY_TEEPROXY_FORWARDED_HOSTS", "")),
+			Rate:             e.getFloat64("GO_DISCOVERY_TEEPROXY_RATE", 50),
+			Burst:            e.getInt("GO_DISCOVERY_TEEPROXY_BURST", 50),
+			FailsToRed:       e.getInt("GO_DISCOVERY_TEEPROXY_FAILS_TO_RED", 10),
+			FailureThreshold: e.getFloat64("GO_DISCOVERY_TEEPROXY_FAILURE_THRESHOLD", 0.5),
+			GreenInterval:    time.Duration(e.getInt("GO_DISCOVERY_TEEPROXY_GREEN_INTERVAL_SECONDS", 10)) * time.Second,
+			MinTimeout:       time.Duration(e.getInt("GO_DISCOVERY_TEEPROXY_MIN_TIMEOUT_SECONDS", 30)) * time.Second,
+			MaxTimeout:       time.Duration(e.getInt("GO_DISCOVERY_TEEPROXY_MAX_TIMEOUT_SECONDS", 240)) * time.Second,
+			SuccsToGreen:     e.getInt("GO_DISCOVERY_TEEPROXY_SUCCS_TO_GREEN", 20),
+			SampleRate:       e.getFloat64("GO_DISCOVERY_TEEPROXY_SAMPLE_RATE", 0),
 		},
-		LogLevel:   os.Getenv("GO_DISCOVERY_LOG_LEVEL"),
-		ServeStats: os.Getenv("GO_DISCOVERY_SERVE_STATS") == "true",
+		LogLevel:    e.get("GO_DISCOVERY_LOG_LEVEL", ""),
+		LogFormat:   e.get("GO_DISCOVERY_LOG_FORMAT", ""),
+		LogSampling: e.get("GO_DISCOVERY_LOG_SAMPLING", ""),
+		OTLP: OTLPSettings{
+			TracesEndpoint: e.otlpTracesEndpoint(),
+			Protocol:       e.get("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", e.get("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf")),
+			Headers:        parseHeaders(e.get("OTEL_EXPORTER_OTLP_TRACES_HEADERS", e.get("OTEL_EXPORTER_OTLP_HEADERS", ""))),
+			ServiceName:    e.get("OTEL_SERVICE_NAME", ""),
+		},
+		ServeStats:    e.getBool("GO_DISCOVERY_SERVE_STATS", false),
+		RetryPolicies: e.retryPolicies(),
+	}
+	cfg.validate(e)
+	if err := e.err(); err != nil {
+		return nil, err
 	}
 	bucket := os.Getenv("GO_DISCOVERY_CONFIG_BUCKET")
 	object := os.Getenv("GO_DISCOVERY_CONFIG_DYNAMIC")
@@ -498,6 +686,92 @@ func Init(ctx context.Context) (_ *Config, err error) {
 	return cfg, nil
 }
 
+// validate records the settings of c that are invalid in e, so that
+// misconfigurations are reported at startup rather than when the setting is
+// first used.
+func (c *Config) validate(e *environment) {
+	checkOneOf := func(key, val string, allowed ...string) {
+		for _, a := range allowed {
+			if val == a {
+				return
+			}
+		}
+		e.errorf("%s: %q is not one of %q", key, val, allowed)
+	}
+	checkPositive := func(key string, val int) {
+		if val <= 0 {
+			e.errorf("%s: %d is not positive", key, val)
+		}
+	}
+	checkFraction := func(key string, val float64) {
+		if val < 0 || val > 1 {
+			e.errorf("%s: %g is not between 0 and 1", key, val)
+		}
+	}
+	checkPort := func(key, val string) {
+		if val == "" {
+			return
+		}
+		if p, err := strconv.Atoi(val); err != nil || p <= 0 || p > 65535 {
+			e.errorf("%s: %q is not a port number", key, val)
+		}
+	}
+
+	checkURL := func(key, val string) {
+		if u, err := url.Parse(val); err != nil || u.Scheme == "" || u.Host == "" {
+			e.errorf("%s: %q is not an absolute URL", key, val)
+		}
+	}
+
+	checkPort("PORT", c.Port)
+	checkPort("DEBUG_PORT", c.DebugPort)
+	checkPort("GO_DISCOVERY_DATABASE_PORT", c.DBPort)
+	checkPort("GO_DISCOVERY_REDIS_PORT", c.RedisCachePort)
+	checkPort("GO_DISCOVERY_REDIS_HA_PORT", c.RedisHAPort)
+	checkURL("GO_MODULE_INDEX_URL", c.IndexURL)
+	if c.ProxyCacheDir != "" && c.ProxyCacheMaxBytes <= 0 {
+		e.errorf("GO_MODULE_PROXY_CACHE_MAX_MB: must be positive")
+	}
+
+	checkOneOf("GO_DISCOVERY_CACHE_BACKEND", c.CacheBackend, "", "none", "redis", "memory", "tiered")
+	if (c.CacheBackend == "redis" || c.CacheBackend == "tiered") && c.RedisCacheHost == "" {
+		e.errorf("GO_DISCOVERY_CACHE_BACKEND: %q requires GO_DISCOVERY_REDIS_HOST", c.CacheBackend)
+	}
+	if (c.CacheBackend == "memory" || c.CacheBackend == "tiered") && c.CacheMemoryMaxBytes <= 0 {
+		e.errorf("GO_DISCOVERY_CACHE_MEMORY_MB: must be positive")
+	}
+	checkOneOf("GO_DISCOVERY_COMPLETION_BACKEND", c.CompletionBackend, "", "none", "redis", "postgres", "memory")
+	if c.CompletionBackend == "redis" && c.RedisHAHost == "" {
+		e.errorf("GO_DISCOVERY_COMPLETION_BACKEND: %q requires GO_DISCOVERY_REDIS_HA_HOST", c.CompletionBackend)
+	}
+
+	checkPositive("GO_DISCOVERY_WORKER_TIMEOUT_MINUTES", int(c.WorkerTimeout/time.Minute))
+	checkPositive("GO_DISCOVERY_LARGE_MODULES_LIMIT", c.LargeModulesLimit)
+
+	checkPositive("GO_DISCOVERY_QUOTA_QPS", c.Quota.QPS)
+	checkPositive("GO_DISCOVERY_QUOTA_BURST", c.Quota.Burst)
+	checkPositive("GO_DISCOVERY_QUOTA_MAX_ENTRIES", c.Quota.MaxEntries)
+
+	t := c.Teeproxy
+	if t.Rate <= 0 {
+		e.errorf("GO_DISCOVERY_TEEPROXY_RATE: %g is not positive", t.Rate)
+	}
+	checkPositive("GO_DISCOVERY_TEEPROXY_BURST", t.Burst)
+	checkPositive("GO_DISCOVERY_TEEPROXY_FAILS_TO_RED", t.FailsToRed)
+	checkPositive("GO_DISCOVERY_TEEPROXY_SUCCS_TO_GREEN", t.SuccsToGreen)
+	checkFraction("GO_DISCOVERY_TEEPROXY_FAILURE_THRESHOLD", t.FailureThreshold)
+	checkFraction("GO_DISCOVERY_TEEPROXY_SAMPLE_RATE", t.SampleRate)
+	if t.MinTimeout > t.MaxTimeout {
+		e.errorf("GO_DISCOVERY_TEEPROXY_MIN_TIMEOUT_SECONDS: %s is greater than the maximum of %s", t.MinTimeout, t.MaxTimeout)
+	}
+
+	checkOneOf("GO_DISCOVERY_LOG_LEVEL", strings.ToLower(c.LogLevel), "", "debug", "info", "warning", "error", "fatal")
+	checkOneOf("GO_DISCOVERY_LOG_FORMAT", c.LogFormat, "", "text", "json")
+	if c.OTLP.TracesEndpoint != "" {
+		checkOneOf("OTEL_EXPORTER_OTLP_PROTOCOL", c.OTLP.Protocol, "http/protobuf", "http/json")
+	}
+}
+
 func readOverrideFile(ctx context.Context, bucketName, objName string) (_ []byte, err error) {
 	defer derrors.Wrap(&err, "readOverrideFile(ctx, %q)", objName)
 
@@ -550,12 +824,81 @@ func overrideBool(name string, field **bool, val *bool) {
 	}
 }
 
-// Dump outputs the current config information to the given Writer.
+// Dump outputs the current config information to the given Writer, with
+// secrets redacted.
 func (c *Config) Dump(w io.Writer) error {
 	fmt.Fprint(w, "config: ")
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "    ")
-	return enc.Encode(c)
+	return enc.Encode(c.redacted())
+}
+
+// redactedValue replaces secrets in the output of Dump.
+const redactedValue = "REDACTED"
+
+// redacted returns a copy of c with the values of secrets replaced by
+// redactedValue. Secrets that are not set are left empty, so the output
+// shows which ones are configured.
+func (c *Config) redacted() *Config {
+	r := *c
+	redact := func(s *string) {
+		if *s != "" {
+			*s = redactedValue
+		}
+	}
+	redactAll := func(ss []string) []string {
+		var rs []string
+		for range ss {
+			rs = append(rs, redactedValue)
+		}
+		return rs
+	}
+	// DBPassword and WebhookSecret are never encoded.
+	redact(&r.Teeproxy.AuthValue)
+	r.ProxyURL = redactUserinfo(c.ProxyURL)
+	r.ProxyRoutes = redactUserinfo(c.ProxyRoutes)
+	r.ModuleListURL = redactUserinfo(c.ModuleListURL)
+	r.AuthValues = redactAll(c.AuthValues)
+	r.Quota.AuthValues = redactAll(c.Quota.AuthValues)
+	if c.OTLP.Headers != nil {
+		r.OTLP.Headers = map[string]string{}
+		for k := range c.OTLP.Headers {
+			r.OTLP.Headers[k] = redactedValue
+		}
+	}
+	return &r
+}
+
+// redactUserinfo returns s, a URL or a list of URLs such as a GOPROXY list or
+// proxy routes, with the password in each URL redacted, or the username if
+// there is no password, since it may be a token.
+func redactUserinfo(s string) string {
+	isSep := func(r rune) bool { return strings.ContainsRune(",|;=", r) }
+	var b strings.Builder
+	for s != "" {
+		i := strings.IndexFunc(s, isSep)
+		if i < 0 {
+			i = len(s)
+		} else if i == 0 {
+			i = 1
+		}
+		tok := s[:i]
+		s = s[i:]
+		if strings.Contains(tok, "@") {
+			if u, err := url.Parse(tok); err != nil {
+				tok = redactedValue
+			} else if u.User != nil {
+				if _, ok := u.User.Password(); ok {
+					u.User = url.UserPassword(u.User.Username(), redactedValue)
+				} else {
+					u.User = url.User(redactedValue)
+				}
+				tok = u.String()
+			}
+		}
+		b.WriteString(tok)
+	}
+	return b.String()
 }
 
 // chooseOne selects one entry at random from a whitespace-separated
@@ -599,6 +942,36 @@ func gceMetadata(ctx context.Context, name string) (_ string, err error) {
 	return string(bytes), nil
 }
 
+// otlpTracesEndpoint returns the URL to which traces are sent with OTLP,
+// which is either given in full or derived from the collector's base URL.
+func (e *environment) otlpTracesEndpoint() string {
+	if u := e.get("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", ""); u != "" {
+		return u
+	}
+	if u := e.get("OTEL_EXPORTER_OTLP_ENDPOINT", ""); u != "" {
+		return strings.TrimSuffix(u, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// parseHeaders parses a comma-separated list of key=value pairs, as in the
+// OTEL_EXPORTER_OTLP_HEADERS environment variable. Values are URL-encoded.
+func parseHeaders(s string) map[string]string {
+	h := map[string]string{}
+	for _, p := range parseCommaList(s) {
+		i := strings.Index(p, "=")
+		if i <= 0 {
+			continue
+		}
+		v, err := url.QueryUnescape(strings.TrimSpace(p[i+1:]))
+		if err != nil {
+			v = p[i+1:]
+		}
+		h[strings.TrimSpace(p[:i])] = v
+	}
+	return h
+}
+
 func parseCommaList(s string) []string {
 	var a []string
 	for _, p := range strings.Split(s, ",") {
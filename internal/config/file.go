@@ -0,0 +1,276 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// fileConfig is the contents of a config file, named by the
+// GO_DISCOVERY_CONFIG_FILE environment variable.
+//
+// Each setting has an env tag naming the environment variable that overrides
+// it. A setting that is missing from the file is treated as if it were not in
+// the environment, so it gets the same default.
+type fileConfig struct {
+	Database struct {
+		Host          string `env:"GO_DISCOVERY_DATABASE_HOST"`
+		SecondaryHost string `env:"GO_DISCOVERY_DATABASE_SECONDARY_HOST"`
+		Port          string `env:"GO_DISCOVERY_DATABASE_PORT"`
+		User          string `env:"GO_DISCOVERY_DATABASE_USER"`
+		Password      string `env:"GO_DISCOVERY_DATABASE_PASSWORD"`
+		Name          string `env:"GO_DISCOVERY_DATABASE_NAME"`
+		Secret        string `env:"GO_DISCOVERY_DATABASE_SECRET"`
+	}
+	Redis struct {
+		CacheHost string `env:"GO_DISCOVERY_REDIS_HOST"`
+		CachePort string `env:"GO_DISCOVERY_REDIS_PORT"`
+		HAHost    string `env:"GO_DISCOVERY_REDIS_HA_HOST"`
+		HAPort    string `env:"GO_DISCOVERY_REDIS_HA_PORT"`
+	}
+	Proxy struct {
+		URL        string `env:"GO_MODULE_PROXY_URL"`
+		Routes     string `env:"GO_MODULE_PROXY_ROUTES"`
+		CacheDir   string `env:"GO_MODULE_PROXY_CACHE_DIR"`
+		CacheMaxMB *int   `env:"GO_MODULE_PROXY_CACHE_MAX_MB"`
+		SumDB      string `env:"GO_MODULE_SUMDB"`
+		NoSumDB    string `env:"GO_MODULE_NOSUMDB"`
+	}
+	Index struct {
+		URL             string   `env:"GO_MODULE_INDEX_URL"`
+		ModuleListURL   string   `env:"GO_DISCOVERY_MODULE_LIST_URL"`
+		WatchedPrefixes []string `env:"GO_DISCOVERY_WATCHED_PREFIXES"`
+	}
+	Queue struct {
+		Worker              string `env:"GO_DISCOVERY_WORKER_TASK_QUEUE"`
+		Frontend            string `env:"GO_DISCOVERY_FRONTEND_TASK_QUEUE"`
+		LaneWeights         string `env:"GO_DISCOVERY_QUEUE_LANE_WEIGHTS"`
+		WorkerInteractive   string `env:"GO_DISCOVERY_WORKER_TASK_QUEUE_INTERACTIVE"`
+		WorkerIndexPoll     string `env:"GO_DISCOVERY_WORKER_TASK_QUEUE_INDEX_POLL"`
+		WorkerReprocess     string `env:"GO_DISCOVERY_WORKER_TASK_QUEUE_REPROCESS"`
+		FrontendInteractive string `env:"GO_DISCOVERY_FRONTEND_TASK_QUEUE_INTERACTIVE"`
+		FrontendIndexPoll   string `env:"GO_DISCOVERY_FRONTEND_TASK_QUEUE_INDEX_POLL"`
+		FrontendReprocess   string `env:"GO_DISCOVERY_FRONTEND_TASK_QUEUE_REPROCESS"`
+	}
+	Quota struct {
+		QPS        *int     `env:"GO_DISCOVERY_QUOTA_QPS"`
+		Burst      *int     `env:"GO_DISCOVERY_QUOTA_BURST"`
+		MaxEntries *int     `env:"GO_DISCOVERY_QUOTA_MAX_ENTRIES"`
+		RecordOnly *bool    `env:"GO_DISCOVERY_QUOTA_RECORD_ONLY"`
+		AuthValues []string `env:"GO_DISCOVERY_AUTH_VALUES"`
+	}
+	Teeproxy struct {
+		AuthValue            string   `env:"GO_DISCOVERY_TEEPROXY_AUTH_VALUE"`
+		Hosts                []string `env:"GO_DISCOVERY_TEEPROXY_FORWARDED_HOSTS"`
+		Rate                 *float64 `env:"GO_DISCOVERY_TEEPROXY_RATE"`
+		Burst                *int     `env:"GO_DISCOVERY_TEEPROXY_BURST"`
+		FailsToRed           *int     `env:"GO_DISCOVERY_TEEPROXY_FAILS_TO_RED"`
+		FailureThreshold     *float64 `env:"GO_DISCOVERY_TEEPROXY_FAILURE_THRESHOLD"`
+		GreenIntervalSeconds *int     `env:"GO_DISCOVERY_TEEPROXY_GREEN_INTERVAL_SECONDS"`
+		MinTimeoutSeconds    *int     `env:"GO_DISCOVERY_TEEPROXY_MIN_TIMEOUT_SECONDS"`
+		MaxTimeoutSeconds    *int     `env:"GO_DISCOVERY_TEEPROXY_MAX_TIMEOUT_SECONDS"`
+		SuccsToGreen         *int     `env:"GO_DISCOVERY_TEEPROXY_SUCCS_TO_GREEN"`
+		SampleRate           *float64 `env:"GO_DISCOVERY_TEEPROXY_SAMPLE_RATE"`
+	}
+	Worker struct {
+		TimeoutMinutes    *int   `env:"GO_DISCOVERY_WORKER_TIMEOUT_MINUTES"`
+		IAPAudience       string `env:"GO_DISCOVERY_IAP_AUDIENCE"`
+		ProxyRemovedFile  string `env:"GO_DISCOVERY_PROXY_REMOVED"`
+		ExcludedFile      string `env:"GO_DISCOVERY_EXCLUDED_FILENAME"`
+		LargeModulesLimit *int   `env:"GO_DISCOVERY_LARGE_MODULES_LIMIT"`
+	}
+	Fetch struct {
+		MaxModuleZipMi   *int `env:"GO_DISCOVERY_MAX_MODULE_ZIP_MI"`
+		MaxInFlightZipMi *int `env:"GO_DISCOVERY_MAX_IN_FLIGHT_ZIP_MI"`
+	}
+	Features struct {
+		CacheBackend          string `env:"GO_DISCOVERY_CACHE_BACKEND"`
+		CacheMemoryMB         *int   `env:"GO_DISCOVERY_CACHE_MEMORY_MB"`
+		CompletionBackend     string `env:"GO_DISCOVERY_COMPLETION_BACKEND"`
+		StdlibSourceDir       string `env:"GO_DISCOVERY_STDLIB_SOURCE_DIR"`
+		StdlibZipCacheDir     string `env:"GO_DISCOVERY_STDLIB_ZIP_CACHE_DIR"`
+		WebhookSecret         string `env:"GO_DISCOVERY_WEBHOOK_SECRET"`
+		GoogleTagManagerID    string `env:"GO_DISCOVERY_GOOGLE_TAG_MANAGER_ID"`
+		UseProfiler           *bool  `env:"GO_DISCOVERY_USE_PROFILER"`
+		ServeStats            *bool  `env:"GO_DISCOVERY_SERVE_STATS"`
+		LocalExperimentsFile  string `env:"GO_DISCOVERY_LOCAL_EXPERIMENTS"`
+		ExperimentsFromConfig *bool  `env:"GO_DISCOVERY_EXPERIMENTS_FROM_CONFIG"`
+	}
+	Log struct {
+		Level    string `env:"GO_DISCOVERY_LOG_LEVEL"`
+		Format   string `env:"GO_DISCOVERY_LOG_FORMAT"`
+		Sampling string `env:"GO_DISCOVERY_LOG_SAMPLING"`
+	}
+	Retry struct {
+		Proxy         string `env:"GO_DISCOVERY_RETRY_POLICY_PROXY"`
+		DB            string `env:"GO_DISCOVERY_RETRY_POLICY_DB"`
+		InsertInvalid string `env:"GO_DISCOVERY_RETRY_POLICY_INSERT_INVALID"`
+		Incomplete    string `env:"GO_DISCOVERY_RETRY_POLICY_INCOMPLETE"`
+		TooLarge      string `env:"GO_DISCOVERY_RETRY_POLICY_TOO_LARGE"`
+	}
+	Server struct {
+		Port      string `env:"PORT"`
+		DebugPort string `env:"DEBUG_PORT"`
+	}
+}
+
+// readConfigFile reads the YAML config file at filename and returns its
+// settings, keyed by the environment variables that override them.
+// Keys that are not settings are an error.
+func readConfigFile(filename string) (_ map[string]string, err error) {
+	defer derrors.Wrap(&err, "readConfigFile(%q)", filename)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfigFile(data)
+}
+
+func parseConfigFile(data []byte) (map[string]string, error) {
+	j, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	var f fileConfig
+	if len(bytes.TrimSpace(j)) > 0 && string(j) != "null" {
+		dec := json.NewDecoder(bytes.NewReader(j))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			return nil, err
+		}
+	}
+	settings := map[string]string{}
+	flattenSettings(reflect.ValueOf(f), settings)
+	return settings, nil
+}
+
+// flattenSettings adds the settings in the struct v that are present to m,
+// formatted as they would be in the environment.
+func flattenSettings(v reflect.Value, m map[string]string) {
+	for i := 0; i < v.NumField(); i++ {
+		fv := v.Field(i)
+		key := v.Type().Field(i).Tag.Get("env")
+		if key == "" {
+			flattenSettings(fv, m)
+			continue
+		}
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		var s string
+		switch fv.Kind() {
+		case reflect.String:
+			s = fv.String()
+		case reflect.Int:
+			s = strconv.FormatInt(fv.Int(), 10)
+		case reflect.Float64:
+			s = strconv.FormatFloat(fv.Float(), 'g', -1, 64)
+		case reflect.Bool:
+			s = strconv.FormatBool(fv.Bool())
+		case reflect.Slice:
+			s = strings.Join(fv.Interface().([]string), ",")
+		default:
+			panic(fmt.Sprintf("config file setting %s has unsupported kind %s", key, fv.Kind()))
+		}
+		if s != "" {
+			m[key] = s
+		}
+	}
+}
+
+// An environment looks up settings in the process environment, falling back
+// to the settings from a config file. It records the settings whose values
+// cannot be parsed, so they can all be reported at once.
+type environment struct {
+	file map[string]string
+	errs []string
+}
+
+func (e *environment) lookup(key string) (string, bool) {
+	if v, ok := os.LookupEnv(key); ok {
+		return v, true
+	}
+	v, ok := e.file[key]
+	return v, ok
+}
+
+// get is like GetEnv.
+func (e *environment) get(key, fallback string) string {
+	if v, ok := e.lookup(key); ok {
+		return v
+	}
+	return fallback
+}
+
+// getInt is like GetEnvInt, but records bad values instead of terminating
+// the program.
+func (e *environment) getInt(key string, fallback int) int {
+	s, ok := e.lookup(key)
+	if !ok {
+		return fallback
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		e.errorf("%s: %q is not an integer", key, s)
+		return fallback
+	}
+	return v
+}
+
+// getFloat64 is like GetEnvFloat64, but records bad values instead of
+// ignoring them.
+func (e *environment) getFloat64(key string, fallback float64) float64 {
+	s, ok := e.lookup(key)
+	if !ok {
+		return fallback
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		e.errorf("%s: %q is not a number", key, s)
+		return fallback
+	}
+	return v
+}
+
+// getBool returns the boolean value of key, or fallback if it is not set or
+// is empty.
+func (e *environment) getBool(key string, fallback bool) bool {
+	s, ok := e.lookup(key)
+	if !ok || s == "" {
+		return fallback
+	}
+	v, err := strconv.ParseBool(strings.TrimSpace(s))
+	if err != nil {
+		e.errorf("%s: %q is not a boolean", key, s)
+		return fallback
+	}
+	return v
+}
+
+func (e *environment) errorf(format string, args ...interface{}) {
+	e.errs = append(e.errs, fmt.Sprintf(format, args...))
+}
+
+// err returns an error listing all the problems found, or nil if there are
+// none.
+func (e *environment) err() error {
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n\t%s", strings.Join(e.errs, "\n\t"))
+}
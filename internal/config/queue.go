@@ -0,0 +1,79 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Lanes of the fetch queue. Each lane holds the fetches of one priority; see
+// queue.Priority.
+const (
+	// QueueLaneInteractive is for fetches requested by a user.
+	QueueLaneInteractive = "interactive"
+	// QueueLaneIndexPoll is for fetches of module versions that are new to
+	// the module index.
+	QueueLaneIndexPoll = "index-poll"
+	// QueueLaneReprocess is for fetches of module versions that have been
+	// processed before.
+	QueueLaneReprocess = "reprocess"
+)
+
+// QueueLanes lists the fetch queue lanes, from highest to lowest priority.
+var QueueLanes = []string{QueueLaneInteractive, QueueLaneIndexPoll, QueueLaneReprocess}
+
+// DefaultQueueLaneWeights returns the share of the in-memory queue's workers
+// that each lane receives when none is configured.
+func DefaultQueueLaneWeights() map[string]int {
+	return map[string]int{
+		QueueLaneInteractive: 2,
+		QueueLaneIndexPoll:   1,
+		QueueLaneReprocess:   1,
+	}
+}
+
+// laneQueues returns the Cloud Tasks queue ID for each lane that has its own
+// queue. The queue ID for a lane is read from the setting prefix + "_" + the
+// upper-cased lane with "-" replaced by "_", for example
+// GO_DISCOVERY_WORKER_TASK_QUEUE_REPROCESS.
+func (e *environment) laneQueues(prefix string) map[string]string {
+	m := map[string]string{}
+	for _, l := range QueueLanes {
+		key := prefix + "_" + strings.ToUpper(strings.ReplaceAll(l, "-", "_"))
+		if q := e.get(key, ""); q != "" {
+			m[l] = q
+		}
+	}
+	return m
+}
+
+// queueLaneWeights returns the default lane weights, overridden by the
+// setting GO_DISCOVERY_QUEUE_LANE_WEIGHTS, a comma-separated list of
+// LANE=WEIGHT pairs such as "interactive=4,reprocess=1". Weights must be
+// positive integers.
+func (e *environment) queueLaneWeights() map[string]int {
+	const key = "GO_DISCOVERY_QUEUE_LANE_WEIGHTS"
+	ws := DefaultQueueLaneWeights()
+	for _, p := range parseCommaList(e.get(key, "")) {
+		i := strings.Index(p, "=")
+		if i < 0 {
+			e.errorf("%s: %q is not of the form LANE=WEIGHT", key, p)
+			continue
+		}
+		lane := strings.TrimSpace(p[:i])
+		if _, ok := ws[lane]; !ok {
+			e.errorf("%s: unknown lane %q", key, lane)
+			continue
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(p[i+1:]))
+		if err != nil || w <= 0 {
+			e.errorf("%s: weight of %s: %q is not a positive integer", key, lane, p[i+1:])
+			continue
+		}
+		ws[lane] = w
+	}
+	return ws
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A RetryPolicy determines how often a module version whose fetch failed is
+// fetched again.
+type RetryPolicy struct {
+	// MaxTries is the number of consecutive fetch attempts failing with the
+	// same status after which a module version is moved to the dead-letter
+	// state. Zero means that the module version is retried indefinitely.
+	MaxTries int
+	// InitialBackoff is the delay before the first retry. Each subsequent
+	// delay is twice the previous one, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func (p RetryPolicy) String() string {
+	return fmt.Sprintf("%d,%s,%s", p.MaxTries, p.InitialBackoff, p.MaxBackoff)
+}
+
+// ParseRetryPolicy parses a retry policy of the form
+// "MAX_TRIES,INITIAL_BACKOFF,MAX_BACKOFF", for example "10,1m,1h".
+func ParseRetryPolicy(s string) (_ RetryPolicy, err error) {
+	defer derrors.Wrap(&err, "ParseRetryPolicy(%q)", s)
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return RetryPolicy{}, fmt.Errorf("want three comma-separated fields, got %d: %w", len(parts), derrors.InvalidArgument)
+	}
+	var p RetryPolicy
+	p.MaxTries, err = strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return RetryPolicy{}, err
+	}
+	p.InitialBackoff, err = time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return RetryPolicy{}, err
+	}
+	p.MaxBackoff, err = time.ParseDuration(strings.TrimSpace(parts[2]))
+	if err != nil {
+		return RetryPolicy{}, err
+	}
+	if p.MaxTries < 0 || p.InitialBackoff <= 0 || p.MaxBackoff < p.InitialBackoff {
+		return RetryPolicy{}, fmt.Errorf("%s: %w", p, derrors.InvalidArgument)
+	}
+	return p, nil
+}
+
+// Classes of fetch statuses that can have a retry policy.
+const (
+	// RetryClassProxy covers server errors and timeouts from the module proxy.
+	RetryClassProxy = "proxy"
+	// RetryClassDB covers internal errors, such as failures to insert a
+	// module into the database.
+	RetryClassDB = "db"
+	// RetryClassInsertInvalid covers modules that were fetched but could not
+	// be inserted into the database because they were invalid.
+	RetryClassInsertInvalid = "insert-invalid"
+	// RetryClassIncomplete covers modules that were processed but have
+	// packages that could not be processed.
+	RetryClassIncomplete = "incomplete"
+	// RetryClassTooLarge covers modules that were too large to process.
+	RetryClassTooLarge = "too-large"
+)
+
+// RetryClasses lists the retry classes, in display order.
+var RetryClasses = []string{RetryClassProxy, RetryClassDB, RetryClassInsertInvalid, RetryClassIncomplete, RetryClassTooLarge}
+
+// DefaultRetryPolicies returns the retry policies used when none are
+// configured. Invalid modules, modules with incomplete packages and modules
+// that are too large are not retried by default; they wait to be reprocessed.
+func DefaultRetryPolicies() map[string]RetryPolicy {
+	return map[string]RetryPolicy{
+		RetryClassProxy: {MaxTries: 10, InitialBackoff: time.Minute, MaxBackoff: time.Hour},
+		RetryClassDB:    {MaxTries: 5, InitialBackoff: time.Minute, MaxBackoff: time.Hour},
+	}
+}
+
+// retryPolicies returns the default retry policies, overridden by the
+// settings GO_DISCOVERY_RETRY_POLICY_<CLASS>, where <CLASS> is the upper-cased
+// retry class with "-" replaced by "_". The value is either a policy in the
+// format accepted by ParseRetryPolicy or "none" to remove the policy for the
+// class.
+func (e *environment) retryPolicies() map[string]RetryPolicy {
+	ps := DefaultRetryPolicies()
+	for _, c := range RetryClasses {
+		key := "GO_DISCOVERY_RETRY_POLICY_" + strings.ToUpper(strings.ReplaceAll(c, "-", "_"))
+		switch s := e.get(key, ""); s {
+		case "":
+		case "none":
+			delete(ps, c)
+		default:
+			p, err := ParseRetryPolicy(s)
+			if err != nil {
+				e.errorf("%s: %v", key, err)
+				continue
+			}
+			ps[c] = p
+		}
+	}
+	return ps
+}
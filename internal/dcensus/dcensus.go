@@ -75,23 +75,77 @@ const debugPage = `
 <html>
 <p><a href="/tracez">/tracez</a> - trace spans</p>
 <p><a href="/statsz">/statz</a> - prometheus metrics page</p>
+<p><a href="/metrics">/metrics</a> - prometheus metrics page, for scraping</p>
 `
 
 // Init configures tracing and aggregation according to the given Views. If
-// running on GCP, Init also configures exporting to StackDriver.
+// running on GCP, Init also configures exporting to StackDriver. If an OTLP
+// endpoint is configured, Init also configures exporting traces to it.
+//
+// Metrics are always served to Prometheus, which keeps a time series for
+// every combination of tag values, so the unbounded tag keys, those created
+// with NewUnboundedKey, are dropped from the views.
 func Init(cfg *config.Config, views ...*view.View) error {
 	// The default trace sampler samples with probability 1e-4. That's too
 	// infrequent for our traffic levels. In the future we may want to decrease
 	// this sampling rate.
 	trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(0.01)})
+	views = boundViews(views)
 	if err := view.Register(views...); err != nil {
 		return fmt.Errorf("dcensus.Init(views): view.Register: %v", err)
 	}
-	exportToStackdriver(context.Background(), cfg)
+	ctx := context.Background()
+	exportToStackdriver(ctx, cfg)
+	if cfg.OTLP.TracesEndpoint != "" {
+		e, err := newOTLPExporter(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("dcensus.Init: %v", err)
+		}
+		trace.RegisterExporter(e)
+		log.Infof(ctx, "exporting traces to %s", cfg.OTLP.TracesEndpoint)
+	}
 	return nil
 }
 
-// NewServer creates a new http.Handler for serving debug information.
+// unboundedKeys holds the tag keys whose values are unbounded, like the
+// paths of modules and packages or of request URLs. Using them as metric
+// labels would create a time series for every value.
+var unboundedKeys = map[tag.Key]bool{
+	ochttp.Path: true,
+}
+
+// NewUnboundedKey returns the tag key with the given name, like
+// tag.MustNewKey, and records that its values are unbounded, so that Init
+// drops it from views. It must be called during package initialization.
+func NewUnboundedKey(name string) tag.Key {
+	k := tag.MustNewKey(name)
+	unboundedKeys[k] = true
+	return k
+}
+
+// boundViews returns views, with the tag keys in unboundedKeys removed. Views that have such keys are copied, rather than modified.
+func boundViews(views []*view.View) []*view.View {
+	var bounded []*view.View
+	for _, v := range views {
+		var keys []tag.Key
+		for _, k := range v.TagKeys {
+			if !unboundedKeys[k] {
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) != len(v.TagKeys) {
+			bv := *v
+			bv.TagKeys = keys
+			v = &bv
+		}
+		bounded = append(bounded, v)
+	}
+	return bounded
+}
+
+// NewServer creates a new http.Handler for serving debug information. It
+// also serves metrics for all registered views at /metrics, in the format
+// that Prometheus scrapes.
 func NewServer() (http.Handler, error) {
 	pe, err := prometheus.NewExporter(prometheus.Options{})
 	if err != nil {
@@ -100,6 +154,7 @@ func NewServer() (http.Handler, error) {
 	mux := http.NewServeMux()
 	zpages.Handle(mux, "/")
 	mux.Handle("/statsz", pe)
+	mux.Handle("/metrics", pe)
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, debugPage)
 	})
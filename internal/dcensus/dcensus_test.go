@@ -13,6 +13,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 )
 
 func TestRouter(t *testing.T) {
@@ -63,3 +64,28 @@ func TestRouter(t *testing.T) {
 		t.Errorf("unexpected route tag counts (-want +got):\n%s", diff)
 	}
 }
+
+// keyTestPath is an unbounded key for TestBoundViews.
+var keyTestPath = NewUnboundedKey("test.path")
+
+func TestBoundViews(t *testing.T) {
+	// Keys are only dropped if they are marked unbounded, whatever their
+	// name.
+	keyModulePath := tag.MustNewKey("module_path")
+	keyStatus := tag.MustNewKey("status")
+	v := &view.View{
+		Name:    "test/bound",
+		TagKeys: []tag.Key{keyStatus, keyModulePath, keyTestPath, ochttp.Path, ochttp.KeyServerRoute},
+	}
+	got := boundViews([]*view.View{v, ServerLatency})
+	want := []tag.Key{keyStatus, keyModulePath, ochttp.KeyServerRoute}
+	if diff := cmp.Diff(want, got[0].TagKeys, cmp.Comparer(func(a, b tag.Key) bool { return a == b })); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if len(v.TagKeys) != 5 {
+		t.Errorf("view was modified: got %d keys, want 5", len(v.TagKeys))
+	}
+	if got[1] != ServerLatency {
+		t.Error("view without unbounded keys was copied")
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dcensus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+const (
+	// otlpBatchSize is the number of spans after which an otlpExporter sends
+	// them without waiting for otlpFlushInterval.
+	otlpBatchSize = 512
+
+	// otlpFlushInterval is how often an otlpExporter sends the spans it has.
+	otlpFlushInterval = 5 * time.Second
+)
+
+// An otlpExporter is a trace.Exporter that sends spans to an OpenTelemetry
+// collector, using OTLP over HTTP with the binary protobuf or the JSON
+// encoding, as described at
+// https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/protocol/otlp.md.
+type otlpExporter struct {
+	endpoint string
+	protocol string // "http/protobuf" or "http/json"
+	headers  map[string]string
+	resource otlpResource
+	client   *http.Client
+
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+// newOTLPExporter returns an otlpExporter configured by cfg. It sends spans
+// every otlpFlushInterval until ctx is done.
+func newOTLPExporter(ctx context.Context, cfg *config.Config) (_ *otlpExporter, err error) {
+	defer derrors.Wrap(&err, "newOTLPExporter(%q)", cfg.OTLP.TracesEndpoint)
+	if cfg.OTLP.Protocol != "http/protobuf" && cfg.OTLP.Protocol != "http/json" {
+		return nil, fmt.Errorf("unsupported OTLP protocol %q; only http/protobuf and http/json are supported", cfg.OTLP.Protocol)
+	}
+	service := cfg.OTLP.ServiceName
+	if service == "" {
+		service = cfg.Application()
+	}
+	e := &otlpExporter{
+		endpoint: cfg.OTLP.TracesEndpoint,
+		protocol: cfg.OTLP.Protocol,
+		headers:  cfg.OTLP.Headers,
+		resource: otlpResource{Attributes: []otlpAttribute{
+			otlpAttr("service.name", service),
+			otlpAttr("service.version", cfg.AppVersionLabel()),
+			otlpAttr("deployment.environment", cfg.DeploymentEnvironment()),
+		}},
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	go func() {
+		ticker := time.NewTicker(otlpFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.Flush(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return e, nil
+}
+
+// ExportSpan implements the trace.Exporter interface.
+func (e *otlpExporter) ExportSpan(s *trace.SpanData) {
+	e.mu.Lock()
+	e.spans = append(e.spans, s)
+	full := len(e.spans) >= otlpBatchSize
+	e.mu.Unlock()
+	if full {
+		go e.Flush(context.Background())
+	}
+}
+
+// Flush sends the spans that have been exported since the last call.
+func (e *otlpExporter) Flush(ctx context.Context) {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	if err := e.send(ctx, spans); err != nil {
+		log.Errorf(ctx, "OTLP trace exporter: %v", err)
+	}
+}
+
+func (e *otlpExporter) send(ctx context.Context, spans []*trace.SpanData) (err error) {
+	defer derrors.Wrap(&err, "send(%d spans)", len(spans))
+	var (
+		body        []byte
+		contentType string
+	)
+	if e.protocol == "http/json" {
+		body, err = json.Marshal(e.request(spans))
+		if err != nil {
+			return err
+		}
+		contentType = "application/json"
+	} else {
+		body = e.request(spans).marshalProto()
+		contentType = "application/x-protobuf"
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// The types below are the parts of the OTLP trace request that spans from
+// OpenCensus can fill in. Following the JSON encoding of OTLP, IDs are hex
+// strings and 64-bit integers are decimal strings. See otlpproto.go for the
+// protobuf encoding.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Events            []otlpEvent     `json:"events,omitempty"`
+		Links             []otlpLink      `json:"links,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+
+	otlpEvent struct {
+		TimeUnixNano string          `json:"timeUnixNano"`
+		Name         string          `json:"name"`
+		Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	}
+
+	otlpLink struct {
+		TraceID    string          `json:"traceId"`
+		SpanID     string          `json:"spanId"`
+		Attributes []otlpAttribute `json:"attributes,omitempty"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+// OTLP span kinds and status codes.
+const (
+	otlpKindInternal = 1
+	otlpKindServer   = 2
+	otlpKindClient   = 3
+
+	otlpStatusError = 2
+)
+
+func (e *otlpExporter) request(spans []*trace.SpanData) *otlpRequest {
+	ss := otlpScopeSpans{Scope: otlpScope{Name: "go.opencensus.io"}}
+	for _, s := range spans {
+		ss.Spans = append(ss.Spans, otlpSpanFor(s))
+	}
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   e.resource,
+		ScopeSpans: []otlpScopeSpans{ss},
+	}}}
+}
+
+func otlpSpanFor(s *trace.SpanData) otlpSpan {
+	span := otlpSpan{
+		TraceID:           s.TraceID.String(),
+		SpanID:            s.SpanID.String(),
+		Name:              s.Name,
+		Kind:              otlpKindInternal,
+		StartTimeUnixNano: otlpTime(s.StartTime),
+		EndTimeUnixNano:   otlpTime(s.EndTime),
+		Attributes:        otlpAttributes(s.Attributes),
+	}
+	if s.ParentSpanID != (trace.SpanID{}) {
+		span.ParentSpanID = s.ParentSpanID.String()
+	}
+	switch s.SpanKind {
+	case trace.SpanKindServer:
+		span.Kind = otlpKindServer
+	case trace.SpanKindClient:
+		span.Kind = otlpKindClient
+	}
+	for _, a := range s.Annotations {
+		span.Events = append(span.Events, otlpEvent{
+			TimeUnixNano: otlpTime(a.Time),
+			Name:         a.Message,
+			Attributes:   otlpAttributes(a.Attributes),
+		})
+	}
+	for _, l := range s.Links {
+		span.Links = append(span.Links, otlpLink{
+			TraceID:    l.TraceID.String(),
+			SpanID:     l.SpanID.String(),
+			Attributes: otlpAttributes(l.Attributes),
+		})
+	}
+	// OpenCensus status codes are gRPC codes, where 0 is OK.
+	if s.Status.Code != 0 {
+		span.Status = otlpStatus{Code: otlpStatusError, Message: s.Status.Message}
+	}
+	return span
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpAttributes(m map[string]interface{}) []otlpAttribute {
+	var attrs []otlpAttribute
+	for k, v := range m {
+		var val otlpValue
+		switch v := v.(type) {
+		case string:
+			val.StringValue = &v
+		case bool:
+			val.BoolValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			val.IntValue = &s
+		case float64:
+			val.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			val.StringValue = &s
+		}
+		attrs = append(attrs, otlpAttribute{Key: k, Value: val})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+}
+
+func otlpAttr(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
@@ -0,0 +1,205 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dcensus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/trace"
+	"golang.org/x/pkgsite/internal/config"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	var (
+		got    map[string]interface{}
+		apiKey string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("api-key")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e, err := newOTLPExporter(ctx, &config.Config{
+		OTLP: config.OTLPSettings{
+			TracesEndpoint: ts.URL + "/v1/traces",
+			Protocol:       "http/json",
+			Headers:        map[string]string{"api-key": "secret"},
+			ServiceName:    "frontend",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1600000000, 0)
+	e.ExportSpan(&trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			SpanID:  trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		},
+		SpanKind:   trace.SpanKindServer,
+		Name:       "/search",
+		StartTime:  start,
+		EndTime:    start.Add(time.Second),
+		Attributes: map[string]interface{}{"http.status_code": int64(500), "cached": false},
+		Status:     trace.Status{Code: 2, Message: "unknown"},
+	})
+	e.Flush(ctx)
+
+	if apiKey != "secret" {
+		t.Errorf("api-key header: got %q, want %q", apiKey, "secret")
+	}
+	rs := got["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	span := rs["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0]
+	want := map[string]interface{}{
+		"traceId":           "0102030405060708090a0b0c0d0e0f10",
+		"spanId":            "0102030405060708",
+		"name":              "/search",
+		"kind":              2.0,
+		"startTimeUnixNano": "1600000000000000000",
+		"endTimeUnixNano":   "1600000001000000000",
+		"attributes": []interface{}{
+			map[string]interface{}{"key": "cached", "value": map[string]interface{}{"boolValue": false}},
+			map[string]interface{}{"key": "http.status_code", "value": map[string]interface{}{"intValue": "500"}},
+		},
+		"status": map[string]interface{}{"code": 2.0, "message": "unknown"},
+	}
+	if diff := cmp.Diff(want, span); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	service := rs["resource"].(map[string]interface{})["attributes"].([]interface{})[0]
+	if diff := cmp.Diff(map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "frontend"}}, service); diff != "" {
+		t.Errorf("service.name mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestOTLPExporterProtocol(t *testing.T) {
+	_, err := newOTLPExporter(context.Background(), &config.Config{
+		OTLP: config.OTLPSettings{TracesEndpoint: "http://localhost:4318/v1/traces", Protocol: "grpc"},
+	})
+	if err == nil {
+		t.Error("got nil error, want error for unsupported protocol")
+	}
+}
+
+func TestOTLPExporterProtobuf(t *testing.T) {
+	var (
+		got         []byte
+		contentType string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		var err error
+		got, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e, err := newOTLPExporter(ctx, &config.Config{
+		OTLP: config.OTLPSettings{
+			TracesEndpoint: ts.URL + "/v1/traces",
+			Protocol:       "http/protobuf",
+			ServiceName:    "frontend",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1600000000, 0)
+	e.ExportSpan(&trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			SpanID:  trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		},
+		SpanKind:   trace.SpanKindServer,
+		Name:       "/search",
+		StartTime:  start,
+		EndTime:    start.Add(time.Second),
+		Attributes: map[string]interface{}{"http.status_code": int64(500)},
+		Status:     trace.Status{Code: 2, Message: "unknown"},
+	})
+	e.Flush(ctx)
+
+	if contentType != "application/x-protobuf" {
+		t.Errorf("Content-Type: got %q, want application/x-protobuf", contentType)
+	}
+	// ExportTraceServiceRequest.resource_spans.scope_spans.spans
+	span := protoField(t, got, 1, 2, 2)
+	for _, test := range []struct {
+		path []protowire.Number
+		want []byte
+	}{
+		{[]protowire.Number{1}, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}},
+		{[]protowire.Number{2}, []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{[]protowire.Number{5}, []byte("/search")},
+		{[]protowire.Number{6}, protowire.AppendVarint(nil, otlpKindServer)},
+		{[]protowire.Number{7}, protowire.AppendFixed64(nil, uint64(start.UnixNano()))},
+		{[]protowire.Number{9, 1}, []byte("http.status_code")},
+		{[]protowire.Number{9, 2, 3}, protowire.AppendVarint(nil, 500)},
+		{[]protowire.Number{15, 2}, []byte("unknown")},
+		{[]protowire.Number{15, 3}, protowire.AppendVarint(nil, otlpStatusError)},
+	} {
+		if got := protoField(t, span, test.path...); !bytes.Equal(got, test.want) {
+			t.Errorf("span field %v: got %v, want %v", test.path, got, test.want)
+		}
+	}
+	// resource_spans.resource.attributes.value.string_value
+	if got := protoField(t, got, 1, 1, 1, 2, 1); string(got) != "frontend" {
+		t.Errorf("service.name: got %q, want %q", got, "frontend")
+	}
+}
+
+// protoField returns the contents of the first field with the number path[0]
+// in the protobuf message b, then of the first field path[1] in that, and so
+// on. Varint and fixed64 fields are returned in their encoded form.
+func protoField(t *testing.T, b []byte, path ...protowire.Number) []byte {
+	t.Helper()
+	for _, want := range path {
+		found := false
+		for len(b) > 0 && !found {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				t.Fatalf("bad tag: %v", protowire.ParseError(n))
+			}
+			b = b[n:]
+			m := protowire.ConsumeFieldValue(num, typ, b)
+			if m < 0 {
+				t.Fatalf("bad field %d: %v", num, protowire.ParseError(m))
+			}
+			if num == want {
+				found = true
+				if typ == protowire.BytesType {
+					v, _ := protowire.ConsumeBytes(b)
+					b = v
+					continue
+				}
+				b = b[:m]
+				continue
+			}
+			b = b[m:]
+		}
+		if !found {
+			t.Fatalf("no field %d on path %v", want, path)
+		}
+	}
+	return b
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dcensus
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// This file encodes an otlpRequest in the binary protobuf encoding of OTLP,
+// as an ExportTraceServiceRequest. The field numbers are those of
+// opentelemetry/proto/collector/trace/v1/trace_service.proto and the files it
+// imports. The otlp types hold IDs and 64-bit integers as strings, following
+// the JSON encoding, so they are converted back here.
+
+// marshalProto returns the protobuf encoding of r.
+func (r *otlpRequest) marshalProto() []byte {
+	var b []byte
+	for _, rs := range r.ResourceSpans {
+		b = appendMessage(b, 1, rs.appendProto(nil))
+	}
+	return b
+}
+
+func (rs *otlpResourceSpans) appendProto(b []byte) []byte {
+	b = appendMessage(b, 1, appendAttributes(nil, 1, rs.Resource.Attributes))
+	for _, ss := range rs.ScopeSpans {
+		b = appendMessage(b, 2, ss.appendProto(nil))
+	}
+	return b
+}
+
+func (ss *otlpScopeSpans) appendProto(b []byte) []byte {
+	b = appendMessage(b, 1, appendString(nil, 1, ss.Scope.Name))
+	for _, s := range ss.Spans {
+		b = appendMessage(b, 2, s.appendProto(nil))
+	}
+	return b
+}
+
+func (s *otlpSpan) appendProto(b []byte) []byte {
+	b = appendHexID(b, 1, s.TraceID)
+	b = appendHexID(b, 2, s.SpanID)
+	b = appendHexID(b, 4, s.ParentSpanID)
+	b = appendString(b, 5, s.Name)
+	b = appendVarint(b, 6, uint64(s.Kind))
+	b = appendTime(b, 7, s.StartTimeUnixNano)
+	b = appendTime(b, 8, s.EndTimeUnixNano)
+	b = appendAttributes(b, 9, s.Attributes)
+	for _, e := range s.Events {
+		eb := appendTime(nil, 1, e.TimeUnixNano)
+		eb = appendString(eb, 2, e.Name)
+		eb = appendAttributes(eb, 3, e.Attributes)
+		b = appendMessage(b, 11, eb)
+	}
+	for _, l := range s.Links {
+		lb := appendHexID(nil, 1, l.TraceID)
+		lb = appendHexID(lb, 2, l.SpanID)
+		lb = appendAttributes(lb, 4, l.Attributes)
+		b = appendMessage(b, 13, lb)
+	}
+	sb := appendString(nil, 2, s.Status.Message)
+	sb = appendVarint(sb, 3, uint64(s.Status.Code))
+	return appendMessage(b, 15, sb)
+}
+
+// appendAttributes appends attrs as repeated KeyValue field num.
+func appendAttributes(b []byte, num protowire.Number, attrs []otlpAttribute) []byte {
+	for _, a := range attrs {
+		var vb []byte
+		switch v := a.Value; {
+		case v.StringValue != nil:
+			vb = protowire.AppendTag(vb, 1, protowire.BytesType)
+			vb = protowire.AppendString(vb, *v.StringValue)
+		case v.BoolValue != nil:
+			vb = protowire.AppendTag(vb, 2, protowire.VarintType)
+			vb = protowire.AppendVarint(vb, protowire.EncodeBool(*v.BoolValue))
+		case v.IntValue != nil:
+			i, _ := strconv.ParseInt(*v.IntValue, 10, 64)
+			vb = protowire.AppendTag(vb, 3, protowire.VarintType)
+			vb = protowire.AppendVarint(vb, uint64(i))
+		case v.DoubleValue != nil:
+			vb = protowire.AppendTag(vb, 4, protowire.Fixed64Type)
+			vb = protowire.AppendFixed64(vb, math.Float64bits(*v.DoubleValue))
+		}
+		kv := appendString(nil, 1, a.Key)
+		kv = appendMessage(kv, 2, vb)
+		b = appendMessage(b, num, kv)
+	}
+	return b
+}
+
+// appendMessage appends the encoded message m as field num.
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+// appendString appends s as field num, unless it is empty.
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendVarint appends v as field num, unless it is zero.
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendHexID appends the bytes of the hex-encoded ID as field num, unless it
+// is empty.
+func appendHexID(b []byte, num protowire.Number, id string) []byte {
+	raw, err := hex.DecodeString(id)
+	if err != nil || len(raw) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, raw)
+}
+
+// appendTime appends the decimal nanosecond time t as fixed64 field num.
+func appendTime(b []byte, num protowire.Number, t string) []byte {
+	ns, _ := strconv.ParseUint(t, 10, 64)
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, ns)
+}
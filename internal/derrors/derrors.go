@@ -18,12 +18,18 @@ var (
 	// HasIncompletePackages indicates a module containing packages that
 	// were processed with a 60x error code.
 	HasIncompletePackages = errors.New("has incomplete packages")
+	// ModuleUnverified indicates a module that was processed, but whose zip
+	// could not be verified against the checksum database.
+	ModuleUnverified = errors.New("module unverified")
 
 	// NotFound indicates that a requested entity was not found (HTTP 404).
 	NotFound = errors.New("not found")
 	// InvalidArgument indicates that the input into the request is invalid in
 	// some way (HTTP 400).
 	InvalidArgument = errors.New("invalid argument")
+	// AlreadyExists indicates that an entity to be created already exists
+	// (HTTP 409).
+	AlreadyExists = errors.New("already exists")
 	// BadModule indicates a problem with a module.
 	BadModule = errors.New("bad module")
 	// Excluded indicates that the module is excluded. (See internal/postgres/excluded.go.)
@@ -38,6 +44,10 @@ var (
 	// any module, up to the max size allowed by the proxy.
 	ModuleTooLarge = errors.New("module too large")
 
+	// ChecksumMismatch indicates that the hash of the module zip differs
+	// from the one recorded in the checksum database.
+	ChecksumMismatch = errors.New("checksum mismatch")
+
 	// SheddingLoad indicates that the server is overloaded and cannot process the
 	// module at this time.
 	SheddingLoad = errors.New("shedding load")
@@ -48,6 +58,10 @@ var (
 	// ProxyTimedOut indicates that a request timed out when fetching from the Module Mirror.
 	ProxyTimedOut = errors.New("proxy timed out")
 
+	// ProxyError indicates that the Module Mirror responded with an unexpected
+	// server error.
+	ProxyError = errors.New("proxy error")
+
 	// PackageBuildContextNotSupported indicates that the build context for the
 	// package is not supported.
 	PackageBuildContextNotSupported = errors.New("package build context not supported")
@@ -83,6 +97,9 @@ var (
 	// ReprocessHasIncompletePackages indicates that the module to be reprocessed
 	// previously had a status of 290.
 	ReprocessHasIncompletePackages = errors.New("reprocess has incomplete packages")
+	// ReprocessModuleUnverified indicates that the module to be reprocessed
+	// previously had a status of 291.
+	ReprocessModuleUnverified = errors.New("reprocess module unverified")
 	// ReprocessBadModule indicates that the module to be reprocessed
 	// previously had a status of derrors.BadModule.
 	ReprocessBadModule = errors.New("reprocess bad module")
@@ -102,21 +119,26 @@ var codes = []struct {
 	{NotFound, http.StatusNotFound},
 	{InvalidArgument, http.StatusBadRequest},
 	{Excluded, http.StatusForbidden},
+	{AlreadyExists, http.StatusConflict},
 	{SheddingLoad, http.StatusServiceUnavailable},
 
 	// Since the following aren't HTTP statuses, pick unused codes.
 	{HasIncompletePackages, 290},
+	{ModuleUnverified, 291},
 	{DBModuleInsertInvalid, 480},
 	{BadModule, 490},
 	{AlternativeModule, 491},
 	{ModuleTooLarge, 492},
+	{ChecksumMismatch, 493},
 
+	{ProxyError, http.StatusBadGateway},
 	{ProxyTimedOut, http.StatusGatewayTimeout},
 	// 52x and 54x errors represents modules that need to be reprocessed, and the
 	// previous status code the module had. Note that the status code
 	// matters for determining reprocessing order.
 	{ReprocessStatusOK, 520},
 	{ReprocessHasIncompletePackages, 521},
+	{ReprocessModuleUnverified, 522},
 	{ReprocessBadModule, 540},
 	{ReprocessAlternative, 541},
 	{ReprocessDBModuleInsertInvalid, 542},
@@ -175,6 +197,8 @@ func ToReprocessStatus(status int) int {
 		return ToStatus(ReprocessStatusOK)
 	case ToStatus(HasIncompletePackages):
 		return ToStatus(ReprocessHasIncompletePackages)
+	case ToStatus(ModuleUnverified):
+		return ToStatus(ReprocessModuleUnverified)
 	case ToStatus(BadModule):
 		return ToStatus(ReprocessBadModule)
 	case ToStatus(AlternativeModule):
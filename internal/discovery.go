@@ -37,7 +37,10 @@ type ModuleInfo struct {
 	CommitTime        time.Time
 	IsRedistributable bool
 	HasGoMod          bool // whether the module zip has a go.mod file
-	SourceInfo        *source.Info
+	// Unverified reports whether the module zip could not be verified
+	// against the checksum database.
+	Unverified bool
+	SourceInfo *source.Info
 }
 
 // VersionMap holds metadata associated with module queries for a version.
@@ -103,13 +106,18 @@ type Module struct {
 	LegacyPackages []*LegacyPackage
 }
 
-// IndexVersion holds the version information returned by the module index.
+// IndexVersion holds the version information returned by the module index,
+// or by another source of module versions.
 type IndexVersion struct {
 	Path      string
 	Version   string
 	Timestamp time.Time
 }
 
+// IndexDiscoverer is the name of the discoverer that polls the module index.
+// Other discoverers are described in package index.
+const IndexDiscoverer = "index"
+
 // ModuleVersionState holds a worker module version state.
 type ModuleVersionState struct {
 	ModulePath string
@@ -130,8 +138,8 @@ type ModuleVersionState struct {
 	// service, for a response with an unsuccessful status code. It is used for
 	// debugging only, and has no semantic significance.
 	Error string
-	// TryCount is the number of times a fetch of this version has been
-	// attempted.
+	// TryCount is the number of consecutive fetches of this version that
+	// failed with Status. It is zero after a successful fetch.
 	TryCount int
 	// LastProcessedAt is the last time this version was updated with a result
 	// from the fetch service.
@@ -139,6 +147,11 @@ type ModuleVersionState struct {
 	// NextProcessedAfter is the next time a fetch for this version should be
 	// attempted.
 	NextProcessedAfter time.Time
+	// DeadLetteredAt is the time at which this version exhausted the retries
+	// allowed by the retry policy for its status, or nil if it has not.
+	// Dead-lettered versions are not fetched again until they are retried
+	// manually.
+	DeadLetteredAt *time.Time
 
 	// AppVersion is the value of the GAE_VERSION environment variable, which is
 	// set by app engine. It is a timestamp in the format 20190709t112655 that
@@ -152,6 +165,11 @@ type ModuleVersionState struct {
 	// NumPackages it the number of packages that were processed as part of the
 	// module (regardless of whether the processing was successful).
 	NumPackages *int
+
+	// Timings holds the time spent in each stage of the most recent fetch,
+	// keyed by stage name. It is only populated by
+	// postgres.DB.LoadModuleVersionStateTimings.
+	Timings map[string]time.Duration
 }
 
 // PackageVersionState holds a worker package version state. It is associated
@@ -44,6 +44,15 @@ type ModuleInfo struct {
 	ResolvedVersion string
 	// ModulePackages is the set of all full package paths in the module.
 	ModulePackages map[string]bool
+	// RequiredModules maps the paths of the modules required by the module's
+	// go.mod file to their required versions. It is used to link packages in
+	// other modules to the version the module depends on.
+	RequiredModules map[string]string
+	// Symbols optionally returns the symbols declared by the package at
+	// pkgPath in the given version of the module at modulePath, as stored
+	// in the symbols table, or nil if they are not known. It is used to
+	// check links to packages in required modules.
+	Symbols func(pkgPath, modulePath, version string) map[string]bool
 }
 
 // RenderOptions are options for Render.
@@ -108,6 +117,12 @@ func Render(ctx context.Context, fset *token.FileSet, p *doc.Package, opt Render
 			}
 			return pathpkg.Join("/pkg", versionedPath)
 		},
+		Symbols: func(path string) map[string]bool {
+			if opt.ModInfo == nil {
+				return nil
+			}
+			return requiredSymbols(path, opt.ModInfo)
+		},
 		DisableHotlinking: true,
 	})
 
@@ -274,10 +289,18 @@ func buildNoteIDs(notes map[string][]*doc.Note) map[string]safehtml.Identifier {
 }
 
 // versionedPkgPath transforms package paths to contain the same version as the
-// current module if the package belongs to the module. As a special case,
-// versionedPkgPath will not add versions to standard library packages.
+// current module if the package belongs to the module, or the required
+// version if the package belongs to a module required by the current module.
+// As a special case, versionedPkgPath will not add versions to standard
+// library packages.
 func versionedPkgPath(pkgPath string, modInfo *ModuleInfo) string {
-	if modInfo == nil || !modInfo.ModulePackages[pkgPath] {
+	if modInfo == nil {
+		return pkgPath
+	}
+	if !modInfo.ModulePackages[pkgPath] {
+		if modulePath, version := requiredModule(pkgPath, modInfo.RequiredModules); modulePath != "" {
+			return fmt.Sprintf("%s@%s%s", modulePath, version, pkgPath[len(modulePath):])
+		}
 		return pkgPath
 	}
 	// We don't need to do anything special here for standard library packages
@@ -286,3 +309,29 @@ func versionedPkgPath(pkgPath string, modInfo *ModuleInfo) string {
 	innerPkgPath := pkgPath[len(modInfo.ModulePath):]
 	return fmt.Sprintf("%s@%s%s", modInfo.ModulePath, modInfo.ResolvedVersion, innerPkgPath)
 }
+
+// requiredSymbols returns the symbols declared by pkgPath, if it is provided
+// by a module that modInfo requires and its symbols are known.
+func requiredSymbols(pkgPath string, modInfo *ModuleInfo) map[string]bool {
+	if modInfo.Symbols == nil || modInfo.ModulePackages[pkgPath] {
+		return nil
+	}
+	modulePath, version := requiredModule(pkgPath, modInfo.RequiredModules)
+	if modulePath == "" {
+		return nil
+	}
+	return modInfo.Symbols(pkgPath, modulePath, version)
+}
+
+// requiredModule returns the path and version of the required module that
+// provides pkgPath. If more than one module path is a prefix of pkgPath, the
+// longest one is used, as the go command does. It returns the empty string if
+// no required module provides pkgPath.
+func requiredModule(pkgPath string, required map[string]string) (modulePath, version string) {
+	for mp, v := range required {
+		if (pkgPath == mp || strings.HasPrefix(pkgPath, mp+"/")) && len(mp) > len(modulePath) {
+			modulePath, version = mp, v
+		}
+	}
+	return modulePath, version
+}
@@ -279,6 +279,39 @@ func TestVersionedPkgPath(t *testing.T) {
 			},
 			want: "golang.org/x/pkgsite@v0.0.0-20200709011933-a59b4ce778c4/internal/log",
 		},
+		{
+			name:    "imports from required modules use the required version",
+			pkgPath: "golang.org/x/mod/semver",
+			modInfo: &ModuleInfo{
+				ModulePath:      "golang.org/x/pkgsite",
+				ResolvedVersion: "v1.1.2",
+				ModulePackages:  map[string]bool{"golang.org/x/pkgsite/internal/log": true},
+				RequiredModules: map[string]string{"golang.org/x/mod": "v0.3.0", "golang.org/x/net": "v0.1.0"},
+			},
+			want: "golang.org/x/mod@v0.3.0/semver",
+		},
+		{
+			name:    "imports from required modules use the longest module path",
+			pkgPath: "cloud.google.com/go/storage/internal",
+			modInfo: &ModuleInfo{
+				ModulePath:      "golang.org/x/pkgsite",
+				ResolvedVersion: "v1.1.2",
+				ModulePackages:  map[string]bool{"golang.org/x/pkgsite/internal/log": true},
+				RequiredModules: map[string]string{"cloud.google.com/go": "v0.66.0", "cloud.google.com/go/storage": "v1.10.0"},
+			},
+			want: "cloud.google.com/go/storage@v1.10.0/internal",
+		},
+		{
+			name:    "imports from required modules with shared prefixes are not versioned",
+			pkgPath: "golang.org/x/modules",
+			modInfo: &ModuleInfo{
+				ModulePath:      "golang.org/x/pkgsite",
+				ResolvedVersion: "v1.1.2",
+				ModulePackages:  map[string]bool{"golang.org/x/pkgsite/internal/log": true},
+				RequiredModules: map[string]string{"golang.org/x/mod": "v0.3.0"},
+			},
+			want: "golang.org/x/modules",
+		},
 		{
 			name:    "imports from same v2 module are versioned",
 			pkgPath: "k8s.io/klog/v2/klogr",
@@ -424,3 +457,24 @@ func mustLoadPackage(path string) (*token.FileSet, *doc.Package) {
 
 	return fset, astPackage
 }
+
+func TestRequiredSymbols(t *testing.T) {
+	var looked []string
+	modInfo := &ModuleInfo{
+		ModulePath:      "example.com/a",
+		ResolvedVersion: "v1.0.0",
+		ModulePackages:  map[string]bool{"example.com/a/p": true},
+		RequiredModules: map[string]string{"example.com/b": "v1.2.3"},
+		Symbols: func(pkgPath, modulePath, version string) map[string]bool {
+			looked = append(looked, pkgPath+" "+modulePath+"@"+version)
+			return map[string]bool{"T": true}
+		},
+	}
+	for _, pkgPath := range []string{"example.com/a/p", "example.com/b/q", "example.com/c"} {
+		requiredSymbols(pkgPath, modInfo)
+	}
+	want := []string{"example.com/b/q example.com/b@v1.2.3"}
+	if diff := cmp.Diff(want, looked); diff != "" {
+		t.Errorf("mismatch (-want +got)\n%s", diff)
+	}
+}
@@ -81,6 +81,13 @@ type packageIDs struct {
 	// E.g., pkgIDs["json"]["Encoder.Encode"] == true
 	pkgIDs map[string]map[string]bool // map[name]map[topLevelID]bool
 
+	// embedded is the set of types embedded in each struct or interface type
+	// in this package and any related package. Embedded types declared in
+	// another package are qualified by that package's name.
+	//
+	// E.g., embedded["io"]["ReadWriter"] == []string{"Reader", "Writer"}
+	embedded map[string]map[string][]string // map[name]map[typeName][]embeddedType
+
 	// topLevelDecls is the set of all AST declarations for the this package.
 	topLevelDecls map[interface{}]bool // map[T]bool where T is *ast.FuncDecl | *ast.GenDecl | *ast.TypeSpec | *ast.ValueSpec
 }
@@ -92,6 +99,7 @@ func newPackageIDs(pkg *doc.Package, related ...*doc.Package) *packageIDs {
 		name:          pkg.Name,
 		impPaths:      make(map[string]string),
 		pkgIDs:        make(map[string]map[string]bool),
+		embedded:      make(map[string]map[string][]string),
 		topLevelDecls: make(map[interface{}]bool),
 	}
 
@@ -102,10 +110,14 @@ func newPackageIDs(pkg *doc.Package, related ...*doc.Package) *packageIDs {
 		}
 		pids.impPaths[pkg.Name] = pkg.ImportPath
 		pids.pkgIDs[pkg.Name] = make(map[string]bool)
+		pids.embedded[pkg.Name] = make(map[string][]string)
 		forEachPackageDecl(pkg, func(decl ast.Decl) {
 			for _, idk := range generateAnchorPoints(decl) {
 				pids.pkgIDs[pkg.Name][idk.ID.String()] = true // E.g., ["io"]["Reader.Read"]
 			}
+			for typeName, embedded := range embeddedTypes(decl) {
+				pids.embedded[pkg.Name][typeName] = embedded // E.g., ["io"]["ReadWriter"]
+			}
 		})
 	}
 
@@ -121,6 +133,36 @@ func newPackageIDs(pkg *doc.Package, related ...*doc.Package) *packageIDs {
 	return pids
 }
 
+// embeddedTypes returns the names of the types embedded in each struct or
+// interface type declared by decl. Types declared in another package are
+// qualified by the package name used in decl.
+func embeddedTypes(decl ast.Decl) map[string][]string {
+	gd, ok := decl.(*ast.GenDecl)
+	if !ok || gd.Tok != token.TYPE {
+		return nil
+	}
+	m := map[string][]string{}
+	for _, sp := range gd.Specs {
+		ts := sp.(*ast.TypeSpec)
+		var fs []*ast.Field
+		switch tx := ts.Type.(type) {
+		case *ast.StructType:
+			fs = tx.Fields.List
+		case *ast.InterfaceType:
+			fs = tx.Methods.List
+		}
+		for _, f := range fs {
+			if f.Names != nil {
+				continue
+			}
+			if typeName, _ := nodeName(f.Type); typeName != "" {
+				m[ts.Name.Name] = append(m[ts.Name.Name], typeName) // E.g., "Reader" or "bytes.Buffer"
+			}
+		}
+	}
+	return m
+}
+
 // declIDs is a collection of identifiers that are related to the ast.Decl
 // currently being processed. Using Decl-level variables allows us to provide
 // greater accuracy in linking when comments refer to the variable names.
@@ -195,6 +237,12 @@ type identifierResolver struct {
 	//
 	// E.g., packageURL("builtin") == "/pkg/builtin/index.html"
 	packageURL func(string) string
+
+	// symbols is a function for looking up the symbols that a package
+	// declares. It may be nil, or return nil if they are not known.
+	//
+	// E.g., symbols("io")["Reader.Read"] == true
+	symbols func(string) map[string]bool
 }
 
 // toURL returns a URL to locate the given package, and
@@ -214,6 +262,19 @@ func (r identifierResolver) toURL(pkgPath, id string) (url string) {
 	return url
 }
 
+// hasSymbol reports whether the package at pkgPath declares the symbol id,
+// which may be qualified by a type name (e.g., "Reader.Read"). If the
+// symbols of the package are not known, it reports whether id is a single
+// identifier.
+func (r identifierResolver) hasSymbol(pkgPath, id string) bool {
+	if r.symbols != nil {
+		if syms := r.symbols(pkgPath); syms != nil {
+			return syms[id]
+		}
+	}
+	return !strings.Contains(id, ".")
+}
+
 // toHTML formats a dot-delimited word as HTML with each ID segment converted
 // to be a link to the relevant declaration.
 func (r identifierResolver) toHTML(word string) safehtml.HTML {
@@ -336,6 +397,58 @@ func (r identifierResolver) lookup(id string) (pkgPath, name string, ok bool) {
 			return r.impPaths[prefix], suffix, true // ID refers to a different package's top-level declaration
 		}
 	}
+	return r.lookupPromoted(id)
+}
+
+// maxEmbeddingDepth is the maximum depth of embedded types that
+// lookupPromoted will search.
+const maxEmbeddingDepth = 5
+
+// lookupPromoted looks up a dot-separated identifier that refers to a field
+// or method promoted from an embedded type.
+// E.g., "ReadWriter.Read" or "io.ReadWriter.Read", which both refer to
+// "Reader.Read" in package io.
+func (r identifierResolver) lookupPromoted(id string) (pkgPath, name string, ok bool) {
+	pkgName := r.name
+	ids := strings.Split(id, ".")
+	if len(ids) == 3 {
+		pkgName, ids = ids[0], ids[1:]
+	}
+	if len(ids) != 2 {
+		return "", "", false
+	}
+	return r.findPromoted(pkgName, ids[0], ids[1], 0)
+}
+
+// findPromoted searches the types embedded in typeName, declared in the
+// package named pkgName, for a field or method named sel. Shallower
+// embeddings are searched first, matching the Go selector rules.
+func (r identifierResolver) findPromoted(pkgName, typeName, sel string, depth int) (pkgPath, name string, ok bool) {
+	if depth >= maxEmbeddingDepth {
+		return "", "", false
+	}
+	embedded := r.embedded[pkgName][typeName]
+	qualify := func(e string) (string, string) {
+		if i := strings.IndexByte(e, '.'); i >= 0 {
+			return e[:i], e[i+1:] // E.g., "bytes.Buffer"
+		}
+		return pkgName, e
+	}
+	for _, e := range embedded {
+		epkg, etype := qualify(e)
+		if r.pkgIDs[epkg][etype+"."+sel] {
+			if epkg != r.name {
+				pkgPath = r.impPaths[epkg]
+			}
+			return pkgPath, etype + "." + sel, true
+		}
+	}
+	for _, e := range embedded {
+		epkg, etype := qualify(e)
+		if pkgPath, name, ok := r.findPromoted(epkg, etype, sel, depth+1); ok {
+			return pkgPath, name, true
+		}
+	}
 	return "", "", false // not found
 }
 
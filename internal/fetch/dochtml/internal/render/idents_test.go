@@ -48,6 +48,7 @@ func TestResolveIdentifier(t *testing.T) {
 				{`Format`, `<a href="#Format">Format</a>`},
 				{`Writers`, `<a href="#Writer">Writers</a>`},
 				{`Write`, `Write`},
+				{`io.ReadWriter.Write`, `<a href="/io">io</a>.<a href="/io#ReadWriter">ReadWriter</a>.<a href="/io#Writer.Write">Write</a>`}, // Promoted from embedded io.Writer
 			},
 		}, {
 			name: "Header",
@@ -68,6 +69,16 @@ func TestResolveIdentifier(t *testing.T) {
 				{`tr.NoExist`, `tr.NoExist`},
 			},
 		}},
+	}, {
+		pkg: pkgIO,
+		tests: []declTest{{
+			name: "",
+			tests: []resolveTest{
+				{`ReadWriter.Read`, `<a href="#ReadWriter">ReadWriter</a>.<a href="#Reader.Read">Read</a>`},
+				{`ReadWriteCloser.Close`, `<a href="#ReadWriteCloser">ReadWriteCloser</a>.<a href="#Closer.Close">Close</a>`},
+				{`ReadWriter.Close`, `<a href="#ReadWriter">ReadWriter</a>.Close`}, // ReadWriter does not embed Closer
+			},
+		}},
 	}, {
 		pkg: pkgTime,
 		tests: []declTest{{
@@ -95,7 +106,7 @@ func TestResolveIdentifier(t *testing.T) {
 			for _, dt := range pt.tests {
 				dids := newDeclIDs(findDecl(pt.pkg, dt.name))
 				t.Run(dt.name, func(t *testing.T) {
-					idr := &identifierResolver{pids, dids, nil}
+					idr := &identifierResolver{pids, dids, nil, nil}
 					for i, rt := range dt.tests {
 						got := idr.toHTML(rt.in)
 						if got.String() != rt.want {
@@ -13,6 +13,7 @@ import (
 	"go/printer"
 	"go/scanner"
 	"go/token"
+	pathpkg "path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -91,7 +92,7 @@ var docTmpl = safetemplate.Must(safetemplate.New("").Parse(`
 
 func (r *Renderer) declHTML(doc string, decl ast.Decl) (out struct{ Doc, Decl safehtml.HTML }) {
 	dids := newDeclIDs(decl)
-	idr := &identifierResolver{r.pids, dids, r.packageURL}
+	idr := &identifierResolver{r.pids, dids, r.packageURL, r.symbols}
 	if doc != "" {
 		var els []docElement
 		for _, blk := range docToBlocks(doc) {
@@ -157,12 +158,42 @@ func (r *Renderer) codeHTML(ex *doc.Example) safehtml.HTML {
 	if err != nil {
 		log.Errorf(r.ctx, "Error converting *doc.Example into string: %v", err)
 	}
-	return codeHTML(codeStr)
+	return codeHTML(codeStr, r.exampleResolver(ex))
+}
+
+// exampleResolver returns an identifierResolver for the package-qualified
+// identifiers in ex. Imports of a runnable example take precedence over
+// the packages known to the renderer.
+func (r *Renderer) exampleResolver(ex *doc.Example) *identifierResolver {
+	if ex == nil || ex.Play == nil || len(ex.Play.Imports) == 0 {
+		return &identifierResolver{r.pids, newDeclIDs(nil), r.packageURL, r.symbols}
+	}
+	pids := *r.pids
+	pids.impPaths = make(map[string]string, len(r.pids.impPaths)+len(ex.Play.Imports))
+	for name, path := range r.pids.impPaths {
+		pids.impPaths[name] = path
+	}
+	for _, spec := range ex.Play.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := pathpkg.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == "_" || name == "." {
+			continue
+		}
+		pids.impPaths[name] = path
+	}
+	return &identifierResolver{&pids, newDeclIDs(nil), r.packageURL, r.symbols}
 }
 
 type codeElement struct {
 	Text    string
 	Comment bool
+	Href    string // if non-empty, Text is linked to Href
 }
 
 var codeTmpl = safetemplate.Must(safetemplate.New("").Parse(`
@@ -170,6 +201,8 @@ var codeTmpl = safetemplate.Must(safetemplate.New("").Parse(`
 {{range .}}
   {{- if .Comment -}}
     <span class="comment">{{.Text}}</span>
+  {{- else if .Href -}}
+    <a href="{{.Href}}">{{.Text}}</a>
   {{- else -}}
     {{.Text}}
   {{- end -}}
@@ -177,7 +210,9 @@ var codeTmpl = safetemplate.Must(safetemplate.New("").Parse(`
 </pre>
 `))
 
-func codeHTML(src string) safehtml.HTML {
+// codeHTML formats src as example code. If idr is non-nil, package-qualified
+// identifiers (e.g., "io.EOF") are linked to their declarations.
+func codeHTML(src string, idr *identifierResolver) safehtml.HTML {
 	var els []codeElement
 	// If code is an *ast.BlockStmt, then trim the braces.
 	var indent string
@@ -198,13 +233,20 @@ func codeHTML(src string) safehtml.HTML {
 	file := fset.AddFile("", fset.Base(), len(src))
 	s.Init(file, []byte(src), nil, scanner.ScanComments)
 	indent = "\n" + indent // prepend newline for easier search-and-replace.
+
+	// pkgPath is the import path of the package named by the identifier
+	// preceding the last "." token, if any. lastTok is the last token scanned.
+	var (
+		pkgPath string
+		lastTok token.Token
+	)
 scan:
 	for {
 		p, tok, lit := s.Scan()
 		offset := file.Offset(p) // current offset into source file
 		prev := src[lastOffset:offset]
 		prev = strings.Replace(prev, indent, "\n", -1)
-		els = append(els, codeElement{prev, false})
+		els = append(els, codeElement{Text: prev})
 		lastOffset = offset
 		switch tok {
 		case token.EOF:
@@ -214,13 +256,35 @@ scan:
 				outputOffset = len(els)
 			}
 			lit = strings.Replace(lit, indent, "\n", -1)
-			els = append(els, codeElement{lit, true})
+			els = append(els, codeElement{Text: lit, Comment: true})
 			lastOffset += len(lit)
 		case token.STRING:
 			// Avoid replacing indents in multi-line string literals.
-			els = append(els, codeElement{lit, false})
+			els = append(els, codeElement{Text: lit})
 			lastOffset += len(lit)
+		case token.IDENT:
+			if idr == nil {
+				break
+			}
+			if lastTok == token.PERIOD && pkgPath != "" {
+				// The second half of a package-qualified identifier.
+				// E.g., "EOF" in "io.EOF".
+				if href := idr.exampleURL(pkgPath, lit); href != "" {
+					els = append(els, codeElement{Text: lit, Href: href})
+					lastOffset += len(lit)
+				}
+				pkgPath = ""
+				break
+			}
+			pkgPath = ""
+			if lastTok != token.PERIOD {
+				pkgPath = idr.impPaths[lit]
+			}
+		}
+		if tok != token.IDENT && tok != token.PERIOD {
+			pkgPath = ""
 		}
+		lastTok = tok
 	}
 
 	if outputOffset >= 0 {
@@ -233,6 +297,28 @@ scan:
 	return ExecuteToHTML(codeTmpl, els)
 }
 
+// exampleURL returns the URL of the top-level identifier id declared in the
+// package with the given import path, or the empty string if id should not
+// be linked. Identifiers in packages whose declarations are known to the
+// resolver are only linked if they exist.
+func (r identifierResolver) exampleURL(pkgPath, id string) string {
+	if !isExported(id) {
+		return ""
+	}
+	for name, path := range r.impPaths {
+		if path != pkgPath || r.pkgIDs[name] == nil {
+			continue
+		}
+		if !r.pkgIDs[name][id] {
+			return ""
+		}
+		if name == r.name {
+			return r.toURL("", id) // E.g., "#NewReader"
+		}
+	}
+	return r.toURL(pkgPath, id)
+}
+
 // formatLineHTML formats the line as HTML-annotated text.
 // URLs and Go identifiers are linked to corresponding declarations.
 func (r *Renderer) formatLineHTML(line string, idr *identifierResolver) safehtml.HTML {
@@ -615,16 +701,25 @@ func generateAnchorLinks(idr *identifierResolver, decl ast.Decl) map[*ast.Ident]
 		switch node := node.(type) {
 		case *ast.SelectorExpr:
 			// Package qualified identifier (e.g., "io.EOF").
-			if prefix, _ := node.X.(*ast.Ident); prefix != nil {
-				if obj := prefix.Obj; obj != nil && obj.Kind == ast.Pkg {
-					if spec, _ := obj.Decl.(*ast.ImportSpec); spec != nil {
-						if path, err := strconv.Unquote(spec.Path.Value); err == nil {
-							// Register two links, one for the package
-							// and one for the qualified identifier.
-							m[prefix] = idr.toURL(path, "")
-							m[node.Sel] = idr.toURL(path, node.Sel.Name)
-							return false
-						}
+			if path := importPath(node.X); path != "" {
+				// Register two links, one for the package
+				// and one for the qualified identifier.
+				// If the package is known not to declare the
+				// identifier, link to the package instead.
+				prefix := node.X.(*ast.Ident)
+				m[prefix] = idr.toURL(path, "")
+				m[node.Sel] = idr.toURL(path, "")
+				if idr.hasSymbol(path, node.Sel.Name) {
+					m[node.Sel] = idr.toURL(path, node.Sel.Name)
+				}
+				return false
+			}
+			// Method or field of a package qualified type
+			// (e.g., "io.Reader.Read").
+			if x, _ := node.X.(*ast.SelectorExpr); x != nil {
+				if path := importPath(x.X); path != "" {
+					if id := x.Sel.Name + "." + node.Sel.Name; idr.hasSymbol(path, id) {
+						m[node.Sel] = idr.toURL(path, id)
 					}
 				}
 			}
@@ -651,3 +746,21 @@ func generateAnchorLinks(idr *identifierResolver, decl ast.Decl) map[*ast.Ident]
 	})
 	return m
 }
+
+// importPath returns the import path of the package that x refers to, or
+// the empty string if x is not the name of an imported package.
+func importPath(x ast.Expr) string {
+	id, _ := x.(*ast.Ident)
+	if id == nil || id.Obj == nil || id.Obj.Kind != ast.Pkg {
+		return ""
+	}
+	spec, _ := id.Obj.Decl.(*ast.ImportSpec)
+	if spec == nil {
+		return ""
+	}
+	path, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return ""
+	}
+	return path
+}
@@ -9,6 +9,7 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"sort"
 	"strings"
 	"testing"
 
@@ -166,6 +167,61 @@ func After(d <a href="#Duration">Duration</a>) &lt;-chan <a href="#Time">Time</a
 	}
 }
 
+func TestGenerateAnchorLinksSymbols(t *testing.T) {
+	fset := token.NewFileSet()
+	file := mustParse(t, fset, "p.go", `package p
+
+import (
+	"io"
+	"time"
+)
+
+var (
+	R       io.Reader
+	W       io.Writer
+	Write   = io.Writer.Write
+	Close   = io.Writer.Close
+	Started time.Time
+)
+`)
+	importer := func(imports map[string]*ast.Object, path string) (*ast.Object, error) {
+		pkg := ast.NewObj(ast.Pkg, path)
+		pkg.Data = ast.NewScope(nil)
+		return pkg, nil
+	}
+	// ast.NewPackage resolves the package names in the file.
+	ast.NewPackage(fset, map[string]*ast.File{"p.go": file}, importer, nil)
+
+	idr := &identifierResolver{newPackageIDs(&doc.Package{Name: "p"}), newDeclIDs(nil), nil,
+		func(pkgPath string) map[string]bool {
+			if pkgPath == "io" {
+				return map[string]bool{"Writer": true, "Writer.Write": true}
+			}
+			return nil // The symbols of time are not known.
+		}}
+	var got []string
+	for id, url := range generateAnchorLinks(idr, file.Decls[1]) {
+		got = append(got, id.Name+" "+url)
+	}
+	sort.Strings(got)
+	want := []string{
+		"Reader /io",
+		"Time /time#Time",
+		"Write /io#Writer.Write",
+		"Writer /io#Writer",
+		"Writer /io#Writer",
+		"Writer /io#Writer",
+		"io /io",
+		"io /io",
+		"io /io",
+		"io /io",
+		"time /time",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got)\n%s", diff)
+	}
+}
+
 func declForName(t *testing.T, pkg *doc.Package, symbol string) ast.Decl {
 
 	inVals := func(vals []*doc.Value) ast.Decl {
@@ -268,7 +324,7 @@ b := 1
 `,
 		},
 	} {
-		out := codeHTML(test.in)
+		out := codeHTML(test.in, nil)
 		got := strings.TrimSpace(string(out.String()))
 		want := strings.TrimSpace(test.want)
 		if got != want {
@@ -277,6 +333,81 @@ b := 1
 	}
 }
 
+func TestCodeHTMLLinks(t *testing.T) {
+	idr := &identifierResolver{newPackageIDs(pkgTar, pkgIO, pkgTime), newDeclIDs(nil), nil, nil}
+	for _, test := range []struct {
+		name, in, want string
+	}{
+		{
+			"known packages",
+			`tr := tar.NewReader(r)
+_, err := io.Copy(os.Stdout, tr)
+if err == io.NoExist {
+}`,
+			`
+<pre class="Documentation-exampleCode">
+tr := tar.<a href="#NewReader">NewReader</a>(r)
+_, err := io.<a href="/io#Copy">Copy</a>(os.Stdout, tr)
+if err == io.NoExist {
+}
+</pre>`,
+		},
+		{
+			"selectors on values",
+			`t := time.Now()
+t.time.Now()
+fmt.Println(t.Unix())`,
+			`
+<pre class="Documentation-exampleCode">
+t := time.<a href="/time#Now">Now</a>()
+t.time.Now()
+fmt.Println(t.Unix())
+</pre>`,
+		},
+	} {
+		out := codeHTML(test.in, idr)
+		got := strings.TrimSpace(out.String())
+		want := strings.TrimSpace(test.want)
+		if got != want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", test.name, got, want)
+		}
+	}
+}
+
+func TestExampleResolver(t *testing.T) {
+	fset := token.NewFileSet()
+	r := New(context.Background(), fset, pkgTar, nil)
+	ex := &doc.Example{
+		Play: mustParse(t, fset, "example_test.go", `package main
+
+import (
+	"fmt"
+	str "strings"
+	_ "embed"
+)
+
+func main() {}
+`),
+	}
+	idr := r.exampleResolver(ex)
+	want := map[string]string{
+		"tar": "archive/tar",
+		"fmt": "fmt",
+		"str": "strings",
+	}
+	for name, path := range want {
+		if got := idr.impPaths[name]; got != path {
+			t.Errorf("impPaths[%q] = %q, want %q", name, got, path)
+		}
+	}
+	if _, ok := idr.impPaths["_"]; ok {
+		t.Error("blank import should not be resolvable")
+	}
+	if _, ok := r.pids.impPaths["fmt"]; ok {
+		t.Error("example imports leaked into the renderer's packageIDs")
+	}
+}
+
 func mustParse(t *testing.T, fset *token.FileSet, filename, src string) *ast.File {
 	t.Helper()
 	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
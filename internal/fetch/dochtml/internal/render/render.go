@@ -35,6 +35,7 @@ type Renderer struct {
 	fset              *token.FileSet
 	pids              *packageIDs
 	packageURL        func(string) string
+	symbols           func(string) map[string]bool
 	disableHotlinking bool
 	disablePermalinks bool
 	ctx               context.Context
@@ -54,6 +55,15 @@ type Options struct {
 	// Only relevant for HTML formatting.
 	PackageURL func(pkgPath string) (url string)
 
+	// Symbols is a function that given a package path, returns the set of
+	// symbols declared by that package, with methods and fields qualified
+	// by their type name (e.g., "Reader.Read"). It returns nil if the
+	// symbols are not known. Links from declarations to identifiers in
+	// other packages are checked against it.
+	//
+	// Only relevant for HTML formatting.
+	Symbols func(pkgPath string) map[string]bool
+
 	// DisableHotlinking turns off hotlinking behavior.
 	//
 	// Only relevant for HTML formatting.
@@ -68,6 +78,7 @@ type Options struct {
 func New(ctx context.Context, fset *token.FileSet, pkg *doc.Package, opts *Options) *Renderer {
 	var others []*doc.Package
 	var packageURL func(string) string
+	var symbols func(string) map[string]bool
 	var disableHotlinking bool
 	var disablePermalinks bool
 	if opts != nil {
@@ -77,6 +88,7 @@ func New(ctx context.Context, fset *token.FileSet, pkg *doc.Package, opts *Optio
 		if opts.PackageURL != nil {
 			packageURL = opts.PackageURL
 		}
+		symbols = opts.Symbols
 		disableHotlinking = opts.DisableHotlinking
 		disablePermalinks = opts.DisablePermalinks
 	}
@@ -85,6 +97,7 @@ func New(ctx context.Context, fset *token.FileSet, pkg *doc.Package, opts *Optio
 		fset:              fset,
 		pids:              pids,
 		packageURL:        packageURL,
+		symbols:           symbols,
 		disableHotlinking: disableHotlinking,
 		disablePermalinks: disablePermalinks,
 		ctx:               ctx,
@@ -98,23 +98,39 @@ type FetchResult struct {
 	Defer                func() // caller must defer this on all code paths
 	Module               *internal.Module
 	PackageVersionStates []*internal.PackageVersionState
+	// Timings holds the time spent in each stage of the fetch, keyed by
+	// stage name (see the Stage constants).
+	Timings map[string]time.Duration
 }
 
+// Names of the stages of a fetch, as recorded in FetchResult.Timings.
+const (
+	StageProxyInfo        = "proxy-info"        // resolving the version and zip size
+	StageZipDownload      = "zip-download"      // downloading the go.mod file and zip
+	StageLicenseDetection = "license-detection" // detecting licenses in the zip
+	StagePackageLoad      = "package-load"      // parsing packages, excluding doc rendering
+	StageDocRender        = "doc-render"        // rendering package documentation
+)
+
 // FetchModule queries the proxy or the Go repo for the requested module
 // version, downloads the module zip, and processes the contents to return an
 // *internal.Module and related information.
 //
+// If symbols is non-nil, it is used to check links in the documentation to
+// packages in the modules that the module requires.
+//
 // Even if err is non-nil, the result may contain useful information, like the go.mod path.
 //
 // Callers of FetchModule must
 //   defer fr.Defer()
 // immediately after the call.
-func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client) (fr *FetchResult) {
+func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client, symbols SymbolLookup) (fr *FetchResult) {
 	start := time.Now()
 	fr = &FetchResult{
 		ModulePath:       modulePath,
 		RequestedVersion: requestedVersion,
 		Defer:            func() {},
+		Timings:          map[string]time.Duration{},
 	}
 	var fi *FetchInfo
 	defer func() {
@@ -140,9 +156,11 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 		commitTime time.Time
 		zipReader  *zip.Reader
 		zipSize    int64
+		unverified bool
 		err        error
 	)
 	// Get the just information we need to make a load-shedding decision.
+	stageStart := time.Now()
 	if modulePath == stdlib.ModulePath {
 		var resolvedVersion string
 		resolvedVersion, zipSize, err = stdlib.ZipInfo(requestedVersion)
@@ -165,6 +183,7 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 			return fr
 		}
 	}
+	fr.Timings[StageProxyInfo] = time.Since(stageStart)
 
 	// Load shed or mark module as too large.
 	// We treat zip size
@@ -194,8 +213,9 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 	}
 	startFetchInfo(fi)
 
+	stageStart = time.Now()
 	if modulePath == stdlib.ModulePath {
-		zipReader, commitTime, err = stdlib.Zip(requestedVersion)
+		zipReader, commitTime, err = stdlib.Zip(fr.ResolvedVersion)
 		if err != nil {
 			fr.Error = err
 			return fr
@@ -225,8 +245,18 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 			fr.Error = err
 			return fr
 		}
+		if err := proxyClient.VerifyZip(ctx, modulePath, fr.ResolvedVersion, zipReader); err != nil {
+			if !errors.Is(err, derrors.ModuleUnverified) {
+				fr.Error = err
+				return fr
+			}
+			// Process the module anyway, but record that it is unverified.
+			log.Warning(ctx, err)
+			unverified = true
+		}
 	}
-	mod, pvs, err := processZipFile(ctx, modulePath, fr.ResolvedVersion, commitTime, zipReader, sourceClient)
+	fr.Timings[StageZipDownload] = time.Since(stageStart)
+	mod, pvs, err := processZipFile(ctx, modulePath, fr.ResolvedVersion, commitTime, zipReader, sourceClient, symbols, fr.Timings)
 	if err != nil {
 		fr.Error = err
 		return fr
@@ -241,11 +271,18 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 			fr.Status = derrors.ToStatus(derrors.HasIncompletePackages)
 		}
 	}
+	if unverified {
+		// This takes precedence over HasIncompletePackages, since it affects
+		// the whole module.
+		fr.Module.Unverified = true
+		fr.Status = derrors.ToStatus(derrors.ModuleUnverified)
+	}
 	return fr
 }
 
-// processZipFile extracts information from the module version zip.
-func processZipFile(ctx context.Context, modulePath string, resolvedVersion string, commitTime time.Time, zipReader *zip.Reader, sourceClient *source.Client) (_ *internal.Module, _ []*internal.PackageVersionState, err error) {
+// processZipFile extracts information from the module version zip. It
+// records the time spent in each stage of processing in timings.
+func processZipFile(ctx context.Context, modulePath string, resolvedVersion string, commitTime time.Time, zipReader *zip.Reader, sourceClient *source.Client, symbols SymbolLookup, timings map[string]time.Duration) (_ *internal.Module, _ []*internal.PackageVersionState, err error) {
 	defer derrors.Wrap(&err, "processZipFile(%q, %q)", modulePath, resolvedVersion)
 
 	ctx, span := trace.StartSpan(ctx, "fetch.processZipFile")
@@ -262,9 +299,18 @@ func processZipFile(ctx context.Context, modulePath string, resolvedVersion stri
 	logf := func(format string, args ...interface{}) {
 		log.Infof(ctx, format, args...)
 	}
+	start := time.Now()
 	d := licenses.NewDetector(modulePath, resolvedVersion, zipReader, logf)
 	allLicenses := d.AllLicenses()
-	packages, packageVersionStates, err := extractPackagesFromZip(ctx, modulePath, resolvedVersion, zipReader, d, sourceInfo)
+	timings[StageLicenseDetection] = time.Since(start)
+	start = time.Now()
+	packages, packageVersionStates, err := extractPackagesFromZip(ctx, modulePath, resolvedVersion, zipReader, d, sourceInfo, symbols)
+	var renderTime time.Duration
+	for _, p := range packages {
+		renderTime += p.renderTime
+	}
+	timings[StageDocRender] = renderTime
+	timings[StagePackageLoad] = time.Since(start) - renderTime
 	if errors.Is(err, errModuleContainsNoPackages) || errors.Is(err, errMalformedZip) {
 		return nil, nil, fmt.Errorf("%v: %w", err.Error(), derrors.BadModule)
 	}
@@ -87,7 +87,7 @@ func TestFetchModule(t *testing.T) {
 				Files:      test.mod.mod.Files,
 			}})
 			defer teardownProxy()
-			got := FetchModule(ctx, modulePath, fetchVersion, proxyClient, sourceClient)
+			got := FetchModule(ctx, modulePath, fetchVersion, proxyClient, sourceClient, nil)
 			defer got.Defer()
 			if got.Error != nil {
 				t.Fatal(got.Error)
@@ -99,8 +99,9 @@ func TestFetchModule(t *testing.T) {
 			opts := []cmp.Option{
 				cmpopts.IgnoreFields(internal.LegacyPackage{}, "DocumentationHTML"),
 				cmpopts.IgnoreFields(internal.Documentation{}, "HTML"),
+				cmpopts.IgnoreFields(internal.Unit{}, "Symbols"),
 				cmpopts.IgnoreFields(internal.PackageVersionState{}, "Error"),
-				cmpopts.IgnoreFields(FetchResult{}, "Defer"),
+				cmpopts.IgnoreFields(FetchResult{}, "Defer", "Timings"),
 				cmp.AllowUnexported(source.Info{}),
 				cmpopts.EquateEmpty(),
 			}
@@ -109,6 +110,11 @@ func TestFetchModule(t *testing.T) {
 				t.Fatalf("mismatch (-want +got):\n%s", diff)
 			}
 			validateDocumentationHTML(t, got.Module, fr.Module)
+			for _, stage := range []string{StageProxyInfo, StageZipDownload, StageLicenseDetection, StagePackageLoad, StageDocRender} {
+				if _, ok := got.Timings[stage]; !ok {
+					t.Errorf("no timing for stage %q", stage)
+				}
+			}
 		})
 	}
 }
@@ -137,7 +143,7 @@ func TestFetchModule_Errors(t *testing.T) {
 			defer teardownProxy()
 
 			sourceClient := source.NewClient(sourceTimeout)
-			got := FetchModule(ctx, modulePath, "v1.0.0", proxyClient, sourceClient)
+			got := FetchModule(ctx, modulePath, "v1.0.0", proxyClient, sourceClient, nil)
 			defer got.Defer()
 			if !errors.Is(got.Error, test.wantErr) {
 				t.Fatalf("FetchModule(ctx, %q, v1.0.0, proxyClient, sourceClient): %v; wantErr = %v)", modulePath, got.Error, test.wantErr)
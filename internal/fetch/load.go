@@ -24,12 +24,12 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/google/safehtml"
 	"github.com/google/safehtml/template"
 	"go.opencensus.io/trace"
 	"golang.org/x/pkgsite/internal"
-	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/fetch/dochtml"
@@ -126,7 +126,15 @@ func loadPackageWithBuildContext(ctx context.Context, goos, goarch string, zipGo
 	if err != nil {
 		return nil, err
 	}
+	symbols, symbolUses := extractSymbols(d, func(pkgPath string) bool {
+		if modulePath == stdlib.ModulePath {
+			pkgPath = path.Join(stdlib.ModulePath, pkgPath)
+		}
+		return modInfo.ModulePackages[pkgPath]
+	})
+	renderStart := time.Now()
 	docHTML, err := renderDocHTML(ctx, innerPath, d, fset, sourceInfo, modInfo)
+	renderTime := time.Since(renderStart)
 	if err != nil && !errors.Is(err, dochtml.ErrTooLarge) {
 		return nil, err
 	}
@@ -152,6 +160,9 @@ func loadPackageWithBuildContext(ctx context.Context, goos, goarch string, zipGo
 		goos:              goos,
 		goarch:            goarch,
 		source:            src,
+		symbols:           symbols,
+		symbolUses:        symbolUses,
+		renderTime:        renderTime,
 	}, err
 }
 
@@ -374,21 +385,20 @@ const mib = 1024 * 1024
 // We probably will OOM if we process a module whose zip is larger.
 var maxModuleZipSize int64 = math.MaxInt64
 
-func init() {
-	v := config.GetEnvInt("GO_DISCOVERY_MAX_MODULE_ZIP_MI", -1)
-	if v > 0 {
-		maxModuleZipSize = int64(v) * mib
-	}
-}
-
 var zipLoadShedder = loadShedder{maxSizeInFlight: math.MaxUint64}
 
-func init() {
-	ctx := context.Background()
-	mebis := config.GetEnvInt("GO_DISCOVERY_MAX_IN_FLIGHT_ZIP_MI", -1)
-	if mebis > 0 {
-		log.Infof(ctx, "shedding load over %dMi", mebis)
-		zipLoadShedder.maxSizeInFlight = uint64(mebis) * mib
+// SetZipLimits sets the size in bytes of the largest module zip that is
+// processed, and the total size of the module zips that may be processed at
+// once, usually to config.Config.MaxModuleZipSize and
+// config.Config.MaxInFlightZipSize. A size that is not positive means no
+// limit. It must be called before any module is fetched.
+func SetZipLimits(ctx context.Context, maxModuleZip, maxInFlightZip int64) {
+	if maxModuleZip > 0 {
+		maxModuleZipSize = maxModuleZip
+	}
+	if maxInFlightZip > 0 {
+		log.Infof(ctx, "shedding load over %dMi", maxInFlightZip/mib)
+		zipLoadShedder.maxSizeInFlight = uint64(maxInFlightZip)
 	}
 }
 
@@ -14,9 +14,11 @@ import (
 	"path"
 	"runtime/debug"
 	"strings"
+	"time"
 
 	"github.com/google/safehtml"
 	"go.opencensus.io/trace"
+	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
@@ -45,6 +47,15 @@ type goPackage struct {
 	// series.
 	v1path string
 	source []byte // the source files of the package, for generating doc at serving time
+
+	// symbols are the exported identifiers declared by the package.
+	// symbolUses are the uses of types in the module by the package's API,
+	// which are recorded in the symbols of other packages by
+	// linkSymbolReferences.
+	symbols    []*internal.Symbol
+	symbolUses []*symbolUse
+
+	renderTime time.Duration // time spent rendering the documentation
 }
 
 // extractPackagesFromZip returns a slice of packages from the module zip r.
@@ -56,7 +67,7 @@ type goPackage struct {
 // * a maximum file size (MaxFileSize)
 // * the particular set of build contexts we consider (goEnvs)
 // * whether the import path is valid.
-func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion string, r *zip.Reader, d *licenses.Detector, sourceInfo *source.Info) (_ []*goPackage, _ []*internal.PackageVersionState, err error) {
+func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion string, r *zip.Reader, d *licenses.Detector, sourceInfo *source.Info, symbols SymbolLookup) (_ []*goPackage, _ []*internal.PackageVersionState, err error) {
 	defer derrors.Wrap(&err, "extractPackagesFromZip(ctx, %q, %q, r, d)", modulePath, resolvedVersion)
 	ctx, span := trace.StartSpan(ctx, "fetch.extractPackagesFromZip")
 	defer span.End()
@@ -98,6 +109,7 @@ func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion str
 			ModulePath:      modulePath,
 			ResolvedVersion: resolvedVersion,
 			ModulePackages:  make(map[string]bool),
+			Symbols:         cacheSymbols(ctx, symbols),
 		}
 
 		// incompleteDirs tracks directories for which we have incomplete
@@ -135,6 +147,16 @@ func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion str
 			// File is in a directory we're not looking to process at this time, so skip it.
 			continue
 		}
+		if f.Name == modulePrefix+"go.mod" {
+			// Use the module's requirements to link to the versions of
+			// packages in other modules that it depends on.
+			required, err := requiredModules(f)
+			if err != nil {
+				log.Infof(ctx, "Unable to read requirements from %s: %v", f.Name, err)
+			}
+			modInfo.RequiredModules = required
+			continue
+		}
 		if !strings.HasSuffix(f.Name, ".go") {
 			// We care about .go files only.
 			continue
@@ -241,9 +263,29 @@ func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion str
 	if len(pkgs) == 0 {
 		return nil, packageVersionStates, errModuleContainsNoPackages
 	}
+	linkSymbolReferences(pkgs)
 	return pkgs, packageVersionStates, nil
 }
 
+// requiredModules returns a map from the paths of the modules required by
+// the go.mod file f to their versions.
+func requiredModules(f *zip.File) (_ map[string]string, err error) {
+	defer derrors.Wrap(&err, "requiredModules(%q)", f.Name)
+	b, err := readZipFile(f, MaxFileSize)
+	if err != nil {
+		return nil, err
+	}
+	mf, err := modfile.ParseLax(f.Name, b, nil)
+	if err != nil {
+		return nil, err
+	}
+	required := make(map[string]string, len(mf.Require))
+	for _, r := range mf.Require {
+		required[r.Mod.Path] = r.Mod.Version
+	}
+	return required, nil
+}
+
 // ignoredByGoTool reports whether the given import path corresponds
 // to a directory that would be ignored by the go tool.
 //
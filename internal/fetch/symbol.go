@@ -0,0 +1,253 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/fetch/internal/doc"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// A SymbolLookup returns the names of the symbols declared by the package at
+// pkgPath in the given version of the module at modulePath, as stored in the
+// symbols table. Methods and fields are qualified by their type name, as in
+// "Reader.Read". It returns an error wrapping derrors.NotFound if the package
+// has not been processed.
+type SymbolLookup func(ctx context.Context, pkgPath, modulePath, version string) (map[string]bool, error)
+
+// cacheSymbols returns a function that calls lookup at most once for each
+// package version, and returns nil if the symbols are not known. It returns
+// nil if lookup is nil.
+func cacheSymbols(ctx context.Context, lookup SymbolLookup) func(pkgPath, modulePath, version string) map[string]bool {
+	if lookup == nil {
+		return nil
+	}
+	cache := map[string]map[string]bool{}
+	return func(pkgPath, modulePath, version string) map[string]bool {
+		key := pkgPath + "@" + version
+		if syms, ok := cache[key]; ok {
+			return syms
+		}
+		syms, err := lookup(ctx, pkgPath, modulePath, version)
+		if err != nil {
+			if !errors.Is(err, derrors.NotFound) {
+				log.Errorf(ctx, "looking up symbols of %s: %v", key, err)
+			}
+			syms = nil
+		}
+		cache[key] = syms
+		return syms
+	}
+}
+
+// A symbolUse is a reference from a declaration in one package to a type in
+// the same package or another package in the same module.
+type symbolUse struct {
+	pkgPath string // import path of the package declaring the type
+	name    string // name of the type
+	from    internal.SymbolReference
+}
+
+// extractSymbols returns the exported symbols declared by d, along with the
+// uses of types made by the exported API of d. Only uses of types declared
+// in d or in a package for which inModule returns true are reported.
+//
+// The identifiers in d must have been resolved, as they are by
+// doc.NewFromFiles.
+func extractSymbols(d *doc.Package, inModule func(pkgPath string) bool) ([]*internal.Symbol, []*symbolUse) {
+	var (
+		symbols []*internal.Symbol
+		uses    []*symbolUse
+	)
+	addSymbol := func(name string, kind internal.SymbolKind) {
+		if ast.IsExported(name[strings.LastIndexByte(name, '.')+1:]) {
+			symbols = append(symbols, &internal.Symbol{Name: name, Kind: kind})
+		}
+	}
+	addUses := func(from string, node ast.Node) {
+		for _, u := range typeUses(d.ImportPath, node, inModule) {
+			if u.pkgPath == d.ImportPath && (u.name == from || strings.HasPrefix(from, u.name+".")) {
+				continue // E.g., a method referring to its receiver type.
+			}
+			u.from = internal.SymbolReference{PackagePath: d.ImportPath, Name: from}
+			uses = append(uses, u)
+		}
+	}
+	addValues := func(vals []*doc.Value, kind internal.SymbolKind) {
+		for _, v := range vals {
+			for _, name := range v.Names {
+				addSymbol(name, kind)
+			}
+			if len(v.Names) > 0 {
+				addUses(v.Names[0], v.Decl)
+			}
+		}
+	}
+	addFuncs := func(fns []*doc.Func, typeName string) {
+		for _, f := range fns {
+			name, kind := f.Name, internal.SymbolKindFunction
+			if f.Decl.Recv != nil {
+				name, kind = typeName+"."+f.Name, internal.SymbolKindMethod
+			}
+			addSymbol(name, kind)
+			// Only the signature is part of the API.
+			addUses(name, f.Decl.Type)
+		}
+	}
+
+	addValues(d.Consts, internal.SymbolKindConstant)
+	addValues(d.Vars, internal.SymbolKindVariable)
+	addFuncs(d.Funcs, "")
+	for _, t := range d.Types {
+		addSymbol(t.Name, internal.SymbolKindType)
+		for _, sp := range t.Decl.Specs {
+			ts, ok := sp.(*ast.TypeSpec)
+			if !ok || ts.Name.Name != t.Name {
+				continue
+			}
+			for name, kind := range members(ts) {
+				addSymbol(t.Name+"."+name, kind)
+			}
+			addUses(t.Name, ts.Type)
+		}
+		addValues(t.Consts, internal.SymbolKindConstant)
+		addValues(t.Vars, internal.SymbolKindVariable)
+		addFuncs(t.Funcs, t.Name)
+		addFuncs(t.Methods, t.Name)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].Name < symbols[j].Name })
+	return symbols, uses
+}
+
+// members returns the names and kinds of the fields of a struct type
+// or the methods of an interface type. The name of an embedded field is
+// the name of its type.
+func members(ts *ast.TypeSpec) map[string]internal.SymbolKind {
+	var (
+		fields []*ast.Field
+		kind   internal.SymbolKind
+	)
+	switch t := ts.Type.(type) {
+	case *ast.StructType:
+		fields, kind = t.Fields.List, internal.SymbolKindField
+	case *ast.InterfaceType:
+		fields, kind = t.Methods.List, internal.SymbolKindMethod
+	}
+	m := map[string]internal.SymbolKind{}
+	for _, f := range fields {
+		for _, n := range f.Names {
+			m[n.Name] = kind
+		}
+		// Embedded interfaces only contribute methods, so they
+		// aren't members in their own right.
+		if f.Names == nil && kind == internal.SymbolKindField {
+			if name := embeddedName(f.Type); name != "" {
+				m[name] = kind
+			}
+		}
+	}
+	return m
+}
+
+// embeddedName returns the field name of an embedded field of type expr.
+func embeddedName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.StarExpr:
+		return embeddedName(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	default:
+		return ""
+	}
+}
+
+// typeUses returns the types referred to by node that are declared in the
+// package with import path pkgPath, or in another package for which
+// inModule returns true.
+func typeUses(pkgPath string, node ast.Node, inModule func(string) bool) []*symbolUse {
+	var uses []*symbolUse
+	seen := map[string]bool{}
+	add := func(path, name string) {
+		if key := path + "." + name; !seen[key] && ast.IsExported(name) {
+			seen[key] = true
+			uses = append(uses, &symbolUse{pkgPath: path, name: name})
+		}
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit, *ast.BlockStmt:
+			// Function bodies are not part of the API.
+			return false
+		case *ast.SelectorExpr:
+			// Package qualified identifier (e.g., "io.Reader").
+			x, ok := n.X.(*ast.Ident)
+			if !ok || x.Obj == nil || x.Obj.Kind != ast.Pkg {
+				return true
+			}
+			spec, ok := x.Obj.Decl.(*ast.ImportSpec)
+			if !ok {
+				return false
+			}
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil && inModule(path) {
+				add(path, n.Sel.Name)
+			}
+			return false
+		case *ast.Ident:
+			if n.Obj != nil && n.Obj.Kind == ast.Typ {
+				if _, ok := n.Obj.Decl.(*ast.TypeSpec); ok {
+					add(pkgPath, n.Name)
+				}
+			}
+		case *ast.GenDecl:
+			if n.Tok == token.IMPORT {
+				return false
+			}
+		}
+		return true
+	})
+	return uses
+}
+
+// linkSymbolReferences records each use of a type by a package in pkgs in
+// the References of the symbol for that type.
+func linkSymbolReferences(pkgs []*goPackage) {
+	symbols := map[string]*internal.Symbol{} // keyed by "pkgPath.Name"
+	for _, p := range pkgs {
+		for _, s := range p.symbols {
+			if s.Kind == internal.SymbolKindType {
+				symbols[p.path+"."+s.Name] = s
+			}
+		}
+	}
+	for _, p := range pkgs {
+		for _, u := range p.symbolUses {
+			if s := symbols[u.pkgPath+"."+u.name]; s != nil {
+				ref := u.from
+				s.References = append(s.References, &ref)
+			}
+		}
+		p.symbolUses = nil
+	}
+	for _, s := range symbols {
+		sort.Slice(s.References, func(i, j int) bool {
+			ri, rj := s.References[i], s.References[j]
+			if ri.PackagePath != rj.PackagePath {
+				return ri.PackagePath < rj.PackagePath
+			}
+			return ri.Name < rj.Name
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+)
+
+func TestSymbols(t *testing.T) {
+	const modulePath = "example.com/m"
+	sources := map[string]string{
+		"a": `
+			package a
+
+			const (
+				Max = 10
+				min = 0
+			)
+
+			// Config configures a Client.
+			type Config struct {
+				Name string
+				Client *Client
+				*Options
+				timeout int
+			}
+
+			type Options struct{}
+
+			type Client struct{}
+
+			func NewClient(c *Config) *Client {
+				var unused Options
+				_ = unused
+				return &Client{}
+			}
+
+			func (c *Client) Do(opts Options) error { return nil }
+
+			func (c *Client) close() {}
+		`,
+		"b": `
+			package b
+
+			import (
+				"io"
+
+				"example.com/m/a"
+			)
+
+			type Doer interface {
+				io.Reader
+				Do(a.Options) error
+			}
+
+			var DefaultConfig = &a.Config{}
+
+			func Run(c *a.Client) {
+				var cfg a.Config
+				_ = cfg
+			}
+		`,
+	}
+	modulePackages := map[string]bool{}
+	for dir := range sources {
+		modulePackages[path.Join(modulePath, dir)] = true
+	}
+
+	var pkgs []*goPackage
+	for _, dir := range []string{"a", "b"} {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, dir+".go", sources[dir], parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := loadPackageWithFiles(modulePath, dir, dir, []*ast.File{f}, fset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		symbols, uses := extractSymbols(d, func(p string) bool { return modulePackages[p] })
+		pkgs = append(pkgs, &goPackage{
+			path:       path.Join(modulePath, dir),
+			symbols:    symbols,
+			symbolUses: uses,
+		})
+	}
+	linkSymbolReferences(pkgs)
+
+	ref := func(dir, name string) *internal.SymbolReference {
+		return &internal.SymbolReference{PackagePath: path.Join(modulePath, dir), Name: name}
+	}
+	want := map[string][]*internal.Symbol{
+		"a": {
+			{Name: "Client", Kind: internal.SymbolKindType, References: []*internal.SymbolReference{
+				ref("a", "Config"),
+				ref("a", "NewClient"),
+				ref("b", "Run"),
+			}},
+			{Name: "Client.Do", Kind: internal.SymbolKindMethod},
+			{Name: "Config", Kind: internal.SymbolKindType, References: []*internal.SymbolReference{
+				ref("a", "NewClient"),
+				ref("b", "DefaultConfig"),
+			}},
+			{Name: "Config.Client", Kind: internal.SymbolKindField},
+			{Name: "Config.Name", Kind: internal.SymbolKindField},
+			{Name: "Config.Options", Kind: internal.SymbolKindField},
+			{Name: "Max", Kind: internal.SymbolKindConstant},
+			{Name: "NewClient", Kind: internal.SymbolKindFunction},
+			{Name: "Options", Kind: internal.SymbolKindType, References: []*internal.SymbolReference{
+				ref("a", "Client.Do"),
+				ref("a", "Config"),
+				ref("b", "Doer"),
+			}},
+		},
+		"b": {
+			{Name: "DefaultConfig", Kind: internal.SymbolKindVariable},
+			{Name: "Doer", Kind: internal.SymbolKindType},
+			{Name: "Doer.Do", Kind: internal.SymbolKindMethod},
+			{Name: "Run", Kind: internal.SymbolKindFunction},
+		},
+	}
+	for _, p := range pkgs {
+		if diff := cmp.Diff(want[path.Base(p.path)], p.symbols); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", p.path, diff)
+		}
+		if p.symbolUses != nil {
+			t.Errorf("%s: symbolUses not cleared", p.path)
+		}
+	}
+}
@@ -61,6 +61,7 @@ func moduleUnits(modulePath, version string,
 		if pkg, ok := pkgLookup[dirPath]; ok {
 			dir.Name = pkg.name
 			dir.Imports = pkg.imports
+			dir.Symbols = pkg.symbols
 			dir.Documentation = &internal.Documentation{
 				GOOS:     pkg.goos,
 				GOARCH:   pkg.goarch,
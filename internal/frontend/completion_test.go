@@ -6,6 +6,11 @@ package frontend
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/alicebob/miniredis/v2"
@@ -49,47 +54,73 @@ func TestAutoCompletion(t *testing.T) {
 			r.ZAdd(complete.RemainingKey, zs...)
 		}
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
-	defer cancel()
+	server := &Server{completer: complete.NewRedisCompleter(r)}
 
 	tests := []struct {
 		q    string
 		want []string
 	}{
 		{"baz", []string{"foo.com/bar/baz"}},
-		{"bar", []string{"foo.com/bar/baz", "foo.com/quux/bark"}},
-		{"foo", []string{"github.com/something/foo", "foo.com/bar/baz"}},
+		{"BAR", []string{"foo.com/bar/baz", "foo.com/quux/bark"}},
+		{"foo", []string{"github.com/something/foo", "foo.com/bar/baz", "foo.com/quux/bark"}},
+		{"nothing", []string{}},
 	}
 	for _, test := range tests {
 		t.Run(test.q, func(t *testing.T) {
-			results, err := doCompletion(ctx, r, test.q, 2)
-			if err != nil {
-				t.Fatal(err)
-			}
-			var got []string
-			for _, res := range results {
-				got = append(got, res.PackagePath)
+			got, code := autoComplete(t, server, test.q)
+			if code != http.StatusOK {
+				t.Fatalf("got status %d, want %d", code, http.StatusOK)
 			}
 			if diff := cmp.Diff(test.want, got); diff != "" {
-				t.Errorf("doCompletion(%q) mismatch (-want +got)\n%s", test.q, diff)
+				t.Errorf("autocomplete %q mismatch (-want +got)\n%s", test.q, diff)
 			}
 		})
 	}
 }
 
-func TestNextPrefix(t *testing.T) {
-	tests := []struct {
-		prefix, want string
-	}{
-		{"", ""},
-		{"~~~", ""},
-		{"aa", "ab"},
-		{"aB", "aC"},
-		{"a~", "b"},
+func TestAutoCompletionWithoutCompleter(t *testing.T) {
+	// autocomplete.js needs an empty array, not null.
+	got, code := autoComplete(t, &Server{}, "foo")
+	if code != http.StatusOK || got == nil || len(got) != 0 {
+		t.Errorf("got %v, status %d; want [], status %d", got, code, http.StatusOK)
 	}
-	for _, test := range tests {
-		if got := nextPrefix(test.prefix); got != test.want {
-			t.Errorf("nextPrefix(%q) = %q, want %q", test.prefix, got, test.want)
-		}
+}
+
+func TestAutoCompletionError(t *testing.T) {
+	_, code := autoComplete(t, &Server{completer: errorCompleter{}}, "foo")
+	if code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want %d", code, http.StatusInternalServerError)
+	}
+}
+
+// autoComplete requests completions of q from s, and returns the package
+// paths of the response and its status code.
+func autoComplete(t *testing.T, s *Server, q string) ([]string, int) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.handleAutoCompletion(w, httptest.NewRequest("GET", "/autocomplete?q="+url.QueryEscape(q), nil))
+	if w.Code != http.StatusOK {
+		return nil, w.Code
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", ct)
+	}
+	var completions []*complete.Completion
+	if err := json.Unmarshal(w.Body.Bytes(), &completions); err != nil {
+		t.Fatal(err)
+	}
+	if completions == nil {
+		return nil, w.Code
 	}
+	paths := []string{}
+	for _, c := range completions {
+		paths = append(paths, c.PackagePath)
+	}
+	return paths, w.Code
+}
+
+type errorCompleter struct{}
+
+func (errorCompleter) Complete(context.Context, string, int) ([]*complete.Completion, error) {
+	return nil, errors.New("bad completer")
 }
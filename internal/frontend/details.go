@@ -22,7 +22,9 @@ import (
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/middleware"
 	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/queue"
 	"golang.org/x/pkgsite/internal/stdlib"
 )
 
@@ -61,6 +63,10 @@ type DetailsPage struct {
 	// For example, if the latest version of /my.module/pkg is version v1.5.2,
 	// the canonical url for that path would be /my.module@v1.5.2/pkg
 	CanonicalURLPath string
+
+	// Unreleased indicates that the page is for the master branch of the
+	// standard library.
+	Unreleased bool
 }
 
 const (
@@ -144,18 +150,23 @@ func (s *Server) serveDetails(w http.ResponseWriter, r *http.Request, ds interna
 
 	urlInfo.modulePath = um.ModulePath
 	urlInfo.resolvedVersion = um.Version
-	if urlInfo.requestedVersion == internal.MasterVersion {
+	// Tag the cached page so that the worker can invalidate it when the
+	// module or the unit changes.
+	middleware.AddCacheTags(ctx, middleware.ModuleCacheTag(um.ModulePath), middleware.PathCacheTag(um.Path))
+	if urlInfo.requestedVersion == internal.MasterVersion && urlInfo.modulePath != stdlib.ModulePath {
 		// Since path@master is a moving target, we don't want it to be stale.
 		// As a result, we enqueue every request of path@master to the frontend
 		// task queue, which will initiate a fetch request depending on the
-		// last time we tried to fetch this module version.
+		// last time we tried to fetch this module version. (The worker
+		// fetches std@master on a schedule, since cloning the Go repo is
+		// expensive.)
 		//
 		// Use a separate context here to prevent the context from being canceled
 		// elsewhere before a task is enqueued.
 		go func() {
 			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 			defer cancel()
-			if _, err := s.queue.ScheduleFetch(ctx, urlInfo.modulePath, internal.MasterVersion, "", s.taskIDChangeInterval); err != nil {
+			if _, err := s.queue.ScheduleFetch(ctx, urlInfo.modulePath, internal.MasterVersion, "", s.taskIDChangeInterval, queue.PriorityInteractive); err != nil {
 				log.Errorf(ctx, "serveDetails(%q): %v", r.URL.Path, err)
 			}
 		}()
@@ -335,9 +346,6 @@ func validatePathAndVersion(ctx context.Context, ds internal.DataSource, fullPat
 
 // isSupportedVersion reports whether the version is supported by the frontend.
 func isSupportedVersion(fullPath, requestedVersion string) bool {
-	if stdlib.Contains(fullPath) && requestedVersion == internal.MasterVersion {
-		return false
-	}
 	if requestedVersion == internal.LatestVersion || semver.IsValid(requestedVersion) {
 		return true
 	}
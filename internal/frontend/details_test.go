@@ -68,6 +68,24 @@ func TestExtractURLPathInfo(t *testing.T) {
 				isModule:         false,
 			},
 		},
+		{
+			"/encoding/json@master",
+			&urlPathInfo{
+				fullPath:         "encoding/json",
+				modulePath:       "std",
+				requestedVersion: internal.MasterVersion,
+				isModule:         false,
+			},
+		},
+		{
+			"/encoding/json@v0.0.0-20201019123456-abcdefabcdef",
+			&urlPathInfo{
+				fullPath:         "encoding/json",
+				modulePath:       "std",
+				requestedVersion: "v0.0.0-20201019123456-abcdefabcdef",
+				isModule:         false,
+			},
+		},
 		{
 			"/mod/a.com",
 			&urlPathInfo{
@@ -216,6 +234,7 @@ func TestValidatePathAndVersion(t *testing.T) {
 	}{
 		{"import/path", "v1.2.3", http.StatusOK},
 		{"import/path", "v1.2.bad", http.StatusBadRequest},
+		{"encoding/json", internal.MasterVersion, http.StatusOK},
 	}
 
 	for _, test := range tests {
@@ -75,6 +75,7 @@ func (s *Server) serveDirectoryPage(ctx context.Context, w http.ResponseWriter,
 		Tabs:             directoryTabSettings,
 		PageType:         pageTypeDirectory,
 		CanonicalURLPath: constructPackageURL(um.Path, um.ModulePath, linkver),
+		Unreleased:       isUnreleased(um.Version, um.ModulePath),
 	}
 	s.servePage(ctx, w, settings.TemplateName, page)
 	return nil
@@ -44,13 +44,16 @@ func fileSource(modulePath, version, filePath string) string {
 	}
 
 	root := strings.TrimPrefix(stdlib.GoRepoURL, "https://")
-	tag, err := stdlib.TagForVersion(version)
+	rev, err := stdlib.RevisionForVersion(version)
 	if err != nil {
 		// This should never happen unless there is a bug in
-		// stdlib.TagForVersion. In which case, fallback to the default
+		// stdlib.RevisionForVersion. In which case, fallback to the default
 		// zipFilePath.
 		log.Errorf(context.TODO(), "fileSource: %v", err)
 		return fmt.Sprintf("%s/+/refs/heads/master/%s", root, filePath)
 	}
-	return fmt.Sprintf("%s/+/refs/tags/%s/%s", root, tag, filePath)
+	if stdlib.IsTipVersion(version) {
+		return fmt.Sprintf("%s/+/%s/%s", root, rev, filePath)
+	}
+	return fmt.Sprintf("%s/+/refs/tags/%s/%s", root, rev, filePath)
 }
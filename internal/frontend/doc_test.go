@@ -28,6 +28,12 @@ func TestFileSource(t *testing.T) {
 			filePath:   "README.md",
 			want:       fmt.Sprintf("go.googlesource.com/go/+/refs/tags/%s/%s", "go1.13", "README.md"),
 		},
+		{
+			modulePath: stdlib.ModulePath,
+			version:    "v0.0.0-20201019123456-abcdefabcdef",
+			filePath:   "README.md",
+			want:       fmt.Sprintf("go.googlesource.com/go/+/%s/%s", "abcdefabcdef", "README.md"),
+		},
 		{
 			modulePath: stdlib.ModulePath,
 			version:    "v1.13.invalid",
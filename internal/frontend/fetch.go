@@ -25,6 +25,7 @@ import (
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/queue"
 	"golang.org/x/pkgsite/internal/source"
 	"golang.org/x/pkgsite/internal/stdlib"
 )
@@ -175,7 +176,7 @@ func (s *Server) checkPossibleModulePaths(ctx context.Context, db *postgres.DB,
 			}
 			// A row for this modulePath and requestedVersion combination does not
 			// exist in version_map. Enqueue the module version to be fetched.
-			if _, err := s.queue.ScheduleFetch(ctx, modulePath, requestedVersion, "", s.taskIDChangeInterval); err != nil {
+			if _, err := s.queue.ScheduleFetch(ctx, modulePath, requestedVersion, "", s.taskIDChangeInterval, queue.PriorityInteractive); err != nil {
 				fr.err = err
 				fr.status = http.StatusInternalServerError
 			}
@@ -216,8 +217,8 @@ func fetchRequestStatusAndResponseText(results []*fetchResult, fullPath, request
 			// finished, return an error letting the user to check back later. The
 			// worker will still be processing the modules in the background.
 			return fr.status, fmt.Sprintf("We're still working on “%s”. Check back in a few minutes!", displayPath(fullPath, requestedVersion))
-		case http.StatusInternalServerError:
-			return fr.status, "Oops! Something went wrong."
+		case http.StatusInternalServerError, http.StatusBadGateway:
+			return http.StatusInternalServerError, "Oops! Something went wrong."
 		case derrors.ToStatus(derrors.AlternativeModule):
 			// TODO(https://golang.org/issue/40306): Make the canonical module
 			// path a clickable link.
@@ -338,7 +339,8 @@ func checkForPath(ctx context.Context, db *postgres.DB,
 	switch fr.status {
 	case http.StatusNotFound,
 		derrors.ToStatus(derrors.DBModuleInsertInvalid),
-		http.StatusInternalServerError:
+		http.StatusInternalServerError,
+		http.StatusBadGateway:
 		if time.Since(vm.UpdatedAt) > taskIDChangeInterval {
 			// If the duration of taskIDChangeInterval has passed since
 			// a module_path was last inserted into version_map with a failed status,
@@ -494,7 +496,7 @@ func FetchAndUpdateState(ctx context.Context, modulePath, requestedVersion strin
 		derrors.Wrap(&err, "FetchAndUpdateState(%q, %q)", modulePath, requestedVersion)
 	}()
 
-	fr := fetch.FetchModule(ctx, modulePath, requestedVersion, proxyClient, sourceClient)
+	fr := fetch.FetchModule(ctx, modulePath, requestedVersion, proxyClient, sourceClient, db.GetSymbolNames)
 	defer fr.Defer()
 	if fr.Error == nil {
 		// Only attempt to insert the module into module_version_states if the
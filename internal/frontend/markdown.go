@@ -0,0 +1,231 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"bytes"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"strings"
+	"unicode"
+
+	"github.com/russross/blackfriday/v2"
+	"golang.org/x/net/html"
+)
+
+const (
+	// minTOCHeadings is the number of headings a README must have for a
+	// table of contents to be rendered above it.
+	minTOCHeadings = 5
+
+	// maxTOCLevel is the level of the deepest headings that appear in the
+	// table of contents.
+	maxTOCLevel = 3
+)
+
+// markdownExtensions are the blackfriday extensions used to parse READMEs.
+// Together with the changes made by processMarkdown, they approximate
+// GitHub-flavored markdown.
+const markdownExtensions = blackfriday.CommonExtensions | blackfriday.Footnotes
+
+// A readmeHeading is a heading of a README, used to build its table of
+// contents.
+type readmeHeading struct {
+	level int
+	text  string
+	id    string
+}
+
+// processMarkdown modifies the markdown tree rooted at root to support
+// GitHub-flavored markdown features that blackfriday lacks:
+//  - Headings are given the same IDs that GitHub gives them, so that links
+//    to sections of a README work as they do on GitHub.
+//  - List items that start with "[ ]" or "[x]" are rendered with a checkbox.
+//
+// It returns the headings of the README.
+func processMarkdown(root *blackfriday.Node) []readmeHeading {
+	var headings []readmeHeading
+	ids := headingIDs{}
+	root.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering {
+			return blackfriday.GoToNext
+		}
+		switch node.Type {
+		case blackfriday.Heading:
+			text := headingText(node)
+			if node.HeadingID == "" {
+				node.HeadingID = ids.next(text)
+			}
+			headings = append(headings, readmeHeading{level: node.Level, text: text, id: node.HeadingID})
+			return blackfriday.SkipChildren
+		case blackfriday.Item:
+			addTaskCheckbox(node)
+		}
+		return blackfriday.GoToNext
+	})
+	return headings
+}
+
+// headingText returns the text of the heading node n, without formatting.
+func headingText(n *blackfriday.Node) string {
+	var b strings.Builder
+	n.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && (node.Type == blackfriday.Text || node.Type == blackfriday.Code) {
+			b.Write(node.Literal)
+		}
+		return blackfriday.GoToNext
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// headingIDs gives headings the IDs that GitHub gives them. It maps each ID
+// to the number of headings that have been given it.
+type headingIDs map[string]int
+
+// next returns the ID of the next heading, whose text is text. Like GitHub,
+// it adds a numeric suffix to make duplicate IDs unique.
+func (ids headingIDs) next(text string) string {
+	id := githubSlug(text)
+	n := ids[id]
+	ids[id]++
+	if n > 0 {
+		return fmt.Sprintf("%s-%d", id, n)
+	}
+	return id
+}
+
+// githubSlug returns the ID that GitHub gives to a heading with the given
+// text: the text is lower-cased, characters other than letters, digits,
+// marks, underscores, hyphens and spaces are removed, and spaces are
+// replaced by hyphens.
+func githubSlug(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == ' ':
+			b.WriteByte('-')
+		case r == '-' || unicode.In(r, unicode.L, unicode.M, unicode.Nd, unicode.Pc):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// addTaskCheckbox replaces the "[ ]" or "[x]" that starts the list item
+// node with a disabled checkbox, as GitHub does for task lists.
+func addTaskCheckbox(item *blackfriday.Node) {
+	p := item.FirstChild
+	if p == nil || p.Type != blackfriday.Paragraph {
+		return
+	}
+	text := p.FirstChild
+	if text == nil || text.Type != blackfriday.Text || len(text.Literal) < 4 || text.Literal[3] != ' ' {
+		return
+	}
+	var checkbox string
+	switch string(text.Literal[:3]) {
+	case "[ ]":
+		checkbox = `<input type="checkbox" disabled=""/>`
+	case "[x]", "[X]":
+		checkbox = `<input type="checkbox" checked="" disabled=""/>`
+	default:
+		return
+	}
+	text.Literal = text.Literal[3:]
+	span := blackfriday.NewNode(blackfriday.HTMLSpan)
+	span.Literal = []byte(checkbox)
+	text.InsertBefore(span)
+}
+
+// writeTOC writes a table of contents for headings to w, if there are at
+// least minTOCHeadings of them. The table of contents is a nested list of
+// links, in a details element so that it can be collapsed.
+func writeTOC(w *bytes.Buffer, headings []readmeHeading) {
+	var hs []readmeHeading
+	minLevel := maxTOCLevel
+	for _, h := range headings {
+		if h.level <= maxTOCLevel && h.id != "" {
+			hs = append(hs, h)
+			if h.level < minLevel {
+				minLevel = h.level
+			}
+		}
+	}
+	if len(hs) < minTOCHeadings {
+		return
+	}
+	w.WriteString("<details open=\"\"><summary>Contents</summary>\n")
+	depth := 0
+	for _, h := range hs {
+		level := h.level - minLevel + 1
+		if level > depth {
+			for ; depth < level; depth++ {
+				w.WriteString("<ul>\n<li>")
+			}
+		} else {
+			w.WriteString("</li>\n")
+			for ; depth > level; depth-- {
+				w.WriteString("</ul>\n</li>\n")
+			}
+			w.WriteString("<li>")
+		}
+		fmt.Fprintf(w, `<a href="#%s">%s</a>`, html.EscapeString(h.id), html.EscapeString(h.text))
+	}
+	w.WriteString("</li>\n")
+	for ; depth > 0; depth-- {
+		w.WriteString("</ul>\n")
+		if depth > 1 {
+			w.WriteString("</li>\n")
+		}
+	}
+	w.WriteString("</details>\n\n")
+}
+
+// isGoCode reports whether the info string of a fenced code block says
+// that it contains Go code.
+func isGoCode(info []byte) bool {
+	fields := strings.Fields(string(info))
+	return len(fields) > 0 && (fields[0] == "go" || fields[0] == "golang")
+}
+
+// writeGoCodeBlock writes src to w as a code block, with Go comments,
+// keywords and literal strings wrapped in spans whose class is "comment",
+// "keyword" or "string". Code that does not scan as Go is written as is.
+func writeGoCodeBlock(w *bytes.Buffer, src []byte) {
+	w.WriteString("<pre><code>")
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+	last := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		var class string
+		switch {
+		case tok == token.COMMENT:
+			class = "comment"
+		case tok.IsKeyword():
+			class = "keyword"
+		case tok == token.STRING || tok == token.CHAR:
+			class = "string"
+		default:
+			continue
+		}
+		start := file.Offset(pos)
+		end := start + len(lit)
+		if start < last || end > len(src) {
+			continue
+		}
+		w.WriteString(html.EscapeString(string(src[last:start])))
+		fmt.Fprintf(w, `<span class="%s">%s</span>`, class, html.EscapeString(string(src[start:end])))
+		last = end
+	}
+	w.WriteString(html.EscapeString(string(src[last:])))
+	w.WriteString("</code></pre>\n")
+}
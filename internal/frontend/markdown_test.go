@@ -0,0 +1,26 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import "testing"
+
+func TestGithubSlug(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"Installation", "installation"},
+		{"Getting Started!", "getting-started"},
+		{"foo_bar-baz", "foo_bar-baz"},
+		{"What's new in v1.2?", "whats-new-in-v12"},
+		{"  Spaces  ", "--spaces--"},
+		{"Über Straße", "über-straße"},
+		{"日本語", "日本語"},
+		{"???", ""},
+	} {
+		if got := githubSlug(test.in); got != test.want {
+			t.Errorf("githubSlug(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/safehtml"
+	"golang.org/x/net/html"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/markup"
+	"golang.org/x/pkgsite/internal/source"
+)
+
+// markupHTML renders doc, a README in one of the formats of package markup,
+// like markdown READMEs: relative links are translated, headings are given
+// the IDs that GitHub gives them, long READMEs are preceded by a table of
+// contents, Go code is highlighted and the result is sanitized.
+func markupHTML(doc *markup.Document, info *source.Info, readme *internal.Readme) safehtml.HTML {
+	r := &markupRenderer{info: info, readme: readme, ids: map[*markup.Block]string{}}
+	var headings []readmeHeading
+	ids := headingIDs{}
+	var collect func([]*markup.Block)
+	collect = func(blocks []*markup.Block) {
+		for _, bl := range blocks {
+			switch bl.Kind {
+			case markup.Heading:
+				text := markup.PlainText(bl.Inlines)
+				r.ids[bl] = ids.next(text)
+				headings = append(headings, readmeHeading{level: bl.Level, text: text, id: r.ids[bl]})
+			case markup.List:
+				for _, item := range bl.Items {
+					collect(item)
+				}
+			case markup.Quote:
+				collect(bl.Blocks)
+			}
+		}
+	}
+	collect(doc.Blocks)
+
+	b := &bytes.Buffer{}
+	writeTOC(b, headings)
+	r.writeBlocks(b, doc.Blocks)
+	return sanitizeHTML(b)
+}
+
+// markupRenderer renders a markup.Document as HTML.
+type markupRenderer struct {
+	info   *source.Info
+	readme *internal.Readme
+	ids    map[*markup.Block]string // IDs of headings
+}
+
+func (r *markupRenderer) writeBlocks(b *bytes.Buffer, blocks []*markup.Block) {
+	for _, bl := range blocks {
+		switch bl.Kind {
+		case markup.Heading:
+			if id := r.ids[bl]; id != "" {
+				fmt.Fprintf(b, `<h%d id="%s">`, bl.Level, html.EscapeString(id))
+			} else {
+				fmt.Fprintf(b, "<h%d>", bl.Level)
+			}
+			r.writeInlines(b, bl.Inlines)
+			fmt.Fprintf(b, "</h%d>\n\n", bl.Level)
+		case markup.Paragraph:
+			b.WriteString("<p>")
+			r.writeInlines(b, bl.Inlines)
+			b.WriteString("</p>\n\n")
+		case markup.CodeBlock:
+			if isGoCode([]byte(bl.Lang)) {
+				writeGoCodeBlock(b, []byte(bl.Text))
+			} else {
+				fmt.Fprintf(b, "<pre><code>%s</code></pre>\n", html.EscapeString(bl.Text))
+			}
+			b.WriteString("\n")
+		case markup.List:
+			tag := "ul"
+			if bl.Ordered {
+				tag = "ol"
+			}
+			fmt.Fprintf(b, "<%s>\n", tag)
+			for _, item := range bl.Items {
+				b.WriteString("<li>")
+				if len(item) == 1 && item[0].Kind == markup.Paragraph {
+					r.writeInlines(b, item[0].Inlines)
+				} else {
+					b.WriteString("\n")
+					r.writeBlocks(b, item)
+				}
+				b.WriteString("</li>\n")
+			}
+			fmt.Fprintf(b, "</%s>\n\n", tag)
+		case markup.Quote:
+			b.WriteString("<blockquote>\n")
+			r.writeBlocks(b, bl.Blocks)
+			b.WriteString("</blockquote>\n\n")
+		case markup.Rule:
+			b.WriteString("<hr/>\n\n")
+		}
+	}
+}
+
+func (r *markupRenderer) writeInlines(b *bytes.Buffer, inlines []*markup.Inline) {
+	for _, in := range inlines {
+		switch in.Kind {
+		case markup.Text:
+			b.WriteString(html.EscapeString(in.Text))
+		case markup.Emphasis:
+			b.WriteString("<em>")
+			r.writeInlines(b, in.Children)
+			b.WriteString("</em>")
+		case markup.Strong:
+			b.WriteString("<strong>")
+			r.writeInlines(b, in.Children)
+			b.WriteString("</strong>")
+		case markup.Code:
+			fmt.Fprintf(b, "<code>%s</code>", html.EscapeString(in.Text))
+		case markup.Link:
+			fmt.Fprintf(b, `<a href="%s">`, html.EscapeString(r.translate(in.URL, false)))
+			r.writeInlines(b, in.Children)
+			b.WriteString("</a>")
+		case markup.Image:
+			fmt.Fprintf(b, `<img src="%s" alt="%s"/>`, html.EscapeString(r.translate(in.URL, true)), html.EscapeString(in.Text))
+		}
+	}
+}
+
+// translate returns the URL that a link or image with the destination dest
+// should have. See translateRelativeLink.
+func (r *markupRenderer) translate(dest string, image bool) string {
+	if d := translateRelativeLink(dest, r.info, image, r.readme); d != "" {
+		return d
+	}
+	return dest
+}
@@ -58,6 +58,7 @@ func (s *Server) serveModulePage(ctx context.Context, w http.ResponseWriter, r *
 			um.ModulePath,
 			linkVersion(um.Version, um.ModulePath),
 		),
+		Unreleased: isUnreleased(um.Version, um.ModulePath),
 	}
 	s.servePage(ctx, w, settings.TemplateName, page)
 	return nil
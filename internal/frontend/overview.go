@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/google/safehtml"
@@ -23,6 +24,7 @@ import (
 	"golang.org/x/net/html/atom"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/markup"
 	"golang.org/x/pkgsite/internal/source"
 )
 
@@ -98,7 +100,11 @@ func fetchPackageOverviewDetails(ctx context.Context, ds internal.DataSource, um
 
 // ReadmeHTML sanitizes readmeContents based on bluemondy.UGCPolicy and returns
 // a safehtml.HTML. If readmeFilePath indicates that this is a markdown file,
-// it will also render the markdown contents using blackfriday.
+// it will also render the markdown contents using blackfriday, with the
+// changes described at processMarkdown, and precede long READMEs with a table
+// of contents. reStructuredText, AsciiDoc and org-mode READMEs are parsed by
+// package markup and rendered in the same way. READMEs in other formats are
+// shown as preformatted text.
 //
 // It is exported to support external testing.
 func ReadmeHTML(ctx context.Context, mi *internal.ModuleInfo, readme *internal.Readme) (_ safehtml.HTML, err error) {
@@ -106,6 +112,9 @@ func ReadmeHTML(ctx context.Context, mi *internal.ModuleInfo, readme *internal.R
 	if readme == nil || readme.Contents == "" {
 		return safehtml.HTML{}, nil
 	}
+	if f := markup.FormatOf(readme.Filepath); f != markup.Unknown {
+		return markupHTML(markup.Parse(f, readme.Contents), mi.SourceInfo, readme), nil
+	}
 	if !isMarkdown(readme.Filepath) {
 		t := template.Must(template.New("").Parse(`<pre class="readme">{{.}}</pre>`))
 		h, err := t.ExecuteToHTML(readme.Contents)
@@ -117,16 +126,22 @@ func ReadmeHTML(ctx context.Context, mi *internal.ModuleInfo, readme *internal.R
 
 	// blackfriday.Run() uses CommonHTMLFlags and CommonExtensions by default.
 	renderer := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{Flags: blackfriday.CommonHTMLFlags})
-	parser := blackfriday.New(blackfriday.WithExtensions(blackfriday.CommonExtensions | blackfriday.AutoHeadingIDs))
+	parser := blackfriday.New(blackfriday.WithExtensions(markdownExtensions))
 
 	// Render HTML similar to blackfriday.Run(), but here we implement a custom
 	// Walk function in order to modify image paths in the rendered HTML.
 	b := &bytes.Buffer{}
 	contents := bytes.ReplaceAll([]byte(readme.Contents), []byte("\r"), nil)
 	rootNode := parser.Parse(contents)
+	writeTOC(b, processMarkdown(rootNode))
 	var walkErr error
 	rootNode.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
 		switch node.Type {
+		case blackfriday.CodeBlock:
+			if isGoCode(node.Info) {
+				writeGoCodeBlock(b, node.Literal)
+				return blackfriday.GoToNext
+			}
 		case blackfriday.Image, blackfriday.Link:
 			useRaw := node.Type == blackfriday.Image
 			if d := translateRelativeLink(string(node.LinkData.Destination), mi.SourceInfo, useRaw, readme); d != "" {
@@ -162,6 +177,12 @@ func sanitizeHTML(r io.Reader) safehtml.HTML {
 	p.AllowAttrs("width", "align").OnElements("img")
 	p.AllowAttrs("width", "align").OnElements("div")
 	p.AllowAttrs("width", "align").OnElements("p")
+
+	// Allow the checkboxes of task lists, and the classes used to highlight
+	// Go code.
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^$`)).OnElements("input")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^(comment|keyword|string)$`)).OnElements("span")
 	s := p.SanitizeReader(r).String()
 	// Trust that bluemonday properly sanitizes the HTML.
 	return uncheckedconversions.HTMLFromStringKnownToSatisfyTypeContract(s)
@@ -230,7 +230,7 @@ func TestReadmeHTML(t *testing.T) {
 			name: "not markdown readme",
 			mi:   &internal.ModuleInfo{},
 			readme: &internal.Readme{
-				Filepath: "README.rst",
+				Filepath: "README.txt",
 				Contents: "This package collects pithy sayings.\n\n" +
 					"It's part of a demonstration of\n" +
 					"[package versioning in Go](https://research.swtch.com/vgo1).",
@@ -356,6 +356,120 @@ func TestReadmeHTML(t *testing.T) {
 			},
 			want: `<p><img src="https://github.com/some/repo/raw/v1.2.3/images/Jupyter%20Notebook_sparkline.svg"/></p>`,
 		},
+		{
+			name: "reStructuredText readme",
+			mi:   aModule,
+			readme: &internal.Readme{
+				Filepath: "README.rst",
+				Contents: "Title\n=====\n\nA *fast* ``thing``. See `the guide <doc/guide.rst>`_.\n\n" +
+					".. image:: doc/logo.png\n   :alt: logo\n\n" +
+					".. raw:: html\n\n   <script>alert(1)</script>\n",
+			},
+			want: `<h1 id="title">Title</h1>` + "\n\n" +
+				`<p>A <em>fast</em> <code>thing</code>. See <a href="https://github.com/some/repo/blob/v1.2.3/doc/guide.rst" rel="nofollow">the guide</a>.</p>` + "\n\n" +
+				`<p><img src="https://github.com/some/repo/raw/v1.2.3/doc/logo.png" alt="logo"/></p>`,
+		},
+		{
+			name: "AsciiDoc readme",
+			mi:   aModule,
+			readme: &internal.Readme{
+				Filepath: "README.adoc",
+				Contents: "= Title\n\nA *fast* `thing`. See link:doc/guide.adoc[the guide].\n\n" +
+					"[source,go]\n----\nfunc f() {}\n----\n\n" +
+					"++++\n<script>alert(1)</script>\n++++\n",
+			},
+			want: `<h1 id="title">Title</h1>` + "\n\n" +
+				`<p>A <strong>fast</strong> <code>thing</code>. See <a href="https://github.com/some/repo/blob/v1.2.3/doc/guide.adoc" rel="nofollow">the guide</a>.</p>` + "\n\n" +
+				`<pre><code><span class="keyword">func</span> f() {}` + "\n</code></pre>",
+		},
+		{
+			name: "org-mode readme",
+			mi:   aModule,
+			readme: &internal.Readme{
+				Filepath: "README.org",
+				Contents: "* Title\n\nA /fast/ =thing=. <b>Escaped</b> [[./logo.png]]\n\n- one\n- two\n",
+			},
+			want: `<h1 id="title">Title</h1>` + "\n\n" +
+				`<p>A <em>fast</em> <code>thing</code>. &lt;b&gt;Escaped&lt;/b&gt; <img src="https://github.com/some/repo/raw/v1.2.3/logo.png" alt=""/></p>` + "\n\n" +
+				"<ul>\n<li>one</li>\n<li>two</li>\n</ul>",
+		},
+		{
+			name: "table with alignment",
+			mi:   &internal.ModuleInfo{},
+			readme: &internal.Readme{
+				Filepath: "README.md",
+				Contents: "| a | b |\n|:--|--:|\n| 1 | 2 |\n",
+			},
+			want: "<table>\n<thead>\n<tr>\n" +
+				`<th align="left">a</th>` + "\n" + `<th align="right">b</th>` + "\n" +
+				"</tr>\n</thead>\n\n<tbody>\n<tr>\n" +
+				`<td align="left">1</td>` + "\n" + `<td align="right">2</td>` + "\n" +
+				"</tr>\n</tbody>\n</table>",
+		},
+		{
+			name: "task list",
+			mi:   &internal.ModuleInfo{},
+			readme: &internal.Readme{
+				Filepath: "README.md",
+				Contents: "- [ ] todo\n- [x] done\n- [y] neither\n",
+			},
+			want: "<ul>\n" +
+				`<li><input type="checkbox" disabled=""/> todo</li>` + "\n" +
+				`<li><input type="checkbox" checked="" disabled=""/> done</li>` + "\n" +
+				"<li>[y] neither</li>\n" +
+				"</ul>",
+		},
+		{
+			name: "footnote",
+			mi:   &internal.ModuleInfo{},
+			readme: &internal.Readme{
+				Filepath: "README.md",
+				Contents: "Note[^1].\n\n[^1]: The note.\n",
+			},
+			want: `<p>Note<sup id="fnref:1"><a href="#fn:1" rel="nofollow">1</a></sup>.</p>` + "\n\n" +
+				"<div>\n\n<hr/>\n\n<ol>\n" + `<li id="fn:1">The note.</li>` + "\n</ol>\n\n</div>",
+		},
+		{
+			name: "heading IDs match GitHub",
+			mi:   &internal.ModuleInfo{},
+			readme: &internal.Readme{
+				Filepath: "README.md",
+				Contents: "## Install It!\n\n### `Foo` bar\n\n## Install it\n",
+			},
+			want: `<h2 id="install-it">Install It!</h2>` + "\n\n" +
+				`<h3 id="foo-bar"><code>Foo</code> bar</h3>` + "\n\n" +
+				`<h2 id="install-it-1">Install it</h2>`,
+		},
+		{
+			name: "highlighted Go code",
+			mi:   &internal.ModuleInfo{},
+			readme: &internal.Readme{
+				Filepath: "README.md",
+				Contents: "```go\n// Hello\nfunc main() { println(\"<b>\") }\n```\n",
+			},
+			want: `<pre><code><span class="comment">// Hello</span>` + "\n" +
+				`<span class="keyword">func</span> main() { println(<span class="string">&#34;&lt;b&gt;&#34;</span>) }` + "\n" +
+				"</code></pre>",
+		},
+		{
+			name: "table of contents",
+			mi:   &internal.ModuleInfo{},
+			readme: &internal.Readme{
+				Filepath: "README.md",
+				Contents: "# A\n\n## B\n\n### C\n\n#### D\n\n## E\n\n## F\n",
+			},
+			want: `<details open=""><summary>Contents</summary>` + "\n" +
+				"<ul>\n" +
+				`<li><a href="#a" rel="nofollow">A</a><ul>` + "\n" +
+				`<li><a href="#b" rel="nofollow">B</a><ul>` + "\n" +
+				`<li><a href="#c" rel="nofollow">C</a></li>` + "\n" +
+				"</ul>\n</li>\n" +
+				`<li><a href="#e" rel="nofollow">E</a></li>` + "\n" +
+				`<li><a href="#f" rel="nofollow">F</a></li>` + "\n" +
+				"</ul>\n</li>\n</ul>\n</details>\n\n" +
+				`<h1 id="a">A</h1>` + "\n\n" + `<h2 id="b">B</h2>` + "\n\n" + `<h3 id="c">C</h3>` + "\n\n" +
+				`<h4 id="d">D</h4>` + "\n\n" + `<h2 id="e">E</h2>` + "\n\n" + `<h2 id="f">F</h2>`,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			hgot, err := ReadmeHTML(ctx, tc.mi, tc.readme)
@@ -101,6 +101,7 @@ func (s *Server) servePackagePage(ctx context.Context,
 			pkgHeader.Path,
 			pkgHeader.Module.ModulePath,
 			pkgHeader.Module.LinkVersion),
+		Unreleased: isUnreleased(um.Version, um.ModulePath),
 	}
 	page.basePage.AllowWideContent = settings.Name == tabDoc
 	s.servePage(ctx, w, settings.TemplateName, page)
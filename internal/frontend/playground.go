@@ -13,11 +13,21 @@ import (
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/resilient"
 )
 
 // playgroundURL is the playground endpoint used for share links.
 const playgroundURL = "https://play.golang.org"
 
+// playgroundClient is the client for requests to the playground. Share
+// requests are not idempotent, so they are not retried or hedged.
+var playgroundClient = &http.Client{
+	Transport: resilient.Must(resilient.New(resilient.Config{
+		Name:                 "playground",
+		MaxConcurrentPerHost: 20,
+	})),
+}
+
 var (
 	keyPlaygroundShareStatus = tag.MustNewKey("playground.share.status")
 	playgroundShareStatus    = stats.Int64(
@@ -58,7 +68,7 @@ func makeFetchPlayRequest(w http.ResponseWriter, r *http.Request, pgURL string)
 	}
 	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
 	req = req.WithContext(r.Context())
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := playgroundClient.Do(req)
 	if err != nil {
 		log.Errorf(ctx, "ERROR share error: %v", err)
 		httpErrorStatus(w, http.StatusInternalServerError)
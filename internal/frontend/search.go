@@ -17,6 +17,7 @@ import (
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/middleware"
 	"golang.org/x/pkgsite/internal/postgres"
 )
 
@@ -173,6 +174,11 @@ func (s *Server) serveSearch(w http.ResponseWriter, r *http.Request, ds internal
 	if err != nil {
 		return fmt.Errorf("fetchSearchPage(ctx, db, %q): %v", query, err)
 	}
+	// Tag the cached page with the modules of its results, so that it is
+	// invalidated when one of them changes.
+	for _, res := range page.Results {
+		middleware.AddCacheTags(ctx, middleware.ModuleCacheTag(res.ModulePath))
+	}
 	page.basePage = s.newBasePage(r, query)
 	s.servePage(ctx, w, "search.tmpl", page)
 	return nil
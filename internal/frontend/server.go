@@ -16,9 +16,9 @@ import (
 	"sync"
 	"time"
 
-	"github.com/go-redis/redis/v7"
 	"github.com/google/safehtml/template"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/complete"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/licenses"
@@ -32,9 +32,9 @@ type Server struct {
 	// getDataSource should never be called from a handler. It is called only in Server.errorHandler.
 	getDataSource func(context.Context) internal.DataSource
 	queue         queue.Queue
-	// cmplClient is a redis client that has access to the "completions" sorted
-	// set.
-	cmplClient           *redis.Client
+	// completer autocompletes package paths. If it is nil, autocompletion
+	// returns no results.
+	completer            complete.Completer
 	taskIDChangeInterval time.Duration
 	staticPath           template.TrustedSource
 	thirdPartyPath       string
@@ -55,7 +55,7 @@ type ServerConfig struct {
 	// It should be goroutine-safe.
 	DataSourceGetter     func(context.Context) internal.DataSource
 	Queue                queue.Queue
-	CompletionClient     *redis.Client
+	Completer            complete.Completer
 	TaskIDChangeInterval time.Duration
 	StaticPath           template.TrustedSource
 	ThirdPartyPath       string
@@ -76,7 +76,7 @@ func NewServer(scfg ServerConfig) (_ *Server, err error) {
 	s := &Server{
 		getDataSource:        scfg.DataSourceGetter,
 		queue:                scfg.Queue,
-		cmplClient:           scfg.CompletionClient,
+		completer:            scfg.Completer,
 		staticPath:           scfg.StaticPath,
 		thirdPartyPath:       scfg.ThirdPartyPath,
 		templateDir:          templateDir,
@@ -96,17 +96,19 @@ func NewServer(scfg ServerConfig) (_ *Server, err error) {
 }
 
 // Install registers server routes using the given handler registration func.
+// Pages are cached in cacheStore, unless it is nil.
 // authValues is the set of values that can be set on authHeader to bypass the
 // cache.
-func (s *Server) Install(handle func(string, http.Handler), redisClient *redis.Client, authValues []string) {
+func (s *Server) Install(handle func(string, http.Handler), cacheStore middleware.CacheStore, authValues []string) {
 	var (
 		detailHandler http.Handler = s.errorHandler(s.serveDetails)
 		fetchHandler  http.Handler = s.errorHandler(s.serveFetch)
 		searchHandler http.Handler = s.errorHandler(s.serveSearch)
 	)
-	if redisClient != nil {
-		detailHandler = middleware.Cache("details", redisClient, detailsTTL, authValues)(detailHandler)
-		searchHandler = middleware.Cache("search", redisClient, middleware.TTL(defaultTTL), authValues)(searchHandler)
+	if cacheStore != nil {
+		swr := middleware.StaleWhileRevalidate(staleTTL)
+		detailHandler = middleware.Cache("details", cacheStore, detailsTTL, authValues, swr)(detailHandler)
+		searchHandler = middleware.Cache("search", cacheStore, middleware.TTL(defaultTTL), authValues, swr)(searchHandler)
 	}
 	handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(s.staticPath.String()))))
 	handle("/third_party/", http.StripPrefix("/third_party", http.FileServer(http.Dir(s.thirdPartyPath))))
@@ -145,6 +147,10 @@ const (
 	shortTTL = 10 * time.Minute
 	// longTTL is used when details content is essentially static.
 	longTTL = 24 * time.Hour
+	// staleTTL is how long a page may be served after its TTL, while it is
+	// refreshed in the background. The worker invalidates pages for modules
+	// that change, so stale pages are usually still correct.
+	staleTTL = 1 * time.Hour
 )
 
 // detailsTTL assigns the cache TTL for package detail requests.
@@ -55,6 +55,9 @@ type testModule struct {
 	redistributable bool
 	versions        []string
 	packages        []testPackage
+	// readmes are the suffixes of the directories, other than the module
+	// root, that have a README.
+	readmes []string
 }
 
 type testPackage struct {
@@ -94,6 +97,7 @@ var testModules = []testModule{
 				doc:    `<a href="/pkg/io#Writer">io.Writer</a>`,
 			},
 		},
+		readmes: []string{"foo", "foo/directory"},
 	},
 	{
 		// A non-redistributable module.
@@ -143,6 +147,7 @@ var testModules = []testModule{
 				suffix: "net/http",
 			},
 		},
+		readmes: []string{"cmd"},
 	},
 }
 
@@ -176,6 +181,15 @@ func insertTestModules(ctx context.Context, t *testing.T, mods []testModule) {
 				sample.AddPackage(m, p)
 			}
 			for _, u := range m.Units {
+				suffix := internal.Suffix(u.Path, mod.path)
+				for _, r := range mod.readmes {
+					if r == suffix {
+						u.Readme = &internal.Readme{
+							Filepath: suffix + "/README.md",
+							Contents: "readme for " + suffix,
+						}
+					}
+				}
 				if !mod.redistributable {
 					u.IsRedistributable = false
 					u.Licenses = nil
@@ -474,8 +488,8 @@ func serverTestCases() []serverTestCase {
 					ModuleLinkText: pkgV100.ModulePath,
 					RepoURL:        "https://" + sample.ModulePath,
 					PackageURL:     "https://" + sample.ModulePath + "/tree/v1.0.0/foo",
-					ReadmeContent:  "readme",
-					ReadmeSource:   sample.ModulePath + "@v1.0.0/README.md",
+					ReadmeContent:  "readme for foo",
+					ReadmeSource:   sample.ModulePath + "@v1.0.0/foo/README.md",
 				})),
 		},
 		{
@@ -611,8 +625,8 @@ func serverTestCases() []serverTestCase {
 					ModuleLink:     "/mod/" + sample.ModulePath,
 					ModuleLinkText: dir.ModulePath,
 					RepoURL:        "https://" + sample.ModulePath,
-					ReadmeContent:  "readme",
-					ReadmeSource:   sample.ModulePath + "@v1.0.0/README.md",
+					ReadmeContent:  "readme for foo/directory",
+					ReadmeSource:   sample.ModulePath + "@v1.0.0/foo/directory/README.md",
 				}),
 				in(".js-canonicalURLPath", attr("data-canonical-url-path", "/github.com/valid/module_name@v1.0.0/foo"))),
 		},
@@ -649,9 +663,9 @@ func serverTestCases() []serverTestCase {
 				pagecheck.OverviewDetails(&pagecheck.Overview{
 					ModuleLink:     "/std@go1.13",
 					ModuleLinkText: "Standard Library",
-					ReadmeContent:  "readme",
+					ReadmeContent:  "readme for cmd",
 					RepoURL:        "https://" + sample.ModulePath, // wrong, but hard to change
-					ReadmeSource:   "go.googlesource.com/go/+/refs/tags/go1.13/README.md",
+					ReadmeSource:   "go.googlesource.com/go/+/refs/tags/go1.13/cmd/README.md",
 				})),
 		},
 		{
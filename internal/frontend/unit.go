@@ -7,6 +7,7 @@ package frontend
 import (
 	"context"
 	"net/http"
+	"path"
 
 	"github.com/google/safehtml"
 	"golang.org/x/pkgsite/internal"
@@ -44,6 +45,10 @@ type UnitPage struct {
 	// The version string formatted for display.
 	DisplayVersion string
 
+	// Unreleased indicates that the page is for the master branch of the
+	// standard library.
+	Unreleased bool
+
 	// LinkVersion is version string suitable for links used to compute
 	// latest badges.
 	LinkVersion string
@@ -80,6 +85,18 @@ type UnitPage struct {
 	DocBody       safehtml.HTML
 	DocOutline    safehtml.HTML
 	MobileOutline safehtml.HTML
+
+	// References lists, for each type declared by the unit, the
+	// declarations elsewhere in the module that refer to it.
+	References []*SymbolReferences
+}
+
+// SymbolReferences contains data used to render the references to a
+// single symbol.
+type SymbolReferences struct {
+	Name       string
+	Href       string
+	References []link
 }
 
 var (
@@ -200,6 +217,7 @@ func (s *Server) serveUnitPage(ctx context.Context, w http.ResponseWriter, r *ht
 		Licenses:        transformLicenseMetadata(unit.Licenses),
 		LastCommitTime:  elapsedTime(unit.CommitTime),
 		DisplayVersion:  displayVersion(unit.Version, unit.ModulePath),
+		Unreleased:      isUnreleased(unit.Version, unit.ModulePath),
 		LinkVersion:     linkVersion(unit.Version, unit.ModulePath),
 		LatestURL:       constructPackageURL(unit.Path, unit.ModulePath, middleware.LatestMinorVersionPlaceholder),
 		PageType:        pageType,
@@ -210,6 +228,7 @@ func (s *Server) serveUnitPage(ctx context.Context, w http.ResponseWriter, r *ht
 		DocOutline:      docOutline,
 		DocBody:         docBody,
 		MobileOutline:   mobileOutline,
+		References:      symbolReferences(unit, linkVersion(unit.Version, unit.ModulePath)),
 	}
 
 	if tab != tabDetails {
@@ -250,6 +269,29 @@ func readmeContent(ctx context.Context, unit *internal.Unit) (safehtml.HTML, err
 	return safehtml.HTML{}, nil
 }
 
+// symbolReferences returns the references to the types declared by unit.
+// References from other packages link to the declaration in that package at
+// linkVersion.
+func symbolReferences(unit *internal.Unit, linkVersion string) []*SymbolReferences {
+	var refs []*SymbolReferences
+	for _, s := range unit.Symbols {
+		if s.Kind != internal.SymbolKindType || len(s.References) == 0 {
+			continue
+		}
+		sr := &SymbolReferences{Name: s.Name, Href: "#" + s.Name}
+		for _, r := range s.References {
+			l := link{Href: "#" + r.Name, Body: r.Name}
+			if r.PackagePath != unit.Path {
+				l.Href = constructPackageURL(r.PackagePath, unit.ModulePath, linkVersion) + l.Href
+				l.Body = path.Base(r.PackagePath) + "." + r.Name
+			}
+			sr.References = append(sr.References, l)
+		}
+		refs = append(refs, sr)
+	}
+	return refs
+}
+
 // pageInfo determines the title and pageType for a given unit.
 func pageInfo(unit *internal.Unit) (title string, pageType string) {
 	if unit.Path == stdlib.ModulePath {
@@ -0,0 +1,44 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+)
+
+func TestSymbolReferences(t *testing.T) {
+	unit := &internal.Unit{
+		UnitMeta: internal.UnitMeta{
+			Path:       "example.com/m/a",
+			ModulePath: "example.com/m",
+			Version:    "v1.2.3",
+		},
+		Symbols: []*internal.Symbol{
+			{Name: "Client", Kind: internal.SymbolKindType, References: []*internal.SymbolReference{
+				{PackagePath: "example.com/m/a", Name: "NewClient"},
+				{PackagePath: "example.com/m/b", Name: "Run"},
+			}},
+			{Name: "Config", Kind: internal.SymbolKindType},
+			{Name: "NewClient", Kind: internal.SymbolKindFunction},
+		},
+	}
+	got := symbolReferences(unit, "v1.2.3")
+	want := []*SymbolReferences{
+		{
+			Name: "Client",
+			Href: "#Client",
+			References: []link{
+				{Href: "#NewClient", Body: "NewClient"},
+				{Href: "/example.com/m@v1.2.3/b#Run", Body: "b.Run"},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(link{})); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
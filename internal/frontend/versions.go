@@ -266,12 +266,19 @@ func pseudoVersionRev(v string) string {
 
 // displayVersion returns the version string, formatted for display.
 func displayVersion(v string, modulePath string) string {
-	if modulePath == stdlib.ModulePath {
+	if modulePath == stdlib.ModulePath && !isUnreleased(v, modulePath) {
 		return goTagForVersion(v)
 	}
 	return formatVersion(v)
 }
 
+// isUnreleased reports whether v is a version of the standard library that
+// has not been released: a pseudo-version for a commit on the master branch
+// of the Go repository.
+func isUnreleased(v string, modulePath string) bool {
+	return modulePath == stdlib.ModulePath && version.IsPseudo(v)
+}
+
 // linkVersion returns the version string, suitable for use in
 // a link to this site.
 func linkVersion(v string, modulePath string) string {
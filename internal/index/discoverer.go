@@ -0,0 +1,208 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/proxy"
+)
+
+// A Discoverer reports module versions for the worker to process. The module
+// index is one discoverer; others find modules that never appear in the
+// public index, such as private modules.
+type Discoverer interface {
+	// Name identifies the discoverer. It is recorded with each module version
+	// that the discoverer reports first.
+	Name() string
+
+	// Discover returns module versions. since is the latest timestamp of the
+	// versions that the discoverer reported earlier, and limit is the
+	// maximum number of versions to return. Discoverers that cannot resume
+	// from a point in time may ignore both, and return versions that they
+	// have returned before.
+	Discover(ctx context.Context, since time.Time, limit int) ([]*internal.IndexVersion, error)
+}
+
+// Name implements Discoverer.
+func (c *Client) Name() string {
+	return internal.IndexDiscoverer
+}
+
+// Discover implements Discoverer by querying the index for versions added
+// after since.
+func (c *Client) Discover(ctx context.Context, since time.Time, limit int) ([]*internal.IndexVersion, error) {
+	return c.GetVersions(ctx, since, limit)
+}
+
+// ListDiscoverer is a Discoverer that reads module versions from a file or a
+// URL. Each line of the list has the form MODULE_PATH@VERSION. Blank lines and
+// lines beginning with "#" are ignored.
+type ListDiscoverer struct {
+	location   string
+	httpClient *http.Client
+}
+
+// NewListDiscoverer returns a ListDiscoverer for the list at location, which
+// is either an http or https URL, or the name of a file.
+func NewListDiscoverer(location string) *ListDiscoverer {
+	return &ListDiscoverer{location: location, httpClient: http.DefaultClient}
+}
+
+// Name implements Discoverer.
+func (d *ListDiscoverer) Name() string {
+	return "list"
+}
+
+// Discover implements Discoverer. It returns every version on the list, with
+// the current time as the timestamp.
+func (d *ListDiscoverer) Discover(ctx context.Context, _ time.Time, _ int) (_ []*internal.IndexVersion, err error) {
+	defer derrors.Wrap(&err, "ListDiscoverer.Discover(ctx) for %q", d.location)
+
+	var r io.ReadCloser
+	if strings.HasPrefix(d.location, "http://") || strings.HasPrefix(d.location, "https://") {
+		resp, err := ctxhttp.Get(ctx, d.httpClient, d.location)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s: %s", d.location, resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(d.location)
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	}
+	defer r.Close()
+	return parseVersionList(r, time.Now())
+}
+
+// parseVersionList parses lines of the form MODULE_PATH@VERSION, giving each
+// version the timestamp ts.
+func parseVersionList(r io.Reader, ts time.Time) ([]*internal.IndexVersion, error) {
+	var versions []*internal.IndexVersion
+	scan := bufio.NewScanner(r)
+	for n := 1; scan.Scan(); n++ {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, '@')
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: %q is not of the form MODULE_PATH@VERSION: %w", n, line, derrors.InvalidArgument)
+		}
+		path, vers := line[:i], line[i+1:]
+		if err := module.Check(path, vers); err != nil {
+			return nil, fmt.Errorf("line %d: %v: %w", n, err, derrors.InvalidArgument)
+		}
+		versions = append(versions, &internal.IndexVersion{Path: path, Version: vers, Timestamp: ts})
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// ProxyDiscoverer is a Discoverer that lists the versions of the modules
+// under a set of watched module path prefixes on a module proxy.
+type ProxyDiscoverer struct {
+	client       *proxy.Client
+	prefixes     []string
+	knownModules func(ctx context.Context, prefix string) ([]string, error)
+}
+
+// NewProxyDiscoverer returns a ProxyDiscoverer that uses client to list the
+// versions of the modules whose paths are one of prefixes or begin with one of
+// them followed by a slash. A proxy cannot list the modules under a prefix, so
+// the modules are the prefixes themselves and the modules under them that
+// knownModules returns, such as those already processed or reported by
+// another discoverer. knownModules may be nil.
+func NewProxyDiscoverer(client *proxy.Client, prefixes []string, knownModules func(ctx context.Context, prefix string) ([]string, error)) *ProxyDiscoverer {
+	return &ProxyDiscoverer{client: client, prefixes: prefixes, knownModules: knownModules}
+}
+
+// Name implements Discoverer.
+func (d *ProxyDiscoverer) Name() string {
+	return "proxy"
+}
+
+// Discover implements Discoverer. It returns every version that the proxy
+// lists for the watched modules, with the current time as the timestamp.
+// Modules that the proxy does not know are skipped.
+func (d *ProxyDiscoverer) Discover(ctx context.Context, _ time.Time, _ int) (_ []*internal.IndexVersion, err error) {
+	defer derrors.Wrap(&err, "ProxyDiscoverer.Discover(ctx)")
+
+	paths, err := d.modulePaths(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var versions []*internal.IndexVersion
+	for _, p := range paths {
+		vs, err := d.client.ListVersions(ctx, p)
+		if errors.Is(err, derrors.NotFound) {
+			log.Warningf(ctx, "ProxyDiscoverer: %s: %v", p, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vs {
+			if !semver.IsValid(v) {
+				continue
+			}
+			versions = append(versions, &internal.IndexVersion{Path: p, Version: v, Timestamp: now})
+		}
+	}
+	return versions, nil
+}
+
+// modulePaths returns the sorted paths of the watched modules.
+func (d *ProxyDiscoverer) modulePaths(ctx context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	var paths []string
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	for _, prefix := range d.prefixes {
+		add(prefix)
+		if d.knownModules == nil {
+			continue
+		}
+		known, err := d.knownModules(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range known {
+			if p == prefix || strings.HasPrefix(p, prefix+"/") {
+				add(p)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/proxy"
+)
+
+func TestParseVersionList(t *testing.T) {
+	ts := time.Now()
+	got, err := parseVersionList(strings.NewReader(`
+# private modules
+example.com/a@v1.0.0
+  example.com/b/v2@v2.1.0
+`), ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.IndexVersion{
+		{Path: "example.com/a", Version: "v1.0.0", Timestamp: ts},
+		{Path: "example.com/b/v2", Version: "v2.1.0", Timestamp: ts},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	for _, bad := range []string{"example.com/a", "example.com/a@master", "@v1.0.0", "example.com/b@v2.0.0"} {
+		if _, err := parseVersionList(strings.NewReader(bad), ts); err == nil {
+			t.Errorf("%q: got nil error, want error", bad)
+		}
+	}
+}
+
+func TestListDiscoverer(t *testing.T) {
+	ctx := context.Background()
+	const list = "example.com/a@v1.0.0\nexample.com/b@v0.1.0\n"
+	want := []*internal.IndexVersion{
+		{Path: "example.com/a", Version: "v1.0.0"},
+		{Path: "example.com/b", Version: "v0.1.0"},
+	}
+	ignoreTime := cmpopts.IgnoreFields(internal.IndexVersion{}, "Timestamp")
+
+	f, err := ioutil.TempFile("", "modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(list); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(list))
+	}))
+	defer server.Close()
+
+	for _, loc := range []string{f.Name(), server.URL} {
+		got, err := NewListDiscoverer(loc).Discover(ctx, time.Time{}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, got, ignoreTime); diff != "" {
+			t.Errorf("%s: mismatch (-want, +got):\n%s", loc, diff)
+		}
+	}
+}
+
+func TestProxyDiscoverer(t *testing.T) {
+	ctx := context.Background()
+	client, teardown := proxy.SetupTestClient(t, []*proxy.Module{
+		{ModulePath: "example.com/private", Version: "v1.0.0", Files: map[string]string{"p.go": "package p"}},
+		{ModulePath: "example.com/private", Version: "v1.1.0", Files: map[string]string{"p.go": "package p"}},
+		{ModulePath: "example.com/private/sub", Version: "v0.1.0", Files: map[string]string{"p.go": "package p"}},
+		{ModulePath: "example.com/privateer", Version: "v1.0.0", Files: map[string]string{"p.go": "package p"}},
+	})
+	defer teardown()
+
+	known := func(ctx context.Context, prefix string) ([]string, error) {
+		if prefix != "example.com/private" {
+			return nil, nil
+		}
+		return []string{"example.com/private/sub", "example.com/privateer"}, nil
+	}
+	d := NewProxyDiscoverer(client, []string{"example.com/private", "example.com/unknown"}, known)
+	got, err := d.Discover(ctx, time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.IndexVersion{
+		{Path: "example.com/private", Version: "v1.0.0"},
+		{Path: "example.com/private", Version: "v1.1.0"},
+		{Path: "example.com/private/sub", Version: "v0.1.0"},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(internal.IndexVersion{}, "Timestamp")); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
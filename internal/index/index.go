@@ -15,12 +15,20 @@ import (
 	"strings"
 	"time"
 
-	"go.opencensus.io/plugin/ochttp"
 	"golang.org/x/net/context/ctxhttp"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/resilient"
 )
 
+// transportConfig configures the transport for requests to the index.
+var transportConfig = resilient.Config{
+	Name:          "index",
+	MaxRetries:    3,
+	RetryDelay:    500 * time.Millisecond,
+	MaxRetryDelay: 5 * time.Second,
+}
+
 // A Client is used by the worker service to communicate with the module index.
 type Client struct {
 	// URL of the module index
@@ -42,7 +50,11 @@ func New(rawurl string) (_ *Client, err error) {
 	if u.Scheme != "https" {
 		return nil, fmt.Errorf("scheme must be https (got %s)", u.Scheme)
 	}
-	return &Client{url: strings.TrimRight(rawurl, "/"), httpClient: &http.Client{Transport: &ochttp.Transport{}}}, nil
+	t, err := resilient.New(transportConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{url: strings.TrimRight(rawurl, "/"), httpClient: &http.Client{Transport: t}}, nil
 }
 
 func (c *Client) pollURL(since time.Time, limit int) string {
@@ -0,0 +1,132 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+// A JSONLogger writes each log entry as a line of JSON, so that entries can
+// be collected and queried by their fields with tools like Loki or
+// Elasticsearch. Each line has the time, severity and message of the entry,
+// along with its trace ID and labels if it has them.
+type JSONLogger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+// jsonEntry is the form in which a JSONLogger writes an entry.
+type jsonEntry struct {
+	Time        string            `json:"time"`
+	Severity    string            `json:"severity"`
+	Message     string            `json:"message,omitempty"`
+	Payload     interface{}       `json:"payload,omitempty"`
+	TraceID     string            `json:"traceID,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	HTTPRequest *jsonHTTPRequest  `json:"httpRequest,omitempty"`
+}
+
+// jsonHTTPRequest is the form in which a JSONLogger writes the HTTP request
+// of an entry.
+type jsonHTTPRequest struct {
+	Method         string  `json:"method,omitempty"`
+	URL            string  `json:"url,omitempty"`
+	UserAgent      string  `json:"userAgent,omitempty"`
+	RemoteAddr     string  `json:"remoteAddr,omitempty"`
+	Status         int     `json:"status,omitempty"`
+	LatencySeconds float64 `json:"latencySeconds,omitempty"`
+}
+
+// NewJSONLogger returns a JSONLogger that writes to w.
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	return &JSONLogger{w: w, now: time.Now}
+}
+
+// UseJSON switches from the default stdlib logger to a JSONLogger that
+// writes to w. It returns that logger, whose Log method can be used to log
+// the start and end of requests in the same format.
+//
+// UseJSON cannot be called after UseJSON or UseStackdriver. If it is, it
+// returns an error.
+func UseJSON(w io.Writer) (_ *JSONLogger, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := logger.(stdlibLogger); !ok {
+		return nil, errors.New("UseJSON: another logger is already in use")
+	}
+	l := NewJSONLogger(w)
+	logger = l
+	return l, nil
+}
+
+func (l *JSONLogger) log(ctx context.Context, s logging.Severity, payload interface{}) {
+	traceID, _ := ctx.Value(traceIDKey{}).(string) // if not present, traceID is ""
+	l.write(logging.Entry{
+		Severity: s,
+		Labels:   contextLabels(ctx),
+		Payload:  payload,
+		Trace:    traceID,
+	})
+}
+
+// Log writes entry. It lets a JSONLogger be used to log requests.
+func (l *JSONLogger) Log(entry logging.Entry) {
+	l.write(entry)
+}
+
+func (l *JSONLogger) write(entry logging.Entry) {
+	je := jsonEntry{
+		Time:     l.now().UTC().Format(time.RFC3339Nano),
+		Severity: entry.Severity.String(),
+		TraceID:  entry.Trace,
+		Labels:   entry.Labels,
+	}
+	switch p := entry.Payload.(type) {
+	case string:
+		je.Message = p
+	case error:
+		// Errors may serialize as the empty JSON object.
+		je.Message = p.Error()
+	case fmt.Stringer:
+		je.Message = p.String()
+	default:
+		je.Payload = p
+	}
+	if r := entry.HTTPRequest; r != nil {
+		jr := &jsonHTTPRequest{
+			Status:         r.Status,
+			LatencySeconds: r.Latency.Seconds(),
+		}
+		if r.Request != nil {
+			jr.Method = r.Request.Method
+			jr.URL = r.Request.URL.String()
+			jr.UserAgent = r.Request.UserAgent()
+			jr.RemoteAddr = r.Request.RemoteAddr
+		}
+		je.HTTPRequest = jr
+	}
+	line, err := json.Marshal(je)
+	if err != nil {
+		// The payload cannot be represented in JSON.
+		je.Payload = nil
+		je.Message = fmt.Sprintf("%+v", entry.Payload)
+		line, err = json.Marshal(je)
+		if err != nil {
+			return
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(line, '\n'))
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"golang.org/x/pkgsite/internal/experiment"
+)
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONLogger(&buf)
+	l.now = func() time.Time { return time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC) }
+
+	ctx := NewContextWithTraceID(context.Background(), "trace1")
+	ctx = NewContextWithLabel(ctx, "fetch", "golang.org/x/net@v1.0.0")
+	ctx = experiment.NewContext(ctx, "exp1")
+	l.log(ctx, logging.Info, "hello")
+	l.log(context.Background(), logging.Error, errors.New("bad"))
+	l.log(context.Background(), logging.Warning, struct{ N int }{3})
+	l.Log(logging.Entry{
+		Severity: logging.Info,
+		Payload:  "request end",
+		Trace:    "trace2",
+		HTTPRequest: &logging.HTTPRequest{
+			Request: httptest.NewRequest("GET", "/net/http", nil),
+			Status:  200,
+			Latency: 1500 * time.Millisecond,
+		},
+	})
+
+	want := `{"time":"2020-09-01T12:00:00Z","severity":"Info","message":"hello","traceID":"trace1","labels":{"experiments":"exp1","fetch":"golang.org/x/net@v1.0.0"}}
+{"time":"2020-09-01T12:00:00Z","severity":"Error","message":"bad"}
+{"time":"2020-09-01T12:00:00Z","severity":"Warning","payload":{"N":3}}
+{"time":"2020-09-01T12:00:00Z","severity":"Info","message":"request end","traceID":"trace2","httpRequest":{"method":"GET","url":"/net/http","remoteAddr":"192.0.2.1:1234","status":200,"latencySeconds":1.5}}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -81,6 +81,18 @@ func (l *stackdriverLogger) log(ctx context.Context, s logging.Severity, payload
 		payload = err.Error()
 	}
 	traceID, _ := ctx.Value(traceIDKey{}).(string) // if not present, traceID is "", which is fine
+	l.sdlogger.Log(logging.Entry{
+		Severity: s,
+		Labels:   contextLabels(ctx),
+		Payload:  payload,
+		Trace:    traceID,
+	})
+}
+
+// contextLabels returns the labels added to ctx by NewContextWithLabel,
+// along with an "experiments" label for the experiments active in ctx, if
+// there are any.
+func contextLabels(ctx context.Context) map[string]string {
 	labels, _ := ctx.Value(labelsKey{}).(map[string]string)
 	es := experimentString(ctx)
 	if len(es) > 0 {
@@ -91,12 +103,7 @@ func (l *stackdriverLogger) log(ctx context.Context, s logging.Severity, payload
 		nl["experiments"] = es
 		labels = nl
 	}
-	l.sdlogger.Log(logging.Entry{
-		Severity: s,
-		Labels:   labels,
-		Payload:  payload,
-		Trace:    traceID,
-	})
+	return labels
 }
 
 // stdlibLogger uses the Go standard library logger.
@@ -224,7 +231,11 @@ func doLog(ctx context.Context, s logging.Severity, payload interface{}) {
 	}
 	mu.Lock()
 	l := logger
+	rules := samplingRules
 	mu.Unlock()
+	if !sample(ctx, s, rules) {
+		return
+	}
 	l.log(ctx, s, payload)
 }
 
@@ -241,25 +252,31 @@ func die() {
 // Possible input values are "", "debug", "info", "warning", "error", "fatal".
 // In case of invalid string input, it maps to DefaultLevel.
 func toLevel(v string) logging.Severity {
-	v = strings.ToLower(v)
+	s, ok := parseLevel(v)
+	if !ok {
+		// Default log level in case of invalid input.
+		log.Printf("Error: %s is invalid LogLevel. Possible values are [debug, info, warning, error, fatal]", v)
+	}
+	return s
+}
 
-	switch v {
+// parseLevel returns the logging.Severity for a given string, and whether
+// the string is a valid level. See toLevel for the possible values.
+func parseLevel(v string) (logging.Severity, bool) {
+	switch strings.ToLower(v) {
 	case "":
 		// default log level will print everything.
-		return logging.Default
+		return logging.Default, true
 	case "debug":
-		return logging.Debug
+		return logging.Debug, true
 	case "info":
-		return logging.Info
+		return logging.Info, true
 	case "warning":
-		return logging.Warning
+		return logging.Warning, true
 	case "error":
-		return logging.Error
+		return logging.Error, true
 	case "fatal":
-		return logging.Critical
+		return logging.Critical, true
 	}
-
-	// Default log level in case of invalid input.
-	log.Printf("Error: %s is invalid LogLevel. Possible values are [debug, info, warning, error, fatal]", v)
-	return logging.Default
+	return logging.Default, false
 }
@@ -0,0 +1,124 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/logging"
+)
+
+// A SamplingRule says what fraction of the log entries at a severity are
+// kept. It can be restricted to the entries whose context has a label.
+type SamplingRule struct {
+	Severity logging.Severity
+
+	// If Label is not empty, the rule applies only to entries whose context
+	// has a label named Label whose value is Value. See NewContextWithLabel.
+	Label, Value string
+
+	// Rate is the fraction of entries that are kept, from 0 (none) to 1 (all).
+	Rate float64
+}
+
+var (
+	// samplingRules holds the rules set by SetSampling.
+	samplingRules []SamplingRule
+
+	// randFloat64 returns a random number in [0, 1). It is a variable for
+	// testing.
+	randFloat64 = rand.Float64
+)
+
+// SetSampling sets the rules used to decide whether log entries are kept.
+// An entry that matches no rule is kept. An entry that matches several
+// rules is sampled by the first rule with a label that it matches, or else
+// by the first rule without a label.
+func SetSampling(rules []SamplingRule) {
+	mu.Lock()
+	defer mu.Unlock()
+	samplingRules = rules
+}
+
+// ParseSamplingRules parses a comma-separated list of sampling rules. Each
+// rule has the form "level:rate" or "level:label=value:rate", where level is
+// one of the levels accepted by SetLevel and rate is a number from 0 to 1.
+// For example, "info:0.1,info:fetch=golang.org/x/tools@v0.1.0:1" keeps a tenth
+// of the Info entries, but all of those logged while fetching one module.
+func ParseSamplingRules(s string) ([]SamplingRule, error) {
+	var rules []SamplingRule
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		i := strings.Index(r, ":")
+		j := strings.LastIndex(r, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("sampling rule %q: missing rate", r)
+		}
+		sev, ok := parseLevel(r[:i])
+		if !ok || r[:i] == "" {
+			return nil, fmt.Errorf("sampling rule %q: invalid level %q", r, r[:i])
+		}
+		rate, err := strconv.ParseFloat(r[j+1:], 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("sampling rule %q: rate must be a number from 0 to 1", r)
+		}
+		rule := SamplingRule{Severity: sev, Rate: rate}
+		if i < j {
+			label := r[i+1 : j]
+			k := strings.Index(label, "=")
+			if k <= 0 {
+				return nil, fmt.Errorf("sampling rule %q: label must have the form name=value", r)
+			}
+			rule.Label, rule.Value = label[:k], label[k+1:]
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// sample reports whether an entry with severity s, logged with ctx, should
+// be kept according to rules.
+func sample(ctx context.Context, s logging.Severity, rules []SamplingRule) bool {
+	if len(rules) == 0 {
+		return true
+	}
+	var (
+		labels map[string]string
+		rule   *SamplingRule
+	)
+	for i := range rules {
+		r := &rules[i]
+		if r.Severity != s {
+			continue
+		}
+		if r.Label == "" {
+			if rule == nil {
+				rule = r
+			}
+			continue
+		}
+		if labels == nil {
+			labels = contextLabels(ctx)
+			if labels == nil {
+				labels = map[string]string{}
+			}
+		}
+		if v, ok := labels[r.Label]; ok && v == r.Value {
+			rule = r
+			break
+		}
+	}
+	if rule == nil {
+		return true
+	}
+	return randFloat64() < rule.Rate
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/logging"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseSamplingRules(t *testing.T) {
+	got, err := ParseSamplingRules("info:0.1, debug:0 ,info:fetch=a.com/m@v1.0.0:1,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SamplingRule{
+		{Severity: logging.Info, Rate: 0.1},
+		{Severity: logging.Debug, Rate: 0},
+		{Severity: logging.Info, Label: "fetch", Value: "a.com/m@v1.0.0", Rate: 1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	for _, s := range []string{"info", "loud:0.5", ":0.5", "info:2", "info:x", "info:fetch:0.5", "info:=a:0.5"} {
+		if _, err := ParseSamplingRules(s); err == nil {
+			t.Errorf("ParseSamplingRules(%q): got nil error, want error", s)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	oldRand := randFloat64
+	defer func() { randFloat64 = oldRand }()
+	randFloat64 = func() float64 { return 0.5 }
+
+	rules := []SamplingRule{
+		{Severity: logging.Info, Rate: 0.1},
+		{Severity: logging.Info, Label: "fetch", Value: "a.com/m@v1.0.0", Rate: 1},
+		{Severity: logging.Debug, Label: "fetch", Value: "a.com/m@v1.0.0", Rate: 0},
+	}
+	fetchCtx := NewContextWithLabel(context.Background(), "fetch", "a.com/m@v1.0.0")
+	otherCtx := NewContextWithLabel(context.Background(), "fetch", "b.com/m@v1.0.0")
+	for _, test := range []struct {
+		name string
+		ctx  context.Context
+		s    logging.Severity
+		want bool
+	}{
+		{"unlabeled rule", context.Background(), logging.Info, false},
+		{"other label", otherCtx, logging.Info, false},
+		{"labeled rule takes precedence", fetchCtx, logging.Info, true},
+		{"labeled rule only", fetchCtx, logging.Debug, false},
+		{"labeled rule does not match", otherCtx, logging.Debug, true},
+		{"no rule", fetchCtx, logging.Error, true},
+	} {
+		if got := sample(test.ctx, test.s, rules); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.name, got, test.want)
+		}
+	}
+	if !sample(context.Background(), logging.Info, nil) {
+		t.Error("without rules: got false, want true")
+	}
+}
@@ -0,0 +1,383 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"regexp"
+	"strings"
+)
+
+// asciiDocParser parses AsciiDoc, as described at
+// https://docs.asciidoctor.org/asciidoc/latest/.
+type asciiDocParser struct {
+	syn *inlineSyntax
+
+	// attrs are the document attributes, which are substituted for
+	// references like {name} in text.
+	attrs map[string]string
+}
+
+func parseAsciiDoc(lines []string) *Document {
+	p := &asciiDocParser{attrs: map[string]string{}}
+	p.syn = &inlineSyntax{
+		spans: []span{
+			{"**", Strong}, {"__", Emphasis}, {"``", Code},
+			{"*", Strong}, {"_", Emphasis}, {"`", Code},
+		},
+		escapes: true,
+		special: p.special,
+	}
+	return &Document{Blocks: p.parseBlocks(lines)}
+}
+
+var (
+	adocAttrEntryRE  = regexp.MustCompile(`^:([\w-]+)(!?):\s*(.*)$`)
+	adocSectionRE    = regexp.MustCompile(`^(={1,6}|#{1,6})\s+(.+?)(?:\s+=+)?$`)
+	adocBlockImageRE = regexp.MustCompile(`^image::([^\[\s]+)\[(.*)\]$`)
+	adocListRE       = regexp.MustCompile(`^\s*(\*{1,5}|-|\.{1,5}|\d+\.)\s+(.*)$`)
+	adocAdmonitionRE = regexp.MustCompile(`^(NOTE|TIP|IMPORTANT|WARNING|CAUTION):\s+(.*)$`)
+	adocAttrRefRE    = regexp.MustCompile(`\{([\w-]+)\}`)
+	adocMacroRE      = regexp.MustCompile(`^(image|link):([^\s\[:][^\s\[]*)\[([^\]]*)\]`)
+	adocURLMacroRE   = regexp.MustCompile(`^(https?://[^\s\[]+)\[([^\]]*)\]`)
+	adocXrefRE       = regexp.MustCompile(`^<<([^,>]+)(?:,\s*([^>]+))?>>`)
+)
+
+// inlines parses the inline markup of text, after substituting attribute
+// references.
+func (p *asciiDocParser) inlines(text string) []*Inline {
+	text = adocAttrRefRE.ReplaceAllStringFunc(text, func(ref string) string {
+		if v, ok := p.attrs[ref[1:len(ref)-1]]; ok {
+			return v
+		}
+		return ref
+	})
+	return p.syn.parse(text)
+}
+
+func (p *asciiDocParser) parseBlocks(lines []string) []*Block {
+	var (
+		blocks []*Block
+		// style and lang are given by the block attribute line that
+		// precedes a block, like [source,go].
+		style, lang string
+	)
+	for i := 0; i < len(lines); {
+		line := strings.TrimRight(lines[i], " ")
+		var b []*Block
+		next := i + 1
+		switch {
+		case isBlank(line):
+			i++
+			continue
+
+		case isAdocDelimiter(line):
+			end := adocBlockEnd(lines, i)
+			b = p.delimitedBlock(line, lines[i+1:end], style, lang)
+			next = end + 1
+
+		case strings.HasPrefix(line, "//"):
+			// A comment.
+
+		case adocAttrEntryRE.MatchString(line):
+			m := adocAttrEntryRE.FindStringSubmatch(line)
+			if m[2] == "!" {
+				delete(p.attrs, m[1])
+			} else {
+				p.attrs[m[1]] = m[3]
+			}
+
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			if !strings.HasPrefix(line, "[[") {
+				fields := strings.Split(line[1:len(line)-1], ",")
+				style = strings.TrimSpace(fields[0])
+				if style == "source" && len(fields) > 1 {
+					lang = strings.TrimSpace(fields[1])
+				}
+			}
+			i++
+			continue
+
+		case line == "'''" || line == "---" || line == "***" || line == "- - -" || line == "* * *":
+			b = []*Block{{Kind: Rule}}
+
+		case strings.HasPrefix(line, "|==="):
+			// Tables are shown as they are written.
+			end := i + 1
+			for end < len(lines) && !strings.HasPrefix(lines[end], "|===") {
+				end++
+			}
+			b = []*Block{{Kind: CodeBlock, Text: codeText(lines[i+1 : end])}}
+			next = end + 1
+
+		case adocSectionRE.MatchString(line):
+			m := adocSectionRE.FindStringSubmatch(line)
+			b = []*Block{{Kind: Heading, Level: len(m[1]), Inlines: p.inlines(m[2])}}
+
+		case adocBlockImageRE.MatchString(line):
+			m := adocBlockImageRE.FindStringSubmatch(line)
+			b = []*Block{{Kind: Paragraph, Inlines: []*Inline{adocImage(m[1], m[2])}}}
+
+		case len(line) > 1 && line[0] == '.' && line[1] != '.' && line[1] != ' ':
+			// A block title.
+			b = []*Block{{Kind: Paragraph, Inlines: []*Inline{{Kind: Strong, Children: p.inlines(line[1:])}}}}
+
+		case adocListRE.MatchString(line):
+			var list *Block
+			list, next = p.list(lines, i)
+			b = []*Block{list}
+
+		case indent(line) > 0 || style == "source" || style == "listing" || style == "literal":
+			// A literal paragraph.
+			end := i
+			for end < len(lines) && !isBlank(lines[end]) {
+				end++
+			}
+			b = []*Block{{Kind: CodeBlock, Lang: lang, Text: codeText(lines[i:end])}}
+			next = end
+
+		default:
+			end := i
+			for end < len(lines) && !isBlank(lines[end]) && !isAdocDelimiter(strings.TrimRight(lines[end], " ")) {
+				end++
+			}
+			b = []*Block{p.paragraph(lines[i:end], style)}
+			next = end
+		}
+		blocks = append(blocks, b...)
+		style, lang = "", ""
+		if next <= i {
+			// Every block consumes at least one line.
+			next = i + 1
+		}
+		i = next
+	}
+	return blocks
+}
+
+// isAdocDelimiter reports whether line delimits a block.
+func isAdocDelimiter(line string) bool {
+	if strings.HasPrefix(line, "```") {
+		return true
+	}
+	if len(line) < 4 || !strings.ContainsRune("-.=_*+/", rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// adocBlockEnd returns the index of the line that closes the delimited
+// block opened by lines[i], or len(lines) if there is none.
+func adocBlockEnd(lines []string, i int) int {
+	delim := strings.TrimRight(lines[i], " ")
+	if strings.HasPrefix(delim, "```") {
+		delim = "```"
+	}
+	for i++; i < len(lines) && strings.TrimRight(lines[i], " ") != delim; i++ {
+	}
+	return i
+}
+
+// delimitedBlock returns the blocks for the content of a delimited block,
+// whose opening delimiter is delim. style and lang are given by the block
+// attribute line that precedes it, if any.
+func (p *asciiDocParser) delimitedBlock(delim string, content []string, style, lang string) []*Block {
+	switch delim[0] {
+	case '`':
+		// A fenced code block, as in markdown.
+		if l := strings.TrimSpace(strings.Trim(delim, "`")); l != "" {
+			lang = l
+		}
+		fallthrough
+	case '-', '.':
+		return []*Block{{Kind: CodeBlock, Lang: lang, Text: codeText(content)}}
+	case '_':
+		return []*Block{{Kind: Quote, Blocks: p.parseBlocks(content)}}
+	case '=', '*':
+		// Example blocks, sidebars and admonition blocks.
+		blocks := p.parseBlocks(content)
+		if title := adocAdmonitionTitle(style); title != "" {
+			blocks = append([]*Block{{Kind: Paragraph, Inlines: []*Inline{{Kind: Strong, Children: []*Inline{{Kind: Text, Text: title}}}}}}, blocks...)
+		}
+		return []*Block{{Kind: Quote, Blocks: blocks}}
+	default:
+		// Comments and passthrough blocks.
+		return nil
+	}
+}
+
+// adocAdmonitionTitle returns the title of an admonition with the given
+// style, like "Note" for NOTE, or "" if style is not an admonition.
+func adocAdmonitionTitle(style string) string {
+	switch style {
+	case "NOTE", "TIP", "IMPORTANT", "WARNING", "CAUTION":
+		return style[:1] + strings.ToLower(style[1:])
+	}
+	return ""
+}
+
+// paragraph returns a paragraph with the given lines, which is shown as an
+// admonition if its style or its first line says so.
+func (p *asciiDocParser) paragraph(lines []string, style string) *Block {
+	title := adocAdmonitionTitle(style)
+	if m := adocAdmonitionRE.FindStringSubmatch(lines[0]); m != nil {
+		title = adocAdmonitionTitle(m[1])
+		lines = append([]string{m[2]}, lines[1:]...)
+	}
+	b := paragraph(lines, p.inlines)
+	if title == "" {
+		return b
+	}
+	b.Inlines = append([]*Inline{{Kind: Strong, Children: []*Inline{{Kind: Text, Text: title + ":"}}}, {Kind: Text, Text: " "}}, b.Inlines...)
+	return &Block{Kind: Quote, Blocks: []*Block{b}}
+}
+
+// An adocItem is an item of an AsciiDoc list.
+type adocItem struct {
+	marker string
+	lines  []string
+}
+
+// list parses the list that starts at lines[i], and returns the index of
+// the line after it. The depth of an item is given by its marker: items
+// marked "**" are nested in items marked "*".
+func (p *asciiDocParser) list(lines []string, i int) (*Block, int) {
+	var items []adocItem
+	for i < len(lines) {
+		m := adocListRE.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		marker := m[1]
+		if marker[0] >= '0' && marker[0] <= '9' {
+			marker = "1."
+		}
+		item := adocItem{marker: marker, lines: []string{m[2]}}
+		i++
+		// The text of an item continues until a blank line or the next item.
+		// A line with a single "+" attaches the following block to the item.
+		for i < len(lines) && !isBlank(lines[i]) && !adocListRE.MatchString(lines[i]) {
+			line := strings.TrimRight(lines[i], " ")
+			i++
+			if line != "+" {
+				item.lines = append(item.lines, line)
+				continue
+			}
+			item.lines = append(item.lines, "")
+			if i < len(lines) && isAdocDelimiter(strings.TrimRight(lines[i], " ")) {
+				end := adocBlockEnd(lines, i)
+				if end < len(lines) {
+					end++
+				}
+				item.lines = append(item.lines, lines[i:end]...)
+				i = end
+			}
+		}
+		items = append(items, item)
+		// Items may be separated by blank lines.
+		j := i
+		for j < len(lines) && isBlank(lines[j]) {
+			j++
+		}
+		if j == len(lines) || !adocListRE.MatchString(lines[j]) {
+			break
+		}
+		i = j
+	}
+	list, _ := p.buildList(items, 0, nil)
+	return list, i
+}
+
+// buildList returns the list made of the items starting at items[k] that
+// have the same marker, with the items between them nested in them. It
+// stops at an item with one of the markers of the enclosing lists, and
+// returns its index.
+func (p *asciiDocParser) buildList(items []adocItem, k int, enclosing []string) (*Block, int) {
+	marker := items[k].marker
+	list := &Block{Kind: List, Ordered: marker[0] == '.' || marker == "1."}
+	inner := append(append([]string(nil), enclosing...), marker)
+	for k < len(items) {
+		it := items[k]
+		if it.marker == marker {
+			list.Items = append(list.Items, p.parseBlocks(it.lines))
+			k++
+			continue
+		}
+		for _, e := range enclosing {
+			if it.marker == e {
+				return list, k
+			}
+		}
+		var sub *Block
+		sub, k = p.buildList(items, k, inner)
+		if len(list.Items) == 0 {
+			list.Items = append(list.Items, nil)
+		}
+		last := len(list.Items) - 1
+		list.Items[last] = append(list.Items[last], sub)
+	}
+	return list, k
+}
+
+// adocImage returns the image of an image macro with the given target and
+// attributes.
+func adocImage(target, attrs string) *Inline {
+	img := &Inline{Kind: Image, URL: target}
+	var link string
+	for i, a := range strings.Split(attrs, ",") {
+		a = strings.TrimSpace(a)
+		switch {
+		case strings.HasPrefix(a, "alt="):
+			img.Text = strings.Trim(a[len("alt="):], `"`)
+		case strings.HasPrefix(a, "link="):
+			link = strings.Trim(a[len("link="):], `"`)
+		case i == 0 && !strings.Contains(a, "="):
+			img.Text = strings.Trim(a, `"`)
+		}
+	}
+	if link != "" {
+		return &Inline{Kind: Link, URL: link, Children: []*Inline{img}}
+	}
+	return img
+}
+
+// special parses the inline markup of AsciiDoc that is not a simple span:
+// macros, URLs with text, cross references and passthroughs.
+func (p *asciiDocParser) special(s string, i int) (*Inline, int) {
+	if i > 0 && !isBoundary(s[i-1]) {
+		return nil, 0
+	}
+	rest := s[i:]
+	if m := adocMacroRE.FindStringSubmatch(rest); m != nil {
+		if m[1] == "image" {
+			return adocImage(m[2], m[3]), len(m[0])
+		}
+		return adocLink(m[2], m[3], p.syn), len(m[0])
+	}
+	if m := adocURLMacroRE.FindStringSubmatch(rest); m != nil {
+		return adocLink(m[1], m[2], p.syn), len(m[0])
+	}
+	if m := adocXrefRE.FindStringSubmatch(rest); m != nil {
+		text := m[2]
+		if text == "" {
+			text = m[1]
+		}
+		return &Inline{Kind: Link, URL: "#" + m[1], Children: p.syn.parse(text)}, len(m[0])
+	}
+	if n := spanLen(s, i, "+"); n > 0 {
+		return &Inline{Kind: Text, Text: s[i+1 : i+n-1]}, n
+	}
+	return nil, 0
+}
+
+// adocLink returns a link to u whose text is the first of the attributes of
+// a link macro, or u itself if there are none.
+func adocLink(u, attrs string, syn *inlineSyntax) *Inline {
+	text := strings.Trim(strings.SplitN(attrs, ",", 2)[0], `"`)
+	text = strings.TrimSuffix(text, "^")
+	if text == "" {
+		return &Inline{Kind: Link, URL: u, Children: []*Inline{{Kind: Text, Text: u}}}
+	}
+	return &Inline{Kind: Link, URL: u, Children: syn.parse(text)}
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// An inlineSyntax describes the inline markup of a format.
+type inlineSyntax struct {
+	// spans are the delimited spans of the format, like *emphasis*. They are
+	// tried in order, so longer delimiters must come first.
+	spans []span
+
+	// escapes reports whether a backslash makes the next character literal.
+	escapes bool
+
+	// special, if non-nil, recognizes other markup of the format, like
+	// links, at s[i:]. It returns the inline and the number of bytes it
+	// spans, or 0 if there is no markup at s[i:]. The inline may be nil if
+	// the markup should be dropped.
+	special func(s string, i int) (*Inline, int)
+}
+
+// A span is text between two occurrences of a delimiter.
+type span struct {
+	delim string
+	kind  InlineKind
+}
+
+// parse parses the inline markup of s.
+func (syn *inlineSyntax) parse(s string) []*Inline {
+	var inlines []*Inline
+	start := 0 // start of the text not yet added to inlines
+	for i := 0; i < len(s); {
+		in, n := syn.inlineAt(s, i)
+		if n == 0 {
+			i++
+			continue
+		}
+		inlines = appendText(inlines, s[start:i])
+		if in != nil {
+			if in.Kind == Text {
+				inlines = appendText(inlines, in.Text)
+			} else {
+				inlines = append(inlines, in)
+			}
+		}
+		i += n
+		start = i
+	}
+	return appendText(inlines, s[start:])
+}
+
+// appendText appends text to inlines, merging it with a preceding Text.
+func appendText(inlines []*Inline, text string) []*Inline {
+	if text == "" {
+		return inlines
+	}
+	if n := len(inlines); n > 0 && inlines[n-1].Kind == Text {
+		inlines[n-1].Text += text
+		return inlines
+	}
+	return append(inlines, &Inline{Kind: Text, Text: text})
+}
+
+// inlineAt returns the inline markup at s[i:] and the number of bytes it
+// spans, or 0 if there is none.
+func (syn *inlineSyntax) inlineAt(s string, i int) (*Inline, int) {
+	if syn.escapes && s[i] == '\\' && i+1 < len(s) {
+		_, n := utf8.DecodeRuneInString(s[i+1:])
+		return &Inline{Kind: Text, Text: s[i+1 : i+1+n]}, 1 + n
+	}
+	if syn.special != nil {
+		if in, n := syn.special(s, i); n > 0 {
+			return in, n
+		}
+	}
+	if in, n := autolink(s, i); n > 0 {
+		return in, n
+	}
+	if i > 0 && !isBoundary(s[i-1]) {
+		return nil, 0
+	}
+	for _, sp := range syn.spans {
+		n := spanLen(s, i, sp.delim)
+		if n == 0 {
+			continue
+		}
+		content := s[i+len(sp.delim) : i+n-len(sp.delim)]
+		in := &Inline{Kind: sp.kind}
+		if sp.kind == Code {
+			in.Text = content
+		} else {
+			in.Children = syn.parse(content)
+		}
+		return in, n
+	}
+	return nil, 0
+}
+
+// spanLen returns the length of the span delimited by delim that starts at
+// s[i:], or 0 if there is none. The text of a span must not start or end
+// with white space, and the closing delimiter must be followed by the end of
+// s, white space or punctuation.
+func spanLen(s string, i int, delim string) int {
+	if !strings.HasPrefix(s[i:], delim) {
+		return 0
+	}
+	start := i + len(delim)
+	if start >= len(s) || isSpace(s[start]) {
+		return 0
+	}
+	for j := start + 1; j+len(delim) <= len(s); j++ {
+		if !strings.HasPrefix(s[j:], delim) || isSpace(s[j-1]) {
+			continue
+		}
+		end := j + len(delim)
+		if end < len(s) && !isBoundary(s[end]) {
+			continue
+		}
+		return end - i
+	}
+	return 0
+}
+
+// autolink returns a Link for the URL at s[i:], if there is one.
+func autolink(s string, i int) (*Inline, int) {
+	if !strings.HasPrefix(s[i:], "http://") && !strings.HasPrefix(s[i:], "https://") {
+		return nil, 0
+	}
+	if i > 0 && !isSpace(s[i-1]) && !strings.ContainsRune("([{\"'", rune(s[i-1])) {
+		return nil, 0
+	}
+	n := strings.IndexAny(s[i:], " \t\n<>\"")
+	if n < 0 {
+		n = len(s) - i
+	}
+	u := strings.TrimRight(s[i:i+n], ".,;:!?)]}'")
+	return &Inline{Kind: Link, URL: u, Children: []*Inline{{Kind: Text, Text: u}}}, len(u)
+}
+
+// isSpace reports whether b is white space.
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n'
+}
+
+// isBoundary reports whether b can precede the opening delimiter of a span,
+// or follow its closing delimiter.
+func isBoundary(b byte) bool {
+	return isSpace(b) || strings.IndexByte(`.,;:!?'"()[]{}<>-/\`, b) >= 0
+}
@@ -0,0 +1,259 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package markup parses the lightweight markup languages, other than
+// markdown, that READMEs are written in: reStructuredText, AsciiDoc and
+// org-mode.
+//
+// Each language is parsed into the same simple document tree, which covers
+// the features READMEs commonly use: headings, paragraphs, lists, code
+// blocks, block quotes, and text with emphasis, code, links and images.
+// Constructs that are not supported are either dropped (like most
+// directives) or kept as preformatted text (like tables).
+package markup
+
+import (
+	"path"
+	"strings"
+)
+
+// A Format is a markup language.
+type Format int
+
+const (
+	// Unknown is the format of files that this package does not parse.
+	Unknown Format = iota
+	ReStructuredText
+	AsciiDoc
+	Org
+)
+
+// FormatOf returns the format of the file with the given name, based on
+// its extension.
+func FormatOf(filename string) Format {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".rst", ".rest":
+		return ReStructuredText
+	case ".adoc", ".asciidoc", ".asc":
+		return AsciiDoc
+	case ".org":
+		return Org
+	default:
+		return Unknown
+	}
+}
+
+// Parse parses contents, which are in format f. It returns nil if f is
+// Unknown.
+func Parse(f Format, contents string) *Document {
+	contents = strings.ReplaceAll(contents, "\r", "")
+	lines := strings.Split(contents, "\n")
+	switch f {
+	case ReStructuredText:
+		return parseRST(lines)
+	case AsciiDoc:
+		return parseAsciiDoc(lines)
+	case Org:
+		return parseOrg(lines)
+	default:
+		return nil
+	}
+}
+
+// A Document is a parsed markup file.
+type Document struct {
+	Blocks []*Block
+}
+
+// A BlockKind is the kind of a Block.
+type BlockKind int
+
+const (
+	Heading BlockKind = iota
+	Paragraph
+	CodeBlock
+	List
+	Quote
+	Rule
+)
+
+// A Block is a block-level element of a document.
+type Block struct {
+	Kind BlockKind
+
+	// Level is the level of a Heading, from 1 to 6.
+	Level int
+
+	// Inlines are the contents of a Heading or Paragraph.
+	Inlines []*Inline
+
+	// Text is the contents of a CodeBlock, and Lang its language, if known.
+	Text string
+	Lang string
+
+	// Items are the items of a List, each a sequence of blocks, and
+	// Ordered reports whether they are numbered.
+	Items   [][]*Block
+	Ordered bool
+
+	// Blocks are the contents of a Quote.
+	Blocks []*Block
+}
+
+// An InlineKind is the kind of an Inline.
+type InlineKind int
+
+const (
+	Text InlineKind = iota
+	Emphasis
+	Strong
+	Code
+	Link
+	Image
+)
+
+// An Inline is an element of the text of a paragraph or heading.
+type Inline struct {
+	Kind InlineKind
+
+	// Text is the contents of a Text or Code element, or the alternative
+	// text of an Image.
+	Text string
+
+	// URL is the destination of a Link, or the source of an Image.
+	URL string
+
+	// Children are the contents of an Emphasis, Strong or Link element.
+	Children []*Inline
+}
+
+// Text returns the text of d, without formatting. Like the text extracted
+// from markdown READMEs for search, it omits images and code blocks.
+func (d *Document) Text() string {
+	var b strings.Builder
+	writeBlocksText(&b, d.Blocks)
+	return b.String()
+}
+
+func writeBlocksText(b *strings.Builder, blocks []*Block) {
+	for _, bl := range blocks {
+		switch bl.Kind {
+		case Heading, Paragraph:
+			if b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			writeInlinesText(b, bl.Inlines)
+		case List:
+			for _, item := range bl.Items {
+				writeBlocksText(b, item)
+			}
+		case Quote:
+			writeBlocksText(b, bl.Blocks)
+		}
+	}
+}
+
+func writeInlinesText(b *strings.Builder, inlines []*Inline) {
+	for _, in := range inlines {
+		switch in.Kind {
+		case Text, Code:
+			b.WriteString(in.Text)
+		case Emphasis, Strong, Link:
+			writeInlinesText(b, in.Children)
+		}
+	}
+}
+
+// PlainText returns the text of inlines, without formatting. Unlike
+// Document.Text, it includes the alternative text of images.
+func PlainText(inlines []*Inline) string {
+	var b strings.Builder
+	var write func([]*Inline)
+	write = func(inlines []*Inline) {
+		for _, in := range inlines {
+			switch in.Kind {
+			case Text, Code, Image:
+				b.WriteString(in.Text)
+			default:
+				write(in.Children)
+			}
+		}
+	}
+	write(inlines)
+	return b.String()
+}
+
+// indent returns the number of leading spaces and tabs of line.
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// isBlank reports whether line contains only white space.
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// dedent removes n leading spaces or tabs from each line, or all of them if
+// a line has fewer.
+func dedent(lines []string, n int) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if indent(line) < n {
+			out[i] = strings.TrimLeft(line, " \t")
+		} else {
+			out[i] = line[n:]
+		}
+	}
+	return out
+}
+
+// minIndent returns the smallest indentation of the non-blank lines.
+func minIndent(lines []string) int {
+	min := -1
+	for _, line := range lines {
+		if isBlank(line) {
+			continue
+		}
+		if n := indent(line); min < 0 || n < min {
+			min = n
+		}
+	}
+	if min < 0 {
+		return 0
+	}
+	return min
+}
+
+// codeText joins lines into the text of a code block, without leading or
+// trailing blank lines, and with their common indentation removed.
+func codeText(lines []string) string {
+	for len(lines) > 0 && isBlank(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(dedent(lines, minIndent(lines)), "\n") + "\n"
+}
+
+// paragraph returns a Paragraph whose text is lines, parsed by parse.
+func paragraph(lines []string, parse func(string) []*Inline) *Block {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimSpace(line)
+	}
+	return &Block{Kind: Paragraph, Inlines: parse(strings.Join(trimmed, "\n"))}
+}
+
+// isImageURL reports whether u looks like the URL of an image.
+func isImageURL(u string) bool {
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	switch strings.ToLower(path.Ext(u)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp":
+		return true
+	}
+	return false
+}
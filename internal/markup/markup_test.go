@@ -0,0 +1,227 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormatOf(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		want     Format
+	}{
+		{"README.rst", ReStructuredText},
+		{"dir/README.REST", ReStructuredText},
+		{"README.adoc", AsciiDoc},
+		{"README.asciidoc", AsciiDoc},
+		{"README.org", Org},
+		{"README.md", Unknown},
+		{"README", Unknown},
+	} {
+		if got := FormatOf(test.filename); got != test.want {
+			t.Errorf("FormatOf(%q) = %v, want %v", test.filename, got, test.want)
+		}
+	}
+}
+
+func text(s string) *Inline {
+	return &Inline{Kind: Text, Text: s}
+}
+
+func para(inlines ...*Inline) *Block {
+	return &Block{Kind: Paragraph, Inlines: inlines}
+}
+
+func heading(level int, s string) *Block {
+	return &Block{Kind: Heading, Level: level, Inlines: []*Inline{text(s)}}
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		format   Format
+		contents string
+		want     []*Block
+	}{
+		{
+			name:     "rst titles",
+			format:   ReStructuredText,
+			contents: "=====\nTitle\n=====\n\nSection\n-------\n\nSub\n~~~\n\nOther\n-------\n",
+			want: []*Block{
+				heading(1, "Title"), heading(2, "Section"), heading(3, "Sub"), heading(2, "Other"),
+			},
+		},
+		{
+			name:     "rst inline markup",
+			format:   ReStructuredText,
+			contents: "A *b* **c** ``d`` `e <http://e>`_ f_ |g|.\n\n.. _f: http://f\n.. |g| image:: g.png\n   :alt: G\n",
+			want: []*Block{para(
+				text("A "),
+				&Inline{Kind: Emphasis, Children: []*Inline{text("b")}},
+				text(" "),
+				&Inline{Kind: Strong, Children: []*Inline{text("c")}},
+				text(" "),
+				&Inline{Kind: Code, Text: "d"},
+				text(" "),
+				&Inline{Kind: Link, URL: "http://e", Children: []*Inline{text("e")}},
+				text(" "),
+				&Inline{Kind: Link, URL: "http://f", Children: []*Inline{text("f")}},
+				text(" "),
+				&Inline{Kind: Image, URL: "g.png", Text: "G"},
+				text("."),
+			)},
+		},
+		{
+			name:     "rst literal block and list",
+			format:   ReStructuredText,
+			contents: "Run::\n\n    go test\n\n- a\n- b\n\n#. c\n",
+			want: []*Block{
+				para(text("Run:")),
+				{Kind: CodeBlock, Text: "go test\n"},
+				{Kind: List, Items: [][]*Block{{para(text("a"))}, {para(text("b"))}}},
+				{Kind: List, Ordered: true, Items: [][]*Block{{para(text("c"))}}},
+			},
+		},
+		{
+			name:     "rst directives",
+			format:   ReStructuredText,
+			contents: ".. code-block:: go\n\n   x := 1\n\n.. image:: a.png\n   :target: http://a\n\n.. contents::\n\n.. a comment\n",
+			want: []*Block{
+				{Kind: CodeBlock, Lang: "go", Text: "x := 1\n"},
+				para(&Inline{Kind: Link, URL: "http://a", Children: []*Inline{{Kind: Image, URL: "a.png"}}}),
+			},
+		},
+		{
+			name:     "asciidoc sections and attributes",
+			format:   AsciiDoc,
+			contents: "= Title\n:v: 1.0\n\n== Section {v}\n\nA *b* _c_ `d` https://e[E] link:f.adoc[F] <<g,G>>.\n",
+			want: []*Block{
+				heading(1, "Title"),
+				heading(2, "Section 1.0"),
+				para(
+					text("A "),
+					&Inline{Kind: Strong, Children: []*Inline{text("b")}},
+					text(" "),
+					&Inline{Kind: Emphasis, Children: []*Inline{text("c")}},
+					text(" "),
+					&Inline{Kind: Code, Text: "d"},
+					text(" "),
+					&Inline{Kind: Link, URL: "https://e", Children: []*Inline{text("E")}},
+					text(" "),
+					&Inline{Kind: Link, URL: "f.adoc", Children: []*Inline{text("F")}},
+					text(" "),
+					&Inline{Kind: Link, URL: "#g", Children: []*Inline{text("G")}},
+					text("."),
+				),
+			},
+		},
+		{
+			name:     "asciidoc blocks",
+			format:   AsciiDoc,
+			contents: "[source,go]\n----\nx := 1\n----\n\n* a\n** b\n* c\n\nimage::d.png[D]\n\n'''\n",
+			want: []*Block{
+				{Kind: CodeBlock, Lang: "go", Text: "x := 1\n"},
+				{Kind: List, Items: [][]*Block{
+					{para(text("a")), {Kind: List, Items: [][]*Block{{para(text("b"))}}}},
+					{para(text("c"))},
+				}},
+				para(&Inline{Kind: Image, URL: "d.png", Text: "D"}),
+				{Kind: Rule},
+			},
+		},
+		{
+			name:     "org headings and markup",
+			format:   Org,
+			contents: "#+TITLE: Title\n* TODO Section :tag:\nA *b* /c/ =d= [[http://e][E]] [[f.png]].\n",
+			want: []*Block{
+				heading(1, "Title"),
+				heading(1, "Section"),
+				para(
+					text("A "),
+					&Inline{Kind: Strong, Children: []*Inline{text("b")}},
+					text(" "),
+					&Inline{Kind: Emphasis, Children: []*Inline{text("c")}},
+					text(" "),
+					&Inline{Kind: Code, Text: "d"},
+					text(" "),
+					&Inline{Kind: Link, URL: "http://e", Children: []*Inline{text("E")}},
+					text(" "),
+					&Inline{Kind: Image, URL: "f.png"},
+					text("."),
+				),
+			},
+		},
+		{
+			name:     "org blocks",
+			format:   Org,
+			contents: "#+begin_src go\nx := 1\n#+end_src\n\n- a\n  - b\n- c\n\n: fixed\n",
+			want: []*Block{
+				{Kind: CodeBlock, Lang: "go", Text: "x := 1\n"},
+				{Kind: List, Items: [][]*Block{
+					{para(text("a")), {Kind: List, Items: [][]*Block{{para(text("b"))}}}},
+					{para(text("c"))},
+				}},
+				{Kind: CodeBlock, Text: "fixed\n"},
+			},
+		},
+		{
+			name:     "asciidoc whitespace-only lines",
+			format:   AsciiDoc,
+			contents: "a\n\t\n \t \nb\n\t",
+			want:     []*Block{para(text("a")), para(text("b"))},
+		},
+		{
+			name:     "asciidoc tab-only line",
+			format:   AsciiDoc,
+			contents: "\t\nx",
+			want:     []*Block{para(text("x"))},
+		},
+		{
+			name:     "org whitespace-only lines",
+			format:   Org,
+			contents: "a\n\t\n \t \nb\n",
+			want:     []*Block{para(text("a")), para(text("b"))},
+		},
+		{
+			name:     "unmatched delimiters",
+			format:   Org,
+			contents: "2*3*4 and a/b/c and * not bold*\n",
+			want:     []*Block{para(text("2*3*4 and a/b/c and * not bold*"))},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := Parse(test.format, test.contents)
+			if diff := cmp.Diff(test.want, got.Blocks); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	const contents = `
+Title
+=====
+
+.. image:: logo.png
+
+A *library* for ` + "`parsing <http://p>`_" + ` things.
+
+.. code-block:: go
+
+    x := 1
+
+- one
+- two
+`
+	got := Parse(ReStructuredText, contents).Text()
+	want := "Title  A library for parsing things. one two"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"regexp"
+	"strings"
+)
+
+// orgParser parses org-mode documents, as described at
+// https://orgmode.org/worg/dev/org-syntax.html.
+type orgParser struct {
+	syn *inlineSyntax
+}
+
+func parseOrg(lines []string) *Document {
+	p := &orgParser{}
+	p.syn = &inlineSyntax{
+		spans: []span{
+			{"*", Strong}, {"/", Emphasis}, {"_", Emphasis},
+			{"=", Code}, {"~", Code},
+		},
+		special: p.special,
+	}
+	return &Document{Blocks: p.parseBlocks(lines)}
+}
+
+var (
+	orgHeadingRE = regexp.MustCompile(`^(\*+)\s+(?:(?:TODO|DONE)\s+)?(?:\[#[A-Z]\]\s+)?(.*?)(?:\s+:[\w@#%:]+:)?\s*$`)
+	orgKeywordRE = regexp.MustCompile(`^#\+(\w+):\s*(.*)$`)
+	orgBeginRE   = regexp.MustCompile(`(?i)^#\+begin_(\w+)\s*(.*)$`)
+	orgListRE    = regexp.MustCompile(`^(\s*)([-+*]|\d+[.)])\s+`)
+	orgDrawerRE  = regexp.MustCompile(`^\s*:[\w-]+:\s*$`)
+	orgLinkRE    = regexp.MustCompile(`^\[\[([^\]]+)\](?:\[([^\]]+)\])?\]`)
+)
+
+func (p *orgParser) parseBlocks(lines []string) []*Block {
+	var blocks []*Block
+	for i := 0; i < len(lines); {
+		line := strings.TrimRight(lines[i], " ")
+		next := i + 1
+		switch {
+		case isBlank(line):
+
+		case orgBeginRE.MatchString(line):
+			m := orgBeginRE.FindStringSubmatch(line)
+			end := i + 1
+			for end < len(lines) && !strings.EqualFold(strings.TrimSpace(lines[end]), "#+end_"+m[1]) {
+				end++
+			}
+			blocks = append(blocks, p.block(strings.ToLower(m[1]), m[2], lines[i+1:end])...)
+			next = end + 1
+
+		case orgKeywordRE.MatchString(line):
+			m := orgKeywordRE.FindStringSubmatch(line)
+			if strings.EqualFold(m[1], "title") {
+				blocks = append(blocks, &Block{Kind: Heading, Level: 1, Inlines: p.syn.parse(m[2])})
+			}
+
+		case line == "#" || strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "#+"):
+			// A comment, or a keyword without a value.
+
+		case orgDrawerRE.MatchString(line):
+			// A drawer, like :PROPERTIES:, which holds metadata.
+			for next < len(lines) && !strings.EqualFold(strings.TrimSpace(lines[next]), ":END:") {
+				next++
+			}
+			next++
+
+		case orgHeadingRE.MatchString(line):
+			m := orgHeadingRE.FindStringSubmatch(line)
+			level := len(m[1])
+			if level > 6 {
+				level = 6
+			}
+			blocks = append(blocks, &Block{Kind: Heading, Level: level, Inlines: p.syn.parse(m[2])})
+
+		case strings.TrimLeft(line, " ") == ":" || strings.HasPrefix(strings.TrimLeft(line, " "), ": "):
+			// Fixed-width lines.
+			var code []string
+			for next = i; next < len(lines); next++ {
+				l := strings.TrimSpace(lines[next])
+				if l != ":" && !strings.HasPrefix(l, ": ") {
+					break
+				}
+				code = append(code, strings.TrimPrefix(strings.TrimPrefix(l, ":"), " "))
+			}
+			blocks = append(blocks, &Block{Kind: CodeBlock, Text: codeText(code)})
+
+		case len(line) >= 5 && strings.Trim(line, "-") == "":
+			blocks = append(blocks, &Block{Kind: Rule})
+
+		case strings.HasPrefix(strings.TrimLeft(line, " "), "|"):
+			// Tables are shown as they are written.
+			for next = i; next < len(lines) && strings.HasPrefix(strings.TrimLeft(lines[next], " "), "|"); next++ {
+			}
+			blocks = append(blocks, &Block{Kind: CodeBlock, Text: codeText(lines[i:next])})
+
+		case orgListMarker(line) > 0:
+			var list *Block
+			list, next = p.list(lines, i)
+			blocks = append(blocks, list)
+
+		default:
+			for next < len(lines) && !isBlank(lines[next]) && !p.startsBlock(strings.TrimRight(lines[next], " ")) {
+				next++
+			}
+			blocks = append(blocks, paragraph(lines[i:next], p.syn.parse))
+		}
+		i = next
+	}
+	return blocks
+}
+
+// startsBlock reports whether line starts an element that ends a paragraph.
+func (p *orgParser) startsBlock(line string) bool {
+	return orgHeadingRE.MatchString(line) || strings.HasPrefix(line, "#+") ||
+		orgListMarker(line) > 0 || strings.HasPrefix(strings.TrimLeft(line, " "), "|")
+}
+
+// block returns the blocks for the contents of a greater block, like
+// #+BEGIN_SRC, whose name is given in lower case.
+func (p *orgParser) block(name, params string, content []string) []*Block {
+	switch name {
+	case "src":
+		var lang string
+		if f := strings.Fields(params); len(f) > 0 {
+			lang = f[0]
+		}
+		return []*Block{{Kind: CodeBlock, Lang: lang, Text: codeText(content)}}
+	case "example":
+		return []*Block{{Kind: CodeBlock, Text: codeText(content)}}
+	case "quote":
+		return []*Block{{Kind: Quote, Blocks: p.parseBlocks(content)}}
+	case "comment", "export":
+		return nil
+	default:
+		return p.parseBlocks(content)
+	}
+}
+
+// orgListMarker returns the width of the list item marker that starts
+// line, including its indentation and the spaces after it, or 0 if line
+// does not start a list item. An unindented "*" starts a heading, not an
+// item.
+func orgListMarker(line string) int {
+	m := orgListRE.FindStringSubmatch(line)
+	if m == nil || (m[1] == "" && m[2] == "*") {
+		return 0
+	}
+	return len(m[0])
+}
+
+// list parses the list that starts at lines[i], and returns the index of
+// the line after it. The contents of an item are the lines indented more
+// than its marker.
+func (p *orgParser) list(lines []string, i int) (*Block, int) {
+	ind := indent(lines[i])
+	marker := orgListRE.FindStringSubmatch(lines[i])[2]
+	ordered := marker[0] >= '0' && marker[0] <= '9'
+	list := &Block{Kind: List, Ordered: ordered}
+	for {
+		width := orgListMarker(lines[i])
+		j := endIndented(lines, i+1, ind+1)
+		item := append([]string{lines[i][width:]}, dedent(lines[i+1:j], width)...)
+		list.Items = append(list.Items, p.parseBlocks(item))
+		i = j
+		if i == len(lines) || indent(lines[i]) != ind || orgListMarker(lines[i]) == 0 {
+			return list, i
+		}
+		m := orgListRE.FindStringSubmatch(lines[i])[2]
+		if (m[0] >= '0' && m[0] <= '9') != ordered {
+			return list, i
+		}
+	}
+}
+
+// special parses org-mode links, like [[url][description]].
+func (p *orgParser) special(s string, i int) (*Inline, int) {
+	m := orgLinkRE.FindStringSubmatch(s[i:])
+	if m == nil {
+		return nil, 0
+	}
+	u := strings.TrimPrefix(m[1], "file:")
+	desc := m[2]
+	switch {
+	case desc == "" && isImageURL(u):
+		return &Inline{Kind: Image, URL: u}, len(m[0])
+	case desc == "":
+		return &Inline{Kind: Link, URL: u, Children: []*Inline{{Kind: Text, Text: m[1]}}}, len(m[0])
+	case isImageURL(desc) && !strings.Contains(desc, " "):
+		// A link whose description is an image.
+		img := &Inline{Kind: Image, URL: strings.TrimPrefix(desc, "file:")}
+		return &Inline{Kind: Link, URL: u, Children: []*Inline{img}}, len(m[0])
+	default:
+		return &Inline{Kind: Link, URL: u, Children: p.syn.parse(desc)}, len(m[0])
+	}
+}
@@ -0,0 +1,460 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"regexp"
+	"strings"
+)
+
+// rstParser parses reStructuredText, as described at
+// https://docutils.sourceforge.io/docs/ref/rst/restructuredtext.html.
+type rstParser struct {
+	syn *inlineSyntax
+
+	// targets are the URLs of the hyperlink targets, by normalized name.
+	targets map[string]string
+
+	// subs are the substitution definitions, by name.
+	subs map[string]*Inline
+
+	// styles are the adornment styles of section titles, in the order they
+	// are first used, which determines the levels of the titles.
+	styles []string
+}
+
+func parseRST(lines []string) *Document {
+	p := &rstParser{
+		targets: map[string]string{},
+		subs:    map[string]*Inline{},
+	}
+	p.syn = &inlineSyntax{
+		spans:   []span{{"**", Strong}, {"``", Code}, {"*", Emphasis}},
+		escapes: true,
+		special: p.special,
+	}
+	p.collectDefinitions(lines)
+	return &Document{Blocks: p.parseBlocks(lines)}
+}
+
+var (
+	rstTargetRE       = regexp.MustCompile("^\\s*\\.\\. _(`[^`]+`|[^:]+):\\s*(.*)$")
+	rstSubstitutionRE = regexp.MustCompile(`^\s*\.\. \|([^|]+)\|\s+([\w-]+)::\s*(.*)$`)
+	rstDirectiveRE    = regexp.MustCompile(`^([\w-]+)::\s*(.*)$`)
+	rstOptionRE       = regexp.MustCompile(`^:([\w-]+):\s*(.*)$`)
+	rstEnumeratedRE   = regexp.MustCompile(`^(\d+|#)[.)] +|^\((\d+|#)\) +`)
+	rstEmbeddedURLRE  = regexp.MustCompile(`^(?s)(.*?)\s*<([^<>]+)>$`)
+	rstRoleRE         = regexp.MustCompile("^:[\\w-]+:`")
+)
+
+// collectDefinitions records the hyperlink targets and substitution
+// definitions of the document, since they can be referred to before they
+// are defined.
+func (p *rstParser) collectDefinitions(lines []string) {
+	for i, line := range lines {
+		if m := rstTargetRE.FindStringSubmatch(line); m != nil {
+			url := m[2]
+			// The URL may continue on the following indented lines.
+			for j := i + 1; j < len(lines) && indent(lines[j]) > indent(line) && !isBlank(lines[j]); j++ {
+				url += strings.TrimSpace(lines[j])
+			}
+			p.targets[rstRefName(m[1])] = strings.TrimSpace(url)
+			continue
+		}
+		m := rstSubstitutionRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		opts, _ := rstOptions(lines[i+1 : endIndented(lines, i+1, indent(line)+1)])
+		switch m[2] {
+		case "image":
+			var in = &Inline{Kind: Image, URL: strings.TrimSpace(m[3]), Text: opts["alt"]}
+			if t := opts["target"]; t != "" {
+				in = &Inline{Kind: Link, URL: t, Children: []*Inline{in}}
+			}
+			p.subs[m[1]] = in
+		case "replace":
+			p.subs[m[1]] = &Inline{Kind: Text, Text: m[3]}
+		}
+	}
+}
+
+// rstRefName returns the normalized form of a reference name, which is
+// case-insensitive and ignores differences in white space.
+func rstRefName(name string) string {
+	name = strings.Trim(name, "`")
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// endIndented returns the index of the first line at or after i that is
+// not blank and is indented less than n.
+func endIndented(lines []string, i, n int) int {
+	for i < len(lines) && (isBlank(lines[i]) || indent(lines[i]) >= n) {
+		i++
+	}
+	return i
+}
+
+func (p *rstParser) parseBlocks(lines []string) []*Block {
+	var blocks []*Block
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+
+		case indent(line) > 0:
+			j := endIndented(lines, i, 1)
+			quoted := lines[i:j]
+			blocks = append(blocks, &Block{Kind: Quote, Blocks: p.parseBlocks(dedent(quoted, minIndent(quoted)))})
+			i = j
+
+		case isRSTAdornment(line) && i+2 < len(lines) && !isBlank(lines[i+1]) &&
+			strings.TrimSpace(lines[i+2]) == strings.TrimSpace(line):
+			// A title with an overline and an underline.
+			blocks = append(blocks, p.heading("over"+line[:1], lines[i+1]))
+			i += 3
+
+		case isRSTAdornment(line) && len(strings.TrimSpace(line)) >= 4 && (i+1 == len(lines) || isBlank(lines[i+1])):
+			blocks = append(blocks, &Block{Kind: Rule})
+			i++
+
+		case i+1 < len(lines) && isRSTAdornment(lines[i+1]) &&
+			(len(strings.TrimSpace(lines[i+1])) >= len(strings.TrimSpace(line)) || len(strings.TrimSpace(lines[i+1])) >= 4):
+			// A title with an underline.
+			blocks = append(blocks, p.heading(lines[i+1][:1], line))
+			i += 2
+
+		case line == ".." || strings.HasPrefix(line, ".. "):
+			j := endIndented(lines, i+1, 1)
+			blocks = append(blocks, p.explicitMarkup(line[2:], lines[i+1:j])...)
+			i = j
+
+		case rstListMarker(line) > 0:
+			var list *Block
+			list, i = p.list(lines, i)
+			blocks = append(blocks, list)
+
+		case strings.HasPrefix(line, ">>> "):
+			// A doctest block.
+			j := i
+			for j < len(lines) && !isBlank(lines[j]) {
+				j++
+			}
+			blocks = append(blocks, &Block{Kind: CodeBlock, Lang: "python", Text: codeText(lines[i:j])})
+			i = j
+
+		case isRSTTableBorder(line):
+			// Tables are shown as they are written.
+			j := i
+			for j < len(lines) && !isBlank(lines[j]) {
+				j++
+			}
+			blocks = append(blocks, &Block{Kind: CodeBlock, Text: codeText(lines[i:j])})
+			i = j
+
+		default:
+			var b []*Block
+			b, i = p.paragraph(lines, i)
+			blocks = append(blocks, b...)
+		}
+	}
+	return blocks
+}
+
+// paragraph parses the paragraph that starts at lines[i], and the literal
+// block that follows it if it ends with "::". It returns the index of the
+// line after them.
+func (p *rstParser) paragraph(lines []string, i int) ([]*Block, int) {
+	j := i
+	for j < len(lines) && !isBlank(lines[j]) && indent(lines[j]) == 0 {
+		j++
+	}
+	if j == i {
+		// An unexpectedly indented line; treat it as a paragraph of its own.
+		j++
+	}
+	para := append([]string(nil), lines[i:j]...)
+	last := strings.TrimRight(para[len(para)-1], " ")
+	literal := strings.HasSuffix(last, "::")
+	if literal {
+		switch {
+		case last == "::":
+			para = para[:len(para)-1]
+		case strings.HasSuffix(last, " ::"):
+			para[len(para)-1] = strings.TrimSuffix(last, " ::")
+		default:
+			para[len(para)-1] = strings.TrimSuffix(last, ":")
+		}
+	}
+	var blocks []*Block
+	if len(para) > 0 {
+		blocks = append(blocks, paragraph(para, p.syn.parse))
+	}
+	if !literal {
+		return blocks, j
+	}
+	k := j
+	for k < len(lines) && isBlank(lines[k]) {
+		k++
+	}
+	if k == len(lines) || indent(lines[k]) == 0 {
+		return blocks, j
+	}
+	end := endIndented(lines, k, 1)
+	return append(blocks, &Block{Kind: CodeBlock, Text: codeText(lines[k:end])}), end
+}
+
+// heading returns a section title with the given text, whose level is
+// determined by its adornment style.
+func (p *rstParser) heading(style, text string) *Block {
+	level := 0
+	for level < len(p.styles) && p.styles[level] != style {
+		level++
+	}
+	if level == len(p.styles) {
+		p.styles = append(p.styles, style)
+	}
+	level++
+	if level > 6 {
+		level = 6
+	}
+	return &Block{Kind: Heading, Level: level, Inlines: p.syn.parse(strings.TrimSpace(text))}
+}
+
+// isRSTAdornment reports whether line is made of at least two repetitions
+// of a punctuation character, and so can underline or overline a section
+// title.
+func isRSTAdornment(line string) bool {
+	line = strings.TrimRight(line, " ")
+	if len(line) < 2 || !strings.ContainsRune("=-`:'\"~^_*+#<>.", rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line) && line != "::" && line != ".."
+}
+
+// isRSTTableBorder reports whether line is the border of a grid table or a
+// simple table.
+func isRSTTableBorder(line string) bool {
+	line = strings.TrimRight(line, " ")
+	if strings.HasPrefix(line, "+-") || strings.HasPrefix(line, "+=") {
+		return strings.Trim(line, "+-=") == ""
+	}
+	return strings.HasPrefix(line, "==") && strings.Contains(line, " ") && strings.Trim(line, "= ") == ""
+}
+
+// rstListMarker returns the width of the list item marker that starts
+// line, including the spaces after it, or 0 if line does not start a list
+// item.
+func rstListMarker(line string) int {
+	if len(line) > 2 && strings.ContainsRune("-*+", rune(line[0])) && line[1] == ' ' {
+		return 2 + indent(line[2:])
+	}
+	if m := rstEnumeratedRE.FindString(line); m != "" && len(m) < len(line) {
+		return len(m)
+	}
+	return 0
+}
+
+// list parses the list that starts at lines[i], and returns the index of
+// the line after it.
+func (p *rstParser) list(lines []string, i int) (*Block, int) {
+	ordered := !strings.ContainsRune("-*+", rune(lines[i][0]))
+	list := &Block{Kind: List, Ordered: ordered}
+	for {
+		width := rstListMarker(lines[i])
+		j := endIndented(lines, i+1, width)
+		item := append([]string{lines[i][width:]}, dedent(lines[i+1:j], width)...)
+		list.Items = append(list.Items, p.parseBlocks(item))
+		i = j
+		if i == len(lines) || rstListMarker(lines[i]) == 0 ||
+			strings.ContainsRune("-*+", rune(lines[i][0])) == ordered {
+			return list, i
+		}
+	}
+}
+
+// explicitMarkup returns the blocks of explicit markup: a directive,
+// a comment, a hyperlink target or a substitution definition. first is the
+// rest of the line that starts with "..", and rest are the indented lines
+// that follow it.
+func (p *rstParser) explicitMarkup(first string, rest []string) []*Block {
+	body := strings.TrimSpace(first)
+	if strings.HasPrefix(body, "_") || strings.HasPrefix(body, "|") {
+		// Hyperlink targets and substitution definitions were recorded by
+		// collectDefinitions.
+		return nil
+	}
+	m := rstDirectiveRE.FindStringSubmatch(body)
+	if m == nil {
+		// A comment.
+		return nil
+	}
+	name, arg := strings.ToLower(m[1]), strings.TrimSpace(m[2])
+	opts, content := rstOptions(rest)
+	switch name {
+	case "image", "figure":
+		var in = &Inline{Kind: Image, URL: arg, Text: opts["alt"]}
+		if t := opts["target"]; t != "" {
+			in = &Inline{Kind: Link, URL: t, Children: []*Inline{in}}
+		}
+		blocks := []*Block{{Kind: Paragraph, Inlines: []*Inline{in}}}
+		if name == "figure" {
+			// The caption and legend of the figure.
+			blocks = append(blocks, p.parseBlocks(content)...)
+		}
+		return blocks
+	case "code", "code-block", "sourcecode":
+		return []*Block{{Kind: CodeBlock, Lang: arg, Text: codeText(content)}}
+	case "note", "tip", "hint", "important", "attention", "caution", "warning", "danger", "error", "admonition":
+		title := strings.ToUpper(name[:1]) + name[1:]
+		if name == "admonition" {
+			title = arg
+		} else if arg != "" {
+			content = append([]string{arg}, content...)
+		}
+		blocks := []*Block{{Kind: Paragraph, Inlines: []*Inline{{Kind: Strong, Children: p.syn.parse(title)}}}}
+		return []*Block{{Kind: Quote, Blocks: append(blocks, p.parseBlocks(content)...)}}
+	default:
+		// Other directives, like "contents" and "toctree", are specific to
+		// documentation tools.
+		return nil
+	}
+}
+
+// rstOptions parses the indented body of a directive into its options and
+// content.
+func rstOptions(body []string) (map[string]string, []string) {
+	body = dedent(body, minIndent(body))
+	opts := map[string]string{}
+	i := 0
+	for ; i < len(body); i++ {
+		m := rstOptionRE.FindStringSubmatch(body[i])
+		if m == nil {
+			break
+		}
+		opts[m[1]] = strings.TrimSpace(m[2])
+	}
+	return opts, body[i:]
+}
+
+// special parses the inline markup of reStructuredText that is not a
+// simple span: references, interpreted text and substitutions.
+func (p *rstParser) special(s string, i int) (*Inline, int) {
+	if i > 0 && !isBoundary(s[i-1]) {
+		return nil, 0
+	}
+	switch s[i] {
+	case '`':
+		if strings.HasPrefix(s[i:], "``") {
+			return nil, 0
+		}
+		return p.interpreted(s, i, "")
+	case ':':
+		m := rstRoleRE.FindString(s[i:])
+		if m == "" {
+			return nil, 0
+		}
+		in, n := p.interpreted(s, i+len(m)-1, strings.Trim(m, ":`"))
+		if n == 0 {
+			return nil, 0
+		}
+		return in, len(m) - 1 + n
+	case '|':
+		end := strings.IndexByte(s[i+1:], '|')
+		if end <= 0 {
+			return nil, 0
+		}
+		name := s[i+1 : i+1+end]
+		sub, ok := p.subs[name]
+		if !ok {
+			return nil, 0
+		}
+		n := end + 2
+		if under := underscores(s[i+n:]); under > 0 {
+			if u, ok := p.targets[rstRefName(name)]; ok {
+				sub = &Inline{Kind: Link, URL: u, Children: []*Inline{sub}}
+			}
+			n += under
+		}
+		return sub, n
+	}
+	// A simple reference name followed by an underscore, like name_.
+	j := i
+	for j < len(s) && (isAlnum(s[j]) || strings.IndexByte("_.+-", s[j]) >= 0) {
+		j++
+	}
+	word := strings.TrimRight(s[i:j], ".")
+	if len(word) < 2 || !strings.HasSuffix(word, "_") {
+		return nil, 0
+	}
+	name := strings.TrimRight(word, "_")
+	u, ok := p.targets[rstRefName(name)]
+	if !ok {
+		return nil, 0
+	}
+	return &Inline{Kind: Link, URL: u, Children: []*Inline{{Kind: Text, Text: name}}}, len(word)
+}
+
+// interpreted parses the interpreted text or reference that starts with the
+// backquote at s[i], whose role, if any, is given.
+func (p *rstParser) interpreted(s string, i int, role string) (*Inline, int) {
+	end := strings.IndexByte(s[i+1:], '`')
+	if end <= 0 {
+		return nil, 0
+	}
+	text := s[i+1 : i+1+end]
+	n := end + 2
+	if under := underscores(s[i+n:]); under > 0 && role == "" {
+		n += under
+		if m := rstEmbeddedURLRE.FindStringSubmatch(text); m != nil {
+			label, u := m[1], m[2]
+			if strings.HasSuffix(u, "_") {
+				u = p.targets[rstRefName(strings.TrimSuffix(u, "_"))]
+			}
+			if label == "" {
+				label = u
+			}
+			return &Inline{Kind: Link, URL: u, Children: p.syn.parse(label)}, n
+		}
+		if u, ok := p.targets[rstRefName(text)]; ok {
+			return &Inline{Kind: Link, URL: u, Children: p.syn.parse(text)}, n
+		}
+		return &Inline{Kind: Text, Text: text}, n
+	}
+	// Roles that refer to other documents, like :ref:`text <target>`, are
+	// shown as their text.
+	if m := rstEmbeddedURLRE.FindStringSubmatch(text); m != nil && m[1] != "" {
+		text = m[1]
+	}
+	switch role {
+	case "", "title-reference", "emphasis":
+		return &Inline{Kind: Emphasis, Children: []*Inline{{Kind: Text, Text: text}}}, n
+	case "strong":
+		return &Inline{Kind: Strong, Children: []*Inline{{Kind: Text, Text: text}}}, n
+	case "code", "literal", "file", "command", "program", "samp", "envvar", "option":
+		return &Inline{Kind: Code, Text: text}, n
+	default:
+		return &Inline{Kind: Text, Text: text}, n
+	}
+}
+
+// underscores returns the length of the "_" or "__" that ends a reference
+// at the start of s, or 0 if there is none.
+func underscores(s string) int {
+	n := 0
+	for n < len(s) && n < 2 && s[n] == '_' {
+		n++
+	}
+	if n == 0 || (n < len(s) && !isBoundary(s[n])) {
+		return 0
+	}
+	return n
+}
+
+// isAlnum reports whether b is an ASCII letter or digit.
+func isAlnum(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
@@ -0,0 +1,159 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A CacheStore holds the responses cached by the Cache middleware.
+type CacheStore interface {
+	// Get returns the value stored under key. It returns false if there is
+	// none, or if it has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Put stores val under key for ttl, and tags it with the given tags.
+	Put(ctx context.Context, key string, val []byte, ttl time.Duration, tags []string) error
+
+	// PurgeTags deletes the values that have any of the given tags, and
+	// returns the number of values deleted.
+	PurgeTags(ctx context.Context, tags ...string) (int, error)
+
+	// Lock acquires the lock with the given name for at most ttl. It returns
+	// false if the lock is already held.
+	Lock(ctx context.Context, name string, ttl time.Duration) (bool, error)
+
+	// Unlock releases the lock with the given name.
+	Unlock(ctx context.Context, name string) error
+}
+
+// RedisStore is a CacheStore backed by redis, which can be shared by several
+// processes.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore returns a RedisStore that uses client.
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Get implements CacheStore.Get.
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	val, err := s.client.WithContext(ctx).Get(key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+// Put implements CacheStore.Put. The key is added to a redis set for each tag.
+func (s *RedisStore) Put(ctx context.Context, key string, val []byte, ttl time.Duration, tags []string) error {
+	pipe := s.client.WithContext(ctx).TxPipeline()
+	pipe.Set(key, val, ttl)
+	for _, tag := range tags {
+		pipe.Eval(addTagScript, []string{tagSetKey(tag)}, key, int64(ttl/time.Second))
+	}
+	_, err := pipe.Exec()
+	return err
+}
+
+// PurgeTags implements CacheStore.PurgeTags.
+func (s *RedisStore) PurgeTags(ctx context.Context, tags ...string) (int, error) {
+	return PurgeCacheTags(ctx, s.client, tags...)
+}
+
+// Lock implements CacheStore.Lock.
+func (s *RedisStore) Lock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	return s.client.WithContext(ctx).SetNX(lockKey(name), 1, ttl).Result()
+}
+
+// Unlock implements CacheStore.Unlock.
+func (s *RedisStore) Unlock(ctx context.Context, name string) error {
+	return s.client.WithContext(ctx).Del(lockKey(name)).Err()
+}
+
+// lockKey returns the key of the lock with the given name. Cache keys are
+// URLs, which never have this prefix.
+func lockKey(name string) string {
+	return "lock:" + name
+}
+
+// TieredStore is a CacheStore that keeps values in a local store, usually an
+// LRUStore, in front of a shared store, usually a RedisStore.
+//
+// Values are kept in the local store for at most a fixed TTL, since they are
+// not purged from it when another process purges the shared store.
+type TieredStore struct {
+	local, shared CacheStore
+	localTTL      time.Duration
+}
+
+// NewTieredStore returns a TieredStore that keeps values from shared in local
+// for at most localTTL.
+func NewTieredStore(local, shared CacheStore, localTTL time.Duration) *TieredStore {
+	return &TieredStore{local: local, shared: shared, localTTL: localTTL}
+}
+
+// Get implements CacheStore.Get. A value found only in the shared store is
+// copied to the local store.
+func (s *TieredStore) Get(ctx context.Context, key string) (_ []byte, _ bool, err error) {
+	defer derrors.Wrap(&err, "TieredStore.Get(%q)", key)
+
+	if val, ok, err := s.local.Get(ctx, key); err == nil && ok {
+		return val, true, nil
+	}
+	val, ok, err := s.shared.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	if err := s.local.Put(ctx, key, val, s.localTTL, nil); err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+// Put implements CacheStore.Put.
+func (s *TieredStore) Put(ctx context.Context, key string, val []byte, ttl time.Duration, tags []string) (err error) {
+	defer derrors.Wrap(&err, "TieredStore.Put(%q)", key)
+
+	localTTL := ttl
+	if localTTL > s.localTTL {
+		localTTL = s.localTTL
+	}
+	if err := s.local.Put(ctx, key, val, localTTL, tags); err != nil {
+		return err
+	}
+	return s.shared.Put(ctx, key, val, ttl, tags)
+}
+
+// PurgeTags implements CacheStore.PurgeTags. It returns the number of values
+// deleted from the shared store.
+func (s *TieredStore) PurgeTags(ctx context.Context, tags ...string) (_ int, err error) {
+	defer derrors.Wrap(&err, "TieredStore.PurgeTags(%q)", tags)
+
+	if _, err := s.local.PurgeTags(ctx, tags...); err != nil {
+		return 0, err
+	}
+	return s.shared.PurgeTags(ctx, tags...)
+}
+
+// Lock implements CacheStore.Lock using the shared store, so that only one
+// process holds the lock.
+func (s *TieredStore) Lock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	return s.shared.Lock(ctx, name, ttl)
+}
+
+// Unlock implements CacheStore.Unlock.
+func (s *TieredStore) Unlock(ctx context.Context, name string) error {
+	return s.shared.Unlock(ctx, name)
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+)
+
+func TestCacheStores(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	newRedis := func() CacheStore {
+		s.FlushAll()
+		return NewRedisStore(redis.NewClient(&redis.Options{Addr: s.Addr()}))
+	}
+	for _, test := range []struct {
+		name  string
+		store func() CacheStore
+	}{
+		{"redis", newRedis},
+		{"lru", func() CacheStore { return NewLRUStore(1<<20, 0) }},
+		{"tiered", func() CacheStore { return NewTieredStore(NewLRUStore(1<<20, 0), newRedis(), time.Minute) }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			testCacheStore(t, test.store())
+		})
+	}
+}
+
+func testCacheStore(t *testing.T, store CacheStore) {
+	ctx := context.Background()
+	check := func(key, want string) {
+		t.Helper()
+		got, ok, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			got = nil
+		}
+		if string(got) != want {
+			t.Errorf("Get(%q) = %q, want %q", key, got, want)
+		}
+	}
+	put := func(key, val string, tags ...string) {
+		t.Helper()
+		if err := store.Put(ctx, key, []byte(val), time.Hour, tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check("/a", "")
+	put("/a", "A", "t1")
+	put("/b", "B", "t1", "t2")
+	put("/c", "C")
+	check("/a", "A")
+	n, err := store.PurgeTags(ctx, "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("PurgeTags(t1) = %d, want 2", n)
+	}
+	check("/a", "")
+	check("/b", "")
+	check("/c", "C")
+
+	ok, err := store.Lock(ctx, "l", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("first Lock: got %t, %v; want true, nil", ok, err)
+	}
+	if ok, _ := store.Lock(ctx, "l", time.Minute); ok {
+		t.Error("second Lock succeeded, want failure")
+	}
+	if err := store.Unlock(ctx, "l"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := store.Lock(ctx, "l", time.Minute); !ok {
+		t.Error("Lock after Unlock failed, want success")
+	}
+}
+
+func TestLRUStore(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	ctx := context.Background()
+	// Each entry takes 10 bytes: a 2-byte key and an 8-byte value.
+	s := NewLRUStore(30, 0)
+	for _, k := range []string{"/a", "/b", "/c"} {
+		s.Put(ctx, k, []byte("12345678"), time.Minute, []string{"t"})
+	}
+	s.Get(ctx, "/a")
+	// Evicts /b, the least recently used.
+	s.Put(ctx, "/d", []byte("12345678"), time.Minute, []string{"t"})
+	// Too big to store.
+	s.Put(ctx, "/e", make([]byte, 29), time.Minute, nil)
+	for k, want := range map[string]bool{"/a": true, "/b": false, "/c": true, "/d": true, "/e": false} {
+		if _, got, _ := s.Get(ctx, k); got != want {
+			t.Errorf("Get(%q): got %t, want %t", k, got, want)
+		}
+	}
+	now = now.Add(time.Minute)
+	if _, ok, _ := s.Get(ctx, "/a"); ok {
+		t.Error("Get(/a) after expiration succeeded")
+	}
+	if n, _ := s.PurgeTags(ctx, "t"); n != 2 {
+		t.Errorf("PurgeTags(t) = %d, want 2", n)
+	}
+	if s.size != 0 || len(s.items) != 0 || len(s.tags) != 0 {
+		t.Errorf("got size %d, %d items, %d tags after purging everything; want 0", s.size, len(s.items), len(s.tags))
+	}
+}
+
+func TestLRUStoreMaxTTL(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	ctx := context.Background()
+	s := NewLRUStore(1<<20, time.Minute)
+	s.Put(ctx, "/a", []byte("a"), time.Hour, nil)
+	s.Put(ctx, "/b", []byte("b"), time.Second, nil)
+	now = now.Add(30 * time.Second)
+	if _, ok, _ := s.Get(ctx, "/a"); !ok {
+		t.Error("Get(/a) before max TTL failed")
+	}
+	if _, ok, _ := s.Get(ctx, "/b"); ok {
+		t.Error("Get(/b) after its own TTL succeeded")
+	}
+	now = now.Add(30 * time.Second)
+	if _, ok, _ := s.Get(ctx, "/a"); ok {
+		t.Error("Get(/a) after max TTL succeeded")
+	}
+}
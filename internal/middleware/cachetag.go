@@ -0,0 +1,124 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/go-redis/redis/v7"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// Cache entries can be tagged, so that they can be invalidated when the data
+// they were computed from changes. A handler behind the Cache middleware adds
+// tags with AddCacheTags. When the response is cached, its key is added to a
+// redis set for each tag. PurgeCacheTags deletes the entries in those sets.
+
+// ModuleCacheTag returns the tag for cache entries that display data from the
+// module with the given path.
+func ModuleCacheTag(modulePath string) string {
+	return "module:" + modulePath
+}
+
+// PathCacheTag returns the tag for cache entries for the unit with the given
+// path, in any module.
+func PathCacheTag(unitPath string) string {
+	return "path:" + unitPath
+}
+
+// tagSetKey returns the key of the redis set holding the cache keys with the
+// given tag. Cache keys are URLs, which never have this prefix.
+func tagSetKey(tag string) string {
+	return "cachetag:" + tag
+}
+
+type cacheTagsKey struct{}
+
+// cacheTags collects the tags added while serving a request.
+type cacheTags struct {
+	mu   sync.Mutex
+	tags map[string]bool
+}
+
+func (t *cacheTags) list() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var tags []string
+	for tag := range t.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func newContextWithCacheTags(ctx context.Context) (context.Context, *cacheTags) {
+	t := &cacheTags{tags: map[string]bool{}}
+	return context.WithValue(ctx, cacheTagsKey{}, t), t
+}
+
+// AddCacheTags tags the cache entry for the request with the given context.
+// It does nothing if the request is not being cached.
+func AddCacheTags(ctx context.Context, tags ...string) {
+	t, ok := ctx.Value(cacheTagsKey{}).(*cacheTags)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tag := range tags {
+		t.tags[tag] = true
+	}
+}
+
+// addTagScript adds the cache key ARGV[1] to the tag set KEYS[1], and makes
+// sure the set lives at least as long as the entry, whose TTL in seconds is
+// ARGV[2]. A set with no expiration has a TTL of -1.
+const addTagScript = `
+redis.call("SADD", KEYS[1], ARGV[1])
+if redis.call("TTL", KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call("EXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`
+
+// PurgeCacheTags deletes every cache entry that has one of the given tags,
+// and returns the number of entries deleted.
+func PurgeCacheTags(ctx context.Context, client *redis.Client, tags ...string) (_ int, err error) {
+	defer derrors.Wrap(&err, "PurgeCacheTags(%q)", tags)
+
+	if len(tags) == 0 {
+		return 0, nil
+	}
+	c := client.WithContext(ctx)
+	var setKeys []string
+	pipe := c.Pipeline()
+	var members []*redis.StringSliceCmd
+	for _, tag := range tags {
+		setKeys = append(setKeys, tagSetKey(tag))
+		members = append(members, pipe.SMembers(tagSetKey(tag)))
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return 0, err
+	}
+	var keys []string
+	for _, m := range members {
+		keys = append(keys, m.Val()...)
+	}
+	pipe = c.TxPipeline()
+	var deleted *redis.IntCmd
+	if len(keys) > 0 {
+		deleted = pipe.Del(keys...)
+	}
+	pipe.Del(setKeys...)
+	if _, err := pipe.Exec(); err != nil {
+		return 0, err
+	}
+	if deleted == nil {
+		return 0, nil
+	}
+	return int(deleted.Val()), nil
+}
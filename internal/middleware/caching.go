@@ -8,19 +8,22 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/gob"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/go-redis/redis/v7"
 	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/xcontext"
 )
 
 var (
@@ -88,9 +91,10 @@ func recordCacheError(ctx context.Context, name, operation string) {
 type cache struct {
 	name       string
 	authValues []string
-	client     *redis.Client
+	store      CacheStore
 	delegate   http.Handler
 	expirer    Expirer
+	staleTTL   time.Duration
 }
 
 // An Expirer computes the TTL that should be used when caching a page.
@@ -103,25 +107,83 @@ func TTL(ttl time.Duration) Expirer {
 	}
 }
 
-// Cache returns a new Middleware that caches every request.
+// A CacheOption configures the Cache middleware.
+type CacheOption func(*cache)
+
+// StaleWhileRevalidate returns a CacheOption that keeps pages for staleTTL
+// after they expire. A request for an expired page is answered with the stale
+// page, while the page is refreshed in the background.
+func StaleWhileRevalidate(staleTTL time.Duration) CacheOption {
+	return func(c *cache) {
+		c.staleTTL = staleTTL
+	}
+}
+
+// Cache returns a new Middleware that caches every request in store.
 // The name of the cache is used only for metrics.
 // The expirer is a func that is used to map a new request to its TTL.
 // authHeader is the header key used by the cache to know that a
 // request should bypass the cache.
 // authValues is the set of values that could be set on the authHeader in
 // order to bypass the cache.
-func Cache(name string, client *redis.Client, expirer Expirer, authValues []string) Middleware {
+//
+// Responses are cached with their status, the headers in cachedHeaders and an
+// ETag computed from the body, and requests whose If-None-Match header
+// matches the ETag are answered with a 304.
+func Cache(name string, store CacheStore, expirer Expirer, authValues []string, opts ...CacheOption) Middleware {
 	return func(h http.Handler) http.Handler {
-		return &cache{
+		c := &cache{
 			name:       name,
 			authValues: authValues,
-			client:     client,
+			store:      store,
 			delegate:   h,
 			expirer:    expirer,
 		}
+		for _, opt := range opts {
+			opt(c)
+		}
+		return c
 	}
 }
 
+// cacheableStatuses are the statuses of the responses that are cached.
+// Not-found responses are not cached, because the frontend reloads the page
+// after it fetches a missing module.
+var cacheableStatuses = map[int]bool{
+	http.StatusOK:                true,
+	http.StatusMovedPermanently:  true,
+	http.StatusPermanentRedirect: true,
+}
+
+// cachedHeaders are the response headers that are stored in the cache.
+// The Content-Security-Policy header must match the body, which may contain
+// nonces.
+var cachedHeaders = []string{
+	"Content-Type",
+	"Content-Language",
+	"Content-Security-Policy",
+	"Location",
+}
+
+// revalidateTimeout bounds the time to refresh a stale page.
+const revalidateTimeout = 1 * time.Minute
+
+// timeNow is the clock used to decide whether pages are stale, replaced in
+// tests.
+var timeNow = time.Now
+
+// A cacheEntry is a response stored in the cache.
+type cacheEntry struct {
+	Status int
+	Header http.Header
+	ETag   string
+	// Body is the gzip-compressed body.
+	Body []byte
+	// FreshUntil is the time after which the entry is stale, and is refreshed
+	// when it is served.
+	FreshUntil time.Time
+}
+
 func (c *cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Check auth header to see if request should bypass cache.
 	authVal := r.Header.Get(config.BypassCacheAuthHeader)
@@ -134,35 +196,125 @@ func (c *cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	key := r.URL.String()
 	start := time.Now()
-	reader, hit := c.get(ctx, key)
+	e, hit := c.get(ctx, key)
 	recordCacheResult(ctx, c.name, hit, time.Since(start))
 	if hit {
-		if _, err := io.Copy(w, reader); err != nil {
-			log.Errorf(ctx, "error copying zip bytes: %v", err)
+		zr, err := gzip.NewReader(bytes.NewReader(e.Body))
+		if err == nil {
+			if c.staleTTL > 0 && timeNow().After(e.FreshUntil) {
+				c.revalidate(r, w.Header().Clone(), key)
+			}
+			writeResponse(w, r, e.Status, e.Header, e.ETag, zr)
+			return
 		}
-		return
+		// Fall back to un-cached serving.
+		log.Errorf(ctx, "cache: gzip.NewReader: %v", err)
+		recordCacheError(ctx, c.name, "UNZIP")
 	}
-	rec := newRecorder(w)
-	c.delegate.ServeHTTP(rec, r)
-	if rec.bufErr == nil && (rec.statusCode == 0 || rec.statusCode == http.StatusOK) {
-		ttl := c.expirer(r)
+	rec := c.record(ctx, r, w.Header())
+	var etag string
+	if cacheableStatuses[rec.statusCode] {
+		etag = computeETag(rec.buf.Bytes())
 		if testMode {
-			c.put(ctx, key, rec, ttl)
+			c.put(ctx, key, rec, etag)
 		} else {
-			go c.put(ctx, key, rec, ttl)
+			go c.put(ctx, key, rec, etag)
+		}
+	}
+	writeResponse(w, r, rec.statusCode, rec.header, etag, bytes.NewReader(rec.buf.Bytes()))
+}
+
+// record serves r with the delegate handler, starting with the given response
+// headers, and returns the recorded response.
+func (c *cache) record(ctx context.Context, r *http.Request, header http.Header) *cacheRecorder {
+	tagCtx, tags := newContextWithCacheTags(ctx)
+	rec := newRecorder(header)
+	rec.req = r.WithContext(tagCtx)
+	c.delegate.ServeHTTP(rec, rec.req)
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.tags = tags.list()
+	return rec
+}
+
+// revalidate refreshes the cache entry for r in the background, unless
+// another request is already refreshing it. header holds the response headers
+// set before the cache middleware.
+func (c *cache) revalidate(r *http.Request, header http.Header, key string) {
+	// Detach from the request, which is answered before the refresh is
+	// done.
+	ctx := xcontext.Detach(r.Context())
+	refresh := func() {
+		ctx, cancel := context.WithTimeout(ctx, revalidateTimeout)
+		defer cancel()
+		lock := "revalidate:" + key
+		ok, err := c.store.Lock(ctx, lock, revalidateTimeout)
+		if err != nil {
+			log.Warningf(ctx, "cache revalidate %q: %v", key, err)
+			recordCacheError(ctx, c.name, "LOCK")
+			return
+		}
+		if !ok {
+			return
+		}
+		defer c.store.Unlock(ctx, lock)
+		rec := c.record(ctx, r, header)
+		if cacheableStatuses[rec.statusCode] {
+			c.put(ctx, key, rec, computeETag(rec.buf.Bytes()))
+		}
+	}
+	if testMode {
+		refresh()
+	} else {
+		go refresh()
+	}
+}
+
+// writeResponse writes a response with the given status, headers and body to
+// w, or a 304 if r has an If-None-Match header that matches etag.
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, header http.Header, etag string, body io.Reader) {
+	h := w.Header()
+	for k, v := range header {
+		h[k] = v
+	}
+	if etag != "" {
+		h.Set("ETag", etag)
+		if status == http.StatusOK && etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
 		}
 	}
+	w.WriteHeader(status)
+	if _, err := io.Copy(w, body); err != nil {
+		log.Errorf(r.Context(), "error copying response bytes: %v", err)
+	}
 }
 
-func (c *cache) get(ctx context.Context, key string) (io.Reader, bool) {
-	// Set a short timeout for redis requests, so that we can quickly
-	// fall back to un-cached serving if redis is unavailable.
+// computeETag returns a strong entity tag for a response body.
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%x"`, sum[:16])
+}
+
+// etagMatches reports whether the value of an If-None-Match header matches
+// etag, using the weak comparison of RFC 7232 section 2.3.2.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cache) get(ctx context.Context, key string) (*cacheEntry, bool) {
+	// Set a short timeout for store requests, so that we can quickly
+	// fall back to un-cached serving if the store is unavailable.
 	getCtx, cancelGet := context.WithTimeout(ctx, 100*time.Millisecond)
 	defer cancelGet()
-	val, err := c.client.WithContext(getCtx).Get(key).Bytes()
-	if err == redis.Nil {
-		return nil, false
-	}
+	val, ok, err := c.store.Get(getCtx, key)
 	if err != nil {
 		select {
 		case <-getCtx.Done():
@@ -173,65 +325,86 @@ func (c *cache) get(ctx context.Context, key string) (io.Reader, bool) {
 		recordCacheError(ctx, c.name, "GET")
 		return nil, false
 	}
-	zr, err := gzip.NewReader(bytes.NewReader(val))
-	if err != nil {
-		log.Errorf(ctx, "cache: gzip.NewReader: %v", err)
-		recordCacheError(ctx, c.name, "UNZIP")
+	if !ok {
 		return nil, false
 	}
-	return zr, true
+	var e cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(val)).Decode(&e); err != nil {
+		log.Errorf(ctx, "cache: decoding %q: %v", key, err)
+		recordCacheError(ctx, c.name, "DECODE")
+		return nil, false
+	}
+	return &e, true
 }
 
-func (c *cache) put(ctx context.Context, key string, rec *cacheRecorder, ttl time.Duration) {
-	if err := rec.zipWriter.Close(); err != nil {
+// put stores the recorded response under key, tagged with the tags of the
+// response.
+func (c *cache) put(ctx context.Context, key string, rec *cacheRecorder, etag string) {
+	ttl := c.expirer(rec.req)
+	e := &cacheEntry{
+		Status:     rec.statusCode,
+		Header:     http.Header{},
+		ETag:       etag,
+		FreshUntil: timeNow().Add(ttl),
+	}
+	for _, h := range cachedHeaders {
+		if v := rec.header.Values(h); len(v) > 0 {
+			e.Header[h] = v
+		}
+	}
+	var zbuf bytes.Buffer
+	zw := gzip.NewWriter(&zbuf)
+	if _, err := zw.Write(rec.buf.Bytes()); err != nil {
+		log.Errorf(ctx, "cache: error zipping %q: %v", key, err)
+		return
+	}
+	if err := zw.Close(); err != nil {
 		log.Errorf(ctx, "cache: error closing zip for %q: %v", key, err)
 		return
 	}
-	log.Infof(ctx, "caching response of length %d for %s", rec.buf.Len(), key)
+	e.Body = zbuf.Bytes()
+	var val bytes.Buffer
+	if err := gob.NewEncoder(&val).Encode(e); err != nil {
+		log.Errorf(ctx, "cache: error encoding %q: %v", key, err)
+		return
+	}
+
+	// Keep the entry past its TTL, to serve it while it is refreshed.
+	ttl += c.staleTTL
+	log.Infof(ctx, "caching response of length %d for %s", len(e.Body), key)
 	setCtx, cancelSet := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancelSet()
-	_, err := c.client.WithContext(setCtx).Set(key, rec.buf.Bytes(), ttl).Result()
-	if err != nil {
+	if err := c.store.Put(setCtx, key, val.Bytes(), ttl, rec.tags); err != nil {
 		recordCacheError(ctx, c.name, "SET")
 		log.Warningf(ctx, "cache set %q: %v", key, err)
 	}
 }
 
-func newRecorder(w http.ResponseWriter) *cacheRecorder {
-	buf := &bytes.Buffer{}
-	zw := gzip.NewWriter(buf)
-	return &cacheRecorder{ResponseWriter: w, buf: buf, zipWriter: zw}
+func newRecorder(header http.Header) *cacheRecorder {
+	return &cacheRecorder{header: header.Clone()}
 }
 
-// cacheRecorder is an http.ResponseWriter that collects http bytes for later
-// writing to the cache. Along the way it collects any error, along with the
-// resulting HTTP status code. We only cache 200 OK responses.
+// cacheRecorder is an http.ResponseWriter that collects the response, so
+// that it can be cached and then written.
 type cacheRecorder struct {
-	http.ResponseWriter
+	header     http.Header
 	statusCode int
+	buf        bytes.Buffer
+	// req is the request the response is for, and tags are the cache tags
+	// added while serving it.
+	req  *http.Request
+	tags []string
+}
 
-	bufErr    error
-	buf       *bytes.Buffer
-	zipWriter *gzip.Writer
+func (r *cacheRecorder) Header() http.Header {
+	return r.header
 }
 
 func (r *cacheRecorder) Write(b []byte) (int, error) {
-	n, err := r.ResponseWriter.Write(b)
-	// Only try writing to the buffer if we haven't yet encountered an error.
-	if r.bufErr == nil {
-		if err == nil {
-			zn, bufErr := r.zipWriter.Write(b)
-			if bufErr != nil {
-				r.bufErr = bufErr
-			}
-			if zn != n {
-				r.bufErr = fmt.Errorf("wrote %d to zip, but wanted %d", zn, n)
-			}
-		} else {
-			r.bufErr = fmt.Errorf("ResponseWriter.Write failed: %v", err)
-		}
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
 	}
-	return n, err
+	return r.buf.Write(b)
 }
 
 func (r *cacheRecorder) WriteHeader(statusCode int) {
@@ -240,5 +413,4 @@ func (r *cacheRecorder) WriteHeader(statusCode int) {
 		// middleware thinks the response is not OK, we will capture this.
 		r.statusCode = statusCode
 	}
-	r.ResponseWriter.WriteHeader(statusCode)
 }
@@ -5,11 +5,13 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -44,7 +46,7 @@ func TestCache(t *testing.T) {
 
 	c := redis.NewClient(&redis.Options{Addr: s.Addr()})
 	mux := http.NewServeMux()
-	mux.Handle("/A", Cache("A", c, TTL(1*time.Minute), []string{"yes"})(handler))
+	mux.Handle("/A", Cache("A", NewRedisStore(c), TTL(1*time.Minute), []string{"yes"})(handler))
 	mux.Handle("/B", handler)
 	ts := httptest.NewServer(mux)
 	view.Register(CacheResultCount)
@@ -178,3 +180,219 @@ func TestCache(t *testing.T) {
 		}
 	}
 }
+
+func TestCacheTags(t *testing.T) {
+	// force cache writes to be synchronous
+	testMode = true
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// The handler tags each page with its first path element, and with "all".
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		AddCacheTags(r.Context(), strings.Split(r.URL.Path, "/")[1], "all")
+		fmt.Fprint(w, r.URL.Path)
+	})
+	c := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ts := httptest.NewServer(Cache("tags", NewRedisStore(c), TTL(1*time.Minute), nil)(handler))
+	defer ts.Close()
+
+	get := func(path string) {
+		t.Helper()
+		resp, err := ts.Client().Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != path {
+			t.Errorf("GET %s: got body %q, want %q", path, body, path)
+		}
+	}
+	checkCalls := func(want int) {
+		t.Helper()
+		if calls != want {
+			t.Errorf("got %d handler calls, want %d", calls, want)
+		}
+	}
+
+	ctx := context.Background()
+	get("/a/1")
+	get("/a/2")
+	get("/b/1")
+	checkCalls(3)
+	// The tag sets outlive the entries they hold.
+	if ttl := s.TTL(tagSetKey("a")); ttl != time.Minute {
+		t.Errorf("got TTL %v for tag set, want 1m", ttl)
+	}
+
+	n, err := PurgeCacheTags(ctx, c, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("PurgeCacheTags(a): deleted %d entries, want 2", n)
+	}
+	get("/a/1") // purged
+	get("/b/1") // still cached
+	checkCalls(4)
+
+	n, err = PurgeCacheTags(ctx, c, "all", "unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("PurgeCacheTags(all, unknown): deleted %d entries, want 2", n)
+	}
+	get("/a/1")
+	get("/b/1")
+	checkCalls(6)
+}
+
+func TestCacheResponse(t *testing.T) {
+	// force cache writes to be synchronous
+	testMode = true
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("X-Not-Cached", "1")
+			fmt.Fprint(w, "<p>page</p>")
+		case "/moved":
+			http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	c := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ts := httptest.NewServer(Cache("response", NewRedisStore(c), TTL(1*time.Minute), nil)(handler))
+	defer ts.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	get := func(path, ifNoneMatch string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest("GET", ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	miss := get("/page", "")
+	etag := miss.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag on uncached response")
+	}
+	hit := get("/page", "")
+	if calls != 1 {
+		t.Errorf("got %d handler calls, want 1", calls)
+	}
+	if got, want := hit.Header.Get("Content-Type"), "text/html; charset=utf-8"; got != want {
+		t.Errorf("cached Content-Type: got %q, want %q", got, want)
+	}
+	if got := hit.Header.Get("X-Not-Cached"); got != "" {
+		t.Errorf("cached X-Not-Cached: got %q, want none", got)
+	}
+	if got := hit.Header.Get("ETag"); got != etag {
+		t.Errorf("cached ETag: got %q, want %q", got, etag)
+	}
+	for _, inm := range []string{etag, `"other", W/` + etag, "*"} {
+		if got := get("/page", inm).StatusCode; got != http.StatusNotModified {
+			t.Errorf("If-None-Match: %s: got status %d, want 304", inm, got)
+		}
+	}
+	if got := get("/page", `"other"`).StatusCode; got != http.StatusOK {
+		t.Errorf(`If-None-Match: "other": got status %d, want 200`, got)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp := get("/moved", "")
+		if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "/page" {
+			t.Errorf("GET /moved: got %d to %q, want 301 to /page", resp.StatusCode, resp.Header.Get("Location"))
+		}
+		if got := get("/missing", "").StatusCode; got != http.StatusNotFound {
+			t.Errorf("GET /missing: got status %d, want 404", got)
+		}
+	}
+	// The redirect is cached, but not the 404.
+	if calls != 4 {
+		t.Errorf("got %d handler calls, want 4", calls)
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	// force cache writes and refreshes to be synchronous
+	testMode = true
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	body := "1"
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	})
+	c := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ts := httptest.NewServer(Cache("swr", NewRedisStore(c), TTL(1*time.Minute), nil, StaleWhileRevalidate(time.Hour))(handler))
+	defer ts.Close()
+
+	for _, test := range []struct {
+		advance time.Duration
+		body    string
+		want    string
+	}{
+		{0, "1", "1"},
+		// Fresh: served from the cache.
+		{30 * time.Second, "2", "1"},
+		// Stale: served from the cache, and refreshed.
+		{time.Minute, "3", "1"},
+		{0, "4", "3"},
+		// Expired, past the stale period.
+		{2 * time.Hour, "5", "5"},
+	} {
+		now = now.Add(test.advance)
+		s.FastForward(test.advance)
+		body = test.body
+		resp, err := ts.Client().Get(ts.URL + "/A")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("after %v with handler body %q: got %q, want %q", test.advance, test.body, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRUStore is a CacheStore that keeps values in memory, up to a total size.
+// When a value does not fit, the least recently used values are evicted.
+type LRUStore struct {
+	maxBytes int64
+	maxTTL   time.Duration
+
+	mu    sync.Mutex
+	size  int64                      // total size of the keys and values
+	order *list.List                 // of *lruEntry, most recently used first
+	items map[string]*list.Element   // by key
+	tags  map[string]map[string]bool // tag to keys
+	locks map[string]time.Time       // lock name to expiration
+}
+
+type lruEntry struct {
+	key     string
+	val     []byte
+	expires time.Time
+	tags    []string
+}
+
+func (e *lruEntry) size() int64 {
+	return int64(len(e.key) + len(e.val))
+}
+
+// NewLRUStore returns an LRUStore holding at most maxBytes of keys and
+// values. If maxTTL is positive, values are kept for at most maxTTL, whatever
+// TTL they are stored with.
+func NewLRUStore(maxBytes int64, maxTTL time.Duration) *LRUStore {
+	return &LRUStore{
+		maxBytes: maxBytes,
+		maxTTL:   maxTTL,
+		order:    list.New(),
+		items:    map[string]*list.Element{},
+		tags:     map[string]map[string]bool{},
+		locks:    map[string]time.Time{},
+	}
+}
+
+// Get implements CacheStore.Get.
+func (s *LRUStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*lruEntry)
+	if !timeNow().Before(e.expires) {
+		s.remove(el)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(el)
+	return e.val, true, nil
+}
+
+// Put implements CacheStore.Put. Values larger than the store are not
+// stored.
+func (s *LRUStore) Put(ctx context.Context, key string, val []byte, ttl time.Duration, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	if s.maxTTL > 0 && ttl > s.maxTTL {
+		ttl = s.maxTTL
+	}
+	e := &lruEntry{key: key, val: val, expires: timeNow().Add(ttl), tags: tags}
+	if e.size() > s.maxBytes {
+		return nil
+	}
+	s.items[key] = s.order.PushFront(e)
+	s.size += e.size()
+	for _, tag := range tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = map[string]bool{}
+		}
+		s.tags[tag][key] = true
+	}
+	for s.size > s.maxBytes {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+// remove deletes the entry of el. s.mu must be held.
+func (s *LRUStore) remove(el *list.Element) {
+	e := el.Value.(*lruEntry)
+	s.order.Remove(el)
+	delete(s.items, e.key)
+	s.size -= e.size()
+	for _, tag := range e.tags {
+		delete(s.tags[tag], e.key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
+
+// PurgeTags implements CacheStore.PurgeTags.
+func (s *LRUStore) PurgeTags(ctx context.Context, tags ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			s.remove(s.items[key])
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Lock implements CacheStore.Lock.
+func (s *LRUStore) Lock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := timeNow()
+	if exp, ok := s.locks[name]; ok && now.Before(exp) {
+		return false, nil
+	}
+	s.locks[name] = now.Add(ttl)
+	return true, nil
+}
+
+// Unlock implements CacheStore.Unlock.
+func (s *LRUStore) Unlock(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.locks, name)
+	return nil
+}
@@ -59,6 +59,10 @@ type handler struct {
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	traceID := r.Header.Get("X-Cloud-Trace-Context")
+	if traceID == "" {
+		// Outside of GCP, use the request ID set by a load balancer or proxy.
+		traceID = r.Header.Get("X-Request-ID")
+	}
 	severity := logging.Info
 	if r.Method == http.MethodGet && r.URL.Path == "/healthz" {
 		severity = logging.Debug
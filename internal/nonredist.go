@@ -35,6 +35,7 @@ func (u *Unit) RemoveNonRedistributableData() {
 	if !u.IsRedistributable {
 		u.Readme = nil
 		u.Documentation = nil
+		u.Symbols = nil
 	}
 }
 
@@ -0,0 +1,79 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/complete"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// maxCompletionCandidates bounds the number of packages that Complete ranks.
+const maxCompletionCandidates = 1000
+
+// minCompletionPrefix is the length of the shortest query that Complete
+// answers. The trigram index cannot narrow down a LIKE pattern with fewer
+// characters, so shorter queries would scan the table on every keystroke.
+const minCompletionPrefix = 3
+
+// Complete implements complete.Completer using the search_documents table. It
+// ranks the most imported packages with a path element that starts with q,
+// using complete.Rank. Queries shorter than minCompletionPrefix have no
+// completions.
+func (db *DB) Complete(ctx context.Context, q string, maxResults int) (_ []*complete.Completion, err error) {
+	defer derrors.Wrap(&err, "Complete(ctx, %q, %d)", q, maxResults)
+	if len(q) < minCompletionPrefix {
+		return nil, nil
+	}
+	// Both conditions can use idx_search_documents_lower_package_path_trgm.
+	query := `
+		SELECT package_path, module_path, version, imported_by_count
+		FROM search_documents
+		WHERE lower(package_path) LIKE $1 OR lower(package_path) LIKE $2
+		ORDER BY imported_by_count DESC
+		LIMIT $3`
+	var partials []complete.Completion
+	collect := func(rows *sql.Rows) error {
+		var c complete.Completion
+		if err := rows.Scan(&c.PackagePath, &c.ModulePath, &c.Version, &c.Importers); err != nil {
+			return fmt.Errorf("rows.Scan(): %v", err)
+		}
+		partials = append(partials, c)
+		return nil
+	}
+	prefix := escapeLike(q) + "%"
+	if err := db.db.RunQuery(ctx, query, collect, prefix, "%/"+prefix, maxCompletionCandidates); err != nil {
+		return nil, err
+	}
+	return complete.Rank(partials, q, maxResults), nil
+}
+
+// escapeLike escapes the characters of s that are special in LIKE patterns.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ForEachPartialCompletion calls add with a completion for every package in
+// search_documents, with all fields but Suffix set, as expected by
+// complete.PathCompletions.
+func (db *DB) ForEachPartialCompletion(ctx context.Context, add func(complete.Completion)) (err error) {
+	defer derrors.Wrap(&err, "ForEachPartialCompletion(ctx)")
+
+	query := `
+		SELECT package_path, module_path, version, imported_by_count
+		FROM search_documents`
+	return db.db.RunQuery(ctx, query, func(rows *sql.Rows) error {
+		var c complete.Completion
+		if err := rows.Scan(&c.PackagePath, &c.ModulePath, &c.Version, &c.Importers); err != nil {
+			return fmt.Errorf("rows.Scan(): %v", err)
+		}
+		add(c)
+		return nil
+	})
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/complete"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestComplete(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	for _, m := range []struct {
+		modulePath, version, suffix string
+		importers                   int
+	}{
+		{"foo.com/bar", "v1.2.3", "baz", 123},
+		{"foo.com/quux", "v1.0.0", "bark", 10},
+		{"github.com/something", "v2.0.0", "foo", 80},
+	} {
+		if err := testDB.InsertModule(ctx, sample.Module(m.modulePath, m.version, m.suffix)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := testDB.db.Exec(ctx, `UPDATE search_documents SET imported_by_count = $1 WHERE package_path = $2`,
+			m.importers, m.modulePath+"/"+m.suffix); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		q    string
+		want []string
+	}{
+		{"baz", []string{"foo.com/bar/baz"}},
+		{"bar", []string{"foo.com/bar/baz", "foo.com/quux/bark"}},
+		{"foo", []string{"github.com/something/foo", "foo.com/bar/baz"}},
+		{"fo_", nil},
+		{"fo", nil},
+		{"", nil},
+	} {
+		results, err := testDB.Complete(ctx, test.q, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.PackagePath)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("Complete(%q) mismatch (-want +got)\n%s", test.q, diff)
+		}
+	}
+
+	m := complete.NewMemoryCompleter()
+	if err := m.Load(func(add func(complete.Completion)) error {
+		return testDB.ForEachPartialCompletion(ctx, add)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.Complete(ctx, "bar", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].PackagePath != "foo.com/bar/baz" {
+		t.Errorf("MemoryCompleter.Complete(bar) = %v, want foo.com/bar/baz first of 2", got)
+	}
+}
@@ -7,10 +7,13 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/log"
 )
@@ -34,21 +37,213 @@ func (db *DB) IsExcluded(ctx context.Context, path string) (_ bool, err error) {
 	return false, nil
 }
 
-// InsertExcludedPrefix inserts prefix into the excluded_prefixes table.
+// InsertExcludedPrefix inserts prefix into the excluded_prefixes table, and
+// records the change in the excluded_prefixes_log table. It returns an error
+// wrapping derrors.AlreadyExists if prefix is already excluded.
 //
-// For real-time administration (e.g. DOS prevention), use the dbadmin tool.
-// to exclude or unexclude a prefix. If the exclusion is permanent (e.g. a user
-// request), also add the prefix and reason to the excluded.txt file.
+// For real-time administration (e.g. DOS prevention), use the worker's
+// excluded prefixes page or the dbadmin tool to exclude or unexclude a prefix.
+// If the exclusion is permanent (e.g. a user request), also add the prefix
+// and reason to the excluded.txt file.
 func (db *DB) InsertExcludedPrefix(ctx context.Context, prefix, user, reason string) (err error) {
 	defer derrors.Wrap(&err, "DB.InsertExcludedPrefix(ctx, %q, %q)", prefix, reason)
 
-	_, err = db.db.Exec(ctx, "INSERT INTO excluded_prefixes (prefix, created_by, reason) VALUES ($1, $2, $3)",
-		prefix, user, reason)
+	// Arrange to re-read the excluded_prefixes table on the next call to IsExcluded.
+	defer setExcludedPrefixesLastFetched(time.Time{})
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		if err := insertExcludedPrefix(ctx, tx, prefix, user, reason); err != nil {
+			return err
+		}
+		_, err := logExcludedPrefixChange(ctx, tx, ExcludedPrefixAdd, prefix, user, reason)
+		return err
+	})
+}
+
+// DeleteExcludedPrefix removes prefix from the excluded_prefixes table, and
+// records the change in the excluded_prefixes_log table. It returns an error
+// wrapping derrors.NotFound if prefix is not excluded.
+func (db *DB) DeleteExcludedPrefix(ctx context.Context, prefix, user, reason string) (err error) {
+	defer derrors.Wrap(&err, "DB.DeleteExcludedPrefix(ctx, %q, %q)", prefix, reason)
+
+	defer setExcludedPrefixesLastFetched(time.Time{})
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		if err := deleteExcludedPrefix(ctx, tx, prefix); err != nil {
+			return err
+		}
+		_, err := logExcludedPrefixChange(ctx, tx, ExcludedPrefixRemove, prefix, user, reason)
+		return err
+	})
+}
+
+// UndoExcludedPrefixChange reverses the change to the excluded_prefixes
+// table recorded in the excluded_prefixes_log table with the given id, and
+// returns the log entry for the reversal.
+//
+// Undoing a removal excludes the prefix again, with the reason it was most
+// recently added. If the prefix has been excluded again since, it returns an
+// error wrapping derrors.AlreadyExists.
+func (db *DB) UndoExcludedPrefixChange(ctx context.Context, id int, user string) (_ *ExcludedPrefixChange, err error) {
+	defer derrors.Wrap(&err, "DB.UndoExcludedPrefixChange(ctx, %d, %q)", id, user)
+
+	defer setExcludedPrefixesLastFetched(time.Time{})
+	var undo *ExcludedPrefixChange
+	err = db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		var c ExcludedPrefixChange
+		err := tx.QueryRow(ctx, `
+			SELECT action, prefix
+			FROM excluded_prefixes_log
+			WHERE id = $1`, id).Scan(&c.Action, &c.Prefix)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no change with id %d: %w", id, derrors.NotFound)
+		}
+		if err != nil {
+			return err
+		}
+		var action string
+		switch c.Action {
+		case ExcludedPrefixAdd:
+			action = ExcludedPrefixRemove
+			err = deleteExcludedPrefix(ctx, tx, c.Prefix)
+		case ExcludedPrefixRemove:
+			action = ExcludedPrefixAdd
+			reason := fmt.Sprintf("restored by undoing change %d", id)
+			err = tx.QueryRow(ctx, `
+				SELECT reason
+				FROM excluded_prefixes_log
+				WHERE prefix = $1 AND action = $2 AND id < $3
+				ORDER BY id DESC
+				LIMIT 1`, c.Prefix, ExcludedPrefixAdd, id).Scan(&reason)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+			err = insertExcludedPrefix(ctx, tx, c.Prefix, user, reason)
+		default:
+			return fmt.Errorf("change %d has unknown action %q", id, c.Action)
+		}
+		if err != nil {
+			return err
+		}
+		undo, err = logExcludedPrefixChange(ctx, tx, action, c.Prefix, user, fmt.Sprintf("undo change %d", id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return undo, nil
+}
+
+func insertExcludedPrefix(ctx context.Context, tx *database.DB, prefix, user, reason string) error {
+	n, err := tx.Exec(ctx, `
+		INSERT INTO excluded_prefixes (prefix, created_by, reason) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, prefix, user, reason)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("prefix %q is already excluded: %w", prefix, derrors.AlreadyExists)
+	}
+	return nil
+}
+
+func deleteExcludedPrefix(ctx context.Context, tx *database.DB, prefix string) error {
+	n, err := tx.Exec(ctx, "DELETE FROM excluded_prefixes WHERE prefix = $1", prefix)
 	if err != nil {
-		// Arrange to re-read the excluded_prefixes table on the next call to IsExcluded.
-		setExcludedPrefixesLastFetched(time.Time{})
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("prefix %q is not excluded: %w", prefix, derrors.NotFound)
 	}
-	return err
+	return nil
+}
+
+// logExcludedPrefixChange records a change to the excluded_prefixes table in
+// the excluded_prefixes_log table.
+func logExcludedPrefixChange(ctx context.Context, tx *database.DB, action, prefix, user, reason string) (*ExcludedPrefixChange, error) {
+	c := &ExcludedPrefixChange{Action: action, Prefix: prefix, CreatedBy: user, Reason: reason}
+	err := tx.QueryRow(ctx, `
+		INSERT INTO excluded_prefixes_log (action, prefix, created_by, reason)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`, action, prefix, user, reason).Scan(&c.ID, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof(ctx, "excluded prefixes: %s %q by %s (%s)", action, prefix, user, reason)
+	return c, nil
+}
+
+// Actions recorded in the excluded_prefixes_log table.
+const (
+	ExcludedPrefixAdd    = "add"
+	ExcludedPrefixRemove = "remove"
+)
+
+// An ExcludedPrefix is a row of the excluded_prefixes table.
+type ExcludedPrefix struct {
+	Prefix    string
+	CreatedBy string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// An ExcludedPrefixChange is a row of the excluded_prefixes_log table.
+type ExcludedPrefixChange struct {
+	ID        int
+	Action    string // ExcludedPrefixAdd or ExcludedPrefixRemove
+	Prefix    string
+	CreatedBy string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// GetExcludedPrefixDetails returns all the rows of the excluded_prefixes
+// table, sorted by prefix.
+func (db *DB) GetExcludedPrefixDetails(ctx context.Context) (_ []*ExcludedPrefix, err error) {
+	defer derrors.Wrap(&err, "DB.GetExcludedPrefixDetails(ctx)")
+
+	var eps []*ExcludedPrefix
+	err = db.db.RunQuery(ctx, `
+		SELECT prefix, created_by, reason, created_at
+		FROM excluded_prefixes
+		ORDER BY prefix`, func(rows *sql.Rows) error {
+		var (
+			ep        ExcludedPrefix
+			createdAt sql.NullTime
+		)
+		if err := rows.Scan(&ep.Prefix, &ep.CreatedBy, &ep.Reason, &createdAt); err != nil {
+			return err
+		}
+		ep.CreatedAt = createdAt.Time
+		eps = append(eps, &ep)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return eps, nil
+}
+
+// GetExcludedPrefixChanges returns the most recent limit changes to the
+// excluded_prefixes table, newest first.
+func (db *DB) GetExcludedPrefixChanges(ctx context.Context, limit int) (_ []*ExcludedPrefixChange, err error) {
+	defer derrors.Wrap(&err, "DB.GetExcludedPrefixChanges(ctx, %d)", limit)
+
+	var cs []*ExcludedPrefixChange
+	err = db.db.RunQuery(ctx, `
+		SELECT id, action, prefix, created_by, reason, created_at
+		FROM excluded_prefixes_log
+		ORDER BY id DESC
+		LIMIT $1`, func(rows *sql.Rows) error {
+		var c ExcludedPrefixChange
+		if err := rows.Scan(&c.ID, &c.Action, &c.Prefix, &c.CreatedBy, &c.Reason, &c.CreatedAt); err != nil {
+			return err
+		}
+		cs = append(cs, &c)
+		return nil
+	}, limit)
+	if err != nil {
+		return nil, err
+	}
+	return cs, nil
 }
 
 // In-memory copy of excluded_prefixes.
@@ -103,3 +298,39 @@ func (db *DB) GetExcludedPrefixes(ctx context.Context) ([]string, error) {
 	}
 	return eps, nil
 }
+
+// DeleteModulesWithPrefix deletes every module version whose path has the
+// given prefix, matching paths the way IsExcluded does. It returns the number
+// of module versions deleted, and the paths of their modules, sorted.
+func (db *DB) DeleteModulesWithPrefix(ctx context.Context, prefix string) (n int, modulePaths []string, err error) {
+	defer derrors.Wrap(&err, "DB.DeleteModulesWithPrefix(ctx, %q)", prefix)
+
+	type modver struct{ path, version string }
+	var mvs []modver
+	err = db.db.RunQuery(ctx, `
+		SELECT module_path, version
+		FROM modules
+		WHERE left(module_path, length($1)) = $1`, func(rows *sql.Rows) error {
+		var mv modver
+		if err := rows.Scan(&mv.path, &mv.version); err != nil {
+			return err
+		}
+		mvs = append(mvs, mv)
+		return nil
+	}, prefix)
+	if err != nil {
+		return 0, nil, err
+	}
+	seen := map[string]bool{}
+	for _, mv := range mvs {
+		if err := db.DeleteModule(ctx, mv.path, mv.version); err != nil {
+			return 0, nil, err
+		}
+		if !seen[mv.path] {
+			seen[mv.path] = true
+			modulePaths = append(modulePaths, mv.path)
+		}
+	}
+	sort.Strings(modulePaths)
+	return len(mvs), modulePaths, nil
+}
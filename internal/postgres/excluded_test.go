@@ -6,7 +6,14 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
 )
 
 func TestIsExcluded(t *testing.T) {
@@ -37,3 +44,98 @@ func TestIsExcluded(t *testing.T) {
 		}
 	}
 }
+
+func TestExcludedPrefixChanges(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if err := testDB.InsertExcludedPrefix(ctx, "bad", "someone", "because"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.InsertExcludedPrefix(ctx, "bad", "someone", "because"); !errors.Is(err, derrors.AlreadyExists) {
+		t.Fatalf("inserting twice: got %v, want AlreadyExists", err)
+	}
+	if err := testDB.DeleteExcludedPrefix(ctx, "bad", "other", "mistake"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.DeleteExcludedPrefix(ctx, "bad", "other", "mistake"); !errors.Is(err, derrors.NotFound) {
+		t.Fatalf("deleting twice: got %v, want NotFound", err)
+	}
+	changes, err := testDB.GetExcludedPrefixChanges(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	undo, err := testDB.UndoExcludedPrefixChange(ctx, changes[0].ID, "third")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := undo.Action, ExcludedPrefixAdd; got != want {
+		t.Errorf("undo action = %q, want %q", got, want)
+	}
+	if _, err := testDB.UndoExcludedPrefixChange(ctx, undo.ID+1, "third"); !errors.Is(err, derrors.NotFound) {
+		t.Fatalf("undoing nonexistent change: got %v, want NotFound", err)
+	}
+
+	excluded, err := testDB.IsExcluded(ctx, "bad.com/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !excluded {
+		t.Error("bad.com/foo not excluded after undoing removal")
+	}
+	got, err := testDB.GetExcludedPrefixDetails(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*ExcludedPrefix{{Prefix: "bad", CreatedBy: "third", Reason: "because"}}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(ExcludedPrefix{}, "CreatedAt")); diff != "" {
+		t.Errorf("GetExcludedPrefixDetails mismatch (-want +got):\n%s", diff)
+	}
+
+	removal := changes[0]
+	changes, err = testDB.GetExcludedPrefixChanges(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantChanges := []*ExcludedPrefixChange{
+		{Action: ExcludedPrefixAdd, Prefix: "bad", CreatedBy: "third", Reason: fmt.Sprintf("undo change %d", removal.ID)},
+		{Action: ExcludedPrefixRemove, Prefix: "bad", CreatedBy: "other", Reason: "mistake"},
+		{Action: ExcludedPrefixAdd, Prefix: "bad", CreatedBy: "someone", Reason: "because"},
+	}
+	if diff := cmp.Diff(wantChanges, changes, cmpopts.IgnoreFields(ExcludedPrefixChange{}, "ID", "CreatedAt")); diff != "" {
+		t.Errorf("GetExcludedPrefixChanges mismatch (-want +got):\n%s", diff)
+	}
+
+	// The prefix is excluded again, so the removal can't be undone twice.
+	if _, err := testDB.UndoExcludedPrefixChange(ctx, removal.ID, "third"); !errors.Is(err, derrors.AlreadyExists) {
+		t.Errorf("undoing removal twice: got %v, want AlreadyExists", err)
+	}
+}
+
+func TestDeleteModulesWithPrefix(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, mv := range []struct{ path, version string }{
+		{"bad.com/a", "v1.0.0"},
+		{"bad.com/a", "v1.1.0"},
+		{"bad.com/b", "v1.0.0"},
+		{"good.com/c", "v1.0.0"},
+	} {
+		if err := testDB.InsertModule(ctx, sample.Module(mv.path, mv.version, "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, modulePaths, err := testDB.DeleteModulesWithPrefix(ctx, "bad.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"bad.com/a", "bad.com/b"}; n != 3 || !cmp.Equal(modulePaths, want) {
+		t.Errorf("got %d, %v; want 3, %v", n, modulePaths, want)
+	}
+	if _, err := testDB.GetModuleInfo(ctx, "good.com/c", "v1.0.0"); err != nil {
+		t.Errorf("module outside the prefix: %v", err)
+	}
+}
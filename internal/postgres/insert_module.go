@@ -188,15 +188,17 @@ func insertModule(ctx context.Context, db *database.DB, m *internal.Module) (_ i
 			source_info,
 			redistributable,
 			has_go_mod,
-			incompatible)
-		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+			incompatible,
+			unverified)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
 		ON CONFLICT
 			(module_path, version)
 		DO UPDATE SET
 			readme_file_path=excluded.readme_file_path,
 			readme_contents=excluded.readme_contents,
 			source_info=excluded.source_info,
-			redistributable=excluded.redistributable
+			redistributable=excluded.redistributable,
+			unverified=excluded.unverified
 		RETURNING id`,
 		m.ModulePath,
 		m.Version,
@@ -210,6 +212,7 @@ func insertModule(ctx context.Context, db *database.DB, m *internal.Module) (_ i
 		m.IsRedistributable,
 		m.HasGoMod,
 		isIncompatible(m.Version),
+		m.Unverified,
 	).Scan(&moduleID)
 	if err != nil {
 		return 0, err
@@ -396,6 +399,7 @@ func insertUnits(ctx context.Context, db *database.DB, m *internal.Module, modul
 		pathToReadme  = map[string]*internal.Readme{}
 		pathToDoc     = map[string]*internal.Documentation{}
 		pathToImports = map[string][]string{}
+		pathToSymbols = map[string][]*internal.Symbol{}
 	)
 	for _, d := range m.Units {
 		var licenseTypes, licensePaths []string
@@ -438,6 +442,9 @@ func insertUnits(ctx context.Context, db *database.DB, m *internal.Module, modul
 		if len(d.Imports) > 0 {
 			pathToImports[d.Path] = d.Imports
 		}
+		if len(d.Symbols) > 0 {
+			pathToSymbols[d.Path] = d.Symbols
+		}
 	}
 
 	if len(pathValues) > 0 {
@@ -523,6 +530,10 @@ func insertUnits(ctx context.Context, db *database.DB, m *internal.Module, modul
 		}
 	}
 
+	if err := insertSymbols(ctx, db, paths, pathToID, pathToSymbols); err != nil {
+		return err
+	}
+
 	logMemory(ctx, "before inserting into package_imports")
 	var importValues []interface{}
 	for _, pkgPath := range paths {
@@ -539,6 +550,38 @@ func insertUnits(ctx context.Context, db *database.DB, m *internal.Module, modul
 	return db.BulkUpsert(ctx, "package_imports", importCols, importValues, importCols)
 }
 
+// insertSymbols replaces the symbols for each of the given paths, which must
+// be sorted, with those in pathToSymbols.
+func insertSymbols(ctx context.Context, db *database.DB, paths []string, pathToID map[string]int, pathToSymbols map[string][]*internal.Symbol) (err error) {
+	defer derrors.Wrap(&err, "insertSymbols(ctx, db, paths, pathToID, pathToSymbols)")
+	logMemory(ctx, "before inserting into symbols")
+
+	// Symbols that are no longer declared by a package would otherwise
+	// survive reprocessing, so remove the existing ones first.
+	var pathIDs []int
+	for _, path := range paths {
+		pathIDs = append(pathIDs, pathToID[path])
+	}
+	if _, err := db.Exec(ctx, `DELETE FROM symbols WHERE path_id = ANY($1)`, pq.Array(pathIDs)); err != nil {
+		return err
+	}
+
+	var symbolValues []interface{}
+	for _, path := range paths {
+		id := pathToID[path]
+		for _, s := range pathToSymbols[path] {
+			var refPaths, refNames []string
+			for _, r := range s.References {
+				refPaths = append(refPaths, r.PackagePath)
+				refNames = append(refNames, r.Name)
+			}
+			symbolValues = append(symbolValues, id, s.Name, s.Kind, pq.Array(refPaths), pq.Array(refNames))
+		}
+	}
+	symbolCols := []string{"path_id", "name", "kind", "reference_paths", "reference_names"}
+	return db.BulkUpsert(ctx, "symbols", symbolCols, symbolValues, []string{"path_id", "name"})
+}
+
 // lock obtains an exclusive, transaction-scoped advisory lock on modulePath.
 func lock(ctx context.Context, tx *database.DB, modulePath string) (err error) {
 	defer derrors.Wrap(&err, "lock(%s)", modulePath)
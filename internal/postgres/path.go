@@ -70,6 +70,7 @@ func (db *DB) GetUnitMeta(ctx context.Context, path, requestedModulePath, reques
 		    m.version,
 		    m.commit_time,
 		    m.source_info,
+		    m.unverified,
 		    p.name,
 		    p.redistributable,
 		    p.license_types,
@@ -87,6 +88,7 @@ func (db *DB) GetUnitMeta(ctx context.Context, path, requestedModulePath, reques
 		&um.Version,
 		&um.CommitTime,
 		jsonbScanner{&um.SourceInfo},
+		&um.Unverified,
 		&um.Name,
 		&um.IsRedistributable,
 		pq.Array(&licenseTypes),
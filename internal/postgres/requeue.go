@@ -11,8 +11,8 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/lib/pq"
 	"golang.org/x/pkgsite/internal"
-	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/log"
@@ -26,6 +26,7 @@ func (db *DB) UpdateModuleVersionStatesForReprocessing(ctx context.Context, appV
 	for _, status := range []int{
 		http.StatusOK,
 		derrors.ToStatus(derrors.HasIncompletePackages),
+		derrors.ToStatus(derrors.ModuleUnverified),
 		derrors.ToStatus(derrors.DBModuleInsertInvalid),
 	} {
 		if err := db.UpdateModuleVersionStatesWithStatus(ctx, status, appVersion); err != nil {
@@ -54,16 +55,45 @@ func (db *DB) UpdateModuleVersionStatesWithStatus(ctx context.Context, status in
 	return nil
 }
 
+// RequeueExcludedModules marks the module versions whose paths have the given
+// prefix and that were not processed because they were excluded, so that
+// they will be returned by the next call to GetNextModulesToFetch. It returns
+// the number of module versions marked.
+func (db *DB) RequeueExcludedModules(ctx context.Context, prefix string) (_ int64, err error) {
+	defer derrors.Wrap(&err, "RequeueExcludedModules(ctx, %q)", prefix)
+
+	query := `UPDATE module_version_states
+			SET
+				status = 0,
+				next_processed_after = CURRENT_TIMESTAMP,
+				last_processed_at = NULL
+			WHERE
+				left(module_path, length($1)) = $1
+				AND status = $2;`
+	affected, err := db.db.Exec(ctx, query, prefix, derrors.ToStatus(derrors.Excluded))
+	if err != nil {
+		return 0, err
+	}
+	log.Infof(ctx, "Requeued excluded module versions with prefix %q; %d affected", prefix, affected)
+	return affected, nil
+}
+
 // largeModulePackageThresold represents the package threshold at which it
 // becomes difficult to process packages. Modules with more than this number
 // of packages are generally different versions or forks of kubernetes,
 // aws-sdk-go, azure-sdk-go, and bilibili.
 const largeModulePackageThreshold = 1500
 
-// largeModulesLimit represents the number of large modules that we are
-// willing to enqueue at a given time.
-// var for testing.
-var largeModulesLimit = config.GetEnvInt("GO_DISCOVERY_LARGE_MODULES_LIMIT", 100)
+// DefaultLargeModulesLimit is the number of large modules that we are
+// willing to enqueue at a given time, unless SetLargeModulesLimit is called.
+const DefaultLargeModulesLimit = 100
+
+// SetLargeModulesLimit sets the number of large modules that
+// GetNextModulesToFetch returns at most, usually to
+// config.Config.LargeModulesLimit.
+func (db *DB) SetLargeModulesLimit(n int) {
+	db.largeModulesLimit = n
+}
 
 // GetNextModulesToFetch returns the next batch of modules that need to be
 // processed. We prioritize modules based on (1) whether it has status zero
@@ -99,7 +129,7 @@ func (db *DB) GetNextModulesToFetch(ctx context.Context, limit int) (_ []*intern
 		mvs = append(mvs, mv)
 		return nil
 	}
-	if err := db.db.RunQuery(ctx, query, collect, largeModulePackageThreshold, limit); err != nil {
+	if err := db.db.RunQuery(ctx, query, collect, largeModulePackageThreshold, limit, pq.Array(db.retryableStatuses())); err != nil {
 		return nil, err
 	}
 	if len(mvs) == 0 {
@@ -120,14 +150,14 @@ func (db *DB) GetNextModulesToFetch(ctx context.Context, limit int) (_ []*intern
 			fmt.Sprintf("%s/@v/%s", end.ModulePath, end.Version))
 	}
 
-	// Don't return more than largeModulesLimit of modules that have more than
+	// Don't return more than db.largeModulesLimit of modules that have more than
 	// largeModulePackageThreshold packages, or of modules with status zero.
 	nLargeOrZero := 0
 	for i, m := range mvs {
 		if m.Status == 0 || (m.NumPackages != nil && *m.NumPackages >= largeModulePackageThreshold) {
 			nLargeOrZero++
 		}
-		if nLargeOrZero > largeModulesLimit {
+		if nLargeOrZero > db.largeModulesLimit {
 			return mvs[:i], nil
 		}
 	}
@@ -154,7 +184,8 @@ const nextModulesToProcessQuery = `
 		FROM module_version_states
 	) s
 	WHERE next_processed_after < CURRENT_TIMESTAMP
-		AND (status = 0 OR status >= 500)
+		AND dead_lettered_at IS NULL
+		AND (status = 0 OR status >= 500 OR status = ANY($3))
 	ORDER BY
 		CASE
 			 -- new modules
@@ -163,15 +194,15 @@ const nextModulesToProcessQuery = `
 				CASE
 					WHEN latest THEN	-- latest version
 						CASE
-							-- with ReprocessStatusOK or ReprocessHasIncompletePackages
-							WHEN status = 520 OR status = 521 THEN 1
+							-- with ReprocessStatusOK, ReprocessHasIncompletePackages or ReprocessModuleUnverified
+							WHEN status = 520 OR status = 521 OR status = 522 THEN 1
 							-- with ReprocessBadModule or ReprocessAlternative or ReprocessDBModuleInsertInvalid
 							WHEN status = 540 OR status = 541 OR status = 542 THEN 2
 							ELSE 9
 						END
 					ELSE				-- non-latest version
 						CASE
-							WHEN status = 520 OR status = 521 THEN 3
+							WHEN status = 520 OR status = 521 OR status = 522 THEN 3
 							WHEN status = 540 OR status = 541 OR status = 542 THEN 4
 							ELSE 9
 						END
@@ -180,13 +211,13 @@ const nextModulesToProcessQuery = `
 				CASE
 					WHEN latest THEN	-- latest version
 						CASE
-							WHEN status = 520 OR status = 521 THEN 5
+							WHEN status = 520 OR status = 521 OR status = 522 THEN 5
 							WHEN status = 540 OR status = 541 OR status = 542 THEN 6
 							ELSE 9
 						END
 					ELSE				-- non-latest version
 						CASE
-							WHEN status = 520 OR status = 521 THEN 7
+							WHEN status = 520 OR status = 521 OR status = 522 THEN 7
 							WHEN status = 540 OR status = 541 OR status = 542 THEN 8
 							ELSE 9
 						END
@@ -226,7 +257,8 @@ const nextModulesToProcessQueryAlt = `
 		FROM module_version_states
 	) s
 	WHERE next_processed_after < CURRENT_TIMESTAMP
-		AND (status = 0 OR status >= 500)
+		AND dead_lettered_at IS NULL
+		AND (status = 0 OR status >= 500 OR status = ANY($3))
         AND $1 = $1 -- ignore largeModulePackageThreshold
 	ORDER BY
 		CASE
@@ -234,14 +266,14 @@ const nextModulesToProcessQueryAlt = `
 			WHEN status = 0 THEN 0
 			WHEN latest THEN
 				CASE
-					-- with SheddingLoad or ReprocessStatusOK or ReprocessHasIncompletePackages
-					WHEN status = 503 or status = 520 OR status = 521 THEN 1
+					-- with SheddingLoad, ReprocessStatusOK, ReprocessHasIncompletePackages or ReprocessModuleUnverified
+					WHEN status = 503 or status = 520 OR status = 521 OR status = 522 THEN 1
 					-- with ReprocessBadModule or ReprocessAlternative or ReprocessDBModuleInsertInvalid
 					WHEN status = 540 OR status = 541 OR status = 542 THEN 2
 					ELSE 5
 				END
 			-- non-latest
-			WHEN status = 503 or status = 520 OR status = 521 THEN 3
+			WHEN status = 503 or status = 520 OR status = 521 OR status = 522 THEN 3
 			WHEN status = 540 OR status = 541 OR status = 542 THEN 4
 			ELSE 5
 		END,
@@ -39,6 +39,7 @@ func TestGetNextModulesToFetchAndUpdateModuleVersionStatesForReprocessing(t *tes
 		statuses  = []int{
 			http.StatusOK,
 			derrors.ToStatus(derrors.HasIncompletePackages),
+			derrors.ToStatus(derrors.ModuleUnverified),
 			derrors.ToStatus(derrors.DBModuleInsertInvalid),
 			http.StatusInternalServerError,
 			http.StatusBadRequest,
@@ -113,7 +114,7 @@ func TestGetNextModulesToFetchAndUpdateModuleVersionStatesForReprocessing(t *tes
 	updateStates := func(wantData []*testData) {
 		for _, m := range wantData {
 			if err := upsertModuleVersionState(ctx, testDB.db, m.modulePath, m.version, "2020-04-29t14", &m.numPackages, now, m.status,
-				m.modulePath, derrors.FromStatus(m.status, "test string")); err != nil {
+				m.modulePath, derrors.FromStatus(m.status, "test string"), testDB.backoff(m.status)); err != nil {
 				t.Fatal(err)
 			}
 		}
@@ -130,7 +131,7 @@ func TestGetNextModulesToFetchAndUpdateModuleVersionStatesForReprocessing(t *tes
 	checkNextToRequeue(want, len(mods))
 	// Mark all modules for reprocessing.
 	for _, m := range mods {
-		if err := upsertModuleVersionState(ctx, testDB.db, m.modulePath, m.version, "2020-04-29t14", &m.numPackages, now, m.status, m.modulePath, derrors.FromStatus(m.status, "test string")); err != nil {
+		if err := upsertModuleVersionState(ctx, testDB.db, m.modulePath, m.version, "2020-04-29t14", &m.numPackages, now, m.status, m.modulePath, derrors.FromStatus(m.status, "test string"), testDB.backoff(m.status)); err != nil {
 			t.Fatal(err)
 		}
 	}
@@ -148,8 +149,8 @@ func TestGetNextModulesToFetchAndUpdateModuleVersionStatesForReprocessing(t *tes
 	}
 
 	// The first modules to requeue should be the latest version of not-large modules with errors
-	// ReprocessStatusOK ReprocessHasIncompletePackages, ReprocessAlternative, and ReprocessBadModule.
-	statuses = []int{200, 290, 480}
+	// ReprocessStatusOK ReprocessHasIncompletePackages, ReprocessModuleUnverified, ReprocessAlternative, and ReprocessBadModule.
+	statuses = []int{200, 290, 291, 480}
 	want = generateMods([]string{latest}, []int{small}, statuses)
 
 	// The next modules to requeue should be the small non-latest versions.
@@ -277,7 +278,9 @@ func TestGetNextModulesToFetchLargeModulesLimit(t *testing.T) {
 		}
 	}
 
-	largeModulesLimit = num500Mods / 2
+	largeModulesLimit := num500Mods / 2
+	testDB.SetLargeModulesLimit(largeModulesLimit)
+	defer testDB.SetLargeModulesLimit(DefaultLargeModulesLimit)
 	got, err := testDB.GetNextModulesToFetch(ctx, num500Mods)
 	if err != nil {
 		t.Fatal(err)
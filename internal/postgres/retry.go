@@ -0,0 +1,146 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// statusRetryClasses maps fetch statuses to their retry classes.
+var statusRetryClasses = map[int]string{
+	derrors.ToStatus(derrors.ProxyError):            config.RetryClassProxy,
+	derrors.ToStatus(derrors.ProxyTimedOut):         config.RetryClassProxy,
+	http.StatusInternalServerError:                  config.RetryClassDB,
+	derrors.ToStatus(derrors.DBModuleInsertInvalid): config.RetryClassInsertInvalid,
+	derrors.ToStatus(derrors.HasIncompletePackages): config.RetryClassIncomplete,
+	derrors.ToStatus(derrors.ModuleTooLarge):        config.RetryClassTooLarge,
+}
+
+// RetryClass returns the retry class of the given fetch status, or the empty
+// string if the status does not belong to a class.
+func RetryClass(status int) string {
+	return statusRetryClasses[status]
+}
+
+// classStatuses returns the fetch statuses in the same retry class as status,
+// including status itself.
+func classStatuses(status int) []int {
+	c := RetryClass(status)
+	if c == "" {
+		return []int{status}
+	}
+	var statuses []int
+	for s, sc := range statusRetryClasses {
+		if sc == c {
+			statuses = append(statuses, s)
+		}
+	}
+	sort.Ints(statuses)
+	return statuses
+}
+
+// defaultBackoff is the backoff for failed fetches whose status has no retry
+// policy.
+var defaultBackoff = config.RetryPolicy{InitialBackoff: time.Minute, MaxBackoff: time.Hour}
+
+// SetRetryPolicies sets the retry policy for each retry class, usually to
+// config.Config.RetryPolicies. A class with no policy keeps the behavior of
+// its status: server errors are retried indefinitely, and other statuses are
+// not retried. A DB returned by New uses config.DefaultRetryPolicies.
+func (db *DB) SetRetryPolicies(ps map[string]config.RetryPolicy) {
+	db.retryPolicies = ps
+}
+
+// RetryPolicies returns the retry policy for each retry class that has one.
+func (db *DB) RetryPolicies() map[string]config.RetryPolicy {
+	ps := map[string]config.RetryPolicy{}
+	for c, p := range db.retryPolicies {
+		ps[c] = p
+	}
+	return ps
+}
+
+// backoff returns the retry policy for the given fetch status, or
+// defaultBackoff if it has none.
+func (db *DB) backoff(status int) config.RetryPolicy {
+	if p, ok := db.retryPolicies[RetryClass(status)]; ok {
+		return p
+	}
+	return defaultBackoff
+}
+
+// retryableStatuses returns the fetch statuses below 500 that have a retry
+// policy. Module versions with those statuses are requeued like those with
+// server errors.
+func (db *DB) retryableStatuses() []int {
+	var statuses []int
+	for s := range statusRetryClasses {
+		if _, ok := db.retryPolicies[RetryClass(s)]; ok && s < 500 {
+			statuses = append(statuses, s)
+		}
+	}
+	sort.Ints(statuses)
+	return statuses
+}
+
+// GetDeadLetteredVersions returns module versions that have exhausted their
+// retries, most recently dead-lettered first.
+func (db *DB) GetDeadLetteredVersions(ctx context.Context, limit int) (_ []*internal.ModuleVersionState, err error) {
+	defer derrors.Wrap(&err, "GetDeadLetteredVersions(ctx, %d)", limit)
+
+	queryFormat := `
+		SELECT %s
+		FROM
+			module_version_states
+		WHERE dead_lettered_at IS NOT NULL
+		ORDER BY dead_lettered_at DESC, module_path, version
+		LIMIT $1`
+	return db.queryModuleVersionStates(ctx, queryFormat, limit)
+}
+
+// A ModuleVersion identifies a version of a module.
+type ModuleVersion struct {
+	ModulePath string
+	Version    string
+}
+
+// RetryDeadLetteredVersions takes the given module versions out of the
+// dead-letter state and resets their try counts, so that they will be
+// returned by the next call to GetNextModulesToFetch. Module versions that are
+// not dead-lettered are ignored. It returns the number of module versions
+// that were retried.
+func (db *DB) RetryDeadLetteredVersions(ctx context.Context, mvs []ModuleVersion) (_ int64, err error) {
+	defer derrors.Wrap(&err, "RetryDeadLetteredVersions(ctx, %d versions)", len(mvs))
+
+	var paths, versions []string
+	for _, mv := range mvs {
+		paths = append(paths, mv.ModulePath)
+		versions = append(versions, mv.Version)
+	}
+	query := `UPDATE module_version_states
+			SET
+				dead_lettered_at = NULL,
+				try_count = 0,
+				next_processed_after = CURRENT_TIMESTAMP,
+				last_processed_at = NULL
+			WHERE
+				(module_path, version) IN (SELECT * FROM unnest($1::text[], $2::text[]))
+				AND dead_lettered_at IS NOT NULL;`
+	affected, err := db.db.Exec(ctx, query, pq.Array(paths), pq.Array(versions))
+	if err != nil {
+		return 0, err
+	}
+	log.Infof(ctx, "Retried %d dead-lettered module versions", affected)
+	return affected, nil
+}
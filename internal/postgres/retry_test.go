@@ -0,0 +1,243 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+func TestRetryableStatuses(t *testing.T) {
+	db := &DB{retryPolicies: config.DefaultRetryPolicies()}
+	if got := db.retryableStatuses(); len(got) != 0 {
+		t.Errorf("default policies: got %v, want none", got)
+	}
+
+	db.SetRetryPolicies(map[string]config.RetryPolicy{
+		config.RetryClassIncomplete: {MaxTries: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour},
+		config.RetryClassTooLarge:   {MaxTries: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour},
+	})
+	want := []int{derrors.ToStatus(derrors.HasIncompletePackages), derrors.ToStatus(derrors.ModuleTooLarge)}
+	if diff := cmp.Diff(want, db.retryableStatuses()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestClassStatuses(t *testing.T) {
+	for _, test := range []struct {
+		status int
+		want   []int
+	}{
+		{derrors.ToStatus(derrors.ProxyError), []int{derrors.ToStatus(derrors.ProxyError), derrors.ToStatus(derrors.ProxyTimedOut)}},
+		{derrors.ToStatus(derrors.DBModuleInsertInvalid), []int{derrors.ToStatus(derrors.DBModuleInsertInvalid)}},
+		{http.StatusNotFound, []int{http.StatusNotFound}},
+	} {
+		if diff := cmp.Diff(test.want, classStatuses(test.status)); diff != "" {
+			t.Errorf("classStatuses(%d) mismatch (-want, +got):\n%s", test.status, diff)
+		}
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+	defer testDB.SetRetryPolicies(config.DefaultRetryPolicies())
+
+	testDB.SetRetryPolicies(map[string]config.RetryPolicy{
+		config.RetryClassDB:         {MaxTries: 2, InitialBackoff: time.Minute, MaxBackoff: time.Hour},
+		config.RetryClassIncomplete: {MaxTries: 2, InitialBackoff: time.Minute, MaxBackoff: time.Hour},
+	})
+	const (
+		dbPath         = "example.com/db"
+		incompletePath = "example.com/incomplete"
+		proxyPath      = "example.com/proxy"
+		vers           = "v1.0.0"
+	)
+	statuses := map[string]int{
+		dbPath:         http.StatusInternalServerError,
+		incompletePath: derrors.ToStatus(derrors.HasIncompletePackages),
+		proxyPath:      derrors.ToStatus(derrors.ProxyError), // no policy
+	}
+	var ivs []*internal.IndexVersion
+	for p := range statuses {
+		ivs = append(ivs, &internal.IndexVersion{Path: p, Version: vers, Timestamp: time.Now()})
+	}
+	if err := testDB.InsertIndexVersions(ctx, ivs); err != nil {
+		t.Fatal(err)
+	}
+
+	fail := func() {
+		t.Helper()
+		for p, status := range statuses {
+			if err := testDB.UpsertModuleVersionState(ctx, p, vers, "app", time.Now(), status, p, derrors.Unknown, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	deadLettered := func() []string {
+		t.Helper()
+		mvs, err := testDB.GetDeadLetteredVersions(ctx, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, mv := range mvs {
+			if mv.DeadLetteredAt == nil {
+				t.Errorf("%s: DeadLetteredAt is nil", mv.ModulePath)
+			}
+			paths = append(paths, mv.ModulePath)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	fail()
+	if got := deadLettered(); len(got) != 0 {
+		t.Fatalf("after one failure, got dead-lettered %v, want none", got)
+	}
+	fail()
+	want := []string{dbPath, incompletePath}
+	if diff := cmp.Diff(want, deadLettered()); diff != "" {
+		t.Fatalf("after two failures, dead-lettered mismatch (-want, +got):\n%s", diff)
+	}
+
+	// Dead-lettered versions are not requeued, even when they are due.
+	if _, err := testDB.db.Exec(ctx, `UPDATE module_version_states SET next_processed_after = CURRENT_TIMESTAMP - INTERVAL '1 minute'`); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.GetNextModulesToFetch(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ModulePath != proxyPath {
+		t.Fatalf("GetNextModulesToFetch: got %d versions, want only %s", len(got), proxyPath)
+	}
+
+	n, err := testDB.RetryDeadLetteredVersions(ctx, []ModuleVersion{
+		{incompletePath, vers},
+		{proxyPath, vers}, // not dead-lettered; ignored
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("RetryDeadLetteredVersions: got %d, want 1", n)
+	}
+	if diff := cmp.Diff([]string{dbPath}, deadLettered()); diff != "" {
+		t.Errorf("after retry, dead-lettered mismatch (-want, +got):\n%s", diff)
+	}
+	mvs, err := testDB.GetModuleVersionState(ctx, incompletePath, vers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mvs.TryCount != 0 {
+		t.Errorf("after retry, got try count %d, want 0", mvs.TryCount)
+	}
+	got, err = testDB.GetNextModulesToFetch(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotPaths []string
+	for _, mv := range got {
+		gotPaths = append(gotPaths, mv.ModulePath)
+	}
+	if diff := cmp.Diff([]string{incompletePath, proxyPath}, gotPaths); diff != "" {
+		t.Errorf("GetNextModulesToFetch after retry mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestDeadLetterCountsConsecutiveFailures(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+	defer testDB.SetRetryPolicies(config.DefaultRetryPolicies())
+
+	testDB.SetRetryPolicies(map[string]config.RetryPolicy{
+		config.RetryClassDB: {MaxTries: 2, InitialBackoff: time.Minute, MaxBackoff: time.Hour},
+	})
+	const (
+		path = "example.com/m"
+		vers = "v1.0.0"
+	)
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{{Path: path, Version: vers, Timestamp: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		status       int
+		wantTryCount int
+	}{
+		{http.StatusInternalServerError, 1},
+		{http.StatusOK, 0},
+		{http.StatusInternalServerError, 1},
+		{derrors.ToStatus(derrors.DBModuleInsertInvalid), 1},
+		{http.StatusInternalServerError, 1},
+	} {
+		var fetchErr error
+		if test.status != http.StatusOK {
+			fetchErr = derrors.FromStatus(test.status, "test")
+		}
+		if err := testDB.UpsertModuleVersionState(ctx, path, vers, "app", time.Now(), test.status, path, fetchErr, nil); err != nil {
+			t.Fatal(err)
+		}
+		mvs, err := testDB.GetModuleVersionState(ctx, path, vers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mvs.TryCount != test.wantTryCount {
+			t.Errorf("after status %d: got try count %d, want %d", test.status, mvs.TryCount, test.wantTryCount)
+		}
+		if mvs.DeadLetteredAt != nil {
+			t.Fatalf("after status %d: dead-lettered, want not dead-lettered", test.status)
+		}
+	}
+}
+
+func TestBackoffRestartsWithRetryClass(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+	defer testDB.SetRetryPolicies(config.DefaultRetryPolicies())
+
+	testDB.SetRetryPolicies(map[string]config.RetryPolicy{
+		config.RetryClassDB:    {InitialBackoff: time.Minute, MaxBackoff: time.Hour},
+		config.RetryClassProxy: {InitialBackoff: 10 * time.Minute, MaxBackoff: time.Hour},
+	})
+	const (
+		path = "example.com/m"
+		vers = "v1.0.0"
+	)
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{{Path: path, Version: vers, Timestamp: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		status int
+		want   time.Duration
+	}{
+		{http.StatusInternalServerError, time.Minute},
+		{http.StatusInternalServerError, 2 * time.Minute},
+		{derrors.ToStatus(derrors.ProxyError), 10 * time.Minute},
+		{derrors.ToStatus(derrors.ProxyTimedOut), 20 * time.Minute},
+	} {
+		if err := testDB.UpsertModuleVersionState(ctx, path, vers, "app", time.Now(), test.status, path, derrors.FromStatus(test.status, "test"), nil); err != nil {
+			t.Fatal(err)
+		}
+		mvs, err := testDB.GetModuleVersionState(ctx, path, vers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := mvs.NextProcessedAfter.Sub(*mvs.LastProcessedAt); got != test.want {
+			t.Errorf("after status %d: got backoff %s, want %s", test.status, got, test.want)
+		}
+	}
+}
@@ -441,18 +441,26 @@ func upsertSearchDocuments(ctx context.Context, db *database.DB, mod *internal.M
 	defer derrors.Wrap(&err, "UpsertSearchDocuments(ctx, %q)", mod.ModulePath)
 	ctx, span := trace.StartSpan(ctx, "UpsertSearchDocuments")
 	defer span.End()
+	readmes := map[string]*internal.Readme{}
+	for _, u := range mod.Units {
+		if u.Readme != nil {
+			readmes[u.Path] = u.Readme
+		}
+	}
 	for _, pkg := range mod.LegacyPackages {
 		if isInternalPackage(pkg.Path) {
 			continue
 		}
-		err := UpsertSearchDocument(ctx, db, upsertSearchDocumentArgs{
-			PackagePath:    pkg.Path,
-			ModulePath:     mod.ModulePath,
-			Synopsis:       pkg.Synopsis,
-			ReadmeFilePath: mod.LegacyReadmeFilePath,
-			ReadmeContents: mod.LegacyReadmeContents,
-		})
-		if err != nil {
+		args := upsertSearchDocumentArgs{
+			PackagePath: pkg.Path,
+			ModulePath:  mod.ModulePath,
+			Synopsis:    pkg.Synopsis,
+		}
+		if r := readmes[pkg.Path]; r != nil {
+			args.ReadmeFilePath = r.Filepath
+			args.ReadmeContents = r.Contents
+		}
+		if err := UpsertSearchDocument(ctx, db, args); err != nil {
 			return err
 		}
 	}
@@ -475,11 +483,6 @@ type upsertSearchDocumentArgs struct {
 func UpsertSearchDocument(ctx context.Context, db *database.DB, args upsertSearchDocumentArgs) (err error) {
 	defer derrors.Wrap(&err, "UpsertSearchDocument(ctx, db, %q, %q)", args.PackagePath, args.ModulePath)
 
-	// Only summarize the README if the package and module have the same path.
-	if args.PackagePath != args.ModulePath {
-		args.ReadmeFilePath = ""
-		args.ReadmeContents = ""
-	}
 	pathTokens := strings.Join(GeneratePathTokens(args.PackagePath), " ")
 	sectionB, sectionC, sectionD := SearchDocumentSections(args.Synopsis, args.ReadmeFilePath, args.ReadmeContents)
 	_, err = db.Exec(ctx, upsertSearchStatement, args.PackagePath, pathTokens, sectionB, sectionC, sectionD)
@@ -497,11 +500,15 @@ func (db *DB) GetPackagesForSearchDocumentUpsert(ctx context.Context, before tim
 			sd.module_path,
 			sd.synopsis,
 			sd.redistributable,
-			m.readme_file_path,
-			m.readme_contents
+			r.file_path,
+			r.contents
 		FROM search_documents sd
 		INNER JOIN modules m
 		USING (module_path, version)
+		INNER JOIN paths p
+		ON p.module_id = m.id AND p.path = sd.package_path
+		LEFT JOIN readmes r
+		ON r.path_id = p.id
 		WHERE sd.updated_at < $1
 		LIMIT $2`
 
@@ -510,7 +517,7 @@ func (db *DB) GetPackagesForSearchDocumentUpsert(ctx context.Context, before tim
 			a      upsertSearchDocumentArgs
 			redist bool
 		)
-		if err := rows.Scan(&a.PackagePath, &a.ModulePath, &a.Synopsis, &redist, &a.ReadmeFilePath, &a.ReadmeContents); err != nil {
+		if err := rows.Scan(&a.PackagePath, &a.ModulePath, &a.Synopsis, &redist, database.NullIsEmpty(&a.ReadmeFilePath), database.NullIsEmpty(&a.ReadmeContents)); err != nil {
 			return err
 		}
 		if !redist && !db.bypassLicenseCheck {
@@ -938,6 +938,11 @@ func TestGetPackagesForSearchDocumentUpsert(t *testing.T) {
 
 	moduleA := sample.Module("mod.com", "v1.2.3",
 		"A", "A/notinternal", "A/internal", "A/internal/B")
+	// Packages are indexed with their own READMEs, not the module's.
+	findDirectory(moduleA, "mod.com/A").Readme = &internal.Readme{
+		Filepath: "A/README.md",
+		Contents: "A readme",
+	}
 	moduleN := nonRedistributableModule()
 	bypassDB := NewBypassingLicenseCheck(testDB.db)
 	for _, m := range []*internal.Module{moduleA, moduleN} {
@@ -964,15 +969,15 @@ func TestGetPackagesForSearchDocumentUpsert(t *testing.T) {
 		{
 			PackagePath:    "mod.com/A",
 			ModulePath:     "mod.com",
-			ReadmeFilePath: "README.md",
-			ReadmeContents: "readme",
+			ReadmeFilePath: "A/README.md",
+			ReadmeContents: "A readme",
 			Synopsis:       "This is a package synopsis",
 		},
 		{
 			PackagePath:    "mod.com/A/notinternal",
 			ModulePath:     "mod.com",
-			ReadmeFilePath: "README.md",
-			ReadmeContents: "readme",
+			ReadmeFilePath: "",
+			ReadmeContents: "",
 			Synopsis:       "This is a package synopsis",
 		},
 	}
@@ -10,6 +10,7 @@ import (
 	"unicode"
 
 	"github.com/russross/blackfriday/v2"
+	"golang.org/x/pkgsite/internal/markup"
 )
 
 const (
@@ -37,6 +38,8 @@ func searchDocumentSections(synopsis, readmeFilename, readme string, maxSecWords
 	var readmeFirst, readmeRest string
 	if isMarkdown(readmeFilename) {
 		readme = processMarkdown(readme)
+	} else if f := markup.FormatOf(readmeFilename); f != markup.Unknown {
+		readme = markup.Parse(f, readme).Text()
 	}
 	if i := sentenceEndIndex(readme); i > 0 {
 		readmeFirst, readmeRest = readme[:i+1], readme[i+1:]
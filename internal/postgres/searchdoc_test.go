@@ -57,6 +57,23 @@ Many Go projects are built using Viper including:`,
 			"",
 			"many go projects are",
 		},
+		{
+			"reStructuredText",
+			"",
+			"README.rst",
+			`
+Sphinx
+======
+
+|badge| Sphinx is a *documentation* ` + "`generator <https://www.sphinx-doc.org>`_" + `. It makes docs.
+
+.. |badge| image:: https://img.shields.io/badge/docs-latest-blue.svg
+`,
+
+			"sphinx sphinx is a documentation generator", // first sentence of README promoted
+			"",
+			"it",
+		},
 	} {
 		gotB, gotC, gotD := searchDocumentSections(test.synopsis, test.readmeFilename, test.readmeContents, 6, 0.5)
 		if gotB != test.wantB {
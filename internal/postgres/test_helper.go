@@ -112,7 +112,7 @@ func ResetTestDB(db *DB, t *testing.T) {
 		if _, err := tx.Exec(ctx, `TRUNCATE module_version_states CASCADE;`); err != nil {
 			return err
 		}
-		if _, err := tx.Exec(ctx, `TRUNCATE excluded_prefixes;`); err != nil {
+		if _, err := tx.Exec(ctx, `TRUNCATE excluded_prefixes, excluded_prefixes_log;`); err != nil {
 			return err
 		}
 		setExcludedPrefixesLastFetched(time.Time{})
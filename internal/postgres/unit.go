@@ -30,7 +30,7 @@ func (db *DB) GetUnit(ctx context.Context, um *internal.UnitMeta, fields interna
 
 	u := &internal.Unit{UnitMeta: *um}
 	if fields&internal.WithReadme != 0 {
-		readme, err := db.getReadme(ctx, u.ModulePath, u.Version)
+		readme, err := db.getReadme(ctx, pathID)
 		if err != nil && !errors.Is(err, derrors.NotFound) {
 			return nil, err
 		}
@@ -66,6 +66,13 @@ func (db *DB) GetUnit(ctx context.Context, um *internal.UnitMeta, fields interna
 		}
 		u.Subdirectories = pkgs
 	}
+	if fields&internal.WithSymbols != 0 {
+		symbols, err := db.getSymbols(ctx, pathID)
+		if err != nil {
+			return nil, err
+		}
+		u.Symbols = symbols
+	}
 	if db.bypassLicenseCheck {
 		u.IsRedistributable = true
 	} else {
@@ -130,24 +137,14 @@ func (db *DB) getDocumentation(ctx context.Context, pathID int) (_ *internal.Doc
 	}
 }
 
-// getReadme returns the README corresponding to the modulePath and version.
-func (db *DB) getReadme(ctx context.Context, modulePath, resolvedVersion string) (_ *internal.Readme, err error) {
-	defer derrors.Wrap(&err, "getReadme(ctx, %q, %q)", modulePath, resolvedVersion)
-	// TODO(golang/go#38513): update to query on PathID and query the readmes
-	// table directly once we start displaying READMEs for directories instead
-	// of the top-level module.
+// getReadme returns the README corresponding to pathID.
+func (db *DB) getReadme(ctx context.Context, pathID int) (_ *internal.Readme, err error) {
+	defer derrors.Wrap(&err, "getReadme(ctx, %d)", pathID)
 	var readme internal.Readme
 	err = db.db.QueryRow(ctx, `
 		SELECT file_path, contents
-		FROM modules m
-		INNER JOIN paths p
-		ON p.module_id = m.id
-		INNER JOIN readmes r
-		ON p.id = r.path_id
-		WHERE
-		    m.module_path=$1
-			AND m.version=$2
-			AND m.module_path=p.path`, modulePath, resolvedVersion).Scan(&readme.Filepath, &readme.Contents)
+		FROM readmes
+		WHERE path_id=$1;`, pathID).Scan(&readme.Filepath, &readme.Contents)
 	switch err {
 	case sql.ErrNoRows:
 		return nil, derrors.NotFound
@@ -179,6 +176,64 @@ func (db *DB) getImports(ctx context.Context, pathID int) (_ []string, err error
 	return imports, nil
 }
 
+// getSymbols returns the symbols corresponding to pathID, sorted by name.
+func (db *DB) getSymbols(ctx context.Context, pathID int) (_ []*internal.Symbol, err error) {
+	defer derrors.Wrap(&err, "getSymbols(ctx, %d)", pathID)
+	var symbols []*internal.Symbol
+	collect := func(rows *sql.Rows) error {
+		var (
+			s                  internal.Symbol
+			refPaths, refNames []string
+		)
+		if err := rows.Scan(&s.Name, &s.Kind, pq.Array(&refPaths), pq.Array(&refNames)); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		if len(refPaths) != len(refNames) {
+			return fmt.Errorf("symbol %q: %d reference paths but %d names", s.Name, len(refPaths), len(refNames))
+		}
+		for i, p := range refPaths {
+			s.References = append(s.References, &internal.SymbolReference{PackagePath: p, Name: refNames[i]})
+		}
+		symbols = append(symbols, &s)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, `
+		SELECT name, kind, reference_paths, reference_names
+		FROM symbols
+		WHERE path_id = $1
+		ORDER BY name`, collect, pathID); err != nil {
+		return nil, err
+	}
+	return symbols, nil
+}
+
+// GetSymbolNames returns the names of the symbols declared by the package at
+// pkgPath in the given module version. It returns an error wrapping
+// derrors.NotFound if the package is not in the database.
+func (db *DB) GetSymbolNames(ctx context.Context, pkgPath, modulePath, resolvedVersion string) (_ map[string]bool, err error) {
+	defer derrors.Wrap(&err, "GetSymbolNames(ctx, %q, %q, %q)", pkgPath, modulePath, resolvedVersion)
+	pathID, err := db.getPathID(ctx, pkgPath, modulePath, resolvedVersion)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	collect := func(rows *sql.Rows) error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		names[name] = true
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, `
+		SELECT name
+		FROM symbols
+		WHERE path_id = $1`, collect, pathID); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
 // getPackagesInUnit returns all of the packages in a unit from a
 // module version, including the package that lives at fullPath, if present.
 func (db *DB) getPackagesInUnit(ctx context.Context, fullPath, modulePath, resolvedVersion string) (_ []*internal.PackageMeta, err error) {
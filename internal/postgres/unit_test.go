@@ -6,6 +6,7 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"path"
 	"testing"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/google/safehtml"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/source"
 	"golang.org/x/pkgsite/internal/stdlib"
@@ -169,12 +171,6 @@ func TestGetUnit(t *testing.T) {
 				// The packages table only includes partial license information; it omits the Coverage field.
 				cmpopts.IgnoreFields(licenses.Metadata{}, "Coverage"),
 			}
-			// TODO(golang/go#38513): remove once we start displaying
-			// READMEs for directories instead of the top-level module.
-			test.want.Readme = &internal.Readme{
-				Filepath: sample.ReadmeFilePath,
-				Contents: sample.ReadmeContents,
-			}
 			test.want.SourceInfo = um.SourceInfo
 			if diff := cmp.Diff(test.want, got, opts...); diff != "" {
 				t.Errorf("mismatch (-want, +got):\n%s", diff)
@@ -191,6 +187,10 @@ func TestGetUnitFieldSet(t *testing.T) {
 
 	// Add a module that has READMEs in a directory and a package.
 	m := sample.Module("a.com/m", "v1.2.3", "dir/p")
+	findDirectory(m, "a.com/m/dir/p").Readme = &internal.Readme{
+		Filepath: "dir/p/README.md",
+		Contents: "pkg readme",
+	}
 	if err := testDB.InsertModule(ctx, m); err != nil {
 		t.Fatal(err)
 	}
@@ -233,8 +233,8 @@ func TestGetUnitFieldSet(t *testing.T) {
 			fields: internal.WithReadme,
 			want: unit("a.com/m/dir/p", "a.com/m", "v1.2.3", "",
 				&internal.Readme{
-					Filepath: "README.md",
-					Contents: "readme",
+					Filepath: "dir/p/README.md",
+					Contents: "pkg readme",
 				}, []string{}),
 		},
 	} {
@@ -264,6 +264,53 @@ func TestGetUnitFieldSet(t *testing.T) {
 	}
 }
 
+func TestGetUnitSymbols(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module("a.com/m", "v1.2.3", "a", "b")
+	ref := &internal.SymbolReference{PackagePath: "a.com/m/b", Name: "NewClient"}
+	findDirectory(m, "a.com/m/a").Symbols = []*internal.Symbol{
+		{Name: "Client", Kind: internal.SymbolKindType, References: []*internal.SymbolReference{ref}},
+		{Name: "Client.Do", Kind: internal.SymbolKindMethod},
+	}
+	findDirectory(m, "a.com/m/b").Symbols = []*internal.Symbol{
+		{Name: "NewClient", Kind: internal.SymbolKindFunction},
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	// Reinserting a module with fewer symbols removes the stale ones.
+	findDirectory(m, "a.com/m/a").Symbols = findDirectory(m, "a.com/m/a").Symbols[:1]
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	um := sample.UnitMeta("a.com/m/a", "a.com/m", "v1.2.3", "a", true)
+	got, err := testDB.GetUnit(ctx, um, internal.WithSymbols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.Symbol{
+		{Name: "Client", Kind: internal.SymbolKindType, References: []*internal.SymbolReference{ref}},
+	}
+	if diff := cmp.Diff(want, got.Symbols); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	names, err := testDB.GetSymbolNames(ctx, "a.com/m/b", "a.com/m", "v1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]bool{"NewClient": true}, names); diff != "" {
+		t.Errorf("GetSymbolNames mismatch (-want, +got):\n%s", diff)
+	}
+	if _, err := testDB.GetSymbolNames(ctx, "a.com/m/b", "a.com/m", "v1.0.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("GetSymbolNames for missing version: got %v, want NotFound", err)
+	}
+}
+
 func unit(fullPath, modulePath, version, name string, readme *internal.Readme, suffixes []string) *internal.Unit {
 	u := &internal.Unit{
 		UnitMeta: internal.UnitMeta{
@@ -8,6 +8,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
 	"net/http"
 	"sort"
 	"time"
@@ -15,6 +16,7 @@ import (
 	"github.com/lib/pq"
 	"go.opencensus.io/trace"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/version"
@@ -24,18 +26,37 @@ import (
 // table with a status of zero.
 func (db *DB) InsertIndexVersions(ctx context.Context, versions []*internal.IndexVersion) (err error) {
 	defer derrors.Wrap(&err, "InsertIndexVersions(ctx, %v)", versions)
+	return db.InsertDiscoveredVersions(ctx, internal.IndexDiscoverer, versions)
+}
+
+// InsertDiscoveredVersions inserts versions reported by the named discoverer
+// into the module_version_states table with a status of zero.
+//
+// Versions reported by the module index replace the index timestamp of
+// existing rows and make them due for processing. Versions reported by other
+// discoverers, which may report the same versions on every poll, are ignored
+// if they are already present.
+func (db *DB) InsertDiscoveredVersions(ctx context.Context, discoverer string, versions []*internal.IndexVersion) (err error) {
+	defer derrors.Wrap(&err, "InsertDiscoveredVersions(ctx, %q, [%d versions])", discoverer, len(versions))
 
 	var vals []interface{}
 	for _, v := range versions {
-		vals = append(vals, v.Path, v.Version, version.ForSorting(v.Version), v.Timestamp, 0, "", "", isIncompatible(v.Version))
+		vals = append(vals, v.Path, v.Version, version.ForSorting(v.Version), v.Timestamp, 0, "", "", isIncompatible(v.Version), discoverer)
 	}
-	cols := []string{"module_path", "version", "sort_version", "index_timestamp", "status", "error", "go_mod_path", "incompatible"}
+	cols := []string{"module_path", "version", "sort_version", "index_timestamp", "status", "error", "go_mod_path", "incompatible", "discovered_by"}
 	conflictAction := `
+		ON CONFLICT
+			(module_path, version)
+		DO NOTHING`
+	if discoverer == internal.IndexDiscoverer {
+		conflictAction = `
 		ON CONFLICT
 			(module_path, version)
 		DO UPDATE SET
 			index_timestamp=excluded.index_timestamp,
-			next_processed_after=CURRENT_TIMESTAMP`
+			next_processed_after=CURRENT_TIMESTAMP,
+			discovered_by=excluded.discovered_by`
+	}
 	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
 		return tx.BulkInsert(ctx, "module_version_states", cols, vals, conflictAction)
 	})
@@ -58,7 +79,7 @@ func (db *DB) UpsertModuleVersionState(ctx context.Context, modulePath, vers, ap
 	}
 
 	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
-		if err := upsertModuleVersionState(ctx, tx, modulePath, vers, appVersion, numPackages, timestamp, status, goModPath, fetchErr); err != nil {
+		if err := upsertModuleVersionState(ctx, tx, modulePath, vers, appVersion, numPackages, timestamp, status, goModPath, fetchErr, db.backoff(status)); err != nil {
 			return err
 		}
 		// Sync modules.status if the module exists in the modules table.
@@ -72,7 +93,7 @@ func (db *DB) UpsertModuleVersionState(ctx context.Context, modulePath, vers, ap
 	})
 }
 
-func upsertModuleVersionState(ctx context.Context, db *database.DB, modulePath, vers, appVersion string, numPackages *int, timestamp time.Time, status int, goModPath string, fetchErr error) (err error) {
+func upsertModuleVersionState(ctx context.Context, db *database.DB, modulePath, vers, appVersion string, numPackages *int, timestamp time.Time, status int, goModPath string, fetchErr error, backoff config.RetryPolicy) (err error) {
 	defer derrors.Wrap(&err, "upsertModuleVersionState(ctx, %q, %q, %q, %s, %d, %q, %v",
 		modulePath, vers, appVersion, timestamp, status, goModPath, fetchErr)
 	ctx, span := trace.StartSpan(ctx, "upsertModuleVersionState")
@@ -83,7 +104,19 @@ func upsertModuleVersionState(ctx context.Context, db *database.DB, modulePath,
 		sqlErrorMsg = fetchErr.Error()
 	}
 
-	affected, err := db.Exec(ctx, `
+	// Back off exponentially according to the retry policy for the status,
+	// then retry at constant intervals. The backoff starts over when the
+	// status moves to a different retry class, since the previous interval
+	// came from another policy. try_count counts the consecutive fetches
+	// that failed with this status: it is reset by a success or a different
+	// status. Once it reaches the policy's tries, move the module version to
+	// the dead-letter state.
+	const tryCount = `CASE
+					WHEN excluded.status = 200 THEN 0
+					WHEN mvs.status = excluded.status THEN mvs.try_count+1
+					ELSE 1
+					END`
+	query := fmt.Sprintf(`
 			INSERT INTO module_version_states AS mvs (
 				module_path,
 				version,
@@ -104,19 +137,26 @@ func upsertModuleVersionState(ctx context.Context, db *database.DB, modulePath,
 				go_mod_path=excluded.go_mod_path,
 				error=excluded.error,
 				num_packages=excluded.num_packages,
-				try_count=mvs.try_count+1,
+				try_count=%[1]s,
 				last_processed_at=CURRENT_TIMESTAMP,
-			    -- back off exponentially until 1 hour, then at constant 1-hour intervals
 				next_processed_after=CASE
-					WHEN mvs.last_processed_at IS NULL THEN
-						CURRENT_TIMESTAMP + INTERVAL '1 minute'
-					WHEN 2*(mvs.next_processed_after - mvs.last_processed_at) < INTERVAL '1 hour' THEN
+					WHEN mvs.last_processed_at IS NULL OR mvs.status <> ALL($14) THEN
+						CURRENT_TIMESTAMP + make_interval(secs => $11)
+					WHEN 2*(mvs.next_processed_after - mvs.last_processed_at) < make_interval(secs => $12) THEN
 						CURRENT_TIMESTAMP + 2*(mvs.next_processed_after - mvs.last_processed_at)
 					ELSE
-						CURRENT_TIMESTAMP + INTERVAL '1 hour'
-					END;`,
+						CURRENT_TIMESTAMP + make_interval(secs => $12)
+					END,
+				dead_lettered_at=CASE
+					WHEN $13 > 0 AND %[1]s >= $13 THEN
+						COALESCE(mvs.dead_lettered_at, CURRENT_TIMESTAMP)
+					ELSE
+						NULL
+					END;`, tryCount)
+	affected, err := db.Exec(ctx, query,
 		modulePath, vers, version.ForSorting(vers),
-		appVersion, timestamp, status, goModPath, sqlErrorMsg, numPackages, isIncompatible(vers))
+		appVersion, timestamp, status, goModPath, sqlErrorMsg, numPackages, isIncompatible(vers),
+		backoff.InitialBackoff.Seconds(), backoff.MaxBackoff.Seconds(), backoff.MaxTries, pq.Array(classStatuses(status)))
 	if err != nil {
 		return err
 	}
@@ -178,18 +218,51 @@ func upsertPackageVersionStates(ctx context.Context, db *database.DB, packageVer
 					error=excluded.error`)
 }
 
+// GetModulePathsWithPrefix returns the sorted paths of the modules in
+// module_version_states that are prefix or are under prefix: that is, whose
+// paths begin with prefix followed by a slash.
+func (db *DB) GetModulePathsWithPrefix(ctx context.Context, prefix string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "GetModulePathsWithPrefix(ctx, %q)", prefix)
+
+	query := `SELECT DISTINCT module_path
+		FROM module_version_states
+		WHERE module_path = $1 OR left(module_path, length($1) + 1) = $1 || '/'
+		ORDER BY module_path`
+	var paths []string
+	collect := func(rows *sql.Rows) error {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, prefix); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
 // LatestIndexTimestamp returns the last timestamp successfully inserted into
-// the module_version_states table.
-func (db *DB) LatestIndexTimestamp(ctx context.Context) (_ time.Time, err error) {
-	defer derrors.Wrap(&err, "LatestIndexTimestamp(ctx)")
+// the module_version_states table from the module index.
+func (db *DB) LatestIndexTimestamp(ctx context.Context) (time.Time, error) {
+	return db.LatestDiscoveryTimestamp(ctx, internal.IndexDiscoverer)
+}
+
+// LatestDiscoveryTimestamp returns the latest index timestamp of the module
+// versions first reported by the named discoverer, or the zero time if there
+// are none.
+func (db *DB) LatestDiscoveryTimestamp(ctx context.Context, discoverer string) (_ time.Time, err error) {
+	defer derrors.Wrap(&err, "LatestDiscoveryTimestamp(ctx, %q)", discoverer)
 
 	query := `SELECT index_timestamp
 		FROM module_version_states
+		WHERE discovered_by = $1
 		ORDER BY index_timestamp DESC
 		LIMIT 1`
 
 	var ts time.Time
-	row := db.db.QueryRow(ctx, query)
+	row := db.db.QueryRow(ctx, query, discoverer)
 	switch err := row.Scan(&ts); err {
 	case sql.ErrNoRows:
 		return time.Time{}, nil
@@ -212,7 +285,8 @@ const moduleVersionStateColumns = `
 			next_processed_after,
 			app_version,
 			go_mod_path,
-			num_packages`
+			num_packages,
+			dead_lettered_at`
 
 // scanModuleVersionState constructs an *internal.ModuleModuleVersionState from the given
 // scanner. It expects columns to be in the order of moduleVersionStateColumns.
@@ -221,9 +295,11 @@ func scanModuleVersionState(scan func(dest ...interface{}) error) (*internal.Mod
 		v               internal.ModuleVersionState
 		lastProcessedAt pq.NullTime
 		numPackages     sql.NullInt64
+		deadLetteredAt  pq.NullTime
 	)
 	if err := scan(&v.ModulePath, &v.Version, &v.IndexTimestamp, &v.CreatedAt, &v.Status, &v.Error,
-		&v.TryCount, &v.LastProcessedAt, &v.NextProcessedAfter, &v.AppVersion, &v.GoModPath, &numPackages); err != nil {
+		&v.TryCount, &v.LastProcessedAt, &v.NextProcessedAfter, &v.AppVersion, &v.GoModPath, &numPackages,
+		&deadLetteredAt); err != nil {
 		return nil, err
 	}
 	if lastProcessedAt.Valid {
@@ -234,6 +310,10 @@ func scanModuleVersionState(scan func(dest ...interface{}) error) (*internal.Mod
 		n := int(numPackages.Int64)
 		v.NumPackages = &n
 	}
+	if deadLetteredAt.Valid {
+		t := deadLetteredAt.Time
+		v.DeadLetteredAt = &t
+	}
 	return &v, nil
 }
 
@@ -424,3 +504,144 @@ func (db *DB) GetVersionStats(ctx context.Context) (_ *VersionStats, err error)
 	}
 	return stats, nil
 }
+
+// UpsertModuleVersionStateTimings replaces the fetch stage timings recorded
+// for the given module version, which must be in the module_version_states
+// table.
+func (db *DB) UpsertModuleVersionStateTimings(ctx context.Context, modulePath, vers string, timings map[string]time.Duration) (err error) {
+	defer derrors.Wrap(&err, "UpsertModuleVersionStateTimings(ctx, %q, %q)", modulePath, vers)
+
+	var stages []string
+	for s := range timings {
+		stages = append(stages, s)
+	}
+	sort.Strings(stages)
+	var vals []interface{}
+	for _, s := range stages {
+		vals = append(vals, modulePath, vers, s, timings[s].Milliseconds())
+	}
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM module_version_state_timings
+			WHERE module_path = $1 AND version = $2`, modulePath, vers); err != nil {
+			return err
+		}
+		if len(vals) == 0 {
+			return nil
+		}
+		return tx.BulkInsert(ctx, "module_version_state_timings",
+			[]string{"module_path", "version", "stage", "duration_ms"}, vals, "")
+	})
+}
+
+// LoadModuleVersionStateTimings sets the Timings field of each element of
+// mvs from the module_version_state_timings table.
+func (db *DB) LoadModuleVersionStateTimings(ctx context.Context, mvs []*internal.ModuleVersionState) (err error) {
+	defer derrors.Wrap(&err, "LoadModuleVersionStateTimings(ctx, %d module versions)", len(mvs))
+
+	if len(mvs) == 0 {
+		return nil
+	}
+	type modver struct{ path, version string }
+	byModver := map[modver]*internal.ModuleVersionState{}
+	var paths, versions []string
+	for _, m := range mvs {
+		byModver[modver{m.ModulePath, m.Version}] = m
+		paths = append(paths, m.ModulePath)
+		versions = append(versions, m.Version)
+	}
+	query := `
+		SELECT module_path, version, stage, duration_ms
+		FROM module_version_state_timings
+		WHERE (module_path, version) IN (SELECT unnest($1::text[]), unnest($2::text[]))`
+	return db.db.RunQuery(ctx, query, func(rows *sql.Rows) error {
+		var (
+			mv         modver
+			stage      string
+			durationMS int64
+		)
+		if err := rows.Scan(&mv.path, &mv.version, &stage, &durationMS); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		m := byModver[mv]
+		if m.Timings == nil {
+			m.Timings = map[string]time.Duration{}
+		}
+		m.Timings[stage] = time.Duration(durationMS) * time.Millisecond
+		return nil
+	}, pq.Array(paths), pq.Array(versions))
+}
+
+// GetSlowestModuleVersions returns the limit module versions whose most
+// recent fetch took the longest, summed over all stages, slowest first. Their
+// Timings fields are populated.
+func (db *DB) GetSlowestModuleVersions(ctx context.Context, limit int) (_ []*internal.ModuleVersionState, err error) {
+	defer derrors.Wrap(&err, "GetSlowestModuleVersions(ctx, %d)", limit)
+
+	queryFormat := `
+		SELECT %s
+		FROM module_version_states
+		INNER JOIN (
+			SELECT module_path, version, sum(duration_ms) AS total
+			FROM module_version_state_timings
+			GROUP BY module_path, version
+			ORDER BY total DESC
+			LIMIT $1
+		) t USING (module_path, version)
+		ORDER BY t.total DESC, module_path, version`
+	mvs, err := db.queryModuleVersionStates(ctx, queryFormat, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.LoadModuleVersionStateTimings(ctx, mvs); err != nil {
+		return nil, err
+	}
+	return mvs, nil
+}
+
+// StageLatency holds percentiles of the time spent in a fetch stage, over
+// the most recent fetch of every module version.
+type StageLatency struct {
+	Stage         string
+	Count         int
+	P50, P90, P99 time.Duration
+	Max           time.Duration
+}
+
+// GetStageLatencies returns the latency percentiles of each fetch stage,
+// sorted by stage name.
+func (db *DB) GetStageLatencies(ctx context.Context) (_ []*StageLatency, err error) {
+	defer derrors.Wrap(&err, "GetStageLatencies(ctx)")
+
+	query := `
+		SELECT
+			stage,
+			count(*),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms),
+			percentile_cont(0.9) WITHIN GROUP (ORDER BY duration_ms),
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY duration_ms),
+			max(duration_ms)
+		FROM module_version_state_timings
+		GROUP BY stage
+		ORDER BY stage;`
+	var sls []*StageLatency
+	err = db.db.RunQuery(ctx, query, func(rows *sql.Rows) error {
+		var (
+			sl            StageLatency
+			p50, p90, p99 float64
+			max           int64
+		)
+		if err := rows.Scan(&sl.Stage, &sl.Count, &p50, &p90, &p99, &max); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		ms := func(f float64) time.Duration { return time.Duration(math.Round(f * float64(time.Millisecond))) }
+		sl.P50, sl.P90, sl.P99 = ms(p50), ms(p90), ms(p99)
+		sl.Max = time.Duration(max) * time.Millisecond
+		sls = append(sls, &sl)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sls, nil
+}
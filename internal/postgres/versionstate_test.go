@@ -255,3 +255,126 @@ func TestUpsertModuleVersionStates(t *testing.T) {
 	}
 
 }
+
+func TestModuleVersionStateTimings(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	now := sample.NowTruncated()
+	versions := []*internal.IndexVersion{
+		{Path: "fast.com/m", Version: "v1.0.0", Timestamp: now},
+		{Path: "slow.com/m", Version: "v1.0.0", Timestamp: now},
+	}
+	if err := testDB.InsertIndexVersions(ctx, versions); err != nil {
+		t.Fatal(err)
+	}
+	timings := map[string]map[string]time.Duration{
+		"fast.com/m": {"zip-download": 100 * time.Millisecond, "db-insert": 200 * time.Millisecond},
+		"slow.com/m": {"zip-download": 3 * time.Second, "db-insert": 400 * time.Millisecond},
+	}
+	for _, v := range versions {
+		// Insert twice, to check that timings are replaced.
+		if err := testDB.UpsertModuleVersionStateTimings(ctx, v.Path, v.Version, map[string]time.Duration{"old": time.Hour}); err != nil {
+			t.Fatal(err)
+		}
+		if err := testDB.UpsertModuleVersionStateTimings(ctx, v.Path, v.Version, timings[v.Path]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	slowest, err := testDB.GetSlowestModuleVersions(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slowest) != 1 || slowest[0].ModulePath != "slow.com/m" {
+		t.Fatalf("GetSlowestModuleVersions(ctx, 1) = %v, want slow.com/m", slowest)
+	}
+	if diff := cmp.Diff(timings["slow.com/m"], slowest[0].Timings); diff != "" {
+		t.Errorf("timings mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err := testDB.GetStageLatencies(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*StageLatency{
+		{Stage: "db-insert", Count: 2, P50: 300 * time.Millisecond, P90: 380 * time.Millisecond, P99: 398 * time.Millisecond, Max: 400 * time.Millisecond},
+		{Stage: "zip-download", Count: 2, P50: 1550 * time.Millisecond, P90: 2710 * time.Millisecond, P99: 2971 * time.Millisecond, Max: 3 * time.Second},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetStageLatencies mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestInsertDiscoveredVersions(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	indexTime := sample.NowTruncated()
+	listTime := indexTime.Add(time.Hour)
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{
+		{Path: "index.com/a", Version: "v1.0.0", Timestamp: indexTime},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	listed := []*internal.IndexVersion{
+		{Path: "index.com/a", Version: "v1.0.0", Timestamp: listTime}, // already known; ignored
+		{Path: "private.com/b", Version: "v1.0.0", Timestamp: listTime},
+	}
+	for i := 0; i < 2; i++ {
+		if err := testDB.InsertDiscoveredVersions(ctx, "list", listed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Versions from other discoverers do not move the index cursor.
+	got, err := testDB.LatestIndexTimestamp(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(indexTime) {
+		t.Errorf("LatestIndexTimestamp = %v, want %v", got, indexTime)
+	}
+	got, err = testDB.LatestDiscoveryTimestamp(ctx, "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(listTime) {
+		t.Errorf("LatestDiscoveryTimestamp(list) = %v, want %v", got, listTime)
+	}
+	mvs, err := testDB.GetModuleVersionState(ctx, "index.com/a", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mvs.IndexTimestamp.Equal(indexTime) {
+		t.Errorf("index.com/a: got index timestamp %v, want %v", mvs.IndexTimestamp, indexTime)
+	}
+	if _, err := testDB.GetModuleVersionState(ctx, "private.com/b", "v1.0.0"); err != nil {
+		t.Errorf("private.com/b: %v", err)
+	}
+}
+
+func TestGetModulePathsWithPrefix(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	var ivs []*internal.IndexVersion
+	for _, p := range []string{"example.com/a", "example.com/a/b", "example.com/a/c/d", "example.com/ab", "other.com/a"} {
+		ivs = append(ivs, &internal.IndexVersion{Path: p, Version: "v1.0.0", Timestamp: sample.NowTruncated()})
+	}
+	ivs = append(ivs, &internal.IndexVersion{Path: "example.com/a/b", Version: "v1.1.0", Timestamp: sample.NowTruncated()})
+	if err := testDB.InsertIndexVersions(ctx, ivs); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.GetModulePathsWithPrefix(ctx, "example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"example.com/a", "example.com/a/b", "example.com/a/c/d"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// A DiskCache stores responses from a module proxy on disk, so that
+// reprocessing a module version does not download it again.
+//
+// Files are laid out like the go command's module cache, under
+// cache/download in the cache directory:
+//
+//   cache/download/<escaped module path>/@v/<escaped version>.info
+//   cache/download/<escaped module path>/@v/<escaped version>.mod
+//   cache/download/<escaped module path>/@v/<escaped version>.zip
+//
+// Alongside each .zip and .mod file is a .ziphash or .modhash file holding
+// its hash in the format of go.sum. The hash is checked whenever the file is
+// read, and files that do not match are discarded.
+//
+// The total size of the cached files is kept below a limit by evicting the
+// least recently used files.
+type DiskCache struct {
+	dir      string // the cache/download directory
+	maxBytes int64
+
+	mu    sync.Mutex
+	size  int64                    // total size of files in lru
+	lru   *list.List               // of *cacheEntry, most recently used first
+	index map[string]*list.Element // from path relative to dir
+}
+
+type cacheEntry struct {
+	rel  string // path relative to DiskCache.dir
+	size int64
+}
+
+// NewDiskCache returns a DiskCache that stores files under dir, which is
+// created if necessary, and keeps their total size under maxBytes. Files
+// already in dir are added to the cache, ordered by modification time.
+func NewDiskCache(dir string, maxBytes int64) (_ *DiskCache, err error) {
+	defer derrors.Wrap(&err, "NewDiskCache(%q, %d)", dir, maxBytes)
+
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("maxBytes must be positive: %w", derrors.InvalidArgument)
+	}
+	c := &DiskCache{
+		dir:      filepath.Join(dir, "cache", "download"),
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		index:    map[string]*list.Element{},
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return nil, err
+	}
+	type file struct {
+		rel   string
+		size  int64
+		mtime time.Time
+	}
+	var files []file
+	err = filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || isHashFile(path) {
+			return nil
+		}
+		if strings.Contains(filepath.Base(path), ".tmp") {
+			// Left over from an interrupted Put.
+			return os.Remove(path)
+		}
+		rel, err := filepath.Rel(c.dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, file{rel, info.Size(), info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Add the least recently used first, so that it ends up at the back.
+	sort.Slice(files, func(i, j int) bool { return files[i].mtime.Before(files[j].mtime) })
+	for _, f := range files {
+		c.add(f.rel, f.size)
+	}
+	c.evict()
+	return c, nil
+}
+
+// cacheable reports whether the proxy's response for the given version and
+// suffix never changes, so that it can be cached.
+func cacheable(version, suffix string) bool {
+	switch suffix {
+	case "info", "mod", "zip":
+		// Only canonical semantic versions are immutable; queries like
+		// "master" or "latest" can resolve to different versions over time.
+		return module.CanonicalVersion(version) == version && version != ""
+	default:
+		return false
+	}
+}
+
+// relPath returns the path of the file for the module version and suffix,
+// relative to c.dir.
+func relPath(modulePath, version, suffix string) (string, error) {
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return "", err
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.FromSlash(escapedPath), "@v", escapedVersion+"."+suffix), nil
+}
+
+// hashSuffix returns the suffix of the hash file for files with the given
+// suffix, or the empty string if those files are not hashed.
+func hashSuffix(suffix string) string {
+	switch suffix {
+	case "zip":
+		return "ziphash"
+	case "mod":
+		return "modhash"
+	default:
+		return ""
+	}
+}
+
+func isHashFile(path string) bool {
+	return strings.HasSuffix(path, ".ziphash") || strings.HasSuffix(path, ".modhash")
+}
+
+// hashFile returns the path of the hash file for path.
+func hashFile(path, suffix string) string {
+	return strings.TrimSuffix(path, suffix) + hashSuffix(suffix)
+}
+
+// Get returns the cached contents for the module version and suffix. It
+// returns an error wrapping derrors.NotFound if the contents are not cached
+// or fail verification.
+func (c *DiskCache) Get(ctx context.Context, modulePath, version, suffix string) (_ []byte, err error) {
+	defer derrors.Wrap(&err, "DiskCache.Get(%q, %q, %q)", modulePath, version, suffix)
+
+	rel, err := relPath(modulePath, version, suffix)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(c.dir, rel)
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		recordCacheResult(ctx, cacheMiss)
+		return nil, derrors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if hs := hashSuffix(suffix); hs != "" {
+		want, err := ioutil.ReadFile(hashFile(path, suffix))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		got, herr := hashContents(path, data, suffix)
+		if err != nil || herr != nil || got != string(want) {
+			log.Warningf(ctx, "proxy cache: discarding %s: hash mismatch", rel)
+			c.remove(rel, suffix)
+			recordCacheResult(ctx, cacheCorrupt)
+			return nil, fmt.Errorf("hash mismatch: %w", derrors.NotFound)
+		}
+	}
+	c.touch(rel)
+	now := time.Now()
+	// Record the access on disk so that the LRU order survives restarts.
+	_ = os.Chtimes(path, now, now)
+	recordCacheResult(ctx, cacheHit)
+	return data, nil
+}
+
+// Put stores data as the contents for the module version and suffix, and
+// evicts the least recently used files if the cache is over its size limit.
+func (c *DiskCache) Put(ctx context.Context, modulePath, version, suffix string, data []byte) (err error) {
+	defer derrors.Wrap(&err, "DiskCache.Put(%q, %q, %q)", modulePath, version, suffix)
+
+	if int64(len(data)) > c.maxBytes {
+		return nil
+	}
+	rel, err := relPath(modulePath, version, suffix)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(c.dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := writeTemp(path, data)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp) // no-op after a successful rename
+	if hs := hashSuffix(suffix); hs != "" {
+		h, err := hashContents(tmp, data, suffix)
+		if err != nil {
+			// The contents are invalid, for example a bad zip. Don't cache
+			// them; the caller will report the error.
+			return nil
+		}
+		htmp, err := writeTemp(path, []byte(h))
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(htmp, hashFile(path, suffix)); err != nil {
+			os.Remove(htmp)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.index[rel]; ok {
+		c.size -= e.Value.(*cacheEntry).size
+		c.lru.Remove(e)
+		delete(c.index, rel)
+	}
+	c.add(rel, int64(len(data)))
+	c.evict()
+	return nil
+}
+
+// writeTemp writes data to a temporary file in the directory of path, and
+// returns the name of the file.
+func writeTemp(path string, data []byte) (string, error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// hashContents returns the go.sum hash of the contents of a .zip or .mod file.
+// The zip must be on disk at path.
+func hashContents(path string, data []byte, suffix string) (string, error) {
+	if suffix == "zip" {
+		return dirhash.HashZip(path, dirhash.Hash1)
+	}
+	return dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+// Size returns the size of the cached file for the module version and suffix,
+// and whether it is cached. The file is not verified.
+func (c *DiskCache) Size(modulePath, version, suffix string) (int64, bool) {
+	rel, err := relPath(modulePath, version, suffix)
+	if err != nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.index[rel]
+	if !ok {
+		return 0, false
+	}
+	return e.Value.(*cacheEntry).size, true
+}
+
+// TotalSize returns the total size of the cached files.
+func (c *DiskCache) TotalSize() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// add adds a file to the front of the LRU list. c.mu must be held, except
+// during construction.
+func (c *DiskCache) add(rel string, size int64) {
+	c.index[rel] = c.lru.PushFront(&cacheEntry{rel: rel, size: size})
+	c.size += size
+}
+
+// touch marks rel as the most recently used file.
+func (c *DiskCache) touch(rel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.index[rel]; ok {
+		c.lru.MoveToFront(e)
+	}
+}
+
+// remove deletes rel and its hash file from the cache.
+func (c *DiskCache) remove(rel, suffix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(rel, suffix)
+}
+
+func (c *DiskCache) removeLocked(rel, suffix string) {
+	if e, ok := c.index[rel]; ok {
+		c.size -= e.Value.(*cacheEntry).size
+		c.lru.Remove(e)
+		delete(c.index, rel)
+	}
+	path := filepath.Join(c.dir, rel)
+	os.Remove(path)
+	if hashSuffix(suffix) != "" {
+		os.Remove(hashFile(path, suffix))
+	}
+}
+
+// evict removes the least recently used files until the cache is within its
+// size limit. c.mu must be held, except during construction.
+func (c *DiskCache) evict() {
+	for c.size > c.maxBytes {
+		e := c.lru.Back()
+		if e == nil {
+			return
+		}
+		rel := e.Value.(*cacheEntry).rel
+		c.removeLocked(rel, strings.TrimPrefix(filepath.Ext(rel), "."))
+		recordCacheResult(context.Background(), cacheEvicted)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func testZip(t *testing.T, modulePath, version, contents string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(modulePath + "@" + version + "/go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(contents)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDiskCache(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "proxycache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := NewDiskCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		modulePath = "example.com/Mod"
+		version    = "v1.0.0"
+	)
+	if _, err := c.Get(ctx, modulePath, version, "zip"); !errors.Is(err, derrors.NotFound) {
+		t.Fatalf("got %v, want NotFound", err)
+	}
+	zipData := testZip(t, modulePath, version, "module example.com/Mod\n")
+	for suffix, data := range map[string][]byte{
+		"info": []byte(`{"Version":"v1.0.0"}`),
+		"mod":  []byte("module example.com/Mod\n"),
+		"zip":  zipData,
+	} {
+		if err := c.Put(ctx, modulePath, version, suffix, data); err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.Get(ctx, modulePath, version, suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: got %q, want %q", suffix, got, data)
+		}
+	}
+	// The layout matches the module cache, with escaped paths.
+	zipPath := filepath.Join(dir, "cache", "download", "example.com", "!mod", "@v", "v1.0.0.zip")
+	for _, f := range []string{zipPath, zipPath[:len(zipPath)-len("zip")] + "ziphash"} {
+		if _, err := os.Stat(f); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// A corrupted file is discarded.
+	if err := ioutil.WriteFile(zipPath, testZip(t, modulePath, version, "corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, modulePath, version, "zip"); !errors.Is(err, derrors.NotFound) {
+		t.Fatalf("corrupt zip: got %v, want NotFound", err)
+	}
+	if _, err := os.Stat(zipPath); !os.IsNotExist(err) {
+		t.Errorf("corrupt zip was not removed: %v", err)
+	}
+
+	// A new cache picks up the files that are already there.
+	c2, err := NewDiskCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c2.TotalSize(), c.TotalSize(); got != want {
+		t.Errorf("reopened cache: got size %d, want %d", got, want)
+	}
+	if _, err := c2.Get(ctx, modulePath, version, "mod"); err != nil {
+		t.Errorf("reopened cache: %v", err)
+	}
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "proxycache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := NewDiskCache(dir, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("0123456789")
+	put := func(v string) {
+		t.Helper()
+		if err := c.Put(ctx, "m.com", v, "info", data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cached := func(v string) bool {
+		_, err := c.Get(ctx, "m.com", v, "info")
+		return err == nil
+	}
+	put("v1.0.0")
+	put("v1.0.1")
+	// Use v1.0.0, so that v1.0.1 is the least recently used.
+	if !cached("v1.0.0") {
+		t.Fatal("v1.0.0 not cached")
+	}
+	put("v1.0.2")
+	if got := c.TotalSize(); got > 25 {
+		t.Errorf("got total size %d, want at most 25", got)
+	}
+	for v, want := range map[string]bool{"v1.0.0": true, "v1.0.1": false, "v1.0.2": true} {
+		if got := cached(v); got != want {
+			t.Errorf("%s: got cached %t, want %t", v, got, want)
+		}
+	}
+}
+
+func TestClientWithDiskCache(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "proxycache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dc, err := NewDiskCache(dir, 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer([]*Module{testModule})
+	requests := 0
+	counting := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		s.mux.ServeHTTP(w, r)
+	})
+	client, teardown, err := newClientForHandler(counting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	client = client.WithDiskCache(dc)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetZip(ctx, sample.ModulePath, sample.VersionString); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.GetMod(ctx, sample.ModulePath, sample.VersionString); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests to the proxy, want 2", requests)
+	}
+	// Queries are not cached.
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetInfo(ctx, sample.ModulePath, "master"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 4 {
+		t.Errorf("got %d requests to the proxy, want 4", requests)
+	}
+}
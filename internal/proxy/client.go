@@ -13,27 +13,65 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
-	"go.opencensus.io/plugin/ochttp"
 	"golang.org/x/mod/module"
 	"golang.org/x/net/context/ctxhttp"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/checksum"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/resilient"
 )
 
 // A Client is used by the fetch service to communicate with a module
 // proxy. It handles all methods defined by go help goproxy.
 type Client struct {
-	// URL of the module proxy web server
-	url string
+	// upstreams are the module proxies to try, in order, for module paths
+	// that do not match a route.
+	upstreams []*upstream
+
+	// routes send module paths with given prefixes to other upstreams.
+	// They are sorted by decreasing prefix length, so that the most specific
+	// route matches first.
+	routes []*route
 
 	// client used for HTTP requests. It is mutable for testing purposes.
 	httpClient *http.Client
+
+	// cache, if non-nil, holds responses for immutable module versions.
+	cache *DiskCache
+
+	// sumDB, if non-nil, is the checksum database used by VerifyZip.
+	sumDB *checksum.DB
+}
+
+// An upstream is a module proxy web server.
+type upstream struct {
+	url string
+	// fallBackOnError reports whether to try the next upstream after any
+	// error, rather than only after a 404 or 410 response. It corresponds to a
+	// "|" rather than a "," after the URL in a GOPROXY list.
+	fallBackOnError bool
+}
+
+// A route sends the module paths that match prefix to upstreams.
+type route struct {
+	prefix    string
+	upstreams []*upstream
+}
+
+// A Route sends requests for module paths matching Prefix to the proxies
+// in Proxy, a list in the same format as the URL passed to New.
+// A module path matches a prefix if it equals the prefix or starts with the
+// prefix followed by a slash.
+type Route struct {
+	Prefix string
+	Proxy  string
 }
 
 // A VersionInfo contains metadata about a given version of a module.
@@ -42,14 +80,122 @@ type VersionInfo struct {
 	Time    time.Time
 }
 
-// New constructs a *Client using the provided url, which is expected to
-// be an absolute URI that can be directly passed to http.Get.
-func New(u string) (_ *Client, err error) {
-	defer derrors.Wrap(&err, "proxy.New(%q)", u)
-	return &Client{
-		url:        strings.TrimRight(u, "/"),
-		httpClient: &http.Client{Transport: &ochttp.Transport{}},
-	}, nil
+// New constructs a *Client using the provided url, which is a list of
+// absolute URIs in the format of the GOPROXY environment variable. As with
+// GOPROXY, the proxies are tried in order: after a proxy responds with 404 or
+// 410, the next one is tried if the URL is followed by a comma or a pipe;
+// after any other error, the next one is tried only if the URL is followed by
+// a pipe. The keywords "direct" and "off" are not supported.
+//
+// Requests for module paths that match one of the given routes are sent to
+// the proxies of the longest matching route instead.
+func New(u string, routes ...Route) (_ *Client, err error) {
+	defer derrors.Wrap(&err, "proxy.New(%q, %v)", u, routes)
+	ups, err := parseProxyList(u)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		upstreams:  ups,
+		httpClient: &http.Client{Transport: resilient.Must(resilient.New(transportConfig))},
+	}
+	for _, r := range routes {
+		prefix := strings.TrimRight(r.Prefix, "/")
+		if prefix == "" {
+			return nil, fmt.Errorf("empty route prefix: %w", derrors.InvalidArgument)
+		}
+		ups, err := parseProxyList(r.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		c.routes = append(c.routes, &route{prefix: prefix, upstreams: ups})
+	}
+	sort.SliceStable(c.routes, func(i, j int) bool {
+		return len(c.routes[i].prefix) > len(c.routes[j].prefix)
+	})
+	return c, nil
+}
+
+// WithDiskCache returns a copy of c that reads responses for immutable module
+// versions from dc when they are present, and stores them there when they are
+// not.
+func (c *Client) WithDiskCache(dc *DiskCache) *Client {
+	c2 := *c
+	c2.cache = dc
+	return &c2
+}
+
+// WithChecksumDB returns a copy of c whose VerifyZip method checks zips
+// against db.
+func (c *Client) WithChecksumDB(db *checksum.DB) *Client {
+	c2 := *c
+	c2.sumDB = db
+	return &c2
+}
+
+// parseProxyList parses a list of proxy URLs in the format of GOPROXY.
+func parseProxyList(list string) (_ []*upstream, err error) {
+	defer derrors.Wrap(&err, "parseProxyList(%q)", list)
+
+	var ups []*upstream
+	for list != "" {
+		var (
+			u        string
+			fallBack bool
+		)
+		if i := strings.IndexAny(list, ",|"); i >= 0 {
+			u = list[:i]
+			fallBack = list[i] == '|'
+			list = list[i+1:]
+		} else {
+			u = list
+			list = ""
+		}
+		u = strings.TrimSpace(u)
+		switch u {
+		case "":
+			continue
+		case "direct", "off":
+			return nil, fmt.Errorf("%q is not supported: %w", u, derrors.InvalidArgument)
+		}
+		ups = append(ups, &upstream{url: strings.TrimRight(u, "/"), fallBackOnError: fallBack})
+	}
+	if len(ups) == 0 {
+		return nil, fmt.Errorf("no proxy URLs: %w", derrors.InvalidArgument)
+	}
+	return ups, nil
+}
+
+// ParseRoutes parses routes of the form "PREFIX=PROXY;PREFIX=PROXY...", where
+// each PROXY is a list of proxy URLs in the format of GOPROXY. For example,
+//
+//   example.com/internal=https://mirror.example.com;example.com/public=https://mirror.example.com|https://proxy.golang.org
+func ParseRoutes(s string) (_ []Route, err error) {
+	defer derrors.Wrap(&err, "ParseRoutes(%q)", s)
+
+	var routes []Route
+	for _, r := range strings.Split(s, ";") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		i := strings.IndexByte(r, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("route %q has no '=': %w", r, derrors.InvalidArgument)
+		}
+		routes = append(routes, Route{Prefix: strings.TrimSpace(r[:i]), Proxy: strings.TrimSpace(r[i+1:])})
+	}
+	return routes, nil
+}
+
+// upstreamsFor returns the upstreams to try for modulePath.
+func (c *Client) upstreamsFor(modulePath string) []*upstream {
+	for _, r := range c.routes {
+		if modulePath == r.prefix || strings.HasPrefix(modulePath, r.prefix+"/") {
+			return r.upstreams
+		}
+	}
+	return c.upstreams
 }
 
 // GetInfo makes a request to $GOPROXY/<module>/@v/<requestedVersion>.info and
@@ -92,32 +238,59 @@ func (c *Client) GetZip(ctx context.Context, modulePath, resolvedVersion string)
 	return zipReader, nil
 }
 
+// VerifyZip checks the hash of zipReader, the zip for the module version,
+// against the checksum database configured with WithChecksumDB. See
+// checksum.DB.VerifyZip for the errors it returns. If c has no checksum
+// database, VerifyZip returns nil.
+//
+// A zip whose hash does not match is removed from the disk cache, so that it
+// is downloaded again the next time it is requested.
+func (c *Client) VerifyZip(ctx context.Context, modulePath, resolvedVersion string, zipReader *zip.Reader) error {
+	if c.sumDB == nil {
+		return nil
+	}
+	err := c.sumDB.VerifyZip(ctx, modulePath, resolvedVersion, zipReader)
+	if errors.Is(err, derrors.ChecksumMismatch) && c.cache != nil {
+		if rel, rerr := relPath(modulePath, resolvedVersion, "zip"); rerr == nil {
+			c.cache.remove(rel, "zip")
+		}
+	}
+	return err
+}
+
 // GetZipSize gets the size in bytes of the zip from the proxy, without downloading it.
 // The version must be resolved, as by a call to Client.GetInfo.
 func (c *Client) GetZipSize(ctx context.Context, modulePath, resolvedVersion string) (_ int64, err error) {
 	defer derrors.Wrap(&err, "proxy.Client.GetZipSize(ctx, %q, %q)", modulePath, resolvedVersion)
 
-	url, err := c.escapedURL(modulePath, resolvedVersion, "zip")
+	if c.cache != nil && cacheable(resolvedVersion, "zip") {
+		if size, ok := c.cache.Size(modulePath, resolvedVersion, "zip"); ok {
+			return size, nil
+		}
+	}
+	path, err := escapedPath(modulePath, resolvedVersion, "zip")
 	if err != nil {
 		return 0, err
 	}
-	res, err := ctxhttp.Head(ctx, c.httpClient, url)
+	var size int64
+	err = c.executeRequest(ctx, http.MethodHead, modulePath, path, func(r *http.Response) error {
+		if r.ContentLength < 0 {
+			return errors.New("unknown content length")
+		}
+		size = r.ContentLength
+		return nil
+	})
 	if err != nil {
-		return 0, fmt.Errorf("ctxhttp.Head(ctx, client, %q): %v", url, err)
-	}
-	defer res.Body.Close()
-	if err := responseError(res); err != nil {
 		return 0, err
 	}
-	if res.ContentLength < 0 {
-		return 0, errors.New("unknown content length")
-	}
-	return res.ContentLength, nil
+	return size, nil
 }
 
-func (c *Client) escapedURL(modulePath, requestedVersion, suffix string) (_ string, err error) {
+// escapedPath returns the path, relative to the proxy URL, of the request for
+// the given module path, version and suffix.
+func escapedPath(modulePath, requestedVersion, suffix string) (_ string, err error) {
 	defer func() {
-		derrors.Wrap(&err, "Client.escapedURL(%q, %q, %q)", modulePath, requestedVersion, suffix)
+		derrors.Wrap(&err, "escapedPath(%q, %q, %q)", modulePath, requestedVersion, suffix)
 	}()
 
 	if suffix != "info" && suffix != "mod" && suffix != "zip" {
@@ -131,31 +304,46 @@ func (c *Client) escapedURL(modulePath, requestedVersion, suffix string) (_ stri
 		if suffix != "info" {
 			return "", fmt.Errorf("cannot ask for latest with suffix %q", suffix)
 		}
-		return fmt.Sprintf("%s/%s/@latest", c.url, escapedPath), nil
+		return fmt.Sprintf("%s/@latest", escapedPath), nil
 	}
 	escapedVersion, err := module.EscapeVersion(requestedVersion)
 	if err != nil {
 		return "", fmt.Errorf("version: %v: %w", err, derrors.InvalidArgument)
 	}
-	return fmt.Sprintf("%s/%s/@v/%s.%s", c.url, escapedPath, escapedVersion, suffix), nil
+	return fmt.Sprintf("%s/@v/%s.%s", escapedPath, escapedVersion, suffix), nil
 }
 
 func (c *Client) readBody(ctx context.Context, modulePath, requestedVersion, suffix string) (_ []byte, err error) {
 	defer derrors.Wrap(&err, "Client.readBody(%q, %q, %q)", modulePath, requestedVersion, suffix)
 
-	u, err := c.escapedURL(modulePath, requestedVersion, suffix)
+	useCache := c.cache != nil && cacheable(requestedVersion, suffix)
+	if useCache {
+		data, err := c.cache.Get(ctx, modulePath, requestedVersion, suffix)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, derrors.NotFound) {
+			log.Warningf(ctx, "proxy cache: %v", err)
+		}
+	}
+	path, err := escapedPath(modulePath, requestedVersion, suffix)
 	if err != nil {
 		return nil, err
 	}
 	var data []byte
-	err = c.executeRequest(ctx, u, func(body io.Reader) error {
+	err = c.executeRequest(ctx, http.MethodGet, modulePath, path, func(r *http.Response) error {
 		var err error
-		data, err = ioutil.ReadAll(body)
+		data, err = ioutil.ReadAll(r.Body)
 		return err
 	})
 	if err != nil {
 		return nil, err
 	}
+	if useCache {
+		if err := c.cache.Put(ctx, modulePath, requestedVersion, suffix, data); err != nil {
+			log.Warningf(ctx, "proxy cache: %v", err)
+		}
+	}
 	return data, nil
 }
 
@@ -166,39 +354,87 @@ func (c *Client) ListVersions(ctx context.Context, modulePath string) ([]string,
 	if err != nil {
 		return nil, fmt.Errorf("module.EscapePath(%q): %w", modulePath, derrors.InvalidArgument)
 	}
-	u := fmt.Sprintf("%s/%s/@v/list", c.url, escapedPath)
 	var versions []string
-	collect := func(body io.Reader) error {
-		scanner := bufio.NewScanner(body)
+	collect := func(r *http.Response) error {
+		versions = nil
+		scanner := bufio.NewScanner(r.Body)
 		for scanner.Scan() {
 			versions = append(versions, scanner.Text())
 		}
 		return scanner.Err()
 	}
-	if err := c.executeRequest(ctx, u, collect); err != nil {
+	if err := c.executeRequest(ctx, http.MethodGet, modulePath, escapedPath+"/@v/list", collect); err != nil {
 		return nil, err
 	}
 	return versions, nil
 }
 
-// executeRequest executes an HTTP GET request for u, then calls the bodyFunc
-// on the response body, if no error occurred.
-func (c *Client) executeRequest(ctx context.Context, u string, bodyFunc func(body io.Reader) error) (err error) {
+// executeRequest executes an HTTP request with the given method for path on
+// the upstreams for modulePath, falling back from one upstream to the next as
+// described at New. It calls respFunc on the first successful response.
+func (c *Client) executeRequest(ctx context.Context, method, modulePath, path string, respFunc func(r *http.Response) error) (err error) {
 	defer func() {
 		if ctx.Err() != nil {
 			err = fmt.Errorf("%v: %w", err, derrors.ProxyTimedOut)
 		}
-		derrors.Wrap(&err, "executeRequest(ctx, %q)", u)
+		derrors.Wrap(&err, "executeRequest(ctx, %s, %q)", method, path)
+	}()
+
+	// As the go command does, report the most informative error: any error
+	// other than "not found" takes precedence.
+	var bestErr error
+	for _, up := range c.upstreamsFor(modulePath) {
+		err := c.executeUpstreamRequest(ctx, up, method, path, respFunc)
+		if err == nil {
+			return nil
+		}
+		if bestErr == nil || errors.Is(bestErr, derrors.NotFound) {
+			bestErr = err
+		}
+		if ctx.Err() != nil || !(up.fallBackOnError || errors.Is(err, derrors.NotFound)) {
+			break
+		}
+	}
+	return bestErr
+}
+
+// executeUpstreamRequest executes an HTTP request with the given method for
+// path on up, then calls respFunc on the response, if no error occurred. It
+// records the latency and result of the request.
+func (c *Client) executeUpstreamRequest(ctx context.Context, up *upstream, method, path string, respFunc func(r *http.Response) error) (err error) {
+	u := up.url + "/" + path
+	start := time.Now()
+	defer func() {
+		recordUpstreamRequest(ctx, up.url, time.Since(start), err)
 	}()
-	r, err := ctxhttp.Get(ctx, c.httpClient, u)
+
+	req, err := http.NewRequest(method, u, nil)
 	if err != nil {
-		return fmt.Errorf("ctxhttp.Get(ctx, client, %q): %v", u, err)
+		return err
+	}
+	r, err := ctxhttp.Do(ctx, c.httpClient, req)
+	if errors.Is(err, resilient.ErrBreakerRed) {
+		// Treat the upstream like one that returned a 5xx, so that the
+		// request is tried again later.
+		return fmt.Errorf("ctxhttp.Do(ctx, client, %s %q): %v: %w", method, u, err, derrors.ProxyError)
+	}
+	if err != nil {
+		return fmt.Errorf("ctxhttp.Do(ctx, client, %s %q): %v", method, u, err)
 	}
 	defer r.Body.Close()
 	if err := responseError(r); err != nil {
 		return err
 	}
-	return bodyFunc(r.Body)
+	return respFunc(r)
+}
+
+// transportConfig configures the transport for requests to the module
+// proxies. Zips can be large, so requests are not hedged.
+var transportConfig = resilient.Config{
+	Name:          "proxy",
+	MaxRetries:    2,
+	RetryDelay:    100 * time.Millisecond,
+	MaxRetryDelay: time.Second,
 }
 
 // responseError translates the response status code to an appropriate error.
@@ -222,6 +458,8 @@ func responseError(r *http.Response) error {
 			return fmt.Errorf("%q: %w", d, derrors.ProxyTimedOut)
 		}
 		return fmt.Errorf("%q: %w", d, derrors.NotFound)
+	case r.StatusCode >= 500:
+		return fmt.Errorf("unexpected status %d %s: %w", r.StatusCode, r.Status, derrors.ProxyError)
 	default:
 		return fmt.Errorf("unexpected status %d %s", r.StatusCode, r.Status)
 	}
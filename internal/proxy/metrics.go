@@ -0,0 +1,103 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/pkgsite/internal/dcensus"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+var (
+	// keyUpstream is a census tag for the URL of an upstream proxy.
+	keyUpstream = tag.MustNewKey("proxy.upstream")
+	// keyUpstreamStatus is a census tag for the status of a request to an
+	// upstream proxy, as returned by derrors.ToStatus.
+	keyUpstreamStatus = tag.MustNewKey("proxy.upstream.status")
+
+	upstreamLatency = stats.Float64(
+		"go-discovery/proxy/upstream-latency",
+		"Latency of a request to an upstream proxy.",
+		stats.UnitMilliseconds,
+	)
+
+	// UpstreamLatencyDistribution aggregates the latency of requests to
+	// upstream proxies by upstream and status.
+	UpstreamLatencyDistribution = &view.View{
+		Name:        "go-discovery/proxy/upstream-latency",
+		Measure:     upstreamLatency,
+		Aggregation: ochttp.DefaultLatencyDistribution,
+		Description: "Upstream proxy request latency, by upstream and status.",
+		TagKeys:     []tag.Key{keyUpstream, keyUpstreamStatus},
+	}
+	// UpstreamResponseCount counts requests to upstream proxies by upstream
+	// and status.
+	UpstreamResponseCount = &view.View{
+		Name:        "go-discovery/proxy/upstream-count",
+		Measure:     upstreamLatency,
+		Aggregation: view.Count(),
+		Description: "Upstream proxy request count, by upstream and status.",
+		TagKeys:     []tag.Key{keyUpstream, keyUpstreamStatus},
+	}
+)
+
+// recordUpstreamRequest records the latency and result of a request to the
+// upstream proxy at upstreamURL.
+func recordUpstreamRequest(ctx context.Context, upstreamURL string, latency time.Duration, err error) {
+	stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(keyUpstream, upstreamLabel(upstreamURL)),
+		tag.Upsert(keyUpstreamStatus, strconv.Itoa(derrors.ToStatus(err))),
+	}, upstreamLatency.M(float64(latency.Milliseconds())))
+}
+
+// upstreamLabel returns upstreamURL without any user information or query,
+// which may hold credentials that must not appear in metrics.
+func upstreamLabel(upstreamURL string) string {
+	u, err := url.Parse(upstreamURL)
+	if err != nil {
+		return "invalid"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+const (
+	cacheHit     = "hit"
+	cacheMiss    = "miss"
+	cacheCorrupt = "corrupt"
+	cacheEvicted = "evicted"
+)
+
+var (
+	keyCacheResult = tag.MustNewKey("proxy.cache.result")
+	cacheResults   = stats.Int64(
+		"go-discovery/proxy/cache-count",
+		"The results of proxy disk cache operations.",
+		stats.UnitDimensionless,
+	)
+	// DiskCacheResultCount counts proxy disk cache hits, misses, corrupt
+	// entries and evictions.
+	DiskCacheResultCount = &view.View{
+		Name:        "go-discovery/proxy/cache-count",
+		Measure:     cacheResults,
+		Aggregation: view.Count(),
+		Description: "Proxy disk cache results, by result.",
+		TagKeys:     []tag.Key{keyCacheResult},
+	}
+)
+
+func recordCacheResult(ctx context.Context, result string) {
+	dcensus.RecordWithTag(ctx, keyCacheResult, result, cacheResults.M(1))
+}
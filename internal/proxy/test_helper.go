@@ -5,6 +5,7 @@
 package proxy
 
 import (
+	"net/http"
 	"testing"
 
 	"golang.org/x/pkgsite/internal/testing/testhelper"
@@ -29,8 +30,14 @@ func SetupTestClient(t *testing.T, modules []*Module) (*Client, func()) {
 // NewClientForServer starts serving proxyMux locally. It returns a client to the
 // server and a function to shut down the server.
 func NewClientForServer(s *Server) (*Client, func(), error) {
+	return newClientForHandler(s.mux)
+}
+
+// newClientForHandler starts serving h locally. It returns a client to the
+// server and a function to shut down the server.
+func newClientForHandler(h http.Handler) (*Client, func(), error) {
 	// override client.httpClient to skip TLS verification
-	httpClient, proxy, serverClose := testhelper.SetupTestClientAndServer(s.mux)
+	httpClient, proxy, serverClose := testhelper.SetupTestClientAndServer(h)
 	client, err := New(proxy.URL)
 	if err != nil {
 		return nil, nil, err
@@ -0,0 +1,150 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/pkgsite/internal/config"
+)
+
+// A Priority is the class of a fetch request. Each priority is served by its
+// own lane, so that fetches of one priority do not wait behind a backlog of
+// fetches of another.
+type Priority int
+
+const (
+	// PriorityInteractive is for fetches requested by a user, who is waiting
+	// for the result.
+	PriorityInteractive Priority = iota
+	// PriorityIndexPoll is for fetches of module versions that are new to the
+	// module index.
+	PriorityIndexPoll
+	// PriorityReprocess is for fetches of module versions that have been
+	// processed before, such as retries and reprocessing after a deploy.
+	PriorityReprocess
+)
+
+// Priorities lists all priorities, from highest to lowest.
+var Priorities = []Priority{PriorityInteractive, PriorityIndexPoll, PriorityReprocess}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return config.QueueLaneInteractive
+	case PriorityIndexPoll:
+		return config.QueueLaneIndexPoll
+	case PriorityReprocess:
+		return config.QueueLaneReprocess
+	default:
+		return "unknown"
+	}
+}
+
+// lanePriorities converts a map keyed by queue lane, such as
+// config.Config.WorkerLaneQueues, to one keyed by the priority of each lane.
+// Unknown lanes are ignored.
+func lanePriorities(m map[string]string) map[Priority]string {
+	pm := map[Priority]string{}
+	for _, p := range Priorities {
+		if v, ok := m[p.String()]; ok {
+			pm[p] = v
+		}
+	}
+	return pm
+}
+
+// laneWeights returns the weight of each priority's lane from weights, which
+// is keyed by lane like config.Config.QueueLaneWeights. Lanes without a
+// weight get the default one.
+func laneWeights(weights map[string]int) map[Priority]int {
+	defaults := config.DefaultQueueLaneWeights()
+	pw := map[Priority]int{}
+	for _, p := range Priorities {
+		w, ok := weights[p.String()]
+		if !ok || w <= 0 {
+			w = defaults[p.String()]
+		}
+		pw[p] = w
+	}
+	return pw
+}
+
+// laneWorkers divides workerCount workers among the lanes in proportion to
+// weights. Every lane gets at least one worker, so that it can make progress,
+// which gives the lanes more workers in total than workerCount when
+// workerCount is less than the number of lanes. Workers left over after the
+// division go to the lanes in priority order. The in-memory queue also limits
+// the number of fetches in all lanes to workerCount.
+func laneWorkers(workerCount int, weights map[Priority]int) map[Priority]int {
+	total := 0
+	for _, p := range Priorities {
+		total += weights[p]
+	}
+	n := map[Priority]int{}
+	assigned := 0
+	for _, p := range Priorities {
+		n[p] = workerCount * weights[p] / total
+		if n[p] < 1 {
+			n[p] = 1
+		}
+		assigned += n[p]
+	}
+	for i := 0; assigned < workerCount; i++ {
+		n[Priorities[i%len(Priorities)]]++
+		assigned++
+	}
+	return n
+}
+
+var (
+	keyPriority = tag.MustNewKey("queue.priority")
+
+	enqueueCount = stats.Int64(
+		"go-discovery/queue/enqueue-count",
+		"The number of fetches enqueued.",
+		stats.UnitDimensionless,
+	)
+	// EnqueueCount counts fetches enqueued by priority.
+	EnqueueCount = &view.View{
+		Name:        "go-discovery/queue/enqueue-count",
+		Measure:     enqueueCount,
+		Aggregation: view.Count(),
+		Description: "Fetches enqueued, by priority.",
+		TagKeys:     []tag.Key{keyPriority},
+	}
+
+	waitLatency = stats.Float64(
+		"go-discovery/queue/wait-latency",
+		"Time between enqueuing a fetch and starting it, for the in-memory queue.",
+		stats.UnitMilliseconds,
+	)
+	// WaitLatencyDistribution aggregates the time fetches spend waiting in
+	// the in-memory queue by priority.
+	WaitLatencyDistribution = &view.View{
+		Name:        "go-discovery/queue/wait-latency",
+		Measure:     waitLatency,
+		Aggregation: ochttp.DefaultLatencyDistribution,
+		Description: "In-memory queue wait latency, by priority.",
+		TagKeys:     []tag.Key{keyPriority},
+	}
+
+	inFlight = stats.Int64(
+		"go-discovery/queue/in-flight",
+		"The number of fetches being processed by the in-memory queue.",
+		stats.UnitDimensionless,
+	)
+	// InFlightCount reports the number of fetches being processed by each
+	// lane of the in-memory queue.
+	InFlightCount = &view.View{
+		Name:        "go-discovery/queue/in-flight",
+		Measure:     inFlight,
+		Aggregation: view.LastValue(),
+		Description: "In-memory fetches in flight, by priority.",
+		TagKeys:     []tag.Key{keyPriority},
+	}
+)
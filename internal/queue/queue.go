@@ -16,6 +16,7 @@ import (
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
 	"github.com/golang/protobuf/ptypes"
 	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/dcensus"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/log"
@@ -27,12 +28,15 @@ import (
 
 // A Queue provides an interface for asynchronous scheduling of fetch actions.
 type Queue interface {
-	ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) (bool, error)
+	ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration, priority Priority) (bool, error)
 }
 
 // New creates a new Queue with name queueName based on the configuration
-// in cfg. When running locally, Queue uses numWorkers concurrent workers.
-func New(ctx context.Context, cfg *config.Config, queueName string, numWorkers int, expGetter middleware.ExperimentGetter, processFunc inMemoryProcessFunc) (Queue, error) {
+// in cfg. Fetches in a lane that has a queue in laneQueues, such as
+// cfg.WorkerLaneQueues, are enqueued on that queue instead of queueName. When
+// running locally, Queue uses numWorkers concurrent workers, divided among the
+// priority lanes according to cfg.QueueLaneWeights.
+func New(ctx context.Context, cfg *config.Config, queueName string, laneQueues map[string]string, numWorkers int, expGetter middleware.ExperimentGetter, processFunc inMemoryProcessFunc) (Queue, error) {
 	if !cfg.OnGCP() {
 		experiments, err := expGetter(ctx)
 		if err != nil {
@@ -44,18 +48,21 @@ func New(ctx context.Context, cfg *config.Config, queueName string, numWorkers i
 				names = append(names, e.Name)
 			}
 		}
-		return NewInMemory(ctx, numWorkers, names, processFunc), nil
+		return newInMemory(ctx, numWorkers, laneWeights(cfg.QueueLaneWeights), names, processFunc), nil
 	}
 
 	client, err := cloudtasks.NewClient(ctx)
 	if err != nil {
 		return nil, err
 	}
-	g, err := newGCP(cfg, client, queueName)
+	g, err := newGCP(cfg, client, queueName, lanePriorities(laneQueues))
 	if err != nil {
 		return nil, err
 	}
 	log.Infof(ctx, "enqueuing at %s with queueService=%q, queueURL=%q", g.queueName, g.queueService, g.queueURL)
+	for p, q := range g.laneQueueNames {
+		log.Infof(ctx, "enqueuing %s fetches at %s", p, q)
+	}
 	return g, nil
 }
 
@@ -66,6 +73,9 @@ type GCP struct {
 	queueName    string // full GCP name of the queue
 	queueService string // AppEngine service to post tasks to
 	queueURL     string // non-AppEngine URL to post tasks to
+	// laneQueueNames holds the full GCP names of the queues for priorities
+	// that do not use queueName.
+	laneQueueNames map[Priority]string
 	// token holds information that lets the task queue construct an authorized request to the worker.
 	// Since the worker sits behind the IAP, the queue needs an identity token that includes the
 	// identity of a service account that has access, and the client ID for the IAP.
@@ -75,9 +85,10 @@ type GCP struct {
 
 // NewGCP returns a new Queue that can be used to enqueue tasks using the
 // cloud tasks API.  The given queueID should be the name of the queue in the
-// cloud tasks console.
-func newGCP(cfg *config.Config, client *cloudtasks.Client, queueID string) (_ *GCP, err error) {
-	defer derrors.Wrap(&err, "newGCP(cfg, client, %q)", queueID)
+// cloud tasks console. laneQueueIDs holds the names of the queues for
+// priorities that have their own.
+func newGCP(cfg *config.Config, client *cloudtasks.Client, queueID string, laneQueueIDs map[Priority]string) (_ *GCP, err error) {
+	defer derrors.Wrap(&err, "newGCP(cfg, client, %q, %v)", queueID, laneQueueIDs)
 	if queueID == "" {
 		return nil, errors.New("empty queueID")
 	}
@@ -104,11 +115,21 @@ func newGCP(cfg *config.Config, client *cloudtasks.Client, queueID string) (_ *G
 			return nil, errors.New("need QueueAudience with QueueURL")
 		}
 	}
+	queueName := func(id string) string {
+		return fmt.Sprintf("projects/%s/locations/%s/queues/%s", cfg.ProjectID, cfg.LocationID, id)
+	}
+	laneQueueNames := map[Priority]string{}
+	for p, id := range laneQueueIDs {
+		if id != "" && id != queueID {
+			laneQueueNames[p] = queueName(id)
+		}
+	}
 	return &GCP{
-		client:       client,
-		queueName:    fmt.Sprintf("projects/%s/locations/%s/queues/%s", cfg.ProjectID, cfg.LocationID, queueID),
-		queueService: cfg.QueueService,
-		queueURL:     cfg.QueueURL,
+		client:         client,
+		queueName:      queueName(queueID),
+		queueService:   cfg.QueueService,
+		queueURL:       cfg.QueueURL,
+		laneQueueNames: laneQueueNames,
 		token: &taskspb.HttpRequest_OidcToken{
 			OidcToken: &taskspb.OidcToken{
 				ServiceAccountEmail: cfg.ServiceAccount,
@@ -119,15 +140,16 @@ func newGCP(cfg *config.Config, client *cloudtasks.Client, queueID string) (_ *G
 }
 
 // ScheduleFetch enqueues a task on GCP to fetch the given modulePath and
-// version. It returns an error if there was an error hashing the task name, or
-// an error pushing the task to GCP. If the task was a duplicate, it returns (false, nil).
-func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) (enqueued bool, err error) {
+// version, on the queue for the given priority. It returns an error if there
+// was an error hashing the task name, or an error pushing the task to GCP. If
+// the task was a duplicate, it returns (false, nil).
+func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration, priority Priority) (enqueued bool, err error) {
 	// the new taskqueue API requires a deadline of <= 30s
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
-	defer derrors.Wrap(&err, "queue.ScheduleFetch(%q, %q, %q, %d)", modulePath, version, suffix, taskIDChangeInterval)
+	defer derrors.Wrap(&err, "queue.ScheduleFetch(%q, %q, %q, %d, %s)", modulePath, version, suffix, taskIDChangeInterval, priority)
 
-	req := q.newTaskRequest(modulePath, version, suffix, taskIDChangeInterval)
+	req := q.newTaskRequest(modulePath, version, suffix, taskIDChangeInterval, priority)
 	enqueued = true
 	if _, err := q.client.CreateTask(ctx, req); err != nil {
 		if status.Code(err) == codes.AlreadyExists {
@@ -137,6 +159,9 @@ func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix str
 			return false, fmt.Errorf("q.client.CreateTask(ctx, req): %v", err)
 		}
 	}
+	if enqueued {
+		dcensus.RecordWithTag(ctx, keyPriority, priority.String(), enqueueCount.M(1))
+	}
 	return enqueued, nil
 }
 
@@ -144,11 +169,20 @@ func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix str
 // See https://cloud.google.com/tasks/docs/creating-http-target-tasks.
 const maxCloudTasksTimeout = 30 * time.Minute
 
-func (q *GCP) newTaskRequest(modulePath, version, suffix string, taskIDChangeInterval time.Duration) *taskspb.CreateTaskRequest {
+// queueFor returns the full GCP name of the queue for priority.
+func (q *GCP) queueFor(priority Priority) string {
+	if n, ok := q.laneQueueNames[priority]; ok {
+		return n
+	}
+	return q.queueName
+}
+
+func (q *GCP) newTaskRequest(modulePath, version, suffix string, taskIDChangeInterval time.Duration, priority Priority) *taskspb.CreateTaskRequest {
+	queueName := q.queueFor(priority)
 	taskID := newTaskID(modulePath, version, time.Now(), taskIDChangeInterval)
 	relativeURI := fmt.Sprintf("/fetch/%s/@v/%s", modulePath, version)
 	task := &taskspb.Task{
-		Name:             fmt.Sprintf("%s/tasks/%s", q.queueName, taskID),
+		Name:             fmt.Sprintf("%s/tasks/%s", queueName, taskID),
 		DispatchDeadline: ptypes.DurationProto(maxCloudTasksTimeout),
 	}
 	if q.queueService != "" {
@@ -171,7 +205,7 @@ func (q *GCP) newTaskRequest(modulePath, version, suffix string, taskIDChangeInt
 		}
 	}
 	req := &taskspb.CreateTaskRequest{
-		Parent: q.queueName,
+		Parent: queueName,
 		Task:   task,
 	}
 	// If suffix is non-empty, append it to the task name. This lets us force reprocessing
@@ -197,74 +231,121 @@ func newTaskID(modulePath, version string, now time.Time, taskIDChangeInterval t
 
 type moduleVersion struct {
 	modulePath, version string
+	enqueued            time.Time
 }
 
 // InMemory is a Queue implementation that schedules in-process fetch
 // operations. Unlike the GCP task queue, it will not automatically retry tasks
 // on failure.
 //
+// Each priority has its own lane with a fixed share of the workers, so that
+// interactive fetches are never stuck behind a backlog of lower-priority
+// ones. No more than the total number of workers run at once.
+//
 // This should only be used for local development.
 type InMemory struct {
-	queue       chan moduleVersion
-	sem         chan struct{}
+	lanes       map[Priority]*lane
 	experiments []string
 }
 
+// A lane holds the fetches of one priority and limits their concurrency.
+type lane struct {
+	queue   chan moduleVersion
+	sem     chan struct{}
+	workers chan struct{} // shared by all lanes
+}
+
 type inMemoryProcessFunc func(context.Context, string, string) (int, error)
 
 // NewInMemory creates a new InMemory that asynchronously fetches
 // from proxyClient and stores in db. It uses workerCount parallelism to
-// execute these fetches.
+// execute these fetches, divided among the priority lanes according to
+// config.DefaultQueueLaneWeights.
 func NewInMemory(ctx context.Context, workerCount int, experiments []string, processFunc inMemoryProcessFunc) *InMemory {
+	return newInMemory(ctx, workerCount, laneWeights(nil), experiments, processFunc)
+}
+
+func newInMemory(ctx context.Context, workerCount int, weights map[Priority]int, experiments []string, processFunc inMemoryProcessFunc) *InMemory {
 	q := &InMemory{
-		queue:       make(chan moduleVersion, 1000),
-		sem:         make(chan struct{}, workerCount),
+		lanes:       map[Priority]*lane{},
 		experiments: experiments,
 	}
-	go func() {
-		for v := range q.queue {
-			select {
-			case <-ctx.Done():
-				return
-			case q.sem <- struct{}{}:
-			}
+	workers := make(chan struct{}, workerCount)
+	for p, n := range laneWorkers(workerCount, weights) {
+		l := &lane{
+			queue:   make(chan moduleVersion, 1000),
+			sem:     make(chan struct{}, n),
+			workers: workers,
+		}
+		q.lanes[p] = l
+		go l.run(ctx, p, experiments, processFunc)
+	}
+	return q
+}
 
-			// If a worker is available, make a request to the fetch service inside a
-			// goroutine and wait for it to finish.
-			go func(v moduleVersion) {
-				defer func() { <-q.sem }()
+// run processes the fetches in the lane until its queue is closed or ctx is
+// done.
+func (l *lane) run(ctx context.Context, priority Priority, experiments []string, processFunc inMemoryProcessFunc) {
+	for v := range l.queue {
+		select {
+		case <-ctx.Done():
+			return
+		case l.sem <- struct{}{}:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case l.workers <- struct{}{}:
+		}
 
-				log.Infof(ctx, "Fetch requested: %q %q (workerCount = %d)", v.modulePath, v.version, cap(q.sem))
+		// If a worker is available, make a request to the fetch service inside a
+		// goroutine and wait for it to finish.
+		go func(v moduleVersion) {
+			defer func() {
+				<-l.workers
+				<-l.sem
+				dcensus.RecordWithTag(ctx, keyPriority, priority.String(), inFlight.M(int64(len(l.sem))))
+			}()
+			dcensus.RecordWithTag(ctx, keyPriority, priority.String(), inFlight.M(int64(len(l.sem))))
+			dcensus.RecordWithTag(ctx, keyPriority, priority.String(),
+				waitLatency.M(float64(time.Since(v.enqueued).Milliseconds())))
 
-				fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-				fetchCtx = experiment.NewContext(fetchCtx, experiments...)
-				defer cancel()
+			log.Infof(ctx, "Fetch requested: %q %q (priority = %s, workerCount = %d)", v.modulePath, v.version, priority, cap(l.sem))
 
-				if _, err := processFunc(fetchCtx, v.modulePath, v.version); err != nil {
-					log.Error(fetchCtx, err)
-				}
-			}(v)
-		}
-	}()
-	return q
+			fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			fetchCtx = experiment.NewContext(fetchCtx, experiments...)
+			defer cancel()
+
+			if _, err := processFunc(fetchCtx, v.modulePath, v.version); err != nil {
+				log.Error(fetchCtx, err)
+			}
+		}(v)
+	}
 }
 
-// ScheduleFetch pushes a fetch task into the local queue to be processed
-// asynchronously.
-func (q *InMemory) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) (bool, error) {
-	q.queue <- moduleVersion{modulePath, version}
+// ScheduleFetch pushes a fetch task into the local queue for the given
+// priority to be processed asynchronously.
+func (q *InMemory) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration, priority Priority) (bool, error) {
+	l, ok := q.lanes[priority]
+	if !ok {
+		return false, fmt.Errorf("queue.ScheduleFetch(%q, %q): unknown priority %d: %w", modulePath, version, priority, derrors.InvalidArgument)
+	}
+	l.queue <- moduleVersion{modulePath, version, time.Now()}
+	dcensus.RecordWithTag(ctx, keyPriority, priority.String(), enqueueCount.M(1))
 	return true, nil
 }
 
 // WaitForTesting waits for all queued requests to finish. It should only be
 // used by test code.
 func (q InMemory) WaitForTesting(ctx context.Context) {
-	for i := 0; i < cap(q.sem); i++ {
-		select {
-		case <-ctx.Done():
-			return
-		case q.sem <- struct{}{}:
+	for _, l := range q.lanes {
+		for i := 0; i < cap(l.sem); i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case l.sem <- struct{}{}:
+			}
 		}
+		close(l.queue)
 	}
-	close(q.queue)
 }
@@ -5,6 +5,9 @@
 package queue
 
 import (
+	"context"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -94,11 +97,11 @@ func TestNewTaskRequest(t *testing.T) {
 		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
-			gcp, err := newGCP(&test.cfg, nil, "queueID")
+			gcp, err := newGCP(&test.cfg, nil, "queueID", nil)
 			if err != nil {
 				t.Fatal(err)
 			}
-			got := gcp.newTaskRequest("mod", "v1.2.3", "suf", time.Minute)
+			got := gcp.newTaskRequest("mod", "v1.2.3", "suf", time.Minute, PriorityInteractive)
 			test.want.Task.Name = got.Task.Name
 			if diff := cmp.Diff(test.want, got, cmp.Comparer(proto.Equal)); diff != "" {
 				t.Errorf("mismatch (-want, +got):\n%s", diff)
@@ -106,3 +109,146 @@ func TestNewTaskRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestNewTaskRequestLaneQueue(t *testing.T) {
+	cfg := &config.Config{
+		ProjectID:    "Project",
+		LocationID:   "us-central1",
+		QueueService: "Service",
+	}
+	gcp, err := newGCP(cfg, nil, "queueID", map[Priority]string{PriorityInteractive: "interactiveID"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		priority Priority
+		want     string
+	}{
+		{PriorityInteractive, "projects/Project/locations/us-central1/queues/interactiveID"},
+		{PriorityIndexPoll, "projects/Project/locations/us-central1/queues/queueID"},
+		{PriorityReprocess, "projects/Project/locations/us-central1/queues/queueID"},
+	} {
+		got := gcp.newTaskRequest("mod", "v1.2.3", "", time.Minute, test.priority)
+		if got.Parent != test.want {
+			t.Errorf("%s: got parent %q, want %q", test.priority, got.Parent, test.want)
+		}
+		if !strings.HasPrefix(got.Task.Name, test.want+"/tasks/") {
+			t.Errorf("%s: got task name %q, want prefix %q", test.priority, got.Task.Name, test.want)
+		}
+	}
+}
+
+func TestLaneWorkers(t *testing.T) {
+	defaults := laneWeights(nil)
+	for _, test := range []struct {
+		workerCount int
+		weights     map[Priority]int
+		want        map[Priority]int
+	}{
+		{1, defaults, map[Priority]int{PriorityInteractive: 1, PriorityIndexPoll: 1, PriorityReprocess: 1}},
+		{5, defaults, map[Priority]int{PriorityInteractive: 3, PriorityIndexPoll: 1, PriorityReprocess: 1}},
+		{10, defaults, map[Priority]int{PriorityInteractive: 6, PriorityIndexPoll: 2, PriorityReprocess: 2}},
+		{20, defaults, map[Priority]int{PriorityInteractive: 10, PriorityIndexPoll: 5, PriorityReprocess: 5}},
+		{10, laneWeights(map[string]int{"interactive": 1, "reprocess": 3}),
+			map[Priority]int{PriorityInteractive: 2, PriorityIndexPoll: 2, PriorityReprocess: 6}},
+	} {
+		got := laneWorkers(test.workerCount, test.weights)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("laneWorkers(%d, %v) mismatch (-want, +got):\n%s", test.workerCount, test.weights, diff)
+		}
+	}
+}
+
+func TestLanePriorities(t *testing.T) {
+	got := lanePriorities(map[string]string{"interactive": "iq", "reprocess": "rq", "other": "oq"})
+	want := map[Priority]string{PriorityInteractive: "iq", PriorityReprocess: "rq"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestInMemoryLanes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Block all reprocess fetches, and check that an interactive fetch
+	// still completes.
+	unblock := make(chan struct{})
+	done := make(chan string, 10)
+	q := NewInMemory(ctx, 4, nil, func(ctx context.Context, modulePath, version string) (int, error) {
+		if strings.HasPrefix(modulePath, "reprocess") {
+			<-unblock
+		}
+		done <- modulePath
+		return 200, nil
+	})
+	for i := 0; i < 5; i++ {
+		if _, err := q.ScheduleFetch(ctx, "reprocess", "v1.0.0", "", time.Minute, PriorityReprocess); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.ScheduleFetch(ctx, "interactive", "v1.0.0", "", time.Minute, PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-done:
+		if got != "interactive" {
+			t.Errorf("got %q to finish first, want interactive", got)
+		}
+	case <-ctx.Done():
+		t.Fatal("interactive fetch did not finish")
+	}
+	close(unblock)
+	q.WaitForTesting(ctx)
+
+	if _, err := q.ScheduleFetch(ctx, "m", "v1.0.0", "", time.Minute, Priority(99)); err == nil {
+		t.Error("got nil error for unknown priority, want error")
+	}
+}
+
+func TestInMemoryWorkerLimit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Each lane has a worker, but only one fetch may run at a time.
+	var (
+		mu               sync.Mutex
+		running, maxRuns int
+	)
+	done := make(chan struct{}, 10)
+	q := NewInMemory(ctx, 1, nil, func(ctx context.Context, modulePath, version string) (int, error) {
+		mu.Lock()
+		running++
+		if running > maxRuns {
+			maxRuns = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		done <- struct{}{}
+		return 200, nil
+	})
+	n := 0
+	for _, p := range Priorities {
+		for i := 0; i < 2; i++ {
+			if _, err := q.ScheduleFetch(ctx, "m", "v1.0.0", "", time.Minute, p); err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			t.Fatal("fetches did not finish")
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if maxRuns != 1 {
+		t.Errorf("got %d concurrent fetches, want 1", maxRuns)
+	}
+}
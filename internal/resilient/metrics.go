@@ -0,0 +1,98 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package resilient
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// Events recorded by a Transport, other than attempts.
+const (
+	eventRetry      = "retry"
+	eventHedge      = "hedge"
+	eventBreakerRed = "breaker-red"
+	eventLimited    = "limited"
+)
+
+var (
+	// keyClient is a census tag for the name of the client. Requests are
+	// not tagged with their host, since a client may send requests to any
+	// number of hosts, and each would add a time series.
+	keyClient = tag.MustNewKey("resilient.client")
+	// keyResult is a census tag for the status code of an attempt, "error"
+	// if it failed without a response, or the kind of event.
+	keyResult = tag.MustNewKey("resilient.result")
+
+	attemptLatency = stats.Float64(
+		"go-discovery/resilient/attempt-latency",
+		"Latency of a single attempt at an outbound request.",
+		stats.UnitMilliseconds,
+	)
+	events = stats.Int64(
+		"go-discovery/resilient/events",
+		"Retries, hedged requests, and requests stopped or delayed by a breaker or concurrency limit.",
+		stats.UnitDimensionless,
+	)
+
+	// AttemptLatencyDistribution aggregates the latency of outbound request
+	// attempts by client and result.
+	AttemptLatencyDistribution = &view.View{
+		Name:        "go-discovery/resilient/attempt-latency",
+		Measure:     attemptLatency,
+		Aggregation: ochttp.DefaultLatencyDistribution,
+		Description: "Outbound request attempt latency, by client and result.",
+		TagKeys:     []tag.Key{keyClient, keyResult},
+	}
+	// AttemptCount counts outbound request attempts by client and result.
+	AttemptCount = &view.View{
+		Name:        "go-discovery/resilient/attempt-count",
+		Measure:     attemptLatency,
+		Aggregation: view.Count(),
+		Description: "Outbound request attempt count, by client and result.",
+		TagKeys:     []tag.Key{keyClient, keyResult},
+	}
+	// EventCount counts retries, hedged requests, and requests stopped by a
+	// red breaker or delayed by a concurrency limit, by client and event.
+	EventCount = &view.View{
+		Name:        "go-discovery/resilient/event-count",
+		Measure:     events,
+		Aggregation: view.Count(),
+		Description: "Outbound request events, by client and event.",
+		TagKeys:     []tag.Key{keyClient, keyResult},
+	}
+
+	// Views are all the views of this package.
+	Views = []*view.View{
+		AttemptLatencyDistribution,
+		AttemptCount,
+		EventCount,
+	}
+)
+
+func (t *Transport) recordAttempt(ctx context.Context, resp *http.Response, err error, latency time.Duration) {
+	result := "error"
+	if err == nil {
+		result = strconv.Itoa(resp.StatusCode)
+	}
+	stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(keyClient, t.cfg.Name),
+		tag.Upsert(keyResult, result),
+	}, attemptLatency.M(float64(latency.Milliseconds())))
+}
+
+func (t *Transport) recordEvent(ctx context.Context, event string) {
+	stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(keyClient, t.cfg.Name),
+		tag.Upsert(keyResult, event),
+	}, events.M(1))
+}
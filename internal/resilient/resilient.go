@@ -0,0 +1,338 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package resilient provides an HTTP transport for outbound requests that
+// copes with slow and failing hosts. For each host it keeps a circuit
+// breaker and limits the number of requests in flight, and it retries and
+// hedges idempotent requests.
+package resilient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"golang.org/x/pkgsite/internal/breaker"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// ErrBreakerRed is returned for requests to a host whose circuit breaker is in
+// the red state.
+var ErrBreakerRed = errors.New("circuit breaker is red")
+
+// Config holds the configuration values for a Transport.
+type Config struct {
+	// Name identifies the client in metrics, for example "proxy".
+	Name string
+	// Base is the transport that sends the requests. If nil, an
+	// ochttp.Transport is used.
+	Base http.RoundTripper
+	// Breaker configures the circuit breaker kept for each host. Zero fields
+	// take their values from DefaultBreakerConfig.
+	Breaker breaker.Config
+	// MaxRetries is the number of times an idempotent request is retried
+	// after a network error or a 429 or 5xx response.
+	MaxRetries int
+	// RetryDelay is the longest wait before the first retry. It doubles with
+	// each retry, up to MaxRetryDelay. The actual wait is chosen at random
+	// below it, so that clients that failed together do not retry together.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// HedgeDelay, if positive, is how long to wait for the response to an
+	// idempotent request before sending it again. The first of the two
+	// responses is used.
+	HedgeDelay time.Duration
+	// MaxConcurrentPerHost, if positive, is the maximum number of requests in
+	// flight to each host. A request is in flight until its response body is
+	// closed.
+	MaxConcurrentPerHost int
+}
+
+// DefaultBreakerConfig is the circuit breaker configuration used for the zero
+// fields of Config.Breaker.
+var DefaultBreakerConfig = breaker.Config{
+	FailsToRed:       10,
+	FailureThreshold: 0.5,
+	GreenInterval:    10 * time.Second,
+	MinTimeout:       30 * time.Second,
+	MaxTimeout:       4 * time.Minute,
+	SuccsToGreen:     20,
+}
+
+// Transport is an http.RoundTripper that guards each host with a circuit
+// breaker and a concurrency limit, and retries and hedges idempotent
+// requests. Its zero value is not usable; call New.
+type Transport struct {
+	cfg Config
+
+	mu    sync.Mutex
+	hosts map[string]*host
+}
+
+// host holds the per-host state of a Transport.
+type host struct {
+	breaker *breaker.Breaker
+	// sem holds a value for each request in flight, if the number of
+	// requests in flight is limited.
+	sem chan struct{}
+}
+
+// New returns a Transport with the given configuration.
+func New(cfg Config) (_ *Transport, err error) {
+	defer derrors.Wrap(&err, "resilient.New(%q)", cfg.Name)
+
+	if cfg.Base == nil {
+		cfg.Base = &ochttp.Transport{}
+	}
+	b := &cfg.Breaker
+	d := DefaultBreakerConfig
+	if b.FailsToRed == 0 {
+		b.FailsToRed = d.FailsToRed
+	}
+	if b.FailureThreshold == 0 {
+		b.FailureThreshold = d.FailureThreshold
+	}
+	if b.GreenInterval == 0 {
+		b.GreenInterval = d.GreenInterval
+	}
+	if b.MinTimeout == 0 {
+		b.MinTimeout = d.MinTimeout
+	}
+	if b.MaxTimeout == 0 {
+		b.MaxTimeout = d.MaxTimeout
+	}
+	if b.SuccsToGreen == 0 {
+		b.SuccsToGreen = d.SuccsToGreen
+	}
+	// Check the breaker configuration now, rather than on the first request
+	// to each host.
+	if _, err := breaker.New(cfg.Breaker); err != nil {
+		return nil, err
+	}
+	if cfg.MaxRetryDelay < cfg.RetryDelay {
+		cfg.MaxRetryDelay = cfg.RetryDelay
+	}
+	return &Transport{cfg: cfg, hosts: map[string]*host{}}, nil
+}
+
+// Must is a helper that wraps a call to New and panics if the error is
+// non-nil. It is intended for configurations that are known to be valid.
+func Must(t *Transport, err error) *Transport {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// NewClient returns an HTTP client that uses a Transport with the given
+// configuration, and the given timeout for each request, including retries.
+func NewClient(cfg Config, timeout time.Duration) (_ *http.Client, err error) {
+	t, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: t, Timeout: timeout}, nil
+}
+
+func (t *Transport) host(name string) *host {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.hosts[name]
+	if h == nil {
+		// The configuration was checked by New.
+		b, _ := breaker.New(t.cfg.Breaker)
+		h = &host{breaker: b}
+		if t.cfg.MaxConcurrentPerHost > 0 {
+			h.sem = make(chan struct{}, t.cfg.MaxConcurrentPerHost)
+		}
+		t.hosts[name] = h
+	}
+	return h
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h := t.host(req.URL.Host)
+	if !idempotent(req) {
+		return t.attempt(req, h)
+	}
+	ctx := req.Context()
+	delay := t.cfg.RetryDelay
+	for n := 0; ; n++ {
+		resp, err := t.hedge(req, h)
+		if n >= t.cfg.MaxRetries || !retryable(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			// Drain the body so that the connection can be reused.
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		t.recordEvent(ctx, eventRetry)
+		if delay > 0 {
+			timer := time.NewTimer(time.Duration(rand.Int63n(int64(delay))))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+			delay *= 2
+			if delay > t.cfg.MaxRetryDelay {
+				delay = t.cfg.MaxRetryDelay
+			}
+		}
+	}
+}
+
+// hedge sends req to h. If HedgeDelay is positive and no response arrives
+// within it, hedge sends req again and returns the first response that is
+// not an error, cancelling the other request. Errors before HedgeDelay are
+// returned without hedging, leaving them to the retry policy.
+func (t *Transport) hedge(req *http.Request, h *host) (*http.Response, error) {
+	if t.cfg.HedgeDelay <= 0 {
+		return t.attempt(req, h)
+	}
+	type result struct {
+		i    int
+		resp *http.Response
+		err  error
+	}
+	var (
+		results = make(chan result, 2)
+		cancels []context.CancelFunc
+	)
+	launch := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		i := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := t.attempt(req.Clone(ctx), h)
+			results <- result{i, resp, err}
+		}()
+	}
+	launch()
+	pending := 1
+	timer := time.NewTimer(t.cfg.HedgeDelay)
+	defer timer.Stop()
+	hedgeC := timer.C
+	for {
+		select {
+		case <-hedgeC:
+			hedgeC = nil
+			t.recordEvent(req.Context(), eventHedge)
+			launch()
+			pending++
+		case r := <-results:
+			pending--
+			if r.err != nil && pending > 0 {
+				// Wait for the other request.
+				cancels[r.i]()
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != r.i {
+					cancel()
+				}
+			}
+			// Close the response to the losing request, if it arrives.
+			go func(n int) {
+				for ; n > 0; n-- {
+					if l := <-results; l.resp != nil {
+						l.resp.Body.Close()
+					}
+				}
+			}(pending)
+			if r.err != nil {
+				cancels[r.i]()
+				return nil, r.err
+			}
+			r.resp.Body = &closeFuncBody{ReadCloser: r.resp.Body, f: cancels[r.i]}
+			return r.resp, nil
+		}
+	}
+}
+
+// attempt sends req to h once, subject to h's circuit breaker and
+// concurrency limit.
+func (t *Transport) attempt(req *http.Request, h *host) (*http.Response, error) {
+	ctx := req.Context()
+	release := func() {}
+	if h.sem != nil {
+		select {
+		case h.sem <- struct{}{}:
+		default:
+			t.recordEvent(ctx, eventLimited)
+			select {
+			case h.sem <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		release = func() { <-h.sem }
+	}
+	if !h.breaker.Allow() {
+		release()
+		t.recordEvent(ctx, eventBreakerRed)
+		return nil, fmt.Errorf("%s: %w", req.URL.Host, ErrBreakerRed)
+	}
+	start := time.Now()
+	resp, err := t.cfg.Base.RoundTrip(req)
+	t.recordAttempt(ctx, resp, err, time.Since(start))
+	// Cancellation by the caller says nothing about the health of the host.
+	if ctx.Err() == nil || err == nil {
+		h.breaker.Record(err == nil && resp.StatusCode < http.StatusInternalServerError)
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &closeFuncBody{ReadCloser: resp.Body, f: release}
+	return resp, nil
+}
+
+// idempotent reports whether req can safely be sent more than once.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.Body == http.NoBody
+	default:
+		return false
+	}
+}
+
+// retryable reports whether a request that resulted in resp and err may
+// succeed if it is sent again.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, ErrBreakerRed) && !errors.Is(err, context.Canceled) &&
+			!errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return resp.StatusCode == http.StatusInternalServerError
+	}
+}
+
+// closeFuncBody is a response body that calls f once when it is closed.
+type closeFuncBody struct {
+	io.ReadCloser
+	once sync.Once
+	f    func()
+}
+
+func (b *closeFuncBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.f)
+	return err
+}
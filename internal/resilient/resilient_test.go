@@ -0,0 +1,149 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package resilient
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/pkgsite/internal/breaker"
+)
+
+func newTestClient(t *testing.T, cfg Config) *http.Client {
+	t.Helper()
+	cfg.Name = "test"
+	cfg.Base = http.DefaultTransport
+	c, err := NewClient(cfg, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRetry(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	c := newTestClient(t, Config{MaxRetries: 2, RetryDelay: time.Millisecond})
+	resp, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || n != 3 {
+		t.Errorf("got status %d after %d requests, want 200 after 3", resp.StatusCode, n)
+	}
+
+	// Requests that are not idempotent are sent once.
+	atomic.StoreInt32(&n, 0)
+	resp, err = c.Post(ts.URL, "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || n != 1 {
+		t.Errorf("POST: got status %d after %d requests, want 503 after 1", resp.StatusCode, n)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	c := newTestClient(t, Config{Breaker: breaker.Config{FailsToRed: 2, MinTimeout: time.Hour, MaxTimeout: time.Hour}})
+	for i := 0; i < 3; i++ {
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if _, err := c.Get(ts.URL); !errors.Is(err, ErrBreakerRed) {
+		t.Errorf("got %v, want ErrBreakerRed", err)
+	}
+	if n != 3 {
+		t.Errorf("server got %d requests, want 3", n)
+	}
+}
+
+func TestHedge(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			// Stall the first request until it is cancelled.
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("hedged"))
+	}))
+	defer ts.Close()
+
+	c := newTestClient(t, Config{HedgeDelay: 10 * time.Millisecond})
+	resp, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hedged" {
+		t.Errorf("got body %q, want %q", body, "hedged")
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	var inFlight, max int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cur := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if cur <= m || atomic.CompareAndSwapInt32(&max, m, cur) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	c := newTestClient(t, Config{MaxConcurrentPerHost: 2})
+	errc := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func() {
+			resp, err := c.Get(ts.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			errc <- err
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if max > 2 {
+		t.Errorf("got %d requests in flight, want at most 2", max)
+	}
+}
@@ -31,11 +31,11 @@ import (
 	"strings"
 	"time"
 
-	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/trace"
 	"golang.org/x/net/context/ctxhttp"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/resilient"
 	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/version"
 )
@@ -208,11 +208,22 @@ type Client struct {
 	httpClient *http.Client
 }
 
+// transportConfig configures the transport for requests to forges and
+// vanity import servers. Any of them may be slow or down, so requests are
+// hedged, and the number of concurrent requests to each host is limited.
+var transportConfig = resilient.Config{
+	Name:                 "source",
+	MaxRetries:           1,
+	RetryDelay:           250 * time.Millisecond,
+	HedgeDelay:           5 * time.Second,
+	MaxConcurrentPerHost: 10,
+}
+
 // New constructs a *Client using the provided timeout.
 func NewClient(timeout time.Duration) *Client {
 	return &Client{
 		httpClient: &http.Client{
-			Transport: &ochttp.Transport{},
+			Transport: resilient.Must(resilient.New(transportConfig)),
 			Timeout:   timeout,
 		},
 	}
@@ -253,7 +264,7 @@ func ModuleInfo(ctx context.Context, client *Client, modulePath, version string)
 	defer span.End()
 
 	if modulePath == stdlib.ModulePath {
-		commit, err := stdlib.TagForVersion(version)
+		commit, err := stdlib.RevisionForVersion(version)
 		if err != nil {
 			return nil, err
 		}
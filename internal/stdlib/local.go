@@ -0,0 +1,269 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package stdlib
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+var (
+	// localSourceDir is the directory passed to UseLocalSource.
+	localSourceDir string
+
+	// localRepo is the Go repository in localSourceDir, if it is a clone.
+	localRepo   *git.Repository
+	localRepoMu sync.Mutex
+
+	// tarballDir is localSourceDir, if it holds source tarballs.
+	tarballDir string
+
+	// zipCacheDir is the directory passed to SetZipCacheDir.
+	zipCacheDir string
+)
+
+// tarballSuffix ends the name of each source tarball, as on
+// https://golang.org/dl.
+const tarballSuffix = ".src.tar.gz"
+
+// UseLocalSource makes the package read the Go repository from dir instead of
+// cloning it from GoRepoURL. dir is either a clone of the Go repository, or a
+// directory of Go source tarballs named like those on https://golang.org/dl,
+// such as go1.15.2.src.tar.gz. A directory of tarballs has no master branch,
+// so tip cannot be fetched from it.
+//
+// The clone is read as is; keeping it up to date is up to the caller.
+// Calling UseLocalSource with the empty string restores cloning.
+func UseLocalSource(dir string) (err error) {
+	defer derrors.Wrap(&err, "UseLocalSource(%q)", dir)
+
+	localRepoMu.Lock()
+	defer localRepoMu.Unlock()
+	localSourceDir, localRepo, tarballDir = "", nil, ""
+	if dir == "" {
+		return nil
+	}
+	repo, err := git.PlainOpen(dir)
+	if err == nil {
+		localSourceDir, localRepo = dir, repo
+		return nil
+	}
+	if !errors.Is(err, git.ErrRepositoryNotExists) {
+		return err
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "go*"+tarballSuffix))
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("%s is neither a git repository nor a directory of Go source tarballs", dir)
+	}
+	localSourceDir, tarballDir = dir, dir
+	return nil
+}
+
+// tarballTags returns the Go tags of the source tarballs in tarballDir.
+func tarballTags() (_ []string, err error) {
+	defer derrors.Wrap(&err, "tarballTags()")
+
+	matches, err := filepath.Glob(filepath.Join(tarballDir, "go*"+tarballSuffix))
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, m := range matches {
+		tags = append(tags, strings.TrimSuffix(filepath.Base(m), tarballSuffix))
+	}
+	return tags, nil
+}
+
+// zipFromTarball returns the contents of the module zip for resolvedVersion,
+// built from its source tarball in tarballDir, and the time of the newest
+// file in the tarball, which stands in for the commit time.
+//
+// It includes the same files as zipFromRepo: the files at the top of the
+// repository, and everything under Directory(resolvedVersion) except testdata
+// directories and files whose names begin with "." or "_".
+func zipFromTarball(resolvedVersion string) (_ []byte, commitTime time.Time, err error) {
+	defer derrors.Wrap(&err, "zipFromTarball(%q)", resolvedVersion)
+
+	if IsTipVersion(resolvedVersion) {
+		return nil, time.Time{}, fmt.Errorf("%w: source tarballs in %s have no master branch", derrors.NotFound, tarballDir)
+	}
+	tag, err := TagForVersion(resolvedVersion)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	f, err := os.Open(filepath.Join(tarballDir, tag+tarballSuffix))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("%w: %v", derrors.NotFound, err)
+		}
+		return nil, time.Time{}, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer gz.Close()
+
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	prefixPath := ModulePath + "@" + resolvedVersion
+	libdir := Directory(resolvedVersion) + "/"
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if hdr.ModTime.After(commitTime) {
+			commitTime = hdr.ModTime
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		// Tarballs hold a single directory, "go".
+		name := hdr.Name
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			name = name[i+1:]
+		}
+		var rel string
+		switch {
+		case !strings.Contains(name, "/"):
+			rel = name
+		case strings.HasPrefix(name, libdir):
+			rel = strings.TrimPrefix(name, libdir)
+		default:
+			continue
+		}
+		// See the comment about README.vendor in addFiles.
+		if rel == "README.vendor" || !includeTarballFile(rel) {
+			continue
+		}
+		if err := writeZipFile(z, path.Join(prefixPath, rel), tr); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+	if err := z.Close(); err != nil {
+		return nil, time.Time{}, err
+	}
+	return buf.Bytes(), commitTime, nil
+}
+
+// includeTarballFile reports whether the file at rel, relative to the top of
+// the repository or the stdlib directory, belongs in the module zip. It
+// follows the same rules as addFiles.
+func includeTarballFile(rel string) bool {
+	parts := strings.Split(rel, "/")
+	for _, p := range parts {
+		if strings.HasPrefix(p, ".") || strings.HasPrefix(p, "_") {
+			return false
+		}
+	}
+	for _, dir := range parts[:len(parts)-1] {
+		if dir == "testdata" {
+			return false
+		}
+	}
+	return parts[len(parts)-1] != "go.mod"
+}
+
+// SetZipCacheDir makes Zip keep the zips it builds for Go releases, betas and
+// release candidates in dir, which is created if necessary. Zips for tip are
+// not cached, since each pseudo-version is usually fetched only once.
+// Calling SetZipCacheDir with the empty string turns caching off.
+func SetZipCacheDir(dir string) (err error) {
+	defer derrors.Wrap(&err, "SetZipCacheDir(%q)", dir)
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	zipCacheDir = dir
+	return nil
+}
+
+// cachedZipPath returns the file that caches the zip for version, or "" if
+// the zip should not be cached.
+func cachedZipPath(version string) string {
+	if zipCacheDir == "" || IsTipVersion(version) {
+		return ""
+	}
+	return filepath.Join(zipCacheDir, ModulePath+"@"+version+".zip")
+}
+
+// readCachedZip returns the contents of the cached zip for version and the
+// commit time, which is stored as the file's modification time. It returns
+// nil contents if the zip is not in the cache.
+func readCachedZip(version string) (_ []byte, commitTime time.Time, err error) {
+	defer derrors.Wrap(&err, "readCachedZip(%q)", version)
+
+	file := cachedZipPath(version)
+	if file == "" {
+		return nil, time.Time{}, nil
+	}
+	info, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, info.ModTime().UTC(), nil
+}
+
+// writeCachedZip stores the zip contents for version in the cache, with
+// commitTime as the file's modification time.
+func writeCachedZip(version string, data []byte, commitTime time.Time) (err error) {
+	defer derrors.Wrap(&err, "writeCachedZip(%q)", version)
+
+	file := cachedZipPath(version)
+	if file == "" {
+		return nil
+	}
+	// Write to a temporary file and rename it, so that concurrent readers
+	// never see a partial zip.
+	tmp, err := ioutil.TempFile(zipCacheDir, filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), commitTime, commitTime); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
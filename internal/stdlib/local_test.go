@@ -0,0 +1,304 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package stdlib
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
+)
+
+// copyTestData copies the files of testdata/version into dir.
+func copyTestData(t *testing.T, version, dir string) {
+	t.Helper()
+	src := filepath.Join(testhelper.TestDataPath("testdata"), version)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dir, rel), 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, rel), data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newFixtureGoRepo creates a Go repository in a temporary directory. Its
+// master branch has a commit tagged go1.12.5 with the files of
+// testdata/v1.12.5, followed by one tagged go1.14.6 with those of
+// testdata/v1.14.6, and an untagged commit at tip.
+func newFixtureGoRepo(t *testing.T) (dir string, cleanup func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "goroot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "Joe Random", Email: "joe@example.com", When: TestCommitTime}
+	commit := func(msg string) {
+		t.Helper()
+		if _, err := wt.Add(""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Commit(msg, &git.CommitOptions{All: true, Author: sig}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tag := range []string{"go1.12.5", "go1.14.6"} {
+		copyTestData(t, VersionForTag(tag), dir)
+		commit(tag)
+		head, err := repo.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.CreateTag(tag, head.Hash(), &git.CreateTagOptions{Tagger: sig, Message: tag}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sig.When = TestCommitTime.Add(time.Hour)
+	if err := ioutil.WriteFile(filepath.Join(dir, "src", "errors", "new.go"), []byte("package errors\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	commit("tip")
+	return dir, cleanup
+}
+
+// newFixtureTarballs creates a directory holding go1.14.6.src.tar.gz, a
+// source tarball with the files of testdata/v1.14.6.
+func newFixtureTarballs(t *testing.T) (dir string, cleanup func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "tarballs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	f, err := os.Create(filepath.Join(dir, "go1.14.6"+tarballSuffix))
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	src := filepath.Join(testhelper.TestDataPath("testdata"), "v1.14.6")
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join("go", rel))
+		hdr.ModTime = TestCommitTime
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return dir, cleanup
+}
+
+func zipFileNames(zr *zip.Reader) []string {
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestLocalGoRepo(t *testing.T) {
+	dir, cleanup := newFixtureGoRepo(t)
+	defer cleanup()
+	if err := UseLocalSource(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer UseLocalSource("")
+
+	got, err := Versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{"v1.12.5", "v1.14.6"}, got); diff != "" {
+		t.Errorf("Versions() mismatch (-want, +got):\n%s", diff)
+	}
+	latest, _, err := ZipInfo("latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != "v1.14.6" {
+		t.Errorf("ZipInfo(latest): got %q, want v1.14.6", latest)
+	}
+
+	zr, _, err := Zip("v1.12.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range zipFileNames(zr) {
+		if strings.HasSuffix(name, "/new.go") {
+			t.Errorf("v1.12.5 has %s, which was added at tip", name)
+		}
+	}
+
+	tip, _, err := ZipInfo("master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(tip, "v0.0.0-20190904020203-") {
+		t.Errorf("ZipInfo(master): got %q, want a pseudo-version an hour after TestCommitTime", tip)
+	}
+	zr, _, err = Zip(tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "std@" + tip + "/errors/new.go"
+	found := false
+	for _, name := range zipFileNames(zr) {
+		found = found || name == want
+	}
+	if !found {
+		t.Errorf("zip for tip is missing %s", want)
+	}
+}
+
+func TestTarballs(t *testing.T) {
+	dir, cleanup := newFixtureTarballs(t)
+	defer cleanup()
+	cacheDir, err := ioutil.TempDir("", "stdzips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	// The zip built from the test repo is the one to match.
+	UseTestData = true
+	wantZip, _, err := Zip("v1.14.6")
+	UseTestData = false
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := UseLocalSource(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer UseLocalSource("")
+	if err := SetZipCacheDir(cacheDir); err != nil {
+		t.Fatal(err)
+	}
+	defer SetZipCacheDir("")
+
+	got, err := Versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"v1.14.6"}, got); diff != "" {
+		t.Errorf("Versions() mismatch (-want, +got):\n%s", diff)
+	}
+	if _, _, err := ZipInfo("master"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("ZipInfo(master): got %v, want NotFound", err)
+	}
+
+	zr, commitTime, err := Zip("v1.14.6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !commitTime.Equal(TestCommitTime) {
+		t.Errorf("commit time: got %s, want %s", commitTime, TestCommitTime)
+	}
+	if len(zr.File) == 0 {
+		t.Fatal("zip from tarball is empty")
+	}
+	if diff := cmp.Diff(zipFileNames(wantZip), zipFileNames(zr)); diff != "" {
+		t.Errorf("zip files mismatch (-want, +got):\n%s", diff)
+	}
+
+	// The second time, the zip comes from the cache.
+	if err := os.Remove(filepath.Join(dir, "go1.14.6"+tarballSuffix)); err != nil {
+		t.Fatal(err)
+	}
+	zr, commitTime, err = Zip("v1.14.6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !commitTime.Equal(TestCommitTime) {
+		t.Errorf("cached commit time: got %s, want %s", commitTime, TestCommitTime)
+	}
+	if diff := cmp.Diff(zipFileNames(wantZip), zipFileNames(zr)); diff != "" {
+		t.Errorf("cached zip files mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestUseLocalSourceError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := UseLocalSource(dir); err == nil {
+		UseLocalSource("")
+		t.Error("got nil error for an empty directory, want error")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/mod/semver"
@@ -54,6 +55,9 @@ var (
 //   "go1.2" => "v1.2.0"
 //   "go1.13beta1" => "v1.13.0-beta.1"
 //   "go1.9rc2" => "v1.9.0-rc.2"
+//
+// The tip of the Go repository has no tag, so pseudo-versions for it are
+// their own tags, and "master" is returned unchanged.
 func VersionForTag(tag string) string {
 	// Special cases for go1.
 	if tag == "go1" {
@@ -62,9 +66,12 @@ func VersionForTag(tag string) string {
 	if tag == "go1.0" {
 		return ""
 	}
-	// Special case for latest.
-	if tag == "latest" {
-		return "latest"
+	// Special cases for latest and master.
+	if tag == "latest" || tag == "master" {
+		return tag
+	}
+	if IsTipVersion(tag) {
+		return tag
 	}
 	m := tagRegexp.FindStringSubmatch(tag)
 	if m == nil {
@@ -92,6 +99,10 @@ func TagForVersion(version string) (_ string, err error) {
 	if version == "v1.0.0" {
 		return "go1", nil
 	}
+	// Special cases for tip: see VersionForTag.
+	if version == "master" || IsTipVersion(version) {
+		return version, nil
+	}
 	if !semver.IsValid(version) {
 		return "", fmt.Errorf("%w: requested version is not a valid semantic version: %q ", derrors.InvalidArgument, version)
 	}
@@ -121,11 +132,27 @@ func TagForVersion(version string) (_ string, err error) {
 	return goVersion, nil
 }
 
+// RevisionForVersion returns the revision of the Go repository for version:
+// the tag for a release, beta or rc, and the commit hash for a pseudo-version
+// of tip.
+func RevisionForVersion(version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "RevisionForVersion(%q)", version)
+
+	if IsTipVersion(version) {
+		return version[strings.LastIndexByte(version, '-')+1:], nil
+	}
+	return TagForVersion(version)
+}
+
 // MajorVersionForVersion returns the Go major version for version.
 // E.g. "v1.13.3" => "go1".
 func MajorVersionForVersion(version string) (_ string, err error) {
 	defer derrors.Wrap(&err, "MajorVersionForVersion(%q)", version)
 
+	// Tip is still Go 1.
+	if IsTipVersion(version) {
+		return "go1", nil
+	}
 	tag, err := TagForVersion(version)
 	if err != nil {
 		return "", err
@@ -140,6 +167,76 @@ func MajorVersionForVersion(version string) (_ string, err error) {
 	return tag[:i], nil
 }
 
+// IsTipVersion reports whether v is a pseudo-version for a commit on the
+// master branch of the Go repository, as returned by ZipInfo for "master".
+func IsTipVersion(v string) bool {
+	return strings.HasPrefix(v, tipVersionPrefix) && version.IsPseudo(v)
+}
+
+// tipVersionPrefix is the start of every pseudo-version for tip. Using v0.0.0
+// keeps tip below all Go releases, so it is never the latest version.
+const tipVersionPrefix = "v0.0.0-"
+
+// tipVersion returns the pseudo-version for the commit with the given hash.
+func tipVersion(repo *git.Repository, hash plumbing.Hash) (_ string, err error) {
+	defer derrors.Wrap(&err, "tipVersion(repo, %s)", hash)
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s-%s", tipVersionPrefix,
+		commit.Committer.When.UTC().Format("20060102150405"), hash.String()[:12]), nil
+}
+
+// resolvedTip holds the repo that semanticVersion read to resolve "master",
+// so that Zip can use the same commit without cloning the Go repo again.
+var resolvedTip struct {
+	mu      sync.Mutex
+	version string
+	repo    *git.Repository
+	hash    plumbing.Hash
+}
+
+// rememberTip records that version was resolved from the commit with the
+// given hash in repo. It replaces any repo recorded earlier.
+func rememberTip(version string, repo *git.Repository, hash plumbing.Hash) {
+	resolvedTip.mu.Lock()
+	defer resolvedTip.mu.Unlock()
+	resolvedTip.version = version
+	resolvedTip.repo = repo
+	resolvedTip.hash = hash
+}
+
+// getGoRepoAtTipVersion returns a repo object for the Go repo, and the hash
+// of the commit for the tip pseudo-version. It uses the repo recorded by
+// rememberTip for version, if there is one, and forgets it so the memory can
+// be reclaimed. Otherwise it reads master, and returns an error wrapping
+// derrors.NotFound if master is no longer at version.
+func getGoRepoAtTipVersion(version string) (_ *git.Repository, _ plumbing.Hash, err error) {
+	resolvedTip.mu.Lock()
+	if resolvedTip.version == version {
+		repo, hash := resolvedTip.repo, resolvedTip.hash
+		resolvedTip.version, resolvedTip.repo = "", nil
+		resolvedTip.mu.Unlock()
+		return repo, hash, nil
+	}
+	resolvedTip.mu.Unlock()
+
+	repo, hash, err := getGoRepoAtTip()
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	tip, err := tipVersion(repo, hash)
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	if tip != version {
+		return nil, plumbing.ZeroHash, fmt.Errorf("%w: master has moved on to %s", derrors.NotFound, tip)
+	}
+	return repo, hash, nil
+}
+
 // finalDigitsIndex returns the index of the first digit in the sequence of digits ending s.
 // If s doesn't end in digits, it returns -1.
 func finalDigitsIndex(s string) int {
@@ -168,23 +265,81 @@ var UseTestData = false
 // TestCommitTime is the time used for all commits when UseTestData is true.
 var TestCommitTime = time.Date(2019, 9, 4, 1, 2, 3, 0, time.UTC)
 
-// getGoRepo returns a repo object for the Go repo at version.
-func getGoRepo(version string) (_ *git.Repository, err error) {
+// getGoRepo returns a repo object for the Go repo, and the hash of the commit
+// for version.
+func getGoRepo(version string) (_ *git.Repository, _ plumbing.Hash, err error) {
 	defer derrors.Wrap(&err, "getGoRepo(%q)", version)
 
 	tag, err := TagForVersion(version)
 	if err != nil {
-		return nil, err
+		return nil, plumbing.ZeroHash, err
+	}
+	if localRepo != nil {
+		hash, err := localRepo.ResolveRevision(plumbing.Revision(plumbing.NewTagReferenceName(tag)))
+		if err != nil {
+			return nil, plumbing.ZeroHash, fmt.Errorf("%w: %v", derrors.NotFound, err)
+		}
+		return localRepo, *hash, nil
 	}
-	return git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
+	return cloneGoRepo(plumbing.NewTagReferenceName(tag))
+}
+
+// getGoRepoAtTip returns a repo object for the Go repo, and the hash of the
+// commit at the head of its master branch.
+func getGoRepoAtTip() (_ *git.Repository, _ plumbing.Hash, err error) {
+	defer derrors.Wrap(&err, "getGoRepoAtTip()")
+
+	switch {
+	case UseTestData:
+		repo, err := getTestGoRepo(testTipDirectory)
+		if err != nil {
+			return nil, plumbing.ZeroHash, err
+		}
+		return headHash(repo)
+	case localRepo != nil:
+		// A clone has refs/heads/master if master is checked out, and
+		// refs/remotes/origin/master otherwise.
+		for _, name := range []plumbing.ReferenceName{plumbing.Master, "refs/remotes/origin/master"} {
+			if hash, err := localRepo.ResolveRevision(plumbing.Revision(name)); err == nil {
+				return localRepo, *hash, nil
+			}
+		}
+		return nil, plumbing.ZeroHash, fmt.Errorf("%w: no master branch in %s", derrors.NotFound, localSourceDir)
+	case tarballDir != "":
+		return nil, plumbing.ZeroHash, fmt.Errorf("%w: source tarballs in %s have no master branch", derrors.NotFound, tarballDir)
+	default:
+		return cloneGoRepo(plumbing.Master)
+	}
+}
+
+// cloneGoRepo clones the commit at ref from GoRepoURL into memory.
+func cloneGoRepo(ref plumbing.ReferenceName) (_ *git.Repository, _ plumbing.Hash, err error) {
+	repo, err := git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
 		URL:           GoRepoURL,
-		ReferenceName: plumbing.NewTagReferenceName(tag),
+		ReferenceName: ref,
 		SingleBranch:  true,
 		Depth:         1,
 		Tags:          git.NoTags,
 	})
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	return headHash(repo)
 }
 
+// headHash returns repo and the hash of the commit at its HEAD.
+func headHash(repo *git.Repository) (*git.Repository, plumbing.Hash, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	return repo, head.Hash(), nil
+}
+
+// testTipDirectory is the testdata directory that stands in for the master
+// branch when UseTestData is true.
+const testTipDirectory = "v1.14.6"
+
 // getTestGoRepo gets a Go repo for testing.
 func getTestGoRepo(version string) (_ *git.Repository, err error) {
 	defer derrors.Wrap(&err, "getTestGoRepo(%q)", version)
@@ -221,9 +376,32 @@ func Versions() (_ []string, err error) {
 	defer derrors.Wrap(&err, "Versions()")
 
 	var refNames []plumbing.ReferenceName
-	if UseTestData {
+	switch {
+	case UseTestData:
 		refNames = testRefs
-	} else {
+	case localRepo != nil:
+		localRepoMu.Lock()
+		defer localRepoMu.Unlock()
+		tags, err := localRepo.Tags()
+		if err != nil {
+			return nil, err
+		}
+		err = tags.ForEach(func(r *plumbing.Reference) error {
+			refNames = append(refNames, r.Name())
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	case tarballDir != "":
+		tags, err := tarballTags()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tags {
+			refNames = append(refNames, plumbing.NewTagReferenceName(t))
+		}
+	default:
 		re := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
 			URLs: []string{GoRepoURL},
 		})
@@ -252,7 +430,8 @@ func Versions() (_ []string, err error) {
 // Directory returns the directory of the standard library relative to the repo root.
 func Directory(version string) string {
 	// For versions older than v1.4.0-beta.1, the stdlib is in src/pkg.
-	if semver.Compare(version, "v1.4.0-beta.1") < 0 {
+	// Pseudo-versions for tip sort before them, but are newer.
+	if semver.Compare(version, "v1.4.0-beta.1") < 0 && !IsTipVersion(version) {
 		return "src/pkg"
 	}
 	return "src"
@@ -278,31 +457,78 @@ func ZipInfo(requestedVersion string) (resolvedVersion string, zipSize int64, er
 // version.
 //
 // Zip reads the standard library at the Go repository tag corresponding to to
-// the given semantic version.
+// the given semantic version, or from the source tarball for that version
+// when a directory of tarballs has been passed to UseLocalSource.
 //
 // Zip ignores go.mod files in the standard library, treating it as if it were a
 // single module named "std" at the given version.
+//
+// A pseudo-version for tip can only be read while it is still at the head of
+// the master branch; once master moves on, Zip returns an error wrapping
+// derrors.NotFound.
+//
+// If SetZipCacheDir has been called, zips for Go releases are kept in the
+// cache directory, and later calls for the same version read them from there.
 func Zip(resolvedVersion string) (_ *zip.Reader, commitTime time.Time, err error) {
-	// This code taken, with modifications, from
-	// https://github.com/shurcooL/play/blob/master/256/moduleproxy/std/std.go.
 	defer derrors.Wrap(&err, "stdlib.Zip(%q)", resolvedVersion)
 
-	var repo *git.Repository
-	if UseTestData {
-		repo, err = getTestGoRepo(resolvedVersion)
-	} else {
-		repo, err = getGoRepo(resolvedVersion)
+	data, commitTime, err := readCachedZip(resolvedVersion)
+	if err != nil {
+		return nil, time.Time{}, err
 	}
+	if data == nil {
+		if tarballDir != "" {
+			data, commitTime, err = zipFromTarball(resolvedVersion)
+		} else {
+			data, commitTime, err = zipFromRepo(resolvedVersion)
+		}
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if err := writeCachedZip(resolvedVersion, data, commitTime); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
 	if err != nil {
 		return nil, time.Time{}, err
 	}
-	var buf bytes.Buffer
-	z := zip.NewWriter(&buf)
-	head, err := repo.Head()
+	return zr, commitTime, nil
+}
+
+// zipFromRepo returns the contents of the module zip for resolvedVersion, built
+// from the Go repo, and the time of the commit for that version.
+func zipFromRepo(resolvedVersion string) (_ []byte, commitTime time.Time, err error) {
+	// This code taken, with modifications, from
+	// https://github.com/shurcooL/play/blob/master/256/moduleproxy/std/std.go.
+	defer derrors.Wrap(&err, "zipFromRepo(%q)", resolvedVersion)
+
+	if localRepo != nil {
+		// A repository on disk is not safe for concurrent use.
+		localRepoMu.Lock()
+		defer localRepoMu.Unlock()
+	}
+	var (
+		repo *git.Repository
+		hash plumbing.Hash
+	)
+	switch {
+	case IsTipVersion(resolvedVersion):
+		repo, hash, err = getGoRepoAtTipVersion(resolvedVersion)
+	case UseTestData:
+		repo, err = getTestGoRepo(resolvedVersion)
+		if err == nil {
+			repo, hash, err = headHash(repo)
+		}
+	default:
+		repo, hash, err = getGoRepo(resolvedVersion)
+	}
 	if err != nil {
 		return nil, time.Time{}, err
 	}
-	commit, err := repo.CommitObject(head.Hash())
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	commit, err := repo.CommitObject(hash)
 	if err != nil {
 		return nil, time.Time{}, err
 	}
@@ -329,19 +555,35 @@ func Zip(resolvedVersion string) (_ *zip.Reader, commitTime time.Time, err error
 	if err := z.Close(); err != nil {
 		return nil, time.Time{}, err
 	}
-	br := bytes.NewReader(buf.Bytes())
-	zr, err := zip.NewReader(br, int64(br.Len()))
-	if err != nil {
-		return nil, time.Time{}, err
-	}
-	return zr, commit.Committer.When, nil
+	return buf.Bytes(), commit.Committer.When, nil
 }
 
 // semanticVersion returns the semantic version corresponding to the
-// requestedVersion.
+// requestedVersion. For "master", that is a pseudo-version for the commit at
+// the head of the master branch.
 func semanticVersion(requestedVersion string) (_ string, err error) {
 	defer derrors.Wrap(&err, "semanticVersion(%q)", requestedVersion)
 
+	if requestedVersion == "master" || IsTipVersion(requestedVersion) {
+		if localRepo != nil {
+			localRepoMu.Lock()
+			defer localRepoMu.Unlock()
+		}
+		repo, hash, err := getGoRepoAtTip()
+		if err != nil {
+			return "", err
+		}
+		tip, err := tipVersion(repo, hash)
+		if err != nil {
+			return "", err
+		}
+		if requestedVersion != "master" && requestedVersion != tip {
+			return "", fmt.Errorf("%w: master has moved on to %s", derrors.NotFound, tip)
+		}
+		rememberTip(tip, repo, hash)
+		return tip, nil
+	}
+
 	knownVersions, err := Versions()
 	if err != nil {
 		return "", err
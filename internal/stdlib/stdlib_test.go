@@ -5,12 +5,14 @@
 package stdlib
 
 import (
+	"errors"
 	"io/ioutil"
 	"reflect"
 	"strings"
 	"testing"
 
 	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal/derrors"
 )
 
 func TestTagForVersion(t *testing.T) {
@@ -230,6 +232,9 @@ func TestVersionForTag(t *testing.T) {
 		{"go1.0", ""},
 		{"weekly.2012-02-14", ""},
 		{"latest", "latest"},
+		{"master", "master"},
+		{"v0.0.0-20201019123456-abcdefabcdef", "v0.0.0-20201019123456-abcdefabcdef"},
+		{"v1.16.0-20201019123456-abcdefabcdef", ""},
 	} {
 		got := VersionForTag(tc.in)
 		if got != tc.want {
@@ -238,6 +243,74 @@ func TestVersionForTag(t *testing.T) {
 	}
 }
 
+func TestTipVersions(t *testing.T) {
+	const tip = "v0.0.0-20201019123456-abcdefabcdef"
+	for _, v := range []string{"master", tip} {
+		tag, err := TagForVersion(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := VersionForTag(tag); got != v {
+			t.Errorf("VersionForTag(TagForVersion(%q)) = %q, want %q", v, got, v)
+		}
+	}
+	rev, err := RevisionForVersion(tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "abcdefabcdef"; rev != want {
+		t.Errorf("RevisionForVersion(%q) = %q, want %q", tip, rev, want)
+	}
+	if got, want := Directory(tip), "src"; got != want {
+		t.Errorf("Directory(%q) = %q, want %q", tip, got, want)
+	}
+	if got, err := MajorVersionForVersion(tip); err != nil || got != "go1" {
+		t.Errorf("MajorVersionForVersion(%q) = %q, %v, want %q, nil", tip, got, err, "go1")
+	}
+}
+
+func TestZipTip(t *testing.T) {
+	UseTestData = true
+	defer func() { UseTestData = false }()
+
+	tip, _, err := ZipInfo("master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsTipVersion(tip) || !strings.HasPrefix(tip, "v0.0.0-20190904010203-") {
+		t.Fatalf("ZipInfo(master): got version %q, want a pseudo-version at TestCommitTime", tip)
+	}
+	if resolvedTip.version != tip {
+		t.Errorf("resolvedTip.version = %q, want %q", resolvedTip.version, tip)
+	}
+	zr, gotTime, err := Zip(tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Zip uses the repo that ZipInfo read, instead of reading master again.
+	if resolvedTip.repo != nil {
+		t.Error("Zip did not use the repo resolved by ZipInfo")
+	}
+	if !gotTime.Equal(TestCommitTime) {
+		t.Errorf("commit time: got %s, want %s", gotTime, TestCommitTime)
+	}
+	want := "std@" + tip + "/errors/errors.go"
+	found := false
+	for _, f := range zr.File {
+		if f.Name == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("zip missing %q", want)
+	}
+
+	// Once master has moved on, the old pseudo-version can't be fetched.
+	if _, _, err := Zip("v0.0.0-20190904010203-abcdefabcdef"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("Zip of old tip: got %v, want NotFound", err)
+	}
+}
+
 func TestContains(t *testing.T) {
 	for _, test := range []struct {
 		in   string
@@ -0,0 +1,48 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+// SymbolKind is the kind of a Symbol. The values match the data-kind
+// attributes of the anchors in rendered documentation.
+type SymbolKind string
+
+const (
+	SymbolKindConstant SymbolKind = "constant"
+	SymbolKindVariable SymbolKind = "variable"
+	SymbolKindFunction SymbolKind = "function"
+	SymbolKindType     SymbolKind = "type"
+	SymbolKindMethod   SymbolKind = "method"
+	SymbolKindField    SymbolKind = "field"
+)
+
+// Symbol is an exported identifier declared by a package: a constant,
+// variable, function or type, or a method or field of a type.
+type Symbol struct {
+	// Name is the name of the symbol, qualified by the name of its type
+	// for methods and fields. It is also the symbol's anchor ID in the
+	// package documentation.
+	//
+	// E.g., "Reader" or "Reader.Read"
+	Name string
+	Kind SymbolKind
+
+	// References are the declarations in the same module that refer to
+	// the symbol, sorted by package path and name. They are only
+	// collected for types.
+	References []*SymbolReference
+}
+
+// SymbolReference identifies a declaration that refers to a Symbol.
+type SymbolReference struct {
+	// PackagePath is the import path of the package containing the
+	// declaration.
+	PackagePath string
+
+	// Name is the name of the declaration, qualified by the name of its
+	// type for methods.
+	//
+	// E.g., "NewReader" or "Reader.WriteTo"
+	Name string
+}
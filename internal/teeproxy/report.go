@@ -0,0 +1,341 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package teeproxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/safehtml/template"
+	"golang.org/x/net/html"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/stdlib"
+)
+
+// maxMismatches is the number of content mismatches kept for the report.
+const maxMismatches = 100
+
+// Mismatch describes a sampled request whose documented identifiers differ
+// between godoc.org and pkg.go.dev.
+type Mismatch struct {
+	Time         time.Time
+	Host         string
+	GddoPath     string
+	PkgGoDevPath string
+	// Missing holds the identifiers documented on godoc.org but not on
+	// pkg.go.dev, and Extra those documented only on pkg.go.dev.
+	Missing []string
+	Extra   []string
+	Parity  float64
+}
+
+// statusPair is a godoc.org status code and the status code that pkg.go.dev
+// returned for the same request.
+type statusPair struct {
+	gddo, pkgGoDev int
+}
+
+// patternKey identifies a row in the report.
+type patternKey struct {
+	host, pattern string
+}
+
+// patternStats accumulates the comparisons for one host and path pattern.
+type patternStats struct {
+	requests int
+	// skipped counts requests that were not sent to the host because of the
+	// rate limiter or circuit breaker.
+	skipped       int
+	disagreements map[statusPair]int
+	sampled       int
+	identical     int
+	paritySum     float64
+}
+
+// report accumulates status and content comparisons between godoc.org and
+// pkg.go.dev since the server started. It is not persisted.
+type report struct {
+	mu         sync.Mutex
+	start      time.Time
+	stats      map[patternKey]*patternStats
+	mismatches []*Mismatch // oldest first
+}
+
+func newReport() *report {
+	return &report{
+		start: time.Now(),
+		stats: map[patternKey]*patternStats{},
+	}
+}
+
+func (r *report) statsFor(host, gddoPath string) *patternStats {
+	k := patternKey{strings.TrimPrefix(host, "https://"), pathPattern(gddoPath)}
+	ps := r.stats[k]
+	if ps == nil {
+		ps = &patternStats{disagreements: map[statusPair]int{}}
+		r.stats[k] = ps
+	}
+	return ps
+}
+
+// recordStatus records the status codes returned for gddoPath by godoc.org
+// and by host.
+func (r *report) recordStatus(host, gddoPath string, gddoStatus, pkgGoDevStatus int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ps := r.statsFor(host, gddoPath)
+	ps.requests++
+	switch {
+	case pkgGoDevStatus == http.StatusTooManyRequests || pkgGoDevStatus == statusRedBreaker:
+		ps.skipped++
+	case gddoStatus != pkgGoDevStatus:
+		ps.disagreements[statusPair{gddoStatus, pkgGoDevStatus}]++
+	}
+}
+
+// recordContent records the comparison of the identifiers documented for a
+// sampled request, and remembers m if the identifiers differ.
+func (r *report) recordContent(host, gddoPath string, m *Mismatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ps := r.statsFor(host, gddoPath)
+	ps.sampled++
+	ps.paritySum += m.Parity
+	if len(m.Missing) == 0 && len(m.Extra) == 0 {
+		ps.identical++
+		return
+	}
+	r.mismatches = append(r.mismatches, m)
+	if len(r.mismatches) > maxMismatches {
+		r.mismatches = r.mismatches[len(r.mismatches)-maxMismatches:]
+	}
+}
+
+// pathPattern returns the pattern that gddoPath is reported under: the path
+// itself if it is one of the special godoc.org pages in
+// gddoToPkgGoDevRequest, and otherwise the kind of page it denotes.
+func pathPattern(gddoPath string) string {
+	if _, ok := gddoToPkgGoDevRequest[gddoPath]; ok {
+		return gddoPath
+	}
+	if strings.HasPrefix(gddoPath, "/-/") {
+		return "/-/*"
+	}
+	if stdlib.Contains(strings.TrimPrefix(gddoPath, "/")) {
+		return "<std package>"
+	}
+	return "<package>"
+}
+
+// identifierRE matches the heading IDs of documented identifiers, such as
+// "Client" and "Client.Do", on both godoc.org and pkg.go.dev.
+var identifierRE = regexp.MustCompile(`^[A-Z]\w*(\.[A-Za-z_]\w*)?$`)
+
+// documentedIdentifiers returns the sorted identifiers documented in the
+// HTML page read from r. Both sites give the heading of each exported
+// function, type, and method an ID that is the identifier's name, so the
+// identifiers can be compared regardless of the rest of the page layout.
+func documentedIdentifiers(r io.Reader) ([]string, error) {
+	seen := map[string]bool{}
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				var ids []string
+				for id := range seen {
+					ids = append(ids, id)
+				}
+				sort.Strings(ids)
+				return ids, nil
+			}
+			return nil, z.Err()
+		case html.StartTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "h2", "h3", "h4":
+			default:
+				continue
+			}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				if string(key) == "id" && identifierRE.Match(val) {
+					seen[string(val)] = true
+				}
+			}
+		}
+	}
+}
+
+// compareIdentifiers returns the identifiers in gddo that are missing from
+// pkgGoDev, those in pkgGoDev that are not in gddo, and the parity of the
+// two: the size of their intersection over the size of their union. Pages
+// that document nothing are in parity.
+func compareIdentifiers(gddo, pkgGoDev []string) (missing, extra []string, parity float64) {
+	in := func(ids []string) map[string]bool {
+		m := map[string]bool{}
+		for _, id := range ids {
+			m[id] = true
+		}
+		return m
+	}
+	inGddo, inPkgGoDev := in(gddo), in(pkgGoDev)
+	for _, id := range gddo {
+		if !inPkgGoDev[id] {
+			missing = append(missing, id)
+		}
+	}
+	for _, id := range pkgGoDev {
+		if !inGddo[id] {
+			extra = append(extra, id)
+		}
+	}
+	union := len(inGddo) + len(extra)
+	if union == 0 {
+		return nil, nil, 1
+	}
+	return missing, extra, float64(union-len(missing)-len(extra)) / float64(union)
+}
+
+// reportRow is a row of the report page.
+type reportRow struct {
+	Host, Pattern string
+	Requests      int
+	Skipped       int
+	Agreed        int
+	Disagreements []string
+	Sampled       int
+	Identical     int
+	MeanParity    string
+}
+
+// reportPage is the data for the report page.
+type reportPage struct {
+	Start      time.Time
+	Rows       []*reportRow
+	Mismatches []*Mismatch
+}
+
+func (r *report) page() *reportPage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := &reportPage{Start: r.start}
+	for k, ps := range r.stats {
+		row := &reportRow{
+			Host:      k.host,
+			Pattern:   k.pattern,
+			Requests:  ps.requests,
+			Skipped:   ps.skipped,
+			Agreed:    ps.requests - ps.skipped,
+			Sampled:   ps.sampled,
+			Identical: ps.identical,
+		}
+		var pairs []statusPair
+		for sp, n := range ps.disagreements {
+			row.Agreed -= n
+			pairs = append(pairs, sp)
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			ni, nj := ps.disagreements[pairs[i]], ps.disagreements[pairs[j]]
+			if ni != nj {
+				return ni > nj
+			}
+			if pairs[i].gddo != pairs[j].gddo {
+				return pairs[i].gddo < pairs[j].gddo
+			}
+			return pairs[i].pkgGoDev < pairs[j].pkgGoDev
+		})
+		for _, sp := range pairs {
+			row.Disagreements = append(row.Disagreements,
+				fmt.Sprintf("%d → %d: %d", sp.gddo, sp.pkgGoDev, ps.disagreements[sp]))
+		}
+		if ps.sampled > 0 {
+			row.MeanParity = fmt.Sprintf("%.1f%%", 100*ps.paritySum/float64(ps.sampled))
+		}
+		p.Rows = append(p.Rows, row)
+	}
+	sort.Slice(p.Rows, func(i, j int) bool {
+		if p.Rows[i].Host != p.Rows[j].Host {
+			return p.Rows[i].Host < p.Rows[j].Host
+		}
+		return p.Rows[i].Pattern < p.Rows[j].Pattern
+	})
+	// Show the newest mismatches first.
+	for i := len(r.mismatches) - 1; i >= 0; i-- {
+		p.Mismatches = append(p.Mismatches, r.mismatches[i])
+	}
+	return p
+}
+
+// HandleReport serves a page comparing the responses of godoc.org and
+// pkg.go.dev since the server started: for each host and path pattern, how
+// often their status codes disagreed, and for the requests whose responses
+// were sampled, how closely the documented identifiers match. The report is
+// kept in memory only, so it starts over when the server restarts.
+//
+// If the server has an auth value, requests must carry it in the same header
+// that the teeproxy uses to authenticate to pkg.go.dev.
+func (s *Server) HandleReport(w http.ResponseWriter, r *http.Request) {
+	if s.authValue != "" && r.Header.Get(s.authKey) != s.authValue {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if err := reportTemplate.Execute(w, s.report.page()); err != nil {
+		log.Errorf(r.Context(), "teeproxy.Server.HandleReport: %v", err)
+	}
+}
+
+var reportTemplate = template.Must(template.New("").Parse(`
+<html>
+  <head>
+    <title>Teeproxy Report</title>
+  </head>
+  <body>
+    <h1>Teeproxy report since {{.Start.Format "2006-01-02 15:04"}}</h1>
+    <h2>Status codes and content parity</h2>
+    <table cellspacing="10rem">
+      <tr>
+        <th>Host</th><th>Pattern</th><th>Requests</th><th>Skipped</th><th>Agreed</th>
+        <th>Disagreements (godoc.org → pkg.go.dev)</th>
+        <th>Sampled</th><th>Identical</th><th>Mean parity</th>
+      </tr>
+      {{range .Rows}}
+        <tr>
+          <td>{{.Host}}</td>
+          <td>{{.Pattern}}</td>
+          <td>{{.Requests}}</td>
+          <td>{{.Skipped}}</td>
+          <td>{{.Agreed}}</td>
+          <td>{{range .Disagreements}}{{.}}<br/>{{end}}</td>
+          <td>{{.Sampled}}</td>
+          <td>{{.Identical}}</td>
+          <td>{{.MeanParity}}</td>
+        </tr>
+      {{end}}
+    </table>
+    <h2>Recent content mismatches</h2>
+    <table cellspacing="10rem">
+      <tr><th>Time</th><th>Host</th><th>Path</th><th>Parity</th><th>Missing on pkg.go.dev</th><th>Only on pkg.go.dev</th></tr>
+      {{range .Mismatches}}
+        <tr>
+          <td>{{.Time.Format "2006-01-02 15:04:05"}}</td>
+          <td>{{.Host}}</td>
+          <td>{{.GddoPath}}{{if ne .GddoPath .PkgGoDevPath}} ({{.PkgGoDevPath}}){{end}}</td>
+          <td>{{printf "%.2f" .Parity}}</td>
+          <td>{{range .Missing}}{{.}} {{end}}</td>
+          <td>{{range .Extra}}{{.}} {{end}}</td>
+        </tr>
+      {{end}}
+    </table>
+  </body>
+</html>
+`))
@@ -0,0 +1,205 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package teeproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	gddoPage = `<html><body>
+<h2 id="pkg-overview">Overview</h2>
+<h3 id="Get">func <a href="#">Get</a></h3>
+<h3 id="Client">type <a href="#">Client</a></h3>
+<h4 id="Client.Do">func (*Client) <a href="#">Do</a></h4>
+<h4 id="Client.Post">func (*Client) <a href="#">Post</a></h4>
+<h3 id="example_Get">Example</h3>
+</body></html>`
+
+	pkgGoDevPage = `<html><body>
+<div class="Documentation-index"><a href="#Get">func Get</a></div>
+<h3 tabindex="-1" id="Get" data-kind="function" class="Documentation-functionHeader">func Get</h3>
+<h3 tabindex="-1" id="Client" data-kind="type" class="Documentation-typeHeader">type Client</h3>
+<h4 tabindex="-1" id="Client.Do" data-kind="method" class="Documentation-typeMethodHeader">func (*Client) Do</h4>
+<h4 tabindex="-1" id="Client.Head" data-kind="method" class="Documentation-typeMethodHeader">func (*Client) Head</h4>
+<span id="Header"></span>
+</body></html>`
+)
+
+func TestDocumentedIdentifiers(t *testing.T) {
+	for _, test := range []struct {
+		name, page string
+		want       []string
+	}{
+		{"godoc.org", gddoPage, []string{"Client", "Client.Do", "Client.Post", "Get"}},
+		{"pkg.go.dev", pkgGoDevPage, []string{"Client", "Client.Do", "Client.Head", "Get"}},
+		{"empty", "<html></html>", nil},
+	} {
+		got, err := documentedIdentifiers(strings.NewReader(test.page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestCompareIdentifiers(t *testing.T) {
+	for _, test := range []struct {
+		gddo, pkgGoDev         []string
+		wantMissing, wantExtra []string
+		wantParity             float64
+	}{
+		{nil, nil, nil, nil, 1},
+		{[]string{"A", "B"}, []string{"A", "B"}, nil, nil, 1},
+		{[]string{"A", "B", "C"}, []string{"A", "B", "D"}, []string{"C"}, []string{"D"}, 0.5},
+		{[]string{"A"}, nil, []string{"A"}, nil, 0},
+	} {
+		missing, extra, parity := compareIdentifiers(test.gddo, test.pkgGoDev)
+		if !cmp.Equal(missing, test.wantMissing) || !cmp.Equal(extra, test.wantExtra) || parity != test.wantParity {
+			t.Errorf("compareIdentifiers(%v, %v) = %v, %v, %v; want %v, %v, %v",
+				test.gddo, test.pkgGoDev, missing, extra, parity, test.wantMissing, test.wantExtra, test.wantParity)
+		}
+	}
+}
+
+func TestPathPattern(t *testing.T) {
+	for _, test := range []struct {
+		path, want string
+	}{
+		{"/", "/"},
+		{"/-/about", "/-/about"},
+		{"/-/search", "/-/*"},
+		{"/net/http", "<std package>"},
+		{"/github.com/a/b", "<package>"},
+	} {
+		if got := pathPattern(test.path); got != test.want {
+			t.Errorf("pathPattern(%q) = %q; want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	gddo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(gddoPage))
+	}))
+	defer gddo.Close()
+	pkgGoDev := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/about" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(pkgGoDevPage))
+	}))
+	defer pkgGoDev.Close()
+
+	s := newTestServer(Config{Hosts: []string{pkgGoDev.URL}, SampleRate: 1, AuthKey: "X-Auth", AuthValue: "secret"})
+	gddoURL, err := url.Parse(gddo.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.gddoURL = gddoURL
+	for _, path := range []string{"/net/http", "/-/about"} {
+		body, err := json.Marshal(&RequestEvent{
+			Host:   "godoc.org",
+			Path:   path,
+			URL:    "https://godoc.org" + path,
+			Status: http.StatusOK,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest("POST", "/", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json; charset=utf-8")
+		if _, _, err := s.doRequest(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.comparisons.Wait()
+
+	p := s.report.page()
+	host := strings.TrimPrefix(pkgGoDev.URL, "https://")
+	want := []*reportRow{
+		{Host: host, Pattern: "/-/about", Requests: 1, Disagreements: []string{"200 → 404: 1"}},
+		{Host: host, Pattern: "<std package>", Requests: 1, Agreed: 1, Sampled: 1, MeanParity: "60.0%"},
+	}
+	if diff := cmp.Diff(want, p.Rows); diff != "" {
+		t.Errorf("rows mismatch (-want +got):\n%s", diff)
+	}
+	if len(p.Mismatches) != 1 {
+		t.Fatalf("got %d mismatches, want 1", len(p.Mismatches))
+	}
+	m := p.Mismatches[0]
+	if !cmp.Equal(m.Missing, []string{"Client.Post"}) || !cmp.Equal(m.Extra, []string{"Client.Head"}) {
+		t.Errorf("got missing %v, extra %v; want [Client.Post], [Client.Head]", m.Missing, m.Extra)
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleReport(w, httptest.NewRequest("GET", "/report", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("report page without auth: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/report", nil)
+	r.Header.Set("X-Auth", "secret")
+	s.HandleReport(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Client.Head") {
+		t.Errorf("report page: got status %d, body %q", w.Code, w.Body.String())
+	}
+}
+
+func TestGddoIdentifiersIgnoresEventURL(t *testing.T) {
+	var fetched []string
+	gddo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = append(fetched, r.URL.Path)
+		w.Write([]byte(gddoPage))
+	}))
+	defer gddo.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("fetched %s from a host named in the request", r.URL)
+	}))
+	defer other.Close()
+
+	s := newTestServer(Config{})
+	gddoURL, err := url.Parse(gddo.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.gddoURL = gddoURL
+	for _, path := range []string{"/net/http", "@" + strings.TrimPrefix(other.URL, "http://") + "/x"} {
+		if _, err := s.gddoIdentifiers(context.Background(), &RequestEvent{Path: path, URL: other.URL + path}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(fetched) != 2 {
+		t.Errorf("godoc.org got %d requests, want 2", len(fetched))
+	}
+}
+
+func TestCompareSlots(t *testing.T) {
+	s := newTestServer(Config{})
+	for i := 0; i < maxComparisons; i++ {
+		if !s.acquireCompareSlot() {
+			t.Fatalf("slot %d: got false, want true", i)
+		}
+	}
+	if s.acquireCompareSlot() {
+		t.Fatal("acquired more than maxComparisons slots")
+	}
+	s.releaseCompareSlot()
+	if !s.acquireCompareSlot() {
+		t.Error("could not acquire a released slot")
+	}
+}
@@ -7,14 +7,19 @@
 package teeproxy
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go.opencensus.io/plugin/ochttp"
@@ -23,6 +28,7 @@ import (
 	"go.opencensus.io/tag"
 	"golang.org/x/net/context/ctxhttp"
 	"golang.org/x/pkgsite/internal/breaker"
+	"golang.org/x/pkgsite/internal/dcensus"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/time/rate"
@@ -37,6 +43,17 @@ type Server struct {
 	// authKey and authValue are used to indicate to pkg.go.dev that the
 	// request is coming from the teeproxy.
 	authKey, authValue string
+	// sampleRate is the fraction of requests whose responses are compared.
+	sampleRate float64
+	report     *report
+	// comparisons tracks the content comparisons running in the background.
+	comparisons sync.WaitGroup
+	// compareSlots bounds the number of comparisons running at once. A
+	// request is only sampled if it can take a slot.
+	compareSlots chan struct{}
+	// gddoURL is the URL of godoc.org, from which pages are fetched for
+	// comparison. It is never taken from a request.
+	gddoURL *url.URL
 }
 
 // Config contains configuration values for Server.
@@ -57,6 +74,10 @@ type Config struct {
 	// Burst is the maximum burst of requests permitted.
 	Burst         int
 	BreakerConfig breaker.Config
+	// SampleRate is the fraction of requests, between 0 and 1, for which the
+	// teeproxy fetches the page from godoc.org as well, and compares the
+	// identifiers documented on both sites.
+	SampleRate float64
 }
 
 // RequestEvent stores information about a godoc.org or pkg.go.dev request.
@@ -105,8 +126,9 @@ var (
 	// keyTeeproxyHost is a census tag for hosts that teeproxy forward requests to.
 	keyTeeproxyHost = tag.MustNewKey("teeproxy.host")
 	// keyTeeproxyPath is a census tag for godoc.org paths that don't work in
-	// pkg.go.dev.
-	keyTeeproxyPath = tag.MustNewKey("teeproxy.path")
+	// pkg.go.dev. Its values are unbounded, so it is dropped from the views;
+	// the paths themselves are logged and shown on the report page.
+	keyTeeproxyPath = dcensus.NewUnboundedKey("teeproxy.path")
 	// teeproxyGddoLatency holds observed latency in individual teeproxy
 	// requests from godoc.org.
 	teeproxyGddoLatency = stats.Float64(
@@ -128,6 +150,20 @@ var (
 		"Count of paths that error in pkg.go.dev but 200 in godoc.org.",
 		stats.UnitDimensionless,
 	)
+	// teeproxyContentParity holds the parity of the identifiers documented
+	// on godoc.org and pkg.go.dev for sampled requests.
+	teeproxyContentParity = stats.Float64(
+		"go-discovery/teeproxy/content-parity",
+		"Fraction of identifiers documented on either godoc.org or pkg.go.dev that are documented on both.",
+		stats.UnitDimensionless,
+	)
+	// teeproxyContentMismatches counts sampled requests whose documented
+	// identifiers differ.
+	teeproxyContentMismatches = stats.Int64(
+		"go-discovery/teeproxy/content-mismatches",
+		"Count of sampled pages whose documented identifiers differ between godoc.org and pkg.go.dev.",
+		stats.UnitDimensionless,
+	)
 
 	// TeeproxyGddoRequestLatencyDistribution aggregates the latency of
 	// teeproxy requests from godoc.org by status code and host.
@@ -172,6 +208,24 @@ var (
 		Description: "Count of broken paths in pkg.go.dev",
 		TagKeys:     []tag.Key{keyTeeproxyStatus, keyTeeproxyHost, keyTeeproxyPath},
 	}
+	// TeeproxyContentParityDistribution aggregates the content parity of
+	// sampled requests by host.
+	TeeproxyContentParityDistribution = &view.View{
+		Name:        "go-discovery/teeproxy/content-parity",
+		Measure:     teeproxyContentParity,
+		Aggregation: view.Distribution(0.25, 0.5, 0.75, 0.9, 0.99, 1),
+		Description: "Content parity of sampled requests, by host",
+		TagKeys:     []tag.Key{keyTeeproxyHost},
+	}
+	// TeeproxyContentMismatchCount counts sampled requests whose documented
+	// identifiers differ, by host.
+	TeeproxyContentMismatchCount = &view.View{
+		Name:        "go-discovery/teeproxy/content-mismatches",
+		Measure:     teeproxyContentMismatches,
+		Aggregation: view.Count(),
+		Description: "Count of content mismatches between godoc.org and pkg.go.dev",
+		TagKeys:     []tag.Key{keyTeeproxyHost},
+	}
 )
 
 // NewServer returns a new Server struct with preconfigured settings.
@@ -222,12 +276,16 @@ func NewServer(config Config) (_ *Server, err error) {
 		authKey = "auth-key-for-testing"
 	}
 	return &Server{
-		hosts:     config.Hosts,
-		client:    client,
-		limiter:   rate.NewLimiter(rate.Limit(config.Rate), config.Burst),
-		breakers:  breakers,
-		authKey:   authKey,
-		authValue: config.AuthValue,
+		hosts:        config.Hosts,
+		client:       client,
+		limiter:      rate.NewLimiter(rate.Limit(config.Rate), config.Burst),
+		breakers:     breakers,
+		authKey:      authKey,
+		authValue:    config.AuthValue,
+		sampleRate:   config.SampleRate,
+		report:       newReport(),
+		compareSlots: make(chan struct{}, maxComparisons),
+		gddoURL:      &url.URL{Scheme: "https", Host: "godoc.org"},
 	}, nil
 }
 
@@ -270,16 +328,20 @@ func (s *Server) doRequest(r *http.Request) (results map[string]*RequestEvent, s
 	}
 	if len(s.hosts) > 0 {
 		rateLimited := !s.limiter.Allow()
+		sample := !rateLimited && s.sampleRate > 0 && gddoEvent.Status == http.StatusOK &&
+			rand.Float64() < s.sampleRate && s.acquireCompareSlot()
+		var sampled []*sampledResponse
 		for _, host := range s.hosts {
 			event := &RequestEvent{
 				Host: host,
 			}
 
+			var body []byte
 			if rateLimited {
 				event.Status = http.StatusTooManyRequests
 				event.Error = errors.New("rate limit exceeded")
 			} else {
-				event = s.doRequestOnHost(ctx, gddoEvent, host)
+				event, body = s.doRequestOnHost(ctx, gddoEvent, host, sample)
 			}
 
 			if event.Error != nil {
@@ -287,12 +349,75 @@ func (s *Server) doRequest(r *http.Request) (results map[string]*RequestEvent, s
 			}
 			results[strings.TrimPrefix(host, "https://")] = event
 			recordTeeProxyMetric(r.Context(), host, gddoEvent.Path, gddoEvent.Status, event.Status, gddoEvent.Latency, event.Latency)
+			s.report.recordStatus(host, gddoEvent.Path, gddoEvent.Status, event.Status)
+			if sample && event.Status == http.StatusOK {
+				sampled = append(sampled, &sampledResponse{event, body})
+			}
+		}
+		if len(sampled) > 0 {
+			s.comparisons.Add(1)
+			go func() {
+				defer s.comparisons.Done()
+				defer s.releaseCompareSlot()
+				s.compareSampled(gddoEvent, sampled)
+			}()
+		} else if sample {
+			s.releaseCompareSlot()
 		}
 	}
 	return results, http.StatusOK, nil
 }
 
-func (s *Server) doRequestOnHost(ctx context.Context, gddoEvent *RequestEvent, host string) *RequestEvent {
+// A sampledResponse is a response from pkg.go.dev whose content is compared
+// with godoc.org.
+type sampledResponse struct {
+	event *RequestEvent
+	body  []byte
+}
+
+// maxComparisons is the maximum number of content comparisons that run at
+// once. Requests that arrive while that many are running are not sampled.
+const maxComparisons = 10
+
+// acquireCompareSlot reports whether a comparison may start, reserving a slot
+// for it if so. It does not block.
+func (s *Server) acquireCompareSlot() bool {
+	select {
+	case s.compareSlots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseCompareSlot frees a slot reserved by acquireCompareSlot.
+func (s *Server) releaseCompareSlot() {
+	<-s.compareSlots
+}
+
+// compareTimeout bounds the time spent fetching a godoc.org page for a
+// content comparison.
+const compareTimeout = time.Minute
+
+// compareSampled fetches the godoc.org page for gddoEvent and compares it
+// with each of the responses. It runs after the request to the teeproxy has
+// been answered, so it does not use the request's context.
+func (s *Server) compareSampled(gddoEvent *RequestEvent, responses []*sampledResponse) {
+	ctx, cancel := context.WithTimeout(context.Background(), compareTimeout)
+	defer cancel()
+	gddoIDs, err := s.gddoIdentifiers(ctx, gddoEvent)
+	if err != nil {
+		log.Errorf(ctx, "teeproxy.Server.compareSampled: %v", err)
+		return
+	}
+	for _, r := range responses {
+		s.compareContent(ctx, gddoEvent, r.event, gddoIDs, r.body)
+	}
+}
+
+// doRequestOnHost makes the pkg.go.dev request corresponding to gddoEvent on
+// host. If readBody is true, it also returns the response body.
+func (s *Server) doRequestOnHost(ctx context.Context, gddoEvent *RequestEvent, host string, readBody bool) (*RequestEvent, []byte) {
 	redirectPath := pkgGoDevPath(gddoEvent.Path)
 	event := &RequestEvent{
 		Host: host,
@@ -304,22 +429,22 @@ func (s *Server) doRequestOnHost(ctx context.Context, gddoEvent *RequestEvent, h
 		// This case should never be reached.
 		event.Status = http.StatusInternalServerError
 		event.Error = errors.New("breaker is nil")
-		return event
+		return event, nil
 	}
 
 	if !breaker.Allow() {
 		event.Status = statusRedBreaker
 		event.Error = errors.New("breaker is red")
-		return event
+		return event, nil
 	}
 
-	event = s.makePkgGoDevRequest(ctx, host, pkgGoDevPath(gddoEvent.Path))
+	event, body := s.makePkgGoDevRequest(ctx, host, pkgGoDevPath(gddoEvent.Path), readBody)
 	if event.Error != nil {
-		return event
+		return event, nil
 	}
 	success := event.Status < http.StatusInternalServerError
 	breaker.Record(success)
-	return event
+	return event, body
 }
 
 // validateTeeProxyRequest validates that a request to the teeproxy is allowed.
@@ -370,8 +495,9 @@ func getGddoEvent(r *http.Request) (gddoEvent *RequestEvent, err error) {
 }
 
 // makePkgGoDevRequest makes a request to the redirectHost and redirectPath,
-// and returns a requestEvent based on the output.
-func (s *Server) makePkgGoDevRequest(ctx context.Context, redirectHost, redirectPath string) *RequestEvent {
+// and returns a requestEvent based on the output. If readBody is true, it
+// also returns the response body, up to maxBodySize bytes.
+func (s *Server) makePkgGoDevRequest(ctx context.Context, redirectHost, redirectPath string, readBody bool) (*RequestEvent, []byte) {
 	redirectURL := redirectHost + redirectPath
 	event := &RequestEvent{
 		Host: redirectHost,
@@ -384,7 +510,7 @@ func (s *Server) makePkgGoDevRequest(ctx context.Context, redirectHost, redirect
 	if err != nil {
 		event.Status = http.StatusInternalServerError
 		event.Error = err
-		return event
+		return event, nil
 	}
 	start := time.Now()
 	req.Header.Set(s.authKey, s.authValue)
@@ -393,12 +519,69 @@ func (s *Server) makePkgGoDevRequest(ctx context.Context, redirectHost, redirect
 		// Use StatusBadGateway to indicate the upstream error.
 		event.Status = http.StatusBadGateway
 		event.Error = err
-		return event
+		return event, nil
 	}
+	defer resp.Body.Close()
 
 	event.Status = resp.StatusCode
 	event.Latency = time.Since(start)
-	return event
+	if !readBody {
+		return event, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		event.Error = err
+		return event, nil
+	}
+	return event, body
+}
+
+// maxBodySize bounds the size of the pages read for comparison.
+const maxBodySize = 10 * 1024 * 1024
+
+// gddoIdentifiers fetches the godoc.org page for gddoEvent and returns the
+// identifiers documented on it. Only the path of gddoEvent is used: the event
+// comes from the request body, so its URL may point anywhere.
+func (s *Server) gddoIdentifiers(ctx context.Context, gddoEvent *RequestEvent) (_ []string, err error) {
+	defer derrors.Wrap(&err, "gddoIdentifiers(%q)", gddoEvent.Path)
+
+	u := *s.gddoURL
+	u.Path = gddoEvent.Path
+	resp, err := ctxhttp.Get(ctx, s.client, u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	return documentedIdentifiers(io.LimitReader(resp.Body, maxBodySize))
+}
+
+// compareContent compares gddoIDs, the identifiers documented on the
+// godoc.org page for gddoEvent, with those in body, the page that host
+// returned for event, and records the result.
+func (s *Server) compareContent(ctx context.Context, gddoEvent, event *RequestEvent, gddoIDs []string, body []byte) {
+	ids, err := documentedIdentifiers(bytes.NewReader(body))
+	if err != nil {
+		log.Errorf(ctx, "teeproxy.Server.compareContent(%q): %v", event.URL, err)
+		return
+	}
+	missing, extra, parity := compareIdentifiers(gddoIDs, ids)
+	m := &Mismatch{
+		Time:         time.Now(),
+		Host:         strings.TrimPrefix(event.Host, "https://"),
+		GddoPath:     gddoEvent.Path,
+		PkgGoDevPath: event.Path,
+		Missing:      missing,
+		Extra:        extra,
+		Parity:       parity,
+	}
+	s.report.recordContent(event.Host, gddoEvent.Path, m)
+	recordContentParityMetric(ctx, event.Host, parity)
+	if len(missing) > 0 || len(extra) > 0 {
+		log.Info(ctx, map[string]interface{}{"teeproxy-mismatch": m})
+	}
 }
 
 // recordTeeProxyMetric records the latencies and counts of requests from
@@ -430,3 +613,13 @@ func recordTeeProxyMetric(ctx context.Context, host, path string, gddoStatus, pk
 		)
 	}
 }
+
+// recordContentParityMetric records the content parity of a sampled request
+// to host, and counts it as a mismatch if the parity is not perfect.
+func recordContentParityMetric(ctx context.Context, host string, parity float64) {
+	ms := []stats.Measurement{teeproxyContentParity.M(parity)}
+	if parity < 1 {
+		ms = append(ms, teeproxyContentMismatches.M(1))
+	}
+	stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(keyTeeproxyHost, host)}, ms...)
+}
@@ -54,7 +54,7 @@ func TestPkgGoDevRequest(t *testing.T) {
 	ctx := context.Background()
 	s := newTestServer(Config{})
 
-	got := s.makePkgGoDevRequest(ctx, ts.URL, "")
+	got, _ := s.makePkgGoDevRequest(ctx, ts.URL, "", false)
 	if got.Error != nil {
 		t.Fatal(got.Error)
 	}
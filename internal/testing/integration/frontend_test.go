@@ -222,7 +222,7 @@ func processVersions(ctx context.Context, t *testing.T, testModules []*proxy.Mod
 
 func fetchAndInsertModule(ctx context.Context, t *testing.T, tm *proxy.Module, proxyClient *proxy.Client) {
 	sourceClient := source.NewClient(1 * time.Second)
-	res := fetch.FetchModule(ctx, tm.ModulePath, tm.Version, proxyClient, sourceClient)
+	res := fetch.FetchModule(ctx, tm.ModulePath, tm.Version, proxyClient, sourceClient, nil)
 	defer res.Defer()
 	if res.Error != nil {
 		t.Fatal(res.Error)
@@ -18,9 +18,11 @@ import (
 	"github.com/go-redis/redis/v7"
 	"github.com/google/safehtml/template"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/complete"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/frontend"
 	"golang.org/x/pkgsite/internal/index"
+	"golang.org/x/pkgsite/internal/middleware"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/queue"
@@ -79,7 +81,7 @@ func TestEndToEndProcessing(t *testing.T) {
 	// back to worker, rather than calling fetch itself.
 	sourceClient := source.NewClient(1 * time.Second)
 	queue := queue.NewInMemory(ctx, 10, nil, func(ctx context.Context, mpath, version string) (int, error) {
-		return worker.FetchAndUpdateState(ctx, mpath, version, proxyClient, sourceClient, testDB, "test")
+		return worker.FetchAndUpdateState(ctx, mpath, version, proxyClient, sourceClient, testDB, redisCacheClient, "test")
 	})
 	workerServer, err := worker.NewServer(&config.Config{}, worker.ServerConfig{
 		DB:                   testDB,
@@ -102,7 +104,7 @@ func TestEndToEndProcessing(t *testing.T) {
 	frontendServer, err := frontend.NewServer(frontend.ServerConfig{
 		DataSourceGetter:     func(context.Context) internal.DataSource { return testDB },
 		Queue:                queue,
-		CompletionClient:     redisHAClient,
+		Completer:            complete.NewRedisCompleter(redisHAClient),
 		TaskIDChangeInterval: 10 * time.Minute,
 		StaticPath:           template.TrustedSourceFromConstant("../../../content/static"),
 		ThirdPartyPath:       "../../../third_party",
@@ -112,7 +114,7 @@ func TestEndToEndProcessing(t *testing.T) {
 		t.Fatal(err)
 	}
 	frontendMux := http.NewServeMux()
-	frontendServer.Install(frontendMux.Handle, redisCacheClient, nil)
+	frontendServer.Install(frontendMux.Handle, middleware.NewRedisStore(redisCacheClient), nil)
 	frontendHTTP := httptest.NewServer(frontendMux)
 
 	if _, err := doGet(workerHTTP.URL + "/poll"); err != nil {
@@ -0,0 +1,48 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package integration
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/testing/pagespec"
+)
+
+// TestPageSpecs checks the pages described by each spec file in
+// testdata/pages, after loading its modules into an empty database.
+func TestPageSpecs(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "pages", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	ts := setupFrontend(ctx, t, nil)
+	defer ts.Close()
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".yaml"), func(t *testing.T) {
+			spec, err := pagespec.Load(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer postgres.ResetTestDB(testDB, t)
+			var modules []*proxy.Module
+			for _, m := range spec.Modules {
+				modules = append(modules, &proxy.Module{ModulePath: m.ModulePath, Version: m.Version, Files: m.Files})
+			}
+			processVersions(ctx, t, modules)
+			for _, p := range spec.Pages {
+				if r := p.Run(ctx, http.DefaultClient, ts.URL); r.Err != nil {
+					t.Errorf("%s (/%s): %v", p.Name, p.RelativeURL, r.Err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pagespec reads declarative descriptions of site pages and checks
+// them against a server.
+//
+// A spec file is YAML. It lists the modules that must be in the database for
+// the pages to render, and the pages to request, each with assertions about
+// the response:
+//
+//	Modules:
+//	  - ModulePath: example.com/m
+//	    Version: v1.0.0
+//	    Files:
+//	      go.mod: module example.com/m
+//	      m.go: "package m\nconst Answer = 42"
+//	Pages:
+//	  - Name: package
+//	    RelativeURL: example.com/m
+//	    Contains: [Answer]
+//	    Selectors:
+//	      - Selector: h1
+//	        Text: m
+//
+// The integration tests load the modules into a test database and check the
+// pages against a frontend.Server; the prober checks the pages of its spec
+// file against a live host, where the modules must already exist.
+package pagespec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/ghodss/yaml"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/htmlcheck"
+)
+
+// A File is the contents of a spec file.
+type File struct {
+	// Modules are the module versions that the pages are about.
+	Modules []*Module
+	// Pages are the pages to check.
+	Pages []*Page
+}
+
+// A Module is a module version to store before checking pages.
+type Module struct {
+	ModulePath string
+	Version    string
+	// Files maps file paths, relative to the module root, to their contents.
+	Files map[string]string
+}
+
+// A Page represents a single HTTP GET request, and the assertions about its
+// response.
+type Page struct {
+	// A short, stable name for the page.
+	// Since the prober uses it in metrics, it shouldn't be too long and
+	// should stay the same even if actual URL changes.
+	Name string
+
+	// The part of the URL after the host:port.
+	RelativeURL string
+
+	// Whether or not to set a header that causes the frontend to skip the redis
+	// cache.
+	BypassCache bool
+
+	// Status is the expected response status. It defaults to 200, or to 302
+	// if RedirectTo is set.
+	Status int
+
+	// RedirectTo, if set, is the expected value of the Location header. The
+	// redirect is not followed.
+	RedirectTo string
+
+	// MaxLatency, if set, is the latency objective for the page, as a
+	// duration like "500ms". Slower responses fail.
+	MaxLatency string
+
+	// Contains lists strings that the response body must contain.
+	Contains []string
+
+	// Matches lists regular expressions that the response body must match.
+	Matches []string
+
+	// Selectors lists elements that the HTML response must have, or must not
+	// have.
+	Selectors []*SelectorAssertion
+
+	maxLatency time.Duration
+	matches    []*regexp.Regexp
+	checker    htmlcheck.Checker
+}
+
+// A SelectorAssertion is an assertion about the first element matching a CSS
+// selector.
+type SelectorAssertion struct {
+	Selector string
+	// Text, if set, is a regular expression that the element's text must
+	// match.
+	Text string
+	// Absent means that no element may match Selector.
+	Absent bool
+}
+
+// Load reads the spec file at filename and validates it.
+func Load(filename string) (_ *File, err error) {
+	defer derrors.Wrap(&err, "pagespec.Load(%q)", filename)
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses and validates the contents of a spec file.
+func Parse(data []byte) (_ *File, err error) {
+	defer derrors.Wrap(&err, "pagespec.Parse")
+
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	for _, m := range f.Modules {
+		if m.ModulePath == "" || m.Version == "" {
+			return nil, fmt.Errorf("module %q@%q: both path and version are required", m.ModulePath, m.Version)
+		}
+	}
+	names := map[string]bool{}
+	for _, p := range f.Pages {
+		if p.Name == "" {
+			return nil, fmt.Errorf("page for %q has no name", p.RelativeURL)
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("duplicate page name %q", p.Name)
+		}
+		names[p.Name] = true
+		if err := p.init(); err != nil {
+			return nil, fmt.Errorf("page %q: %v", p.Name, err)
+		}
+	}
+	return &f, nil
+}
+
+// init fills in defaults and compiles the assertions of p.
+func (p *Page) init() error {
+	if p.Status == 0 {
+		p.Status = http.StatusOK
+		if p.RedirectTo != "" {
+			p.Status = http.StatusFound
+		}
+	}
+	if p.MaxLatency != "" {
+		d, err := time.ParseDuration(p.MaxLatency)
+		if err != nil {
+			return err
+		}
+		p.maxLatency = d
+	}
+	for _, m := range p.Matches {
+		re, err := regexp.Compile(m)
+		if err != nil {
+			return err
+		}
+		p.matches = append(p.matches, re)
+	}
+	var checkers []htmlcheck.Checker
+	for _, s := range p.Selectors {
+		// htmlcheck panics on invalid selectors and regexps, so check them
+		// here.
+		if _, err := cascadia.Compile(s.Selector); err != nil {
+			return fmt.Errorf("selector %q: %v", s.Selector, err)
+		}
+		if _, err := regexp.Compile(s.Text); err != nil {
+			return err
+		}
+		switch {
+		case s.Absent:
+			checkers = append(checkers, htmlcheck.NotIn(s.Selector))
+		case s.Text != "":
+			checkers = append(checkers, htmlcheck.In(s.Selector, htmlcheck.HasText(s.Text)))
+		default:
+			checkers = append(checkers, htmlcheck.In(s.Selector))
+		}
+	}
+	if len(checkers) > 0 {
+		p.checker = htmlcheck.In("", checkers...)
+	}
+	return nil
+}
+
+// Check returns an error describing the first assertion of p that res fails.
+// body is the response body, and latency the time to its first byte.
+func (p *Page) Check(res *http.Response, body []byte, latency time.Duration) error {
+	if res.StatusCode != p.Status {
+		return fmt.Errorf("status %s, want %d", res.Status, p.Status)
+	}
+	if p.RedirectTo != "" {
+		if loc := res.Header.Get("Location"); loc != p.RedirectTo {
+			return fmt.Errorf("redirect to %q, want %q", loc, p.RedirectTo)
+		}
+	}
+	if p.maxLatency > 0 && latency > p.maxLatency {
+		return fmt.Errorf("latency %s exceeds %s", latency.Round(time.Millisecond), p.maxLatency)
+	}
+	for _, s := range p.Contains {
+		if !bytes.Contains(body, []byte(s)) {
+			return fmt.Errorf("body does not contain %q", s)
+		}
+	}
+	for _, re := range p.matches {
+		if !re.Match(body) {
+			return fmt.Errorf("body does not match %q", re)
+		}
+	}
+	if p.checker != nil {
+		if err := htmlcheck.Run(bytes.NewReader(body), p.checker); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A Result is the outcome of requesting a page.
+type Result struct {
+	Page *Page
+	// Response is the response, with its body already read and closed, or
+	// nil if the request failed.
+	Response *http.Response
+	// Latency is the time to the first byte of the response.
+	Latency time.Duration
+	// Err describes why the page failed, or is nil if it passed.
+	Err error
+	// Outcome summarizes the result in a few words, suitable for a metric
+	// tag: the response status if the page passed or had an unexpected
+	// status, and otherwise "FAILED call", "FAILED read body" or "FAILED
+	// assertion".
+	Outcome string
+}
+
+// Run requests the page p from the server at baseURL with client, and checks
+// the response. Redirects are not followed if p expects a 3xx status.
+func (p *Page) Run(ctx context.Context, client *http.Client, baseURL string) *Result {
+	r := &Result{Page: p}
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/"+p.RelativeURL, nil)
+	if err != nil {
+		r.Err = err
+		r.Outcome = "FAILED call"
+		return r
+	}
+	if p.BypassCache {
+		req.Header.Set("x-go-discovery-bypass-cache", "yes")
+	}
+	if p.Status/100 == 3 {
+		c := *client
+		c.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		client = &c
+	}
+	start := time.Now()
+	res, err := client.Do(req.WithContext(ctx))
+	r.Latency = time.Since(start)
+	if err != nil {
+		r.Err = err
+		r.Outcome = "FAILED call"
+		return r
+	}
+	defer res.Body.Close()
+	r.Response = res
+	if res.StatusCode != p.Status {
+		r.Err = fmt.Errorf("status %s, want %d", res.Status, p.Status)
+		r.Outcome = res.Status
+		return r
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		r.Err = fmt.Errorf("reading body: %v", err)
+		r.Outcome = "FAILED read body"
+		return r
+	}
+	if err := p.Check(res, body, r.Latency); err != nil {
+		r.Err = err
+		r.Outcome = "FAILED assertion"
+		return r
+	}
+	r.Outcome = res.Status
+	return r
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagespec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const spec = `
+Modules:
+  - ModulePath: example.com/m
+    Version: v1.0.0
+    Files:
+      go.mod: module example.com/m
+Pages:
+  - Name: home
+    RelativeURL: ""
+    Contains: [Welcome]
+    Matches: ['\d+ modules']
+    Selectors:
+      - Selector: h1
+        Text: ^Welcome$
+      - Selector: .Error
+        Absent: true
+  - Name: old
+    RelativeURL: old
+    RedirectTo: /new
+  - Name: missing
+    RelativeURL: missing
+    Status: 404
+  - Name: wrong-text
+    RelativeURL: ""
+    Selectors:
+      - Selector: h1
+        Text: Goodbye
+  - Name: slow
+    RelativeURL: ""
+    MaxLatency: 1ns
+`
+
+func TestRun(t *testing.T) {
+	f, err := Parse([]byte(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Modules) != 1 || f.Modules[0].Files["go.mod"] != "module example.com/m" {
+		t.Errorf("got modules %+v, want example.com/m@v1.0.0 with a go.mod", f.Modules)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<html><h1>Welcome</h1><p>42 modules</p></html>`))
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	want := map[string]string{
+		"home":       "",
+		"old":        "",
+		"missing":    "",
+		"wrong-text": "Goodbye",
+		"slow":       "latency",
+	}
+	for _, p := range f.Pages {
+		r := p.Run(context.Background(), ts.Client(), ts.URL)
+		switch {
+		case want[p.Name] == "" && r.Err != nil:
+			t.Errorf("%s: got error %v, want success", p.Name, r.Err)
+		case want[p.Name] != "" && (r.Err == nil || !strings.Contains(r.Err.Error(), want[p.Name])):
+			t.Errorf("%s: got error %v, want one containing %q", p.Name, r.Err, want[p.Name])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, bad := range []string{
+		"Pages: [{RelativeURL: x}]",
+		"Pages: [{Name: a}, {Name: a}]",
+		"Pages: [{Name: a, MaxLatency: soon}]",
+		"Pages: [{Name: a, Matches: ['(']}]",
+		"Pages: [{Name: a, Selectors: [{Selector: '[['}]}]",
+		"Modules: [{ModulePath: example.com/m}]",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("Parse(%q): got nil error, want error", bad)
+		}
+	}
+}
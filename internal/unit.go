@@ -27,6 +27,7 @@ type UnitMeta struct {
 	ModulePath string
 	CommitTime time.Time
 	SourceInfo *source.Info
+	Unverified bool // see ModuleInfo.Unverified
 }
 
 // IsPackage reports whether the path represents a package path.
@@ -55,6 +56,7 @@ type Unit struct {
 	Subdirectories  []*PackageMeta
 	Imports         []string
 	LicenseContents []*licenses.License
+	Symbols         []*Symbol
 }
 
 // Documentation is the rendered documentation for a given package
@@ -117,4 +119,5 @@ const (
 	WithImports
 	WithLicenses
 	WithSubdirectories
+	WithSymbols
 )
@@ -0,0 +1,118 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// deadLetterLimit is the number of dead-lettered module versions displayed on
+// the dead-letter page.
+const deadLetterLimit = 1000
+
+// deadLetterVersion is a dead-lettered module version with the retry class of
+// its status.
+type deadLetterVersion struct {
+	*internal.ModuleVersionState
+	Class string
+}
+
+// retryPolicy is a retry class with its policy, for display.
+type retryPolicy struct {
+	Class  string
+	Policy *config.RetryPolicy // nil if the class has no policy
+	Count  int                 // number of dead-lettered versions in the class
+}
+
+// doDeadLetterPage writes the page listing module versions that have exhausted
+// their retries.
+func (s *Server) doDeadLetterPage(w http.ResponseWriter, r *http.Request) (err error) {
+	defer derrors.Wrap(&err, "doDeadLetterPage")
+
+	ctx := r.Context()
+	mvs, err := s.db.GetDeadLetteredVersions(ctx, deadLetterLimit)
+	if err != nil {
+		return err
+	}
+	counts := map[string]int{}
+	var versions []*deadLetterVersion
+	for _, mv := range mvs {
+		c := postgres.RetryClass(mv.Status)
+		counts[c]++
+		versions = append(versions, &deadLetterVersion{mv, c})
+	}
+	policies := s.db.RetryPolicies()
+	var classes []*retryPolicy
+	for _, c := range config.RetryClasses {
+		rp := &retryPolicy{Class: c, Count: counts[c]}
+		if p, ok := policies[c]; ok {
+			rp.Policy = &p
+		}
+		classes = append(classes, rp)
+	}
+
+	page := struct {
+		Config         *config.Config
+		Env            string
+		ResourcePrefix string
+		Limit          int
+		Policies       []*retryPolicy
+		Versions       []*deadLetterVersion
+	}{
+		Config:         s.cfg,
+		Env:            env(s.cfg),
+		ResourcePrefix: strings.ToLower(env(s.cfg)) + "-",
+		Limit:          deadLetterLimit,
+		Policies:       classes,
+		Versions:       versions,
+	}
+	return renderPage(ctx, w, page, s.templates[deadLetterTemplate])
+}
+
+// handleRetryDeadLetters takes the module versions in the "version" params,
+// each of the form MODULE_PATH@VERSION, out of the dead-letter state so that
+// they will be requeued.
+func (s *Server) handleRetryDeadLetters(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return &serverError{http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method)}
+	}
+	if err := r.ParseForm(); err != nil {
+		return &serverError{http.StatusBadRequest, err}
+	}
+	mvs, err := parseModuleVersions(r.Form["version"])
+	if err != nil {
+		return &serverError{http.StatusBadRequest, err}
+	}
+	n, err := s.db.RetryDeadLetteredVersions(r.Context(), mvs)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Retried %d module versions.\n", n)
+	return nil
+}
+
+// parseModuleVersions parses strings of the form MODULE_PATH@VERSION.
+func parseModuleVersions(ss []string) ([]postgres.ModuleVersion, error) {
+	if len(ss) == 0 {
+		return nil, errors.New("must provide at least one 'version' param")
+	}
+	var mvs []postgres.ModuleVersion
+	for _, s := range ss {
+		i := strings.LastIndexByte(s, '@')
+		if i <= 0 || i == len(s)-1 {
+			return nil, fmt.Errorf("invalid module version %q: want MODULE_PATH@VERSION", s)
+		}
+		mvs = append(mvs, postgres.ModuleVersion{ModulePath: s[:i], Version: s[i+1:]})
+	}
+	return mvs, nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+func TestParseModuleVersions(t *testing.T) {
+	got, err := parseModuleVersions([]string{"example.com/m@v1.0.0", "std@v1.15.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []postgres.ModuleVersion{
+		{ModulePath: "example.com/m", Version: "v1.0.0"},
+		{ModulePath: "std", Version: "v1.15.0"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	for _, in := range [][]string{nil, {"example.com/m"}, {"@v1.0.0"}, {"example.com/m@"}} {
+		if _, err := parseModuleVersions(in); err == nil {
+			t.Errorf("parseModuleVersions(%q): got nil error, want error", in)
+		}
+	}
+}
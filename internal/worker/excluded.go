@@ -0,0 +1,223 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/sync/errgroup"
+)
+
+// iapUserHeader is the header in which Identity-Aware Proxy, which guards
+// the worker on GCP, passes the email address of the authenticated user.
+// Its value has the form "accounts.google.com:user@example.com".
+const iapUserHeader = "X-Goog-Authenticated-User-Email"
+
+// unauthenticatedUser is the user recorded in audit logs for requests made
+// where the user cannot be authenticated.
+const unauthenticatedUser = "unauthenticated"
+
+// requestUser returns the user making r, for recording in audit logs.
+//
+// On GCP, the user must have been authenticated by Identity-Aware Proxy.
+// Elsewhere, nothing authenticates the user or strips a client-supplied IAP
+// header, so the user is recorded as unauthenticatedUser.
+func (s *Server) requestUser(r *http.Request) (string, error) {
+	if !s.cfg.OnGCP() {
+		return unauthenticatedUser, nil
+	}
+	v := r.Header.Get(iapUserHeader)
+	if v == "" {
+		return "", &serverError{http.StatusUnauthorized, errors.New("request was not authenticated")}
+	}
+	return v[strings.LastIndexByte(v, ':')+1:], nil
+}
+
+// excludedLogLimit is the number of excluded_prefixes_log entries displayed
+// on the excluded prefixes page.
+const excludedLogLimit = 100
+
+// doExcludedPage writes the page for administering excluded prefixes.
+func (s *Server) doExcludedPage(w http.ResponseWriter, r *http.Request) (err error) {
+	defer derrors.Wrap(&err, "doExcludedPage")
+	if _, err := s.requestUser(r); err != nil {
+		return err
+	}
+	var (
+		excluded []*postgres.ExcludedPrefix
+		changes  []*postgres.ExcludedPrefixChange
+	)
+	g, ctx := errgroup.WithContext(r.Context())
+	g.Go(func() error {
+		var err error
+		excluded, err = s.db.GetExcludedPrefixDetails(ctx)
+		if err != nil {
+			return annotation{err, "error fetching excluded"}
+		}
+		return nil
+	})
+	g.Go(func() error {
+		var err error
+		changes, err = s.db.GetExcludedPrefixChanges(ctx, excludedLogLimit)
+		if err != nil {
+			return annotation{err, "error fetching excluded log"}
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		var e annotation
+		if errors.As(err, &e) {
+			log.Errorf(ctx, e.msg, err)
+		}
+		return err
+	}
+
+	page := struct {
+		Config         *config.Config
+		Env            string
+		ResourcePrefix string
+		Excluded       []*postgres.ExcludedPrefix
+		Changes        []*postgres.ExcludedPrefixChange
+	}{
+		Config:         s.cfg,
+		Env:            env(s.cfg),
+		ResourcePrefix: strings.ToLower(env(s.cfg)) + "-",
+		Excluded:       excluded,
+		Changes:        changes,
+	}
+	return renderPage(ctx, w, page, s.templates[excludedTemplate])
+}
+
+// handleExcludeAdd excludes the prefix in the "prefix" param for the reason
+// in the "reason" param. If the "delete" param is set, all module versions
+// matching the prefix are deleted.
+func (s *Server) handleExcludeAdd(w http.ResponseWriter, r *http.Request) error {
+	prefix, reason, user, err := s.excludedParams(r)
+	if err != nil {
+		return err
+	}
+	ctx := r.Context()
+	if err := s.db.InsertExcludedPrefix(ctx, prefix, user, reason); err != nil {
+		if errors.Is(err, derrors.AlreadyExists) {
+			return &serverError{http.StatusConflict, err}
+		}
+		return err
+	}
+	msg := fmt.Sprintf("Excluded %q.\n", prefix)
+	if r.FormValue("delete") != "" {
+		n, modulePaths, err := s.db.DeleteModulesWithPrefix(ctx, prefix)
+		if err != nil {
+			return err
+		}
+		msg += fmt.Sprintf("Deleted %d module versions", n)
+		if m := s.purgeModulePages(ctx, modulePaths...); m != "" {
+			msg += "; " + m
+		}
+		msg += ".\n"
+	}
+	fmt.Fprint(w, msg)
+	return nil
+}
+
+// handleExcludeRemove removes the prefix in the "prefix" param from the
+// excluded prefixes, for the reason in the "reason" param. Module versions
+// that were not processed because they matched the prefix are requeued.
+func (s *Server) handleExcludeRemove(w http.ResponseWriter, r *http.Request) error {
+	prefix, reason, user, err := s.excludedParams(r)
+	if err != nil {
+		return err
+	}
+	ctx := r.Context()
+	if err := s.db.DeleteExcludedPrefix(ctx, prefix, user, reason); err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{http.StatusNotFound, err}
+		}
+		return err
+	}
+	n, err := s.db.RequeueExcludedModules(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Removed %q from excluded prefixes.\nRequeued %d module versions.\n", prefix, n)
+	return nil
+}
+
+// handleExcludeUndo undoes the change to the excluded prefixes with the ID in
+// the "id" param. Undoing an addition requeues module versions as
+// handleExcludeRemove does; undoing a removal deletes matching module versions
+// if the "delete" param is set, as handleExcludeAdd does.
+func (s *Server) handleExcludeUndo(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return &serverError{http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method)}
+	}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		return &serverError{http.StatusBadRequest, fmt.Errorf("id is invalid: %q", r.FormValue("id"))}
+	}
+	user, err := s.requestUser(r)
+	if err != nil {
+		return err
+	}
+	ctx := r.Context()
+	c, err := s.db.UndoExcludedPrefixChange(ctx, id, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, derrors.NotFound):
+			return &serverError{http.StatusNotFound, err}
+		case errors.Is(err, derrors.AlreadyExists):
+			return &serverError{http.StatusConflict, err}
+		}
+		return err
+	}
+	msg := fmt.Sprintf("Undid change %d: %s %q.\n", id, c.Action, c.Prefix)
+	if c.Action == postgres.ExcludedPrefixRemove {
+		n, err := s.db.RequeueExcludedModules(ctx, c.Prefix)
+		if err != nil {
+			return err
+		}
+		msg += fmt.Sprintf("Requeued %d module versions.\n", n)
+	} else if r.FormValue("delete") != "" {
+		n, modulePaths, err := s.db.DeleteModulesWithPrefix(ctx, c.Prefix)
+		if err != nil {
+			return err
+		}
+		msg += fmt.Sprintf("Deleted %d module versions", n)
+		if m := s.purgeModulePages(ctx, modulePaths...); m != "" {
+			msg += "; " + m
+		}
+		msg += ".\n"
+	}
+	fmt.Fprint(w, msg)
+	return nil
+}
+
+// excludedParams validates a request to change the excluded prefixes, and
+// returns its prefix, reason and user.
+func (s *Server) excludedParams(r *http.Request) (prefix, reason, user string, err error) {
+	if r.Method != http.MethodPost {
+		return "", "", "", &serverError{http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method)}
+	}
+	prefix = strings.TrimSpace(r.FormValue("prefix"))
+	if prefix == "" {
+		return "", "", "", &serverError{http.StatusBadRequest, errors.New("must provide 'prefix' param")}
+	}
+	reason = strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		return "", "", "", &serverError{http.StatusBadRequest, errors.New("must provide 'reason' param")}
+	}
+	user, err = s.requestUser(r)
+	if err != nil {
+		return "", "", "", err
+	}
+	return prefix, reason, user, nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/config"
+)
+
+func TestRequestUser(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		onGCP   bool
+		header  string
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{"iap", true, "accounts.google.com:someone@example.com", "/excluded", "someone@example.com", false},
+		{"unauthenticated", true, "", "/excluded?user=someone", "", true},
+		{"local param ignored", false, "", "/excluded?user=someone", unauthenticatedUser, false},
+		{"iap header ignored off GCP", false, "accounts.google.com:someone@example.com", "/excluded", unauthenticatedUser, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if test.onGCP {
+				defer os.Setenv("GO_DISCOVERY_ON_GKE", os.Getenv("GO_DISCOVERY_ON_GKE"))
+				os.Setenv("GO_DISCOVERY_ON_GKE", "true")
+			}
+			s := &Server{cfg: &config.Config{}}
+			r := httptest.NewRequest("GET", test.url, nil)
+			if test.header != "" {
+				r.Header.Set(iapUserHeader, test.header)
+			}
+			got, err := s.requestUser(r)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -13,13 +13,20 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"github.com/go-redis/redis/v7"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 	"go.opencensus.io/trace"
 	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/dcensus"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/fetch"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/middleware"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/source"
@@ -35,6 +42,30 @@ const (
 // even though they are still in the index.
 var ProxyRemoved = map[string]bool{}
 
+// stageDBInsert is the name of the fetch stage that inserts the module into
+// the database. The other stages are named by the Stage constants in the
+// fetch package.
+const stageDBInsert = "db-insert"
+
+var (
+	keyFetchStage     = tag.MustNewKey("fetch.stage")
+	fetchStageLatency = stats.Float64(
+		"go-discovery/worker/fetch-stage-latency",
+		"Latency of a stage of a fetch.",
+		stats.UnitMilliseconds,
+	)
+
+	// FetchStageLatencyDistribution aggregates the latency of each stage of
+	// a fetch by stage.
+	FetchStageLatencyDistribution = &view.View{
+		Name:        "go-discovery/worker/fetch-stage-latency",
+		Measure:     fetchStageLatency,
+		Aggregation: ochttp.DefaultLatencyDistribution,
+		Description: "Fetch stage latency by stage.",
+		TagKeys:     []tag.Key{keyFetchStage},
+	}
+)
+
 // fetchTask represents the result of a fetch task that was processed.
 type fetchTask struct {
 	fetch.FetchResult
@@ -45,7 +76,10 @@ type fetchTask struct {
 // the module_version_states table according to the result. It returns an HTTP
 // status code representing the result of the fetch operation, and a non-nil
 // error if this status code is not 200.
-func FetchAndUpdateState(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB, appVersionLabel string) (_ int, err error) {
+//
+// If redisCacheClient is non-nil, the cached frontend pages that may show the
+// module version are invalidated after it is inserted or deleted.
+func FetchAndUpdateState(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB, redisCacheClient *redis.Client, appVersionLabel string) (_ int, err error) {
 	defer derrors.Wrap(&err, "FetchAndUpdateState(%q, %q)", modulePath, requestedVersion)
 
 	tctx, span := trace.StartSpan(ctx, "FetchAndUpdateState")
@@ -87,6 +121,11 @@ func FetchAndUpdateState(ctx context.Context, modulePath, requestedVersion strin
 		// Do not return an error here, because we want to insert into
 		// module_version_states below.
 	}
+	// Unless the database could not be updated, the pages for the module
+	// have changed.
+	if ft.Status < 500 {
+		invalidateCache(ctx, redisCacheClient, ft)
+	}
 	if !semver.IsValid(ft.ResolvedVersion) {
 		// If the requestedVersion was not successfully resolved to a semantic
 		// version, then at this point it will be the same as the
@@ -115,6 +154,7 @@ func FetchAndUpdateState(ctx context.Context, modulePath, requestedVersion strin
 		logTaskResult(ctx, ft, "Failed to update module version state")
 		return http.StatusInternalServerError, ft.Error
 	}
+	recordStageTimings(ctx, db, ft)
 	logTaskResult(ctx, ft, "Updated module version state")
 	return ft.Status, ft.Error
 }
@@ -159,7 +199,7 @@ func fetchAndInsertModule(ctx context.Context, modulePath, requestedVersion stri
 	}
 
 	start := time.Now()
-	fr := fetch.FetchModule(ctx, modulePath, requestedVersion, proxyClient, sourceClient)
+	fr := fetch.FetchModule(ctx, modulePath, requestedVersion, proxyClient, sourceClient, db.GetSymbolNames)
 	if fr == nil {
 		panic("fetch.FetchModule should never return a nil FetchResult")
 	}
@@ -181,6 +221,7 @@ func fetchAndInsertModule(ctx context.Context, modulePath, requestedVersion stri
 	start = time.Now()
 	err = db.InsertModule(ctx, ft.Module)
 	ft.timings["db.InsertModule"] = time.Since(start)
+	ft.Timings[stageDBInsert] = ft.timings["db.InsertModule"]
 	if err != nil {
 		log.Error(ctx, err)
 
@@ -251,11 +292,63 @@ func deleteModule(ctx context.Context, db *postgres.DB, ft *fetchTask) (err erro
 	return nil
 }
 
+// invalidateCache deletes the cached pages that may show the module of ft:
+// the pages tagged with its module path, including @latest views and search
+// results, and the pages for the paths of its units, which may have been
+// served from a different module before. Failures are only logged, since the
+// pages expire eventually anyway.
+func invalidateCache(ctx context.Context, redisCacheClient *redis.Client, ft *fetchTask) {
+	if redisCacheClient == nil {
+		return
+	}
+	start := time.Now()
+	defer func() {
+		ft.timings["worker.invalidateCache"] = time.Since(start)
+	}()
+	tags := []string{middleware.ModuleCacheTag(ft.ModulePath)}
+	if ft.Module != nil {
+		for _, u := range ft.Module.Units {
+			tags = append(tags, middleware.PathCacheTag(u.Path))
+		}
+	}
+	n, err := middleware.PurgeCacheTags(ctx, redisCacheClient, tags...)
+	if err != nil {
+		log.Errorf(ctx, "%s@%s: invalidating cache: %v", ft.ModulePath, ft.ResolvedVersion, err)
+		return
+	}
+	log.Infof(ctx, "%s@%s: invalidated %d cached pages", ft.ModulePath, ft.ResolvedVersion, n)
+}
+
+// recordStageTimings records the duration of each stage of the fetch in
+// module_version_state_timings and in the FetchStageLatencyDistribution view.
+// Failures are logged but otherwise ignored, since they don't affect the
+// result of the fetch.
+//
+// Like module_version_states, module_version_state_timings only has rows for
+// semantic versions.
+func recordStageTimings(ctx context.Context, db *postgres.DB, ft *fetchTask) {
+	if len(ft.Timings) == 0 {
+		return
+	}
+	for stage, d := range ft.Timings {
+		dcensus.RecordWithTag(ctx, keyFetchStage, stage, fetchStageLatency.M(float64(d.Milliseconds())))
+	}
+	if !semver.IsValid(ft.ResolvedVersion) {
+		return
+	}
+	if err := db.UpsertModuleVersionStateTimings(ctx, ft.ModulePath, ft.ResolvedVersion, ft.Timings); err != nil {
+		log.Error(ctx, err)
+	}
+}
+
 func logTaskResult(ctx context.Context, ft *fetchTask, prefix string) {
 	var times []string
 	for k, v := range ft.timings {
 		times = append(times, fmt.Sprintf("%s=%.3fs", k, v.Seconds()))
 	}
+	for k, v := range ft.Timings {
+		times = append(times, fmt.Sprintf("stage.%s=%.3fs", k, v.Seconds()))
+	}
 	sort.Strings(times)
 	msg := strings.Join(times, ", ")
 	logf := log.Infof
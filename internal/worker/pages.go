@@ -33,6 +33,10 @@ type annotation struct {
 
 var startTime = time.Now()
 
+// slowestModulesLimit is the number of module versions displayed in the
+// slowest fetches table on the index page.
+const slowestModulesLimit = 10
+
 // doIndexPage writes the status page. On error it returns the error and a short
 // string to be written back to the client.
 func (s *Server) doIndexPage(w http.ResponseWriter, r *http.Request) (err error) {
@@ -40,6 +44,7 @@ func (s *Server) doIndexPage(w http.ResponseWriter, r *http.Request) (err error)
 	var (
 		experiments []*internal.Experiment
 		excluded    []string
+		slowest     []*internal.ModuleVersionState
 	)
 	if s.getExperiments != nil {
 		experiments = s.getExperiments()
@@ -53,6 +58,14 @@ func (s *Server) doIndexPage(w http.ResponseWriter, r *http.Request) (err error)
 		}
 		return nil
 	})
+	g.Go(func() error {
+		var err error
+		slowest, err = s.db.GetSlowestModuleVersions(ctx, slowestModulesLimit)
+		if err != nil {
+			return annotation{err, "error fetching slowest modules"}
+		}
+		return nil
+	})
 	if err := g.Wait(); err != nil {
 		var e annotation
 		if errors.As(err, &e) {
@@ -89,6 +102,7 @@ func (s *Server) doIndexPage(w http.ResponseWriter, r *http.Request) (err error)
 		SystemStats           systemMemStats
 		CgroupStats           map[string]uint64
 		Fetches               []*fetch.FetchInfo
+		SlowestModules        []*internal.ModuleVersionState
 	}{
 		Config:                s.cfg,
 		Env:                   env(s.cfg),
@@ -97,7 +111,7 @@ func (s *Server) doIndexPage(w http.ResponseWriter, r *http.Request) (err error)
 		Hostname:              os.Getenv("HOSTNAME"),
 		StartTime:             startTime,
 		Experiments:           experiments,
-		ExperimentsFromConfig: os.Getenv("GO_DISCOVERY_EXPERIMENTS_FROM_CONFIG") == "true",
+		ExperimentsFromConfig: s.cfg.ExperimentsFromConfig,
 		Excluded:              excluded,
 		LoadShedStats:         fetch.ZipLoadShedStats(),
 		GoMemStats:            gms,
@@ -105,6 +119,7 @@ func (s *Server) doIndexPage(w http.ResponseWriter, r *http.Request) (err error)
 		SystemStats:           sms,
 		CgroupStats:           getCgroupMemStats(),
 		Fetches:               fetch.FetchInfos(),
+		SlowestModules:        slowest,
 	}
 	return renderPage(ctx, w, page, s.templates[indexTemplate])
 }
@@ -116,6 +131,7 @@ func (s *Server) doVersionsPage(w http.ResponseWriter, r *http.Request) (err err
 	var (
 		next, failures, recents []*internal.ModuleVersionState
 		stats                   *postgres.VersionStats
+		latencies               []*postgres.StageLatency
 	)
 	g.Go(func() error {
 		var err error
@@ -131,6 +147,9 @@ func (s *Server) doVersionsPage(w http.ResponseWriter, r *http.Request) (err err
 		if err != nil {
 			return annotation{err, "error fetching recent failures"}
 		}
+		if err := s.db.LoadModuleVersionStateTimings(ctx, failures); err != nil {
+			return annotation{err, "error fetching recent failure timings"}
+		}
 		return nil
 	})
 	g.Go(func() error {
@@ -139,6 +158,9 @@ func (s *Server) doVersionsPage(w http.ResponseWriter, r *http.Request) (err err
 		if err != nil {
 			return annotation{err, "error fetching recent versions"}
 		}
+		if err := s.db.LoadModuleVersionStateTimings(ctx, recents); err != nil {
+			return annotation{err, "error fetching recent version timings"}
+		}
 		return nil
 	})
 	g.Go(func() error {
@@ -149,6 +171,14 @@ func (s *Server) doVersionsPage(w http.ResponseWriter, r *http.Request) (err err
 		}
 		return nil
 	})
+	g.Go(func() error {
+		var err error
+		latencies, err = s.db.GetStageLatencies(ctx)
+		if err != nil {
+			return annotation{err, "error fetching stage latencies"}
+		}
+		return nil
+	})
 	if err := g.Wait(); err != nil {
 		var e annotation
 		if errors.As(err, &e) {
@@ -178,6 +208,7 @@ func (s *Server) doVersionsPage(w http.ResponseWriter, r *http.Request) (err err
 		ResourcePrefix               string
 		LatestTimestamp              *time.Time
 		Counts                       []*count
+		StageLatencies               []*postgres.StageLatency
 	}{
 		Next:            next,
 		Recent:          recents,
@@ -187,6 +218,7 @@ func (s *Server) doVersionsPage(w http.ResponseWriter, r *http.Request) (err err
 		ResourcePrefix:  strings.ToLower(env(s.cfg)) + "-",
 		LatestTimestamp: &stats.LatestTimestamp,
 		Counts:          counts,
+		StageLatencies:  latencies,
 	}
 	return renderPage(ctx, w, page, s.templates[versionsTemplate])
 }
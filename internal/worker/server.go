@@ -42,7 +42,7 @@ import (
 // Server can be installed to serve the go discovery worker.
 type Server struct {
 	cfg                  *config.Config
-	indexClient          *index.Client
+	discoverers          []index.Discoverer
 	proxyClient          *proxy.Client
 	sourceClient         *source.Client
 	redisHAClient        *redis.Client
@@ -60,6 +60,7 @@ type Server struct {
 type ServerConfig struct {
 	DB                   *postgres.DB
 	IndexClient          *index.Client
+	Discoverers          []index.Discoverer // polled along with IndexClient
 	ProxyClient          *proxy.Client
 	SourceClient         *source.Client
 	RedisHAClient        *redis.Client
@@ -72,8 +73,10 @@ type ServerConfig struct {
 }
 
 const (
-	indexTemplate    = "index.tmpl"
-	versionsTemplate = "versions.tmpl"
+	indexTemplate      = "index.tmpl"
+	versionsTemplate   = "versions.tmpl"
+	excludedTemplate   = "excluded.tmpl"
+	deadLetterTemplate = "dead_letter.tmpl"
 )
 
 // NewServer creates a new Server with the given dependencies.
@@ -87,15 +90,30 @@ func NewServer(cfg *config.Config, scfg ServerConfig) (_ *Server, err error) {
 	if err != nil {
 		return nil, err
 	}
+	t3, err := parseTemplate(scfg.StaticPath, template.TrustedSourceFromConstant(excludedTemplate))
+	if err != nil {
+		return nil, err
+	}
+	t4, err := parseTemplate(scfg.StaticPath, template.TrustedSourceFromConstant(deadLetterTemplate))
+	if err != nil {
+		return nil, err
+	}
 	templates := map[string]*template.Template{
-		indexTemplate:    t1,
-		versionsTemplate: t2,
+		indexTemplate:      t1,
+		versionsTemplate:   t2,
+		excludedTemplate:   t3,
+		deadLetterTemplate: t4,
 	}
 
+	var discoverers []index.Discoverer
+	if scfg.IndexClient != nil {
+		discoverers = append(discoverers, scfg.IndexClient)
+	}
+	discoverers = append(discoverers, scfg.Discoverers...)
 	return &Server{
 		cfg:                  cfg,
 		db:                   scfg.DB,
-		indexClient:          scfg.IndexClient,
+		discoverers:          discoverers,
 		proxyClient:          scfg.ProxyClient,
 		sourceClient:         scfg.SourceClient,
 		redisHAClient:        scfg.RedisHAClient,
@@ -118,13 +136,19 @@ func (s *Server) Install(handle func(string, http.Handler)) {
 		rmw = middleware.ErrorReporting(s.reportingClient.Report)
 	}
 
-	// scheduled: poll polls the Module Index for new modules
-	// that have been published and inserts that metadata into
-	// module_version_states.
+	// scheduled: poll polls the Module Index and the other discoverers for
+	// new modules that have been published and inserts that metadata into
+	// module_version_states. If a "discoverer" param is provided, only the
+	// discoverer with that name is polled.
 	// This endpoint is intended to be invoked periodically by a scheduler.
 	// See the note about duplicate tasks for "/enqueue" below.
 	handle("/poll", rmw(s.errorHandler(s.handlePollIndex)))
 
+	// webhook: webhook receives tag-push events from forges such as GitHub
+	// and GitLab, and inserts the module versions they announce into
+	// module_version_states.
+	handle("/webhook", rmw(s.errorHandler(s.handleWebhook)))
+
 	// scheduled: update-imported-by-count update the imported_by_count for
 	// packages in search_documents where imported_by_count_updated_at is null
 	// or imported_by_count_updated_at < version_updated_at.
@@ -180,6 +204,13 @@ func (s *Server) Install(handle func(string, http.Handler)) {
 	// see the comments on duplicate tasks for "/requeue", above.
 	handle("/populate-stdlib", rmw(s.errorHandler(s.handlePopulateStdLib)))
 
+	// scheduled: fetch-std-master inserts the master branch of the Go
+	// repository into the tasks queue, to be processed as a pseudo-version of
+	// the standard library and served as std@master.
+	// This endpoint is intended to be invoked periodically by a scheduler.
+	// See the comments on duplicate tasks for "/requeue", above.
+	handle("/fetch-std-master", rmw(s.errorHandler(s.handleFetchStdMaster)))
+
 	// manual: populate-search-documents repopulates every row in the
 	// search_documents table that was last updated before the time in the
 	// "before" query parameter.
@@ -188,17 +219,43 @@ func (s *Server) Install(handle func(string, http.Handler)) {
 	// manual: clear-cache clears the redis cache.
 	handle("/clear-cache", rmw(s.errorHandler(s.clearCache)))
 
+	// manual: purge-cache deletes the cached pages for the module or unit
+	// path in the "path" query parameter.
+	handle("/purge-cache", rmw(s.errorHandler(s.handlePurgeCache)))
+
 	// manual: update-experiment updates a given experiment.
 	handle("/update-experiment", rmw(s.errorHandler(s.updateExperiment)))
 
 	// manual: delete the specified module version.
 	handle("/delete/", http.StripPrefix("/delete", rmw(s.errorHandler(s.handleDelete))))
 
+	// manual: exclude/add adds a prefix to the excluded prefixes, and
+	// optionally deletes the module versions it matches.
+	handle("/exclude/add", rmw(s.errorHandler(s.handleExcludeAdd)))
+
+	// manual: exclude/remove removes a prefix from the excluded prefixes, and
+	// requeues the module versions it matches.
+	handle("/exclude/remove", rmw(s.errorHandler(s.handleExcludeRemove)))
+
+	// manual: exclude/undo undoes a change to the excluded prefixes recorded
+	// in the audit log.
+	handle("/exclude/undo", rmw(s.errorHandler(s.handleExcludeUndo)))
+
+	// manual: retry-dead-letters takes the given module versions out of the
+	// dead-letter state, so that they are requeued.
+	handle("/retry-dead-letters", rmw(s.errorHandler(s.handleRetryDeadLetters)))
+
 	handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(s.staticPath.String()))))
 
 	// returns an HTML page displaying information about recent versions that were processed.
 	handle("/versions", http.HandlerFunc(s.handleHTMLPage(s.doVersionsPage)))
 
+	// returns an HTML page for administering excluded prefixes.
+	handle("/excluded", s.errorHandler(s.doExcludedPage))
+
+	// returns an HTML page listing module versions that exhausted their retries.
+	handle("/dead-letter", s.errorHandler(s.doDeadLetterPage))
+
 	// Health check.
 	handle("/healthz", http.HandlerFunc(s.handleHealthCheck))
 
@@ -285,7 +342,7 @@ func (s *Server) doFetch(r *http.Request) (string, int) {
 		return err.Error(), http.StatusBadRequest
 	}
 
-	code, err := FetchAndUpdateState(r.Context(), modulePath, version, s.proxyClient, s.sourceClient, s.db, s.cfg.AppVersionLabel())
+	code, err := FetchAndUpdateState(r.Context(), modulePath, version, s.proxyClient, s.sourceClient, s.db, s.redisCacheClient, s.cfg.AppVersionLabel())
 	if err != nil {
 		return err.Error(), code
 	}
@@ -320,18 +377,49 @@ func (s *Server) handlePollIndex(w http.ResponseWriter, r *http.Request) (err er
 	defer derrors.Wrap(&err, "handlePollIndex(%q)", r.URL.Path)
 	ctx := r.Context()
 	limit := parseLimitParam(r, 10)
-	since, err := s.db.LatestIndexTimestamp(ctx)
+	name := r.FormValue("discoverer")
+	polled := false
+	// Poll every discoverer even if some fail, so that one that is broken
+	// does not stop the others.
+	var errs []error
+	for _, d := range s.discoverers {
+		if name != "" && d.Name() != name {
+			continue
+		}
+		polled = true
+		if err := s.pollDiscoverer(ctx, d, limit); err != nil {
+			log.Error(ctx, err)
+			errs = append(errs, err)
+		}
+	}
+	if !polled && name != "" {
+		return &serverError{http.StatusBadRequest, fmt.Errorf("unknown discoverer %q", name)}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return fmt.Errorf("%d discoverers failed; first error: %w", len(errs), errs[0])
+	}
+}
+
+// pollDiscoverer inserts the module versions reported by d.
+func (s *Server) pollDiscoverer(ctx context.Context, d index.Discoverer, limit int) (err error) {
+	defer derrors.Wrap(&err, "pollDiscoverer(ctx, %q)", d.Name())
+	since, err := s.db.LatestDiscoveryTimestamp(ctx, d.Name())
 	if err != nil {
 		return err
 	}
-	modules, err := s.indexClient.GetVersions(ctx, since, limit)
+	modules, err := d.Discover(ctx, since, limit)
 	if err != nil {
 		return err
 	}
-	if err := s.db.InsertIndexVersions(ctx, modules); err != nil {
+	if err := s.db.InsertDiscoveredVersions(ctx, d.Name(), modules); err != nil {
 		return err
 	}
-	log.Infof(ctx, "Inserted %d modules from the index", len(modules))
+	log.Infof(ctx, "Inserted %d modules from discoverer %q", len(modules), d.Name())
 	return nil
 }
 
@@ -385,7 +473,7 @@ func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) (err erro
 		sem <- struct{}{}
 		go func() {
 			defer func() { <-sem }()
-			enqueued, err := s.queue.ScheduleFetch(ctx, m.ModulePath, m.Version, suffixParam, s.taskIDChangeInterval)
+			enqueued, err := s.queue.ScheduleFetch(ctx, m.ModulePath, m.Version, suffixParam, s.taskIDChangeInterval, enqueuePriority(m))
 			mu.Lock()
 			if err != nil {
 				log.Errorf(ctx, "enqueuing: %v", err)
@@ -407,6 +495,16 @@ func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) (err erro
 	return nil
 }
 
+// enqueuePriority returns the queue priority for fetching a module version
+// returned by GetNextModulesToFetch. Versions that have never been processed
+// came from the index; all others are being retried or reprocessed.
+func enqueuePriority(m *internal.ModuleVersionState) queue.Priority {
+	if m.Status == 0 {
+		return queue.PriorityIndexPoll
+	}
+	return queue.PriorityReprocess
+}
+
 // handleHTMLPage returns an HTML page using a template from s.templates.
 func (s *Server) handleHTMLPage(f func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -434,13 +532,23 @@ func (s *Server) doPopulateStdLib(ctx context.Context, suffix string) (string, e
 		return "", err
 	}
 	for _, v := range versions {
-		if _, err := s.queue.ScheduleFetch(ctx, stdlib.ModulePath, v, suffix, s.taskIDChangeInterval); err != nil {
+		if _, err := s.queue.ScheduleFetch(ctx, stdlib.ModulePath, v, suffix, s.taskIDChangeInterval, queue.PriorityReprocess); err != nil {
 			return "", fmt.Errorf("error scheduling fetch for %s: %w", v, err)
 		}
 	}
 	return fmt.Sprintf("Scheduling modules to be fetched: %s.\n", strings.Join(versions, ", ")), nil
 }
 
+func (s *Server) handleFetchStdMaster(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	if _, err := s.queue.ScheduleFetch(ctx, stdlib.ModulePath, internal.MasterVersion, r.FormValue("suffix"), s.taskIDChangeInterval, queue.PriorityReprocess); err != nil {
+		return fmt.Errorf("handleFetchStdMaster: %v", err)
+	}
+	log.Infof(ctx, "handleFetchStdMaster: scheduled %s@%s", stdlib.ModulePath, internal.MasterVersion)
+	fmt.Fprintf(w, "Scheduled %s@%s to be fetched.\n", stdlib.ModulePath, internal.MasterVersion)
+	return nil
+}
+
 func (s *Server) handleReprocess(w http.ResponseWriter, r *http.Request) error {
 	appVersion := r.FormValue("app_version")
 	if appVersion == "" {
@@ -490,7 +598,51 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) error {
 	if err := s.db.DeleteModule(r.Context(), modulePath, version); err != nil {
 		return &serverError{http.StatusInternalServerError, err}
 	}
-	fmt.Fprintf(w, "Deleted %s@%s", modulePath, version)
+	msg := fmt.Sprintf("Deleted %s@%s", modulePath, version)
+	if m := s.purgeModulePages(r.Context(), modulePath); m != "" {
+		msg += "; " + m
+	}
+	fmt.Fprint(w, msg)
+	return nil
+}
+
+// purgeModulePages deletes the cached pages tagged with the given module
+// paths, after their data has been deleted. It returns a description of the
+// result for the response, or the empty string if there is no cache. Failures
+// are logged rather than returned, since the deletion itself succeeded and the
+// pages expire eventually anyway.
+func (s *Server) purgeModulePages(ctx context.Context, modulePaths ...string) string {
+	if s.redisCacheClient == nil || len(modulePaths) == 0 {
+		return ""
+	}
+	var tags []string
+	for _, p := range modulePaths {
+		tags = append(tags, middleware.ModuleCacheTag(p))
+	}
+	n, err := middleware.PurgeCacheTags(ctx, s.redisCacheClient, tags...)
+	if err != nil {
+		log.Errorf(ctx, "purging cached pages of %v: %v", modulePaths, err)
+		return fmt.Sprintf("could not invalidate cached pages: %v", err)
+	}
+	return fmt.Sprintf("invalidated %d cached pages", n)
+}
+
+// handlePurgeCache deletes the cached pages tagged with the module or unit
+// path in the "path" query parameter.
+func (s *Server) handlePurgeCache(w http.ResponseWriter, r *http.Request) error {
+	if s.redisCacheClient == nil {
+		return errors.New("redis cache client is not configured")
+	}
+	path := strings.Trim(r.FormValue("path"), "/")
+	if path == "" {
+		return &serverError{http.StatusBadRequest, errors.New("missing path")}
+	}
+	n, err := middleware.PurgeCacheTags(r.Context(), s.redisCacheClient,
+		middleware.ModuleCacheTag(path), middleware.PathCacheTag(path))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Purged %d cached pages for %q.", n, path)
 	return nil
 }
 
@@ -535,6 +687,10 @@ func parseTemplate(staticPath, filename template.TrustedSource) (*template.Templ
 		"timeSub": func(t1, t2 time.Time) time.Duration {
 			return t1.Sub(t2).Round(time.Second)
 		},
+		"roundDuration": func(d time.Duration) time.Duration {
+			return d.Round(time.Millisecond)
+		},
+		"totalDuration": totalDuration,
 	}).ParseFilesFromTrustedSources(templatePath)
 }
 
@@ -567,6 +723,15 @@ func formatTime(t *time.Time) string {
 	return t.In(locNewYork).Format("2006-01-02 15:04:05")
 }
 
+// totalDuration returns the sum of the durations in m.
+func totalDuration(m map[string]time.Duration) time.Duration {
+	var total time.Duration
+	for _, d := range m {
+		total += d
+	}
+	return total
+}
+
 // bytesToMi converts an integral value of bytes into mebibytes.
 func bytesToMi(b uint64) uint64 {
 	return b / (1024 * 1024)
@@ -11,9 +11,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"go.opencensus.io/trace"
@@ -21,6 +24,7 @@ import (
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/index"
+	"golang.org/x/pkgsite/internal/middleware"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/queue"
@@ -140,8 +144,8 @@ func TestWorker(t *testing.T) {
 				httptest.NewRequest("POST", "/poll", nil),
 				httptest.NewRequest("POST", "/enqueue", nil),
 			},
-			wantFoo: fooState(http.StatusOK, 1),
-			wantBar: barState(http.StatusOK, 1),
+			wantFoo: fooState(http.StatusOK, 0),
+			wantBar: barState(http.StatusOK, 0),
 		}, {
 			label: "partial fetch",
 			index: []*internal.IndexVersion{fooIndex, barIndex},
@@ -150,7 +154,7 @@ func TestWorker(t *testing.T) {
 				httptest.NewRequest("POST", "/poll?limit=1", nil),
 				httptest.NewRequest("POST", "/enqueue", nil),
 			},
-			wantFoo: fooState(http.StatusOK, 1),
+			wantFoo: fooState(http.StatusOK, 0),
 		}, {
 			label: "fetch with errors",
 			index: []*internal.IndexVersion{fooIndex, barIndex},
@@ -159,7 +163,7 @@ func TestWorker(t *testing.T) {
 				httptest.NewRequest("POST", "/poll", nil),
 				httptest.NewRequest("POST", "/enqueue", nil),
 			},
-			wantFoo: fooState(http.StatusOK, 1),
+			wantFoo: fooState(http.StatusOK, 0),
 			wantBar: barState(http.StatusNotFound, 1),
 		},
 	}
@@ -176,7 +180,7 @@ func TestWorker(t *testing.T) {
 
 			// Use 10 workers to have parallelism consistent with the worker binary.
 			q := queue.NewInMemory(ctx, 10, nil, func(ctx context.Context, mpath, version string) (int, error) {
-				return FetchAndUpdateState(ctx, mpath, version, proxyClient, sourceClient, testDB, "")
+				return FetchAndUpdateState(ctx, mpath, version, proxyClient, sourceClient, testDB, nil, "")
 			})
 
 			s, err := NewServer(&config.Config{}, ServerConfig{
@@ -243,6 +247,47 @@ func TestWorker(t *testing.T) {
 	}
 }
 
+// fakeDiscoverer is an index.Discoverer that reports versions, or fails.
+type fakeDiscoverer struct {
+	name     string
+	versions []*internal.IndexVersion
+	err      error
+}
+
+func (d *fakeDiscoverer) Name() string { return d.name }
+
+func (d *fakeDiscoverer) Discover(context.Context, time.Time, int) ([]*internal.IndexVersion, error) {
+	return d.versions, d.err
+}
+
+func TestPollIndexContinuesAfterError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	iv := &internal.IndexVersion{Path: "example.com/m", Version: "v1.0.0", Timestamp: sample.NowTruncated()}
+	s, err := NewServer(&config.Config{}, ServerConfig{
+		DB: testDB,
+		Discoverers: []index.Discoverer{
+			&fakeDiscoverer{name: "broken", err: errors.New("bad list")},
+			&fakeDiscoverer{name: "list", versions: []*internal.IndexVersion{iv}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	s.Install(mux.Handle)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/poll", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if _, err := testDB.GetModuleVersionState(ctx, iv.Path, iv.Version); err != nil {
+		t.Errorf("version from the discoverer after the broken one: %v", err)
+	}
+}
+
 func TestParseIntParam(t *testing.T) {
 	for _, test := range []struct {
 		in   string
@@ -326,3 +371,33 @@ type fakeTransport struct{}
 func (fakeTransport) RoundTrip(*http.Request) (*http.Response, error) {
 	return nil, errors.New("bad")
 }
+
+func TestPurgeModulePages(t *testing.T) {
+	ctx := context.Background()
+	if got := (&Server{}).purgeModulePages(ctx, "example.com/a"); got != "" {
+		t.Errorf("without a cache: got %q, want empty", got)
+	}
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	for _, key := range []string{"/example.com/a", "/example.com/b", "/example.com/c"} {
+		mr.Set(key, "page")
+		mr.SAdd("cachetag:"+middleware.ModuleCacheTag(strings.TrimPrefix(key, "/")), key)
+	}
+	s := &Server{redisCacheClient: redis.NewClient(&redis.Options{Addr: mr.Addr()})}
+	if got, want := s.purgeModulePages(ctx, "example.com/a", "example.com/b"), "invalidated 2 cached pages"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !mr.Exists("/example.com/c") {
+		t.Error("purged a page of another module")
+	}
+
+	// A purge failure is reported in the message, not returned.
+	mr.Close()
+	if got := s.purgeModulePages(ctx, "example.com/c"); !strings.HasPrefix(got, "could not invalidate cached pages") {
+		t.Errorf("with the cache down: got %q", got)
+	}
+}
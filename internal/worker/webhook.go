@@ -0,0 +1,171 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// webhookDiscoverer is the name recorded for module versions reported by
+// forge webhooks.
+const webhookDiscoverer = "webhook"
+
+// maxWebhookBodySize bounds the size of a webhook payload. Push events
+// include commit details, so they can be large.
+const maxWebhookBodySize = 10 * 1024 * 1024
+
+var errWebhookUnauthenticated = errors.New("webhook signature or token does not match")
+
+// handleWebhook receives tag-push events from a forge and inserts the module
+// versions they announce into module_version_states. It accepts GitHub push
+// events (also sent by Gitea) signed with the webhook secret, and GitLab tag
+// push events carrying the webhook secret as their token.
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) error {
+	if s.cfg.WebhookSecret == "" {
+		return &serverError{http.StatusNotFound, errors.New("webhooks are not configured")}
+	}
+	if r.Method != http.MethodPost {
+		return &serverError{http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method)}
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	if err != nil {
+		return &serverError{http.StatusBadRequest, err}
+	}
+	versions, err := parseTagPushEvent(r.Header, body, s.cfg.WebhookSecret, time.Now())
+	if errors.Is(err, errWebhookUnauthenticated) {
+		return &serverError{http.StatusUnauthorized, err}
+	}
+	if err != nil {
+		return &serverError{http.StatusBadRequest, err}
+	}
+	if len(versions) == 0 {
+		fmt.Fprintln(w, "Ignored event.")
+		return nil
+	}
+	if err := s.db.InsertDiscoveredVersions(r.Context(), webhookDiscoverer, versions); err != nil {
+		return err
+	}
+	for _, v := range versions {
+		log.Infof(r.Context(), "webhook: discovered %s@%s", v.Path, v.Version)
+		fmt.Fprintf(w, "Discovered %s@%s.\n", v.Path, v.Version)
+	}
+	return nil
+}
+
+// tagPushEvent holds the fields of GitHub and GitLab push events that are
+// needed to determine the pushed module version.
+type tagPushEvent struct {
+	Ref        string `json:"ref"`
+	After      string `json:"after"`
+	Deleted    bool   `json:"deleted"`
+	Repository struct {
+		HTMLURL string `json:"html_url"`
+	} `json:"repository"`
+	Project struct {
+		WebURL string `json:"web_url"`
+	} `json:"project"`
+}
+
+// parseTagPushEvent authenticates a webhook request with the given headers
+// and body, and returns the module versions for the tag that it announces,
+// with timestamp ts. Events that are not tag pushes, and pushes that delete
+// a tag, result in no versions.
+func parseTagPushEvent(header http.Header, body []byte, secret string, ts time.Time) ([]*internal.IndexVersion, error) {
+	var repoURL func(*tagPushEvent) string
+	switch {
+	case header.Get("X-GitHub-Event") != "" || header.Get("X-Gitea-Event") != "":
+		if !validGitHubSignature(header.Get("X-Hub-Signature-256"), body, secret) {
+			return nil, errWebhookUnauthenticated
+		}
+		if header.Get("X-GitHub-Event") != "push" && header.Get("X-Gitea-Event") != "push" {
+			return nil, nil
+		}
+		repoURL = func(e *tagPushEvent) string { return e.Repository.HTMLURL }
+	case header.Get("X-Gitlab-Event") != "":
+		if subtle.ConstantTimeCompare([]byte(header.Get("X-Gitlab-Token")), []byte(secret)) != 1 {
+			return nil, errWebhookUnauthenticated
+		}
+		if header.Get("X-Gitlab-Event") != "Tag Push Hook" {
+			return nil, nil
+		}
+		repoURL = func(e *tagPushEvent) string { return e.Project.WebURL }
+	default:
+		return nil, errors.New("unknown webhook sender")
+	}
+	var e tagPushEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("decoding event: %v", err)
+	}
+	// GitLab reports a deleted tag with an all-zero "after" commit.
+	deleted := e.Deleted || (e.After != "" && strings.Trim(e.After, "0") == "")
+	if !strings.HasPrefix(e.Ref, "refs/tags/") || deleted {
+		return nil, nil
+	}
+	return tagModuleVersions(repoURL(&e), strings.TrimPrefix(e.Ref, "refs/tags/"), ts)
+}
+
+// validGitHubSignature reports whether sig, the value of an
+// X-Hub-Signature-256 header, is the HMAC-SHA256 of body with secret.
+func validGitHubSignature(sig string, body []byte, secret string) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil || !strings.HasPrefix(sig, "sha256=") {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// tagModuleVersions returns the module versions that a tag in the repository
+// at repoURL may denote. A tag like "v1.2.3" is a version of the module at the
+// root of the repository, and "sub/dir/v1.2.3" is a version of the module in
+// sub/dir. For major versions 2 and above, the tag may belong to a module
+// whose path ends in the major version, or to one without a go.mod file, so
+// both are returned; fetching the wrong one fails harmlessly.
+// Tags that are not semantic versions result in no versions.
+func tagModuleVersions(repoURL, tag string, ts time.Time) ([]*internal.IndexVersion, error) {
+	repo := strings.TrimPrefix(strings.TrimPrefix(repoURL, "https://"), "http://")
+	repo = strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+	if repo == "" {
+		return nil, errors.New("event has no repository URL")
+	}
+	path, vers := repo, tag
+	if i := strings.LastIndexByte(tag, '/'); i >= 0 {
+		path, vers = repo+"/"+tag[:i], tag[i+1:]
+	}
+	if semver.Canonical(vers) != vers {
+		return nil, nil
+	}
+	candidates := []module.Version{{Path: path, Version: vers}}
+	if major := semver.Major(vers); major != "v0" && major != "v1" {
+		candidates = []module.Version{
+			{Path: path + "/" + major, Version: vers},
+			{Path: path, Version: vers + "+incompatible"},
+		}
+	}
+	var versions []*internal.IndexVersion
+	for _, c := range candidates {
+		if err := module.Check(c.Path, c.Version); err != nil {
+			continue
+		}
+		versions = append(versions, &internal.IndexVersion{Path: c.Path, Version: c.Version, Timestamp: ts})
+	}
+	return versions, nil
+}
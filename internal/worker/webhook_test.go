@@ -0,0 +1,79 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+)
+
+func TestParseTagPushEvent(t *testing.T) {
+	const secret = "s3cret"
+	ts := time.Now()
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	const (
+		githubPush   = `{"ref": "refs/tags/v1.2.3", "repository": {"html_url": "https://github.com/owner/repo"}}`
+		githubBranch = `{"ref": "refs/heads/main", "repository": {"html_url": "https://github.com/owner/repo"}}`
+		githubSubdir = `{"ref": "refs/tags/sub/dir/v0.1.0", "repository": {"html_url": "https://github.com/owner/repo"}}`
+		githubV2     = `{"ref": "refs/tags/v2.0.0", "repository": {"html_url": "https://github.com/owner/repo"}}`
+		githubDelete = `{"ref": "refs/tags/v1.2.3", "deleted": true, "repository": {"html_url": "https://github.com/owner/repo"}}`
+		gitlabPush   = `{"ref": "refs/tags/v1.0.0", "after": "a1b2c3", "project": {"web_url": "https://gitlab.example.com/group/proj"}}`
+		gitlabDelete = `{"ref": "refs/tags/v1.0.0", "after": "0000000000000000000000000000000000000000", "project": {"web_url": "https://gitlab.example.com/group/proj"}}`
+		notSemver    = `{"ref": "refs/tags/release-1", "repository": {"html_url": "https://github.com/owner/repo"}}`
+	)
+	github := func(event, body string) http.Header {
+		return http.Header{"X-Github-Event": {event}, "X-Hub-Signature-256": {sign(body)}}
+	}
+	gitlab := func(event, token string) http.Header {
+		return http.Header{"X-Gitlab-Event": {event}, "X-Gitlab-Token": {token}}
+	}
+	iv := func(path, version string) *internal.IndexVersion {
+		return &internal.IndexVersion{Path: path, Version: version, Timestamp: ts}
+	}
+	for _, test := range []struct {
+		name    string
+		header  http.Header
+		body    string
+		want    []*internal.IndexVersion
+		wantErr error
+	}{
+		{"github", github("push", githubPush), githubPush, []*internal.IndexVersion{iv("github.com/owner/repo", "v1.2.3")}, nil},
+		{"github branch", github("push", githubBranch), githubBranch, nil, nil},
+		{"github subdirectory", github("push", githubSubdir), githubSubdir, []*internal.IndexVersion{iv("github.com/owner/repo/sub/dir", "v0.1.0")}, nil},
+		{"github major version", github("push", githubV2), githubV2, []*internal.IndexVersion{
+			iv("github.com/owner/repo/v2", "v2.0.0"),
+			iv("github.com/owner/repo", "v2.0.0+incompatible"),
+		}, nil},
+		{"github delete", github("push", githubDelete), githubDelete, nil, nil},
+		{"github other event", github("ping", githubPush), githubPush, nil, nil},
+		{"github bad signature", github("push", githubBranch), githubPush, nil, errWebhookUnauthenticated},
+		{"not semver", github("push", notSemver), notSemver, nil, nil},
+		{"gitlab", gitlab("Tag Push Hook", secret), gitlabPush, []*internal.IndexVersion{iv("gitlab.example.com/group/proj", "v1.0.0")}, nil},
+		{"gitlab delete", gitlab("Tag Push Hook", secret), gitlabDelete, nil, nil},
+		{"gitlab bad token", gitlab("Tag Push Hook", "wrong"), gitlabPush, nil, errWebhookUnauthenticated},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseTagPushEvent(test.header, []byte(test.body), secret, ts)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
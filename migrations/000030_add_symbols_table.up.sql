@@ -0,0 +1,22 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE symbols (
+    path_id INTEGER NOT NULL REFERENCES paths(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    kind text NOT NULL,
+    reference_paths text[],
+    reference_names text[],
+    PRIMARY KEY (path_id, name)
+);
+COMMENT ON TABLE symbols IS
+'TABLE symbols contains the exported identifiers declared by a package in the paths table. Methods and fields are qualified by their type name, as in "Reader.Read".';
+COMMENT ON COLUMN symbols.reference_paths IS
+'COLUMN reference_paths contains the package paths of the declarations in the same module that refer to the symbol. It is parallel to reference_names.';
+COMMENT ON COLUMN symbols.reference_names IS
+'COLUMN reference_names contains the names of the declarations in the same module that refer to the symbol. It is parallel to reference_paths.';
+
+END;
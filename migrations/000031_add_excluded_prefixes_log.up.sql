@@ -0,0 +1,25 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE excluded_prefixes_log (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    action text NOT NULL,
+    prefix text NOT NULL,
+    created_by text NOT NULL,
+    reason text NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT excluded_prefixes_log_action_check CHECK ((action IN ('add', 'remove'))),
+    CONSTRAINT excluded_prefixes_log_created_by_check CHECK ((created_by <> ''::text)),
+    CONSTRAINT excluded_prefixes_log_reason_check CHECK ((reason <> ''::text))
+);
+COMMENT ON TABLE excluded_prefixes_log IS
+'TABLE excluded_prefixes_log is an audit log of every change made to the excluded_prefixes table.';
+COMMENT ON COLUMN excluded_prefixes_log.action IS
+'COLUMN action is either "add" or "remove".';
+COMMENT ON COLUMN excluded_prefixes_log.reason IS
+'COLUMN reason is the reason given for the change. For a removal, it is not necessarily the reason the prefix was originally excluded.';
+
+END;
@@ -0,0 +1,20 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE module_version_state_timings (
+    module_path text NOT NULL,
+    version text NOT NULL,
+    stage text NOT NULL,
+    duration_ms integer NOT NULL,
+    PRIMARY KEY (module_path, version, stage),
+    FOREIGN KEY (module_path, version) REFERENCES module_version_states(module_path, version) ON DELETE CASCADE
+);
+COMMENT ON TABLE module_version_state_timings IS
+'TABLE module_version_state_timings contains the time spent in each stage of the most recent fetch of a module version.';
+COMMENT ON COLUMN module_version_state_timings.stage IS
+'COLUMN stage is the name of a fetch stage, such as "zip-download" or "doc-render". See the Stage constants in internal/fetch.';
+
+END;
@@ -0,0 +1,14 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE module_version_states ADD COLUMN dead_lettered_at timestamp with time zone;
+COMMENT ON COLUMN module_version_states.dead_lettered_at IS
+'COLUMN dead_lettered_at is the time at which the module version exhausted the retries allowed by the retry policy for its status. Module versions with a non-NULL dead_lettered_at are not requeued until they are retried manually.';
+
+CREATE INDEX idx_module_version_states_dead_lettered_at ON module_version_states(dead_lettered_at)
+    WHERE dead_lettered_at IS NOT NULL;
+
+END;
@@ -0,0 +1,11 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules ADD COLUMN unverified boolean NOT NULL DEFAULT false;
+COMMENT ON COLUMN modules.unverified IS
+'COLUMN unverified reports whether the module zip could not be verified against the checksum database, for example because the database has no record of the module version.';
+
+END;
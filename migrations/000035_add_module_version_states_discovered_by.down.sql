@@ -0,0 +1,10 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+DROP INDEX idx_module_version_states_discovered_by_index_timestamp;
+ALTER TABLE module_version_states DROP COLUMN discovered_by;
+
+END;
@@ -0,0 +1,14 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE module_version_states ADD COLUMN discovered_by text NOT NULL DEFAULT 'index';
+COMMENT ON COLUMN module_version_states.discovered_by IS
+'COLUMN discovered_by is the name of the discoverer that first reported the module version, such as "index" for the module index or "webhook" for forge tag-push events. Only module versions discovered by the index are used to determine where the next poll of the index starts.';
+
+CREATE INDEX idx_module_version_states_discovered_by_index_timestamp
+    ON module_version_states(discovered_by, index_timestamp DESC);
+
+END;
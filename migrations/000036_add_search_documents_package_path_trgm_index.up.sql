@@ -0,0 +1,14 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_search_documents_lower_package_path_trgm
+    ON search_documents USING gin (lower(package_path) gin_trgm_ops);
+COMMENT ON INDEX idx_search_documents_lower_package_path_trgm IS
+'INDEX idx_search_documents_lower_package_path_trgm is used to improve performance of LIKE statements for lower(package_path). It is used to autocomplete package paths, which match at the start of any path element.';
+
+END;
@@ -0,0 +1,17 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+-- Unverified modules (status 291) are processed and are no longer retried,
+-- so they should never be dead-lettered. Release the ones that were, and
+-- let them be reprocessed with status 522 (ReprocessModuleUnverified) like
+-- other processed modules.
+UPDATE module_version_states
+SET
+    dead_lettered_at = NULL,
+    try_count = 0
+WHERE status = 291;
+
+END;